@@ -0,0 +1,162 @@
+package axcli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/jmalloc/ax/messagestore"
+	"github.com/jmalloc/ax/persistence"
+	"github.com/spf13/cobra"
+)
+
+// NewExportCommand returns a CLI command that exports the contents of a
+// message store using messagestore.Export().
+func NewExportCommand(
+	ds persistence.DataStore,
+	ms messagestore.GloballyOrderedStore,
+) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export messages from the message store",
+		Args:  cobra.NoArgs,
+		RunE: func(c *cobra.Command, args []string) error {
+			timeout, err := c.Flags().GetDuration("timeout")
+			if err != nil {
+				return err
+			}
+
+			output, err := c.Flags().GetString("output")
+			if err != nil {
+				return err
+			}
+
+			streams, err := c.Flags().GetStringSlice("stream")
+			if err != nil {
+				return err
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+
+			c.SilenceUsage = true
+
+			// the summary is written to stderr when the messages themselves
+			// are written to stdout.
+			w, status := c.OutOrStdout(), c.OutOrStdout()
+			var f *os.File
+
+			if output == "-" {
+				status = c.OutOrStderr()
+			} else {
+				f, err = os.Create(output)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				w = f
+			}
+
+			n, err := messagestore.Export(ctx, w, ds, ms, streams)
+			if err != nil {
+				return err
+			}
+
+			if f != nil {
+				if err := f.Close(); err != nil {
+					return err
+				}
+			}
+
+			fmt.Fprintf(status, "exported %d message(s)\n", n)
+
+			return nil
+		},
+	}
+
+	flags := cmd.Flags()
+
+	flags.StringP(
+		"output", "o",
+		"-",
+		"the file to write the messages to, or '-' for stdout",
+	)
+
+	flags.StringSlice(
+		"stream",
+		nil,
+		"export only the messages in the given stream, may be repeated",
+	)
+
+	flags.DurationP(
+		"timeout", "t",
+		5*time.Minute,
+		"sets the timeout for the export",
+	)
+
+	return cmd
+}
+
+// NewImportCommand returns a CLI command that imports messages into a message
+// store using messagestore.Import().
+func NewImportCommand(
+	ds persistence.DataStore,
+	ms messagestore.Store,
+) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "import",
+		Short: "Import previously exported messages into the message store",
+		Args:  cobra.NoArgs,
+		RunE: func(c *cobra.Command, args []string) error {
+			timeout, err := c.Flags().GetDuration("timeout")
+			if err != nil {
+				return err
+			}
+
+			input, err := c.Flags().GetString("input")
+			if err != nil {
+				return err
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+
+			c.SilenceUsage = true
+
+			var r io.Reader = os.Stdin
+
+			if input != "-" {
+				f, err := os.Open(input)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				r = f
+			}
+
+			n, err := messagestore.Import(ctx, r, ds, ms)
+
+			fmt.Fprintf(c.OutOrStdout(), "imported %d message(s)\n", n)
+
+			return err
+		},
+	}
+
+	flags := cmd.Flags()
+
+	flags.StringP(
+		"input", "i",
+		"-",
+		"the file to read the messages from, or '-' for stdin",
+	)
+
+	flags.DurationP(
+		"timeout", "t",
+		5*time.Minute,
+		"sets the timeout for the import",
+	)
+
+	return cmd
+}
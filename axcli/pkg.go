@@ -1,2 +1,4 @@
-// Package axcli generates Cobra CLI commands that send Ax messages.
+// Package axcli generates Cobra CLI commands that send Ax messages, and
+// provides commands for exporting and importing the contents of a message
+// store, rebuilding projections and managing their parked messages.
 package axcli
@@ -0,0 +1,197 @@
+package axcli
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jmalloc/ax/projection"
+	"github.com/spf13/cobra"
+)
+
+// NewRebuildCommand returns a CLI command that rebuilds a projection using
+// projection.GlobalStoreConsumer.Rebuild().
+//
+// The projection is identified by the persistence key of its projector, which
+// must be the persistence key of the projector of one of the given consumers.
+func NewRebuildCommand(consumers ...*projection.GlobalStoreConsumer) *cobra.Command {
+	keys := make([]string, len(consumers))
+	for i, c := range consumers {
+		keys[i] = c.Projector.PersistenceKey()
+	}
+
+	cmd := &cobra.Command{
+		Use:       "rebuild <projection>",
+		Short:     "Rebuild a projection from the beginning of the message store",
+		Args:      cobra.ExactArgs(1),
+		ValidArgs: keys,
+		RunE: func(c *cobra.Command, args []string) error {
+			timeout, err := c.Flags().GetDuration("timeout")
+			if err != nil {
+				return err
+			}
+
+			resetOnly, err := c.Flags().GetBool("reset-only")
+			if err != nil {
+				return err
+			}
+
+			i := indexOf(keys, args[0])
+			if i == -1 {
+				return fmt.Errorf("unknown projection '%s'", args[0])
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+
+			c.SilenceUsage = true
+
+			con := consumers[i]
+
+			if resetOnly {
+				if err := con.Reset(ctx); err != nil {
+					return err
+				}
+
+				fmt.Fprintf(c.OutOrStdout(), "reset projection %s\n", args[0])
+
+				return nil
+			}
+
+			if err := con.Rebuild(ctx); err != nil {
+				return err
+			}
+
+			fmt.Fprintf(c.OutOrStdout(), "rebuilt projection %s\n", args[0])
+
+			return nil
+		},
+	}
+
+	flags := cmd.Flags()
+
+	flags.Bool(
+		"reset-only",
+		false,
+		"reset the projection without replaying any messages, leaving them to be replayed by running consumers",
+	)
+
+	flags.DurationP(
+		"timeout", "t",
+		time.Hour,
+		"sets the timeout for the rebuild",
+	)
+
+	return cmd
+}
+
+// NewParkedCommand returns a CLI command for managing the messages that have
+// been parked by projection consumers, as per projection.ErrorPolicy.
+//
+// It has a "list" sub-command that lists the parked messages of a projection,
+// and a "retry" sub-command that applies a parked message to its projector
+// using projection.GlobalStoreConsumer.RetryParkedMessage().
+//
+// The projection is identified by the persistence key of its projector, which
+// must be the persistence key of the projector of one of the given consumers.
+func NewParkedCommand(consumers ...*projection.GlobalStoreConsumer) *cobra.Command {
+	keys := make([]string, len(consumers))
+	for i, c := range consumers {
+		keys[i] = c.Projector.PersistenceKey()
+	}
+
+	find := func(pk string) (*projection.GlobalStoreConsumer, error) {
+		i := indexOf(keys, pk)
+		if i == -1 {
+			return nil, fmt.Errorf("unknown projection '%s'", pk)
+		}
+
+		return consumers[i], nil
+	}
+
+	cmd := &cobra.Command{
+		Use:   "parked",
+		Short: "Manage messages that could not be applied to a projection",
+	}
+
+	cmd.AddCommand(
+		&cobra.Command{
+			Use:       "list <projection>",
+			Short:     "List the parked messages of a projection",
+			Args:      cobra.ExactArgs(1),
+			ValidArgs: keys,
+			RunE: func(c *cobra.Command, args []string) error {
+				con, err := find(args[0])
+				if err != nil {
+					return err
+				}
+
+				c.SilenceUsage = true
+
+				messages, err := con.LoadParkedMessages(context.Background())
+				if err != nil {
+					return err
+				}
+
+				w := tabwriter.NewWriter(c.OutOrStdout(), 0, 4, 2, ' ', 0)
+				fmt.Fprintln(w, "OFFSET\tMESSAGE ID\tMESSAGE TYPE\tPARKED AT\tERROR")
+
+				for _, m := range messages {
+					fmt.Fprintf(
+						w,
+						"%d\t%s\t%s\t%s\t%s\n",
+						m.Offset,
+						m.MessageID,
+						m.MessageType,
+						m.ParkedAt.Format(time.RFC3339),
+						m.Error,
+					)
+				}
+
+				return w.Flush()
+			},
+		},
+		&cobra.Command{
+			Use:       "retry <projection> <offset>",
+			Short:     "Apply a parked message to its projection",
+			Args:      cobra.ExactArgs(2),
+			ValidArgs: keys,
+			RunE: func(c *cobra.Command, args []string) error {
+				con, err := find(args[0])
+				if err != nil {
+					return err
+				}
+
+				o, err := strconv.ParseUint(args[1], 10, 64)
+				if err != nil {
+					return fmt.Errorf("invalid offset '%s'", args[1])
+				}
+
+				c.SilenceUsage = true
+
+				if err := con.RetryParkedMessage(context.Background(), o); err != nil {
+					return err
+				}
+
+				fmt.Fprintf(c.OutOrStdout(), "applied parked message at offset %d to projection %s\n", o, args[0])
+
+				return nil
+			},
+		},
+	)
+
+	return cmd
+}
+
+// indexOf returns the index of s in v, or -1 if v does not contain s.
+func indexOf(v []string, s string) int {
+	for i, x := range v {
+		if x == s {
+			return i
+		}
+	}
+
+	return -1
+}
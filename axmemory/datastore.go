@@ -0,0 +1,83 @@
+package axmemory
+
+import (
+	"context"
+	"sync"
+
+	"github.com/jmalloc/ax/messagestore"
+	"github.com/jmalloc/ax/persistence"
+)
+
+// DataStore is an in-memory implementation of Ax's persistence.DataStore
+// interface.
+//
+// Changes made within a transaction are not visible outside of that
+// transaction until it is committed. A transaction that reads a row in
+// order to modify it fails to commit if that row was modified by some other
+// transaction in the meantime.
+type DataStore struct {
+	m sync.Mutex
+
+	// tables contains the committed rows in each table, keyed by table name
+	// then row key.
+	tables map[string]map[string]interface{}
+
+	// versions contains the current version of each row. It is incremented
+	// each time the row is written, including when it is deleted.
+	versions map[rowID]uint64
+
+	// claims contains the rows that are claimed by a transaction. Claimed
+	// rows are skipped by claim() in other transactions.
+	claims map[rowID]*Tx
+
+	// messages contains the message store's messages in global order.
+	messages []messagestore.StoredMessage
+
+	// appended is closed and replaced whenever messages are appended to the
+	// message store.
+	appended chan struct{}
+}
+
+// NewDataStore returns a new, empty in-memory data store.
+func NewDataStore() *DataStore {
+	return &DataStore{}
+}
+
+// BeginTx starts a new transaction.
+func (ds *DataStore) BeginTx(ctx context.Context) (persistence.Tx, persistence.Committer, error) {
+	tx := &Tx{ds: ds}
+	return tx, tx, nil
+}
+
+// rowID uniquely identifies a row within the data store.
+type rowID struct {
+	Table string
+	Key   string
+}
+
+// get returns the committed value of the row with the given key.
+func (ds *DataStore) get(table, key string) (interface{}, bool) {
+	ds.m.Lock()
+	defer ds.m.Unlock()
+
+	v, ok := ds.tables[table][key]
+	return v, ok
+}
+
+// scan calls fn for each committed row in the given table.
+//
+// fn must not call any other methods on ds.
+func (ds *DataStore) scan(table string, fn func(key string, v interface{})) {
+	ds.m.Lock()
+	defer ds.m.Unlock()
+
+	for k, v := range ds.tables[table] {
+		fn(k, v)
+	}
+}
+
+// extractDataStore returns ds as a *DataStore.
+// It panics if ds is not a *DataStore.
+func extractDataStore(ds persistence.DataStore) *DataStore {
+	return ds.(*DataStore)
+}
@@ -0,0 +1,227 @@
+package axmemory_test
+
+import (
+	"context"
+
+	. "github.com/jmalloc/ax/axmemory"
+	"github.com/jmalloc/ax/persistence"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("DataStore", func() {
+	var (
+		ctx context.Context
+		ds  *DataStore
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		ds = NewDataStore()
+	})
+
+	Describe("BeginTx", func() {
+		It("does not make changes visible outside the transaction until it is committed", func() {
+			tx, com, err := ds.BeginTx(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+			defer com.Rollback()
+
+			err = ProjectionOffsetStore.SaveOffset(ctx, tx, "<pk>", 0, 0, 1)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			o, err := ProjectionOffsetStore.LoadOffset(ctx, ds, "<pk>", 0)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(o).To(BeNumerically("==", 0))
+
+			err = com.Commit()
+			Expect(err).ShouldNot(HaveOccurred())
+
+			o, err = ProjectionOffsetStore.LoadOffset(ctx, ds, "<pk>", 0)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(o).To(BeNumerically("==", 1))
+		})
+
+		It("makes changes visible within the transaction before it is committed", func() {
+			tx, com, err := ds.BeginTx(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+			defer com.Rollback()
+
+			err = ProjectionOffsetStore.SaveOffset(ctx, tx, "<pk>", 0, 0, 1)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = ProjectionOffsetStore.SaveOffset(ctx, tx, "<pk>", 0, 1, 2)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		It("discards changes when the transaction is rolled back", func() {
+			tx, com, err := ds.BeginTx(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = ProjectionOffsetStore.SaveOffset(ctx, tx, "<pk>", 0, 0, 1)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = com.Rollback()
+			Expect(err).ShouldNot(HaveOccurred())
+
+			o, err := ProjectionOffsetStore.LoadOffset(ctx, ds, "<pk>", 0)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(o).To(BeNumerically("==", 0))
+		})
+
+		It("fails to commit if a row that the transaction depends on is modified by another transaction", func() {
+			tx1, com1, err := ds.BeginTx(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+			defer com1.Rollback()
+
+			tx2, com2, err := ds.BeginTx(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+			defer com2.Rollback()
+
+			err = ProjectionOffsetStore.SaveOffset(ctx, tx1, "<pk>", 0, 0, 1)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = ProjectionOffsetStore.SaveOffset(ctx, tx2, "<pk>", 0, 0, 1)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = com1.Commit()
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = com2.Commit()
+			Expect(err).Should(HaveOccurred())
+
+			o, err := ProjectionOffsetStore.LoadOffset(ctx, ds, "<pk>", 0)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(o).To(BeNumerically("==", 1))
+		})
+	})
+})
+
+var _ = Describe("Tx", func() {
+	var (
+		ctx context.Context
+		ds  *DataStore
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		ds = NewDataStore()
+	})
+
+	Describe("DataStore", func() {
+		It("returns the data store that the transaction operates on", func() {
+			tx, com, err := ds.BeginTx(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+			defer com.Rollback()
+
+			Expect(tx.DataStore()).To(BeIdenticalTo(ds))
+		})
+	})
+
+	Describe("Commit", func() {
+		It("calls the after-commit hooks", func() {
+			tx, com, err := ds.BeginTx(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			var committed, rolledBack bool
+			tx.AfterCommit(func() { committed = true })
+			tx.AfterRollback(func() { rolledBack = true })
+
+			err = com.Commit()
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = com.Rollback()
+			Expect(err).Should(HaveOccurred())
+
+			Expect(committed).To(BeTrue())
+			Expect(rolledBack).To(BeFalse())
+		})
+
+		It("calls the after-rollback hooks if the commit fails", func() {
+			tx1, com1, err := ds.BeginTx(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+			defer com1.Rollback()
+
+			tx2, com2, err := ds.BeginTx(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+			defer com2.Rollback()
+
+			var committed, rolledBack bool
+			tx2.AfterCommit(func() { committed = true })
+			tx2.AfterRollback(func() { rolledBack = true })
+
+			err = ProjectionOffsetStore.SaveOffset(ctx, tx1, "<pk>", 0, 0, 1)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = ProjectionOffsetStore.SaveOffset(ctx, tx2, "<pk>", 0, 0, 1)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = com1.Commit()
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = com2.Commit()
+			Expect(err).Should(HaveOccurred())
+
+			Expect(committed).To(BeFalse())
+			Expect(rolledBack).To(BeTrue())
+		})
+
+		It("returns an error if the transaction has already ended", func() {
+			_, com, err := ds.BeginTx(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = com.Commit()
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = com.Commit()
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+
+	Describe("Rollback", func() {
+		It("calls the after-rollback hooks", func() {
+			tx, com, err := ds.BeginTx(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			var committed, rolledBack bool
+			tx.AfterCommit(func() { committed = true })
+			tx.AfterRollback(func() { rolledBack = true })
+
+			err = com.Rollback()
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(committed).To(BeFalse())
+			Expect(rolledBack).To(BeTrue())
+		})
+
+		It("calls hooks registered by participants when the owner commits", func() {
+			tx, com, err := ds.BeginTx(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			pctx := persistence.WithTx(ctx, tx)
+			ptx, pcom, err := persistence.GetOrBeginTx(pctx)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			var called bool
+			ptx.AfterRollback(func() { called = true })
+
+			err = pcom.Rollback()
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(called).To(BeFalse())
+
+			err = com.Rollback()
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(called).To(BeTrue())
+		})
+
+		It("returns an error if the transaction has already ended", func() {
+			_, com, err := ds.BeginTx(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = com.Commit()
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = com.Rollback()
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,75 @@
+package axmemory
+
+import (
+	"context"
+
+	"github.com/jmalloc/ax/delayedmessage"
+	"github.com/jmalloc/ax/endpoint"
+	"github.com/jmalloc/ax/persistence"
+)
+
+// DelayedMessageRepository is a delayed message repository backed by an
+// in-memory data store.
+var DelayedMessageRepository delayedmessage.Repository = delayedMessageRepository{}
+
+// delayedMessageTable is the name of the table that stores delayed messages,
+// keyed by message ID.
+const delayedMessageTable = "delayed_message"
+
+// delayedMessageRepository is an in-memory implementation of Ax's
+// delayedmessage.Repository interface.
+type delayedMessageRepository struct{}
+
+// LoadNextMessage loads the next that is scheduled to be sent.
+func (delayedMessageRepository) LoadNextMessage(
+	ctx context.Context,
+	pds persistence.DataStore,
+) (endpoint.OutboundEnvelope, bool, error) {
+	ds := extractDataStore(pds)
+
+	var (
+		next endpoint.OutboundEnvelope
+		ok   bool
+	)
+
+	ds.scan(delayedMessageTable, func(_ string, v interface{}) {
+		env := v.(endpoint.OutboundEnvelope)
+		if !ok || env.SendAt.Before(next.SendAt) {
+			next = env
+			ok = true
+		}
+	})
+
+	if !ok {
+		return endpoint.OutboundEnvelope{}, false, nil
+	}
+
+	return cloneOutboundEnvelope(next), true, nil
+}
+
+// SaveMessage saves a message to be sent at a later time.
+// If does NOT return an error if the message already exists in the repository.
+func (delayedMessageRepository) SaveMessage(
+	ctx context.Context,
+	ptx persistence.Tx,
+	env endpoint.OutboundEnvelope,
+) error {
+	tx := extractTx(ptx)
+
+	if _, ok := tx.get(delayedMessageTable, env.MessageID.Get()); !ok {
+		tx.put(delayedMessageTable, env.MessageID.Get(), cloneOutboundEnvelope(env))
+	}
+
+	return nil
+}
+
+// MarkAsSent marks a message as sent, removing it from the repository.
+func (delayedMessageRepository) MarkAsSent(
+	ctx context.Context,
+	ptx persistence.Tx,
+	env endpoint.OutboundEnvelope,
+) error {
+	tx := extractTx(ptx)
+	tx.delete(delayedMessageTable, env.MessageID.Get())
+	return nil
+}
@@ -0,0 +1,21 @@
+package axmemory_test
+
+import (
+	. "github.com/jmalloc/ax/axmemory"
+	"github.com/jmalloc/ax/axtest/delayedmessagetests"
+	"github.com/jmalloc/ax/delayedmessage"
+	"github.com/jmalloc/ax/persistence"
+	. "github.com/onsi/ginkgo"
+)
+
+var _ = Describe(
+	"DelayedMessageRepository",
+	delayedmessagetests.RepositorySuite(
+		func() persistence.DataStore {
+			return NewDataStore()
+		},
+		func() delayedmessage.Repository {
+			return DelayedMessageRepository
+		},
+	),
+)
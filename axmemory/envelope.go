@@ -0,0 +1,24 @@
+package axmemory
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/jmalloc/ax"
+	"github.com/jmalloc/ax/endpoint"
+)
+
+// cloneEnvelope returns a deep copy of env, such that modifications made to
+// the message after it is saved do not affect the stored value.
+func cloneEnvelope(env ax.Envelope) ax.Envelope {
+	env.Message = proto.Clone(env.Message).(ax.Message)
+	return env
+}
+
+// cloneOutboundEnvelope returns a deep copy of env.
+//
+// The span context is discarded, as it is not persisted by the other
+// repository implementations.
+func cloneOutboundEnvelope(env endpoint.OutboundEnvelope) endpoint.OutboundEnvelope {
+	env.Envelope = cloneEnvelope(env.Envelope)
+	env.SpanContext = nil
+	return env
+}
@@ -0,0 +1,426 @@
+package axmemory
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmalloc/ax"
+	"github.com/jmalloc/ax/messagestore"
+	"github.com/jmalloc/ax/persistence"
+)
+
+// MessageStore is a message store backed by an in-memory data store.
+//
+// It also implements messagestore.QueryableStore.
+var MessageStore messagestore.GloballyOrderedStore = messageStore{}
+
+const (
+	// messageStoreStreamTable is the name of the table that stores the next
+	// unused offset of each stream, keyed by stream name.
+	messageStoreStreamTable = "messagestore_stream"
+
+	// messageStoreMessageTable is the name of the table that stores the
+	// position of each message, keyed by message ID.
+	messageStoreMessageTable = "messagestore_message"
+)
+
+// messagePosition is the position of a message within the message store.
+type messagePosition struct {
+	Stream string
+	Offset uint64
+}
+
+// messageStore is an in-memory implementation of Ax's
+// messagestore.GloballyOrderedStore interface.
+type messageStore struct{}
+
+// AppendMessages appends one or more messages to a named stream.
+//
+// offset is a zero-based index into the stream. If offset is not the next
+// unused offset in the stream, it returns a *messagestore.ConflictError,
+// unless the messages already stored at offset have the same message IDs as
+// envs, in which case those messages are not appended again.
+//
+// It returns a *messagestore.DuplicateMessageError if a message with the same
+// ID as one of envs is already stored at some other position.
+func (messageStore) AppendMessages(
+	ctx context.Context,
+	ptx persistence.Tx,
+	stream string,
+	offset uint64,
+	envs []ax.Envelope,
+) error {
+	tx := extractTx(ptx)
+
+	v, _ := tx.lock(messageStoreStreamTable, stream)
+	next, _ := v.(uint64)
+
+	if offset > next {
+		return &messagestore.ConflictError{Stream: stream, Offset: offset}
+	}
+
+	// skip any messages that are already stored at the expected offsets, as
+	// they have been appended by a prior attempt.
+	for len(envs) > 0 && offset < next {
+		p, ok := tx.lock(messageStoreMessageTable, envs[0].MessageID.Get())
+		if !ok || p != (messagePosition{stream, offset}) {
+			return &messagestore.ConflictError{Stream: stream, Offset: offset}
+		}
+
+		envs = envs[1:]
+		offset++
+	}
+
+	if len(envs) == 0 {
+		return nil
+	}
+
+	for i, env := range envs {
+		id := env.MessageID.Get()
+
+		if p, ok := tx.lock(messageStoreMessageTable, id); ok {
+			pos := p.(messagePosition)
+			return &messagestore.DuplicateMessageError{
+				MessageID:    env.MessageID,
+				Stream:       pos.Stream,
+				StreamOffset: pos.Offset,
+			}
+		}
+
+		tx.put(
+			messageStoreMessageTable,
+			id,
+			messagePosition{stream, offset + uint64(i)},
+		)
+	}
+
+	n := uint64(len(envs))
+	tx.put(messageStoreStreamTable, stream, offset+n)
+
+	c := messagestore.StreamCategory(ctx, stream)
+
+	messages := make([]messagestore.StoredMessage, n)
+	for i, env := range envs {
+		messages[i] = messagestore.StoredMessage{
+			Envelope:     cloneEnvelope(env),
+			Stream:       stream,
+			StreamOffset: offset + uint64(i),
+			Category:     c,
+		}
+	}
+
+	// global offsets are allocated when the transaction is committed, so that
+	// they are contiguous and in commit order. the global offset of each
+	// message is its index within DataStore.messages.
+	tx.afterCommit(func() {
+		now := time.Now()
+
+		for i := range messages {
+			messages[i].GlobalOffset = uint64(len(tx.ds.messages) + i)
+			messages[i].HasGlobalOffset = true
+			messages[i].InsertTime = now
+		}
+
+		tx.ds.messages = append(tx.ds.messages, messages...)
+
+		if tx.ds.appended != nil {
+			close(tx.ds.appended)
+			tx.ds.appended = nil
+		}
+	})
+
+	return nil
+}
+
+// OpenStream opens a stream of messages for reading from a specific offset.
+//
+// The offset may be past the end of the stream. It returns false if the stream
+// does not exist.
+func (messageStore) OpenStream(
+	ctx context.Context,
+	pds persistence.DataStore,
+	stream string,
+	offset uint64,
+) (messagestore.Stream, bool, error) {
+	ds := extractDataStore(pds)
+
+	if _, ok := ds.get(messageStoreStreamTable, stream); !ok {
+		return nil, false, nil
+	}
+
+	return &messageStream{
+		ds:         ds,
+		name:       stream,
+		nextOffset: offset,
+	}, true, nil
+}
+
+// OpenGlobal opens the entire store for reading as a single stream.
+//
+// The offset may be beyond the end of the stream. If types is non-empty, only
+// messages of those types are returned.
+func (messageStore) OpenGlobal(
+	ctx context.Context,
+	pds persistence.DataStore,
+	offset uint64,
+	types ax.MessageTypeSet,
+) (messagestore.Stream, error) {
+	return &messageStream{
+		ds:         extractDataStore(pds),
+		global:     true,
+		types:      types,
+		nextOffset: offset,
+		cursor:     offset,
+	}, nil
+}
+
+// OpenCategory opens all messages in a category for reading as a single stream.
+// The category of each message is determined when it is appended, as per
+// messagestore.StreamCategory().
+//
+// The offset may be beyond the end of the stream. If types is non-empty, only
+// messages of those types are returned.
+func (messageStore) OpenCategory(
+	ctx context.Context,
+	pds persistence.DataStore,
+	category string,
+	offset uint64,
+	types ax.MessageTypeSet,
+) (messagestore.Stream, error) {
+	return &messageStream{
+		ds:         extractDataStore(pds),
+		global:     true,
+		byCategory: true,
+		category:   category,
+		types:      types,
+		nextOffset: offset,
+		cursor:     offset,
+	}, nil
+}
+
+// LoadMessage loads the message with the given message ID.
+//
+// It returns false if the message does not exist.
+func (messageStore) LoadMessage(
+	ctx context.Context,
+	pds persistence.DataStore,
+	id ax.MessageID,
+) (messagestore.StoredMessage, bool, error) {
+	messages := queryMessages(
+		extractDataStore(pds),
+		func(m *messagestore.StoredMessage) bool {
+			return m.Envelope.MessageID == id
+		},
+	)
+
+	if len(messages) == 0 {
+		return messagestore.StoredMessage{}, false, nil
+	}
+
+	return messages[0], true, nil
+}
+
+// LoadMessagesByCorrelationID loads all messages with the given correlation
+// ID, in the order they were inserted.
+func (messageStore) LoadMessagesByCorrelationID(
+	ctx context.Context,
+	pds persistence.DataStore,
+	id ax.MessageID,
+) ([]messagestore.StoredMessage, error) {
+	return queryMessages(
+		extractDataStore(pds),
+		func(m *messagestore.StoredMessage) bool {
+			return m.Envelope.CorrelationID == id
+		},
+	), nil
+}
+
+// LoadMessagesByCausationID loads all messages that were caused by the message
+// with the given ID, in the order they were inserted.
+func (messageStore) LoadMessagesByCausationID(
+	ctx context.Context,
+	pds persistence.DataStore,
+	id ax.MessageID,
+) ([]messagestore.StoredMessage, error) {
+	return queryMessages(
+		extractDataStore(pds),
+		func(m *messagestore.StoredMessage) bool {
+			return m.Envelope.CausationID == id
+		},
+	), nil
+}
+
+// LoadMessagesByInsertTime loads the messages that were inserted at or after
+// begin, and before end, in the order they were inserted.
+//
+// At most limit messages are returned. If limit is zero, all messages within
+// the time range are returned.
+func (messageStore) LoadMessagesByInsertTime(
+	ctx context.Context,
+	pds persistence.DataStore,
+	begin, end time.Time,
+	limit uint64,
+) ([]messagestore.StoredMessage, error) {
+	messages := queryMessages(
+		extractDataStore(pds),
+		func(m *messagestore.StoredMessage) bool {
+			return !m.InsertTime.Before(begin) && m.InsertTime.Before(end)
+		},
+	)
+
+	if limit != 0 && uint64(len(messages)) > limit {
+		messages = messages[:limit]
+	}
+
+	return messages, nil
+}
+
+// queryMessages returns copies of the messages in ds for which fn returns
+// true, in global order.
+func queryMessages(
+	ds *DataStore,
+	fn func(*messagestore.StoredMessage) bool,
+) []messagestore.StoredMessage {
+	ds.m.Lock()
+	defer ds.m.Unlock()
+
+	var messages []messagestore.StoredMessage
+
+	for i := range ds.messages {
+		m := &ds.messages[i]
+
+		if fn(m) {
+			c := *m
+			c.Envelope = cloneEnvelope(c.Envelope)
+			messages = append(messages, c)
+		}
+	}
+
+	return messages
+}
+
+// messageStream is an in-memory implementation of Ax's messagestore.Stream
+// interface.
+type messageStream struct {
+	ds     *DataStore
+	name   string
+	global bool
+	types  ax.MessageTypeSet
+
+	// byCategory is true if the stream only contains messages in the given
+	// category.
+	byCategory bool
+	category   string
+
+	// nextOffset is the offset of the next message to be returned, within the
+	// stream being read.
+	nextOffset uint64
+
+	// cursor is the global offset of the next message to be examined.
+	cursor uint64
+
+	// current is the message at the current offset.
+	current *messagestore.StoredMessage
+}
+
+// Next advances the stream to the next message.
+//
+// It blocks until a message is available, or ctx is canceled.
+func (s *messageStream) Next(ctx context.Context) error {
+	for {
+		ok, appended := s.advance()
+		if ok {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-appended:
+		}
+	}
+}
+
+// TryNext advances the stream to the next message.
+//
+// It returns false if there are no more messages in the stream.
+func (s *messageStream) TryNext(ctx context.Context) (bool, error) {
+	ok, _ := s.advance()
+	return ok, nil
+}
+
+// Get returns the message at the current offset in the stream.
+func (s *messageStream) Get(ctx context.Context) (ax.Envelope, error) {
+	if s.current == nil {
+		panic("Next() must be called before Get()")
+	}
+
+	return cloneEnvelope(s.current.Envelope), nil
+}
+
+// GetStored returns the message at the current offset in the stream, along
+// with its position within the store.
+func (s *messageStream) GetStored(ctx context.Context) (messagestore.StoredMessage, error) {
+	if s.current == nil {
+		panic("Next() must be called before GetStored()")
+	}
+
+	m := *s.current
+	m.Envelope = cloneEnvelope(m.Envelope)
+
+	return m, nil
+}
+
+// Offset returns the offset of the message returned by Get().
+func (s *messageStream) Offset() (uint64, error) {
+	if s.current == nil {
+		panic("Next() must be called before Offset()")
+	}
+
+	return s.nextOffset - 1, nil
+}
+
+// Close closes the stream.
+func (s *messageStream) Close() error {
+	return nil
+}
+
+// advance advances the stream to the next message.
+//
+// If there are no more messages it returns false, along with a channel that
+// is closed when further messages are appended to the store.
+func (s *messageStream) advance() (bool, <-chan struct{}) {
+	s.ds.m.Lock()
+	defer s.ds.m.Unlock()
+
+	for s.cursor < uint64(len(s.ds.messages)) {
+		m := &s.ds.messages[s.cursor]
+		s.cursor++
+
+		if s.global {
+			if s.types.Len() != 0 && !s.types.Has(m.Envelope.Type()) {
+				continue
+			}
+
+			if s.byCategory && m.Category != s.category {
+				continue
+			}
+
+			s.current = m
+			s.nextOffset = s.cursor
+			return true, nil
+		}
+
+		if m.Stream == s.name && m.StreamOffset >= s.nextOffset {
+			s.current = m
+			s.nextOffset = m.StreamOffset + 1
+			return true, nil
+		}
+	}
+
+	if s.ds.appended == nil {
+		s.ds.appended = make(chan struct{})
+	}
+
+	return false, s.ds.appended
+}
@@ -0,0 +1,478 @@
+package axmemory_test
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmalloc/ax"
+	. "github.com/jmalloc/ax/axmemory"
+	"github.com/jmalloc/ax/axtest"
+	"github.com/jmalloc/ax/axtest/testmessages"
+	"github.com/jmalloc/ax/messagestore"
+	"github.com/jmalloc/ax/persistence"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("MessageStore", func() {
+	var (
+		ctx              context.Context
+		cancel           func()
+		ds               *DataStore
+		env1, env2, env3 ax.Envelope
+	)
+
+	BeforeEach(func() {
+		var fn func()
+		ctx, fn = context.WithTimeout(context.Background(), 5*time.Second)
+		cancel = fn // defeat go vet warning about unused cancel func
+
+		ds = NewDataStore()
+
+		env1 = ax.NewEnvelope(&testmessages.MessageA{Value: "<foo>"})
+		env2 = ax.NewEnvelope(&testmessages.MessageB{Value: "<bar>"})
+		env3 = ax.NewEnvelope(&testmessages.MessageC{Value: "<baz>"})
+	})
+
+	AfterEach(func() {
+		cancel()
+	})
+
+	appendMessages := func(stream string, offset uint64, envs ...ax.Envelope) error {
+		tx, com, err := ds.BeginTx(ctx)
+		if err != nil {
+			return err
+		}
+		defer com.Rollback()
+
+		if err := MessageStore.AppendMessages(ctx, tx, stream, offset, envs); err != nil {
+			return err
+		}
+
+		return com.Commit()
+	}
+
+	readAll := func(s messagestore.Stream) ([]ax.Envelope, []uint64) {
+		var (
+			envs    []ax.Envelope
+			offsets []uint64
+		)
+
+		for {
+			ok, err := s.TryNext(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			if !ok {
+				return envs, offsets
+			}
+
+			env, err := s.Get(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			o, err := s.Offset()
+			Expect(err).ShouldNot(HaveOccurred())
+
+			envs = append(envs, env)
+			offsets = append(offsets, o)
+		}
+	}
+
+	Describe("AppendMessages", func() {
+		It("returns a conflict error if the offset is not the next free offset", func() {
+			err := appendMessages("<stream>", 0, env1)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = appendMessages("<stream>", 0, env2)
+			Expect(messagestore.IsConflict(err)).To(BeTrue())
+
+			err = appendMessages("<stream>", 2, env2)
+			Expect(messagestore.IsConflict(err)).To(BeTrue())
+		})
+
+		It("does not append messages that are already stored at the same offsets", func() {
+			err := appendMessages("<stream>", 0, env1, env2)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = appendMessages("<stream>", 0, env1, env2)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = appendMessages("<stream>", 1, env2, env3)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			s, ok, err := MessageStore.OpenStream(ctx, ds, "<stream>", 0)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(ok).To(BeTrue())
+			defer s.Close()
+
+			envs, offsets := readAll(s)
+			Expect(axtest.ConsistsOfEnvelopes(envs, env1, env2, env3)).To(BeTrue())
+			Expect(offsets).To(Equal([]uint64{0, 1, 2}))
+		})
+
+		It("returns a duplicate message error if the message is already stored elsewhere", func() {
+			err := appendMessages("<stream-1>", 0, env1)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = appendMessages("<stream-2>", 0, env2, env1)
+			Expect(err).To(Equal(&messagestore.DuplicateMessageError{
+				MessageID:    env1.MessageID,
+				Stream:       "<stream-1>",
+				StreamOffset: 0,
+			}))
+
+			err = appendMessages("<stream-2>", 0, env2, env2)
+			Expect(messagestore.IsDuplicateMessage(err)).To(BeTrue())
+		})
+
+		It("does not make messages visible until the transaction is committed", func() {
+			tx, com, err := ds.BeginTx(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+			defer com.Rollback()
+
+			err = MessageStore.AppendMessages(ctx, tx, "<stream>", 0, []ax.Envelope{env1})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			s, err := MessageStore.OpenGlobal(ctx, ds, 0, ax.MessageTypeSet{})
+			Expect(err).ShouldNot(HaveOccurred())
+			defer s.Close()
+
+			ok, err := s.TryNext(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(ok).To(BeFalse())
+		})
+	})
+
+	Describe("OpenStream", func() {
+		BeforeEach(func() {
+			err := appendMessages("<stream-1>", 0, env1)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = appendMessages("<stream-2>", 0, env2)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = appendMessages("<stream-1>", 1, env3)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		It("returns false if the stream does not exist", func() {
+			_, ok, err := MessageStore.OpenStream(ctx, ds, "<unknown>", 0)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(ok).To(BeFalse())
+		})
+
+		It("returns only the messages in the stream, with their stream offsets", func() {
+			s, ok, err := MessageStore.OpenStream(ctx, ds, "<stream-1>", 0)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(ok).To(BeTrue())
+			defer s.Close()
+
+			envs, offsets := readAll(s)
+			Expect(axtest.ConsistsOfEnvelopes(envs, env1, env3)).To(BeTrue())
+			Expect(offsets).To(Equal([]uint64{0, 1}))
+		})
+
+		It("starts reading at the given offset", func() {
+			s, _, err := MessageStore.OpenStream(ctx, ds, "<stream-1>", 1)
+			Expect(err).ShouldNot(HaveOccurred())
+			defer s.Close()
+
+			envs, offsets := readAll(s)
+			Expect(axtest.ConsistsOfEnvelopes(envs, env3)).To(BeTrue())
+			Expect(offsets).To(Equal([]uint64{1}))
+		})
+	})
+
+	Describe("GetStored", func() {
+		BeforeEach(func() {
+			err := appendMessages("<stream-1>", 0, env1)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = appendMessages("<stream-2>", 0, env2, env3)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		It("returns the message along with its position within the store", func() {
+			s, err := MessageStore.OpenGlobal(ctx, ds, 2, ax.MessageTypeSet{})
+			Expect(err).ShouldNot(HaveOccurred())
+			defer s.Close()
+
+			err = s.Next(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			m, err := s.GetStored(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(axtest.EnvelopesEqual(m.Envelope, env3)).To(BeTrue())
+			Expect(m.Stream).To(Equal("<stream-2>"))
+			Expect(m.StreamOffset).To(BeNumerically("==", 1))
+			Expect(m.GlobalOffset).To(BeNumerically("==", 2))
+			Expect(m.HasGlobalOffset).To(BeTrue())
+			Expect(m.InsertTime).To(BeTemporally("~", time.Now(), time.Second))
+		})
+	})
+
+	Describe("OpenCategory", func() {
+		BeforeEach(func() {
+			err := appendMessages("account:1", 0, env1)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = appendMessages("transfer:1", 0, env2)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = appendMessages("account:2", 0, env3)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		It("returns only messages from streams in the category, with their global offsets", func() {
+			s, err := MessageStore.OpenCategory(ctx, ds, "account", 0, ax.MessageTypeSet{})
+			Expect(err).ShouldNot(HaveOccurred())
+			defer s.Close()
+
+			envs, offsets := readAll(s)
+			Expect(axtest.ConsistsOfEnvelopes(envs, env1, env3)).To(BeTrue())
+			Expect(offsets).To(Equal([]uint64{0, 2}))
+		})
+
+		It("returns messages that were appended with the category in the context", func() {
+			env := ax.NewEnvelope(&testmessages.MessageA{Value: "<categorized>"})
+
+			err := persistence.Atomically(
+				persistence.WithDataStore(messagestore.WithCategory(ctx, "account"), ds),
+				func(ctx context.Context, tx persistence.Tx) error {
+					return MessageStore.AppendMessages(ctx, tx, "<stream>", 0, []ax.Envelope{env})
+				},
+			)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			s, err := MessageStore.OpenCategory(ctx, ds, "account", 0, ax.MessageTypeSet{})
+			Expect(err).ShouldNot(HaveOccurred())
+			defer s.Close()
+
+			envs, _ := readAll(s)
+			Expect(axtest.ConsistsOfEnvelopes(envs, env1, env3, env)).To(BeTrue())
+		})
+
+		It("returns only messages of the given types", func() {
+			s, err := MessageStore.OpenCategory(
+				ctx,
+				ds,
+				"account",
+				0,
+				ax.TypesOf(&testmessages.MessageC{}),
+			)
+			Expect(err).ShouldNot(HaveOccurred())
+			defer s.Close()
+
+			envs, offsets := readAll(s)
+			Expect(axtest.ConsistsOfEnvelopes(envs, env3)).To(BeTrue())
+			Expect(offsets).To(Equal([]uint64{2}))
+		})
+	})
+
+	Describe("OpenGlobal", func() {
+		BeforeEach(func() {
+			err := appendMessages("<stream-1>", 0, env1)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = appendMessages("<stream-2>", 0, env2)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		It("returns messages from all streams, with their global offsets", func() {
+			s, err := MessageStore.OpenGlobal(ctx, ds, 0, ax.MessageTypeSet{})
+			Expect(err).ShouldNot(HaveOccurred())
+			defer s.Close()
+
+			envs, offsets := readAll(s)
+			Expect(axtest.ConsistsOfEnvelopes(envs, env1, env2)).To(BeTrue())
+			Expect(offsets).To(Equal([]uint64{0, 1}))
+		})
+
+		It("returns only messages of the given types, with their global offsets", func() {
+			err := appendMessages("<stream-1>", 1, env3)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			s, err := MessageStore.OpenGlobal(
+				ctx,
+				ds,
+				0,
+				ax.TypesOf(&testmessages.MessageA{}, &testmessages.MessageC{}),
+			)
+			Expect(err).ShouldNot(HaveOccurred())
+			defer s.Close()
+
+			envs, offsets := readAll(s)
+			Expect(axtest.ConsistsOfEnvelopes(envs, env1, env3)).To(BeTrue())
+			Expect(offsets).To(Equal([]uint64{0, 2}))
+		})
+
+		It("blocks in Next() until a message is appended", func() {
+			s, err := MessageStore.OpenGlobal(ctx, ds, 2, ax.MessageTypeSet{})
+			Expect(err).ShouldNot(HaveOccurred())
+			defer s.Close()
+
+			go func() {
+				defer GinkgoRecover()
+
+				time.Sleep(10 * time.Millisecond)
+				err := appendMessages("<stream-1>", 1, env3)
+				Expect(err).ShouldNot(HaveOccurred())
+			}()
+
+			err = s.Next(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			env, err := s.Get(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(axtest.EnvelopesEqual(env, env3)).To(BeTrue())
+		})
+
+		It("returns an error from Next() if the context is canceled", func() {
+			s, err := MessageStore.OpenGlobal(ctx, ds, 2, ax.MessageTypeSet{})
+			Expect(err).ShouldNot(HaveOccurred())
+			defer s.Close()
+
+			cancel()
+
+			err = s.Next(ctx)
+			Expect(err).To(Equal(context.Canceled))
+		})
+	})
+})
+
+var _ = Describe("MessageStore (queries)", func() {
+	var (
+		ctx                            context.Context
+		cancel                         func()
+		ds                             *DataStore
+		store                          messagestore.QueryableStore
+		root, child, grandchild, other ax.Envelope
+	)
+
+	appendMessages := func(stream string, offset uint64, envs ...ax.Envelope) error {
+		tx, com, err := ds.BeginTx(ctx)
+		if err != nil {
+			return err
+		}
+		defer com.Rollback()
+
+		if err := MessageStore.AppendMessages(ctx, tx, stream, offset, envs); err != nil {
+			return err
+		}
+
+		return com.Commit()
+	}
+
+	envelopes := func(messages []messagestore.StoredMessage) []ax.Envelope {
+		var envs []ax.Envelope
+		for _, m := range messages {
+			envs = append(envs, m.Envelope)
+		}
+		return envs
+	}
+
+	BeforeEach(func() {
+		var fn func()
+		ctx, fn = context.WithTimeout(context.Background(), 5*time.Second)
+		cancel = fn // defeat go vet warning about unused cancel func
+
+		ds = NewDataStore()
+		store = MessageStore.(messagestore.QueryableStore)
+
+		root = ax.NewEnvelope(&testmessages.MessageA{Value: "<root>"})
+		child = root.NewChild(&testmessages.MessageB{Value: "<child>"})
+		grandchild = child.NewChild(&testmessages.MessageC{Value: "<grandchild>"})
+		other = ax.NewEnvelope(&testmessages.MessageA{Value: "<other>"})
+
+		err := appendMessages("<stream-1>", 0, root)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		err = appendMessages("<stream-2>", 0, child, grandchild)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		err = appendMessages("<stream-3>", 0, other)
+		Expect(err).ShouldNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		cancel()
+	})
+
+	Describe("LoadMessage", func() {
+		It("returns the message with the given ID, along with its position within the store", func() {
+			m, ok, err := store.LoadMessage(ctx, ds, grandchild.MessageID)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(ok).To(BeTrue())
+			Expect(axtest.EnvelopesEqual(m.Envelope, grandchild)).To(BeTrue())
+			Expect(m.Stream).To(Equal("<stream-2>"))
+			Expect(m.StreamOffset).To(BeNumerically("==", 1))
+			Expect(m.GlobalOffset).To(BeNumerically("==", 2))
+			Expect(m.HasGlobalOffset).To(BeTrue())
+		})
+
+		It("returns false if the message does not exist", func() {
+			_, ok, err := store.LoadMessage(ctx, ds, ax.GenerateMessageID())
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(ok).To(BeFalse())
+		})
+	})
+
+	Describe("LoadMessagesByCorrelationID", func() {
+		It("returns all messages in the correlation chain", func() {
+			messages, err := store.LoadMessagesByCorrelationID(ctx, ds, root.MessageID)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(axtest.ConsistsOfEnvelopes(envelopes(messages), root, child, grandchild)).To(BeTrue())
+		})
+	})
+
+	Describe("LoadMessagesByCausationID", func() {
+		It("returns the messages caused by the given message", func() {
+			messages, err := store.LoadMessagesByCausationID(ctx, ds, child.MessageID)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(axtest.ConsistsOfEnvelopes(envelopes(messages), grandchild)).To(BeTrue())
+		})
+	})
+
+	Describe("LoadMessagesByInsertTime", func() {
+		It("returns the messages inserted within the time range", func() {
+			now := time.Now()
+
+			messages, err := store.LoadMessagesByInsertTime(
+				ctx,
+				ds,
+				now.Add(-time.Minute),
+				now.Add(time.Minute),
+				0,
+			)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(axtest.ConsistsOfEnvelopes(envelopes(messages), root, child, grandchild, other)).To(BeTrue())
+		})
+
+		It("does not return messages inserted outside the time range", func() {
+			now := time.Now()
+
+			messages, err := store.LoadMessagesByInsertTime(
+				ctx,
+				ds,
+				now.Add(time.Minute),
+				now.Add(2*time.Minute),
+				0,
+			)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(messages).To(BeEmpty())
+		})
+
+		It("returns at most limit messages", func() {
+			now := time.Now()
+
+			messages, err := store.LoadMessagesByInsertTime(
+				ctx,
+				ds,
+				now.Add(-time.Minute),
+				now.Add(time.Minute),
+				2,
+			)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(messages).To(HaveLen(2))
+		})
+	})
+})
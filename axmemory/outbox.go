@@ -0,0 +1,242 @@
+package axmemory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jmalloc/ax"
+	"github.com/jmalloc/ax/endpoint"
+	"github.com/jmalloc/ax/outbox"
+	"github.com/jmalloc/ax/persistence"
+)
+
+// OutboxRepository is an outbox repository backed by an in-memory data store.
+var OutboxRepository outbox.Repository = outboxRepository{}
+
+const (
+	// outboxTable is the name of the table that stores outboxes, keyed by the
+	// ID of the message that produced them.
+	outboxTable = "outbox"
+
+	// outboxMessageTable is the name of the table that stores outbox messages,
+	// keyed by message ID.
+	outboxMessageTable = "outbox_message"
+
+	// outboxStandaloneMessageTable is the name of the table that stores
+	// messages that are not associated with an inbound message, keyed by
+	// message ID.
+	outboxStandaloneMessageTable = "outbox_standalone_message"
+)
+
+// outboxRow is a row in the outbox table.
+type outboxRow struct {
+	InsertTime time.Time
+}
+
+// outboxMessageRow is a row in the outbox message tables.
+type outboxMessageRow struct {
+	Envelope   endpoint.OutboundEnvelope
+	InsertTime time.Time
+}
+
+// outboxRepository is an in-memory implementation of Ax's outbox.Repository
+// interface.
+type outboxRepository struct{}
+
+// LoadOutbox loads the unsent outbound messages that were produced when the
+// message identified by id was first processed.
+func (outboxRepository) LoadOutbox(
+	ctx context.Context,
+	pds persistence.DataStore,
+	id ax.MessageID,
+) ([]endpoint.OutboundEnvelope, bool, error) {
+	ds := extractDataStore(pds)
+
+	if _, ok := ds.get(outboxTable, id.Get()); !ok {
+		return nil, false, nil
+	}
+
+	var envs []endpoint.OutboundEnvelope
+
+	ds.scan(outboxMessageTable, func(_ string, v interface{}) {
+		r := v.(outboxMessageRow)
+		if r.Envelope.CausationID == id {
+			envs = append(envs, cloneOutboundEnvelope(r.Envelope))
+		}
+	})
+
+	return envs, true, nil
+}
+
+// SaveOutbox saves a set of unsent outbound messages that were produced
+// when the message identified by id was processed.
+func (outboxRepository) SaveOutbox(
+	ctx context.Context,
+	ptx persistence.Tx,
+	id ax.MessageID,
+	envs []endpoint.OutboundEnvelope,
+) error {
+	tx := extractTx(ptx)
+
+	if _, ok := tx.lock(outboxTable, id.Get()); ok {
+		return fmt.Errorf(
+			"can not save outbox for message %s, it already exists",
+			id,
+		)
+	}
+
+	now := time.Now()
+	tx.put(outboxTable, id.Get(), outboxRow{now})
+
+	for _, env := range envs {
+		tx.put(
+			outboxMessageTable,
+			env.MessageID.Get(),
+			outboxMessageRow{cloneOutboundEnvelope(env), now},
+		)
+	}
+
+	return nil
+}
+
+// SaveMessages saves a set of unsent outbound messages that are not
+// associated with an outbox.
+func (outboxRepository) SaveMessages(
+	ctx context.Context,
+	ptx persistence.Tx,
+	envs []endpoint.OutboundEnvelope,
+) error {
+	tx := extractTx(ptx)
+	now := time.Now()
+
+	for _, env := range envs {
+		tx.put(
+			outboxStandaloneMessageTable,
+			env.MessageID.Get(),
+			outboxMessageRow{cloneOutboundEnvelope(env), now},
+		)
+	}
+
+	return nil
+}
+
+// MarkAsSent marks messages as sent, removing them from the repository.
+func (outboxRepository) MarkAsSent(
+	ctx context.Context,
+	ptx persistence.Tx,
+	envs ...endpoint.OutboundEnvelope,
+) error {
+	tx := extractTx(ptx)
+
+	for _, env := range envs {
+		tx.delete(outboxMessageTable, env.MessageID.Get())
+		tx.delete(outboxStandaloneMessageTable, env.MessageID.Get())
+	}
+
+	return nil
+}
+
+// ClaimUnsentMessages locks and returns up to n unsent messages from outboxes
+// that were saved more than d ago, and any unsent messages saved by
+// SaveMessages(), regardless of their age.
+//
+// Messages claimed by another transaction are skipped. Claimed messages remain
+// locked until tx ends.
+func (outboxRepository) ClaimUnsentMessages(
+	ctx context.Context,
+	ptx persistence.Tx,
+	d time.Duration,
+	n int,
+) ([]endpoint.OutboundEnvelope, error) {
+	tx := extractTx(ptx)
+
+	rows := tx.claim(
+		outboxStandaloneMessageTable,
+		func(interface{}) bool { return true },
+		insertedBefore,
+		n,
+	)
+
+	if len(rows) < n {
+		cutoff := time.Now().Add(-d)
+		orphaned := map[string]bool{}
+
+		tx.scan(outboxTable, func(k string, v interface{}) {
+			if !v.(outboxRow).InsertTime.After(cutoff) {
+				orphaned[k] = true
+			}
+		})
+
+		rows = append(
+			rows,
+			tx.claim(
+				outboxMessageTable,
+				func(v interface{}) bool {
+					return orphaned[v.(outboxMessageRow).Envelope.CausationID.Get()]
+				},
+				insertedBefore,
+				n-len(rows),
+			)...,
+		)
+	}
+
+	envs := make([]endpoint.OutboundEnvelope, len(rows))
+	for i, r := range rows {
+		envs[i] = cloneOutboundEnvelope(r.(outboxMessageRow).Envelope)
+	}
+
+	return envs, nil
+}
+
+// PurgeOutboxes deletes up to n outboxes that were saved more than d ago and
+// no longer contain any unsent messages. It returns the number of outboxes
+// deleted.
+func (outboxRepository) PurgeOutboxes(
+	ctx context.Context,
+	ptx persistence.Tx,
+	d time.Duration,
+	n int,
+) (int, error) {
+	tx := extractTx(ptx)
+
+	unsent := map[string]bool{}
+	tx.scan(outboxMessageTable, func(_ string, v interface{}) {
+		unsent[v.(outboxMessageRow).Envelope.CausationID.Get()] = true
+	})
+
+	type candidate struct {
+		Key        string
+		InsertTime time.Time
+	}
+
+	cutoff := time.Now().Add(-d)
+	var candidates []candidate
+
+	tx.scan(outboxTable, func(k string, v interface{}) {
+		t := v.(outboxRow).InsertTime
+		if !unsent[k] && t.Before(cutoff) {
+			candidates = append(candidates, candidate{k, t})
+		}
+	})
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].InsertTime.Before(candidates[j].InsertTime)
+	})
+
+	if len(candidates) > n {
+		candidates = candidates[:n]
+	}
+
+	for _, c := range candidates {
+		tx.delete(outboxTable, c.Key)
+	}
+
+	return len(candidates), nil
+}
+
+// insertedBefore returns true if outbox message row a was inserted before b.
+func insertedBefore(a, b interface{}) bool {
+	return a.(outboxMessageRow).InsertTime.Before(b.(outboxMessageRow).InsertTime)
+}
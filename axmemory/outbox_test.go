@@ -0,0 +1,21 @@
+package axmemory_test
+
+import (
+	. "github.com/jmalloc/ax/axmemory"
+	"github.com/jmalloc/ax/axtest/outboxtests"
+	"github.com/jmalloc/ax/outbox"
+	"github.com/jmalloc/ax/persistence"
+	. "github.com/onsi/ginkgo"
+)
+
+var _ = Describe(
+	"OutboxRepository",
+	outboxtests.RepositorySuite(
+		func() persistence.DataStore {
+			return NewDataStore()
+		},
+		func() outbox.Repository {
+			return OutboxRepository
+		},
+	),
+)
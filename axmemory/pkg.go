@@ -0,0 +1,6 @@
+// Package axmemory provides in-memory implementations of various interfaces
+// consumed by Ax.
+//
+// The implementations are intended for use in tests and other short-lived
+// processes. Data is lost when the process exits.
+package axmemory
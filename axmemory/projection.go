@@ -0,0 +1,260 @@
+package axmemory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/jmalloc/ax/persistence"
+	"github.com/jmalloc/ax/projection"
+)
+
+// ProjectionOffsetStore is an offset store backed by an in-memory data store.
+var ProjectionOffsetStore projection.OffsetStore = offsetStore{}
+
+// ProjectionParkedMessageStore is a parked message store backed by an in-memory
+// data store.
+var ProjectionParkedMessageStore projection.ParkedMessageStore = parkedMessageStore{}
+
+const (
+	// projectionOffsetTable is the name of the table that stores projection
+	// offsets, keyed by the projector's persistence key and version.
+	projectionOffsetTable = "projection_offset"
+
+	// projectionVersionTable is the name of the table that stores the active
+	// version of each projector, keyed by the projector's persistence key.
+	projectionVersionTable = "projection_version"
+
+	// projectionParkedMessageTable is the name of the table that stores parked
+	// messages, keyed by the projector's persistence key and version, and the
+	// offset of the message.
+	projectionParkedMessageTable = "projection_parked_message"
+)
+
+// offsetStore is an in-memory implementation of Ax's projection.OffsetStore
+// interface.
+type offsetStore struct{}
+
+// LoadOffset returns the offset at which a consumer should resume
+// reading from the stream.
+//
+// pk is the projector's persistence key, and v is its version.
+func (offsetStore) LoadOffset(
+	ctx context.Context,
+	pds persistence.DataStore,
+	pk string,
+	v uint64,
+) (uint64, error) {
+	ds := extractDataStore(pds)
+
+	if o, ok := ds.get(projectionOffsetTable, offsetKey(pk, v)); ok {
+		return o.(uint64), nil
+	}
+
+	return 0, nil
+}
+
+// SaveOffset sets the offset at which a consumer should resume reading from
+// the stream.
+//
+// pk is the projector's persistence key, and v is its version. c is the offset
+// that is currently stored, as returned by LoadOffset(), and n is the new
+// offset. If c is not the offset that is currently stored, the save fails and
+// a *projection.ConflictError is returned.
+func (offsetStore) SaveOffset(
+	ctx context.Context,
+	ptx persistence.Tx,
+	pk string,
+	v uint64,
+	c, n uint64,
+) error {
+	tx := extractTx(ptx)
+	k := offsetKey(pk, v)
+
+	var current uint64
+	if o, ok := tx.lock(projectionOffsetTable, k); ok {
+		current = o.(uint64)
+	}
+
+	if c != current {
+		return &projection.ConflictError{PersistenceKey: pk, Version: v, Offset: c}
+	}
+
+	tx.put(projectionOffsetTable, k, n)
+
+	return nil
+}
+
+// ResetOffset sets the offset at which a consumer should resume reading from
+// the stream back to zero.
+//
+// pk is the projector's persistence key, and v is its version.
+func (offsetStore) ResetOffset(
+	ctx context.Context,
+	ptx persistence.Tx,
+	pk string,
+	v uint64,
+) error {
+	tx := extractTx(ptx)
+	k := offsetKey(pk, v)
+
+	tx.lock(projectionOffsetTable, k)
+	tx.delete(projectionOffsetTable, k)
+
+	return nil
+}
+
+// ActivateVersion makes v the active version of the projector with the
+// persistence key pk, unless a later version is already active.
+//
+// It returns true if v is the active version.
+func (offsetStore) ActivateVersion(
+	ctx context.Context,
+	ptx persistence.Tx,
+	pk string,
+	v uint64,
+) (bool, error) {
+	tx := extractTx(ptx)
+
+	if a, ok := tx.lock(projectionVersionTable, pk); ok && a.(uint64) > v {
+		return false, nil
+	}
+
+	tx.put(projectionVersionTable, pk, v)
+
+	return true, nil
+}
+
+// LoadActiveVersion returns the active version of the projector with the
+// persistence key pk.
+//
+// It returns false if no version of the projector has been activated.
+func (offsetStore) LoadActiveVersion(
+	ctx context.Context,
+	pds persistence.DataStore,
+	pk string,
+) (uint64, bool, error) {
+	ds := extractDataStore(pds)
+
+	if a, ok := ds.get(projectionVersionTable, pk); ok {
+		return a.(uint64), true, nil
+	}
+
+	return 0, false, nil
+}
+
+// offsetKey returns the key of the row that stores the offset for version v
+// of the projector with the persistence key pk.
+func offsetKey(pk string, v uint64) string {
+	return fmt.Sprintf("%d:%s", v, pk)
+}
+
+// parkedMessageStore is an in-memory implementation of Ax's
+// projection.ParkedMessageStore interface.
+type parkedMessageStore struct{}
+
+// parkedMessageRow is a row in the parked message table.
+type parkedMessageRow struct {
+	PersistenceKey string
+	Version        uint64
+	Message        projection.ParkedMessage
+}
+
+// ParkMessage records that the message at offset m.Offset has been parked.
+//
+// If the message is already parked, its details are replaced with m.
+func (parkedMessageStore) ParkMessage(
+	ctx context.Context,
+	ptx persistence.Tx,
+	pk string,
+	v uint64,
+	m projection.ParkedMessage,
+) error {
+	tx := extractTx(ptx)
+	tx.put(
+		projectionParkedMessageTable,
+		parkedMessageKey(pk, v, m.Offset),
+		parkedMessageRow{pk, v, m},
+	)
+
+	return nil
+}
+
+// LoadParkedMessages returns the parked messages, in order of their offset.
+func (parkedMessageStore) LoadParkedMessages(
+	ctx context.Context,
+	pds persistence.DataStore,
+	pk string,
+	v uint64,
+) ([]projection.ParkedMessage, error) {
+	ds := extractDataStore(pds)
+
+	var messages []projection.ParkedMessage
+
+	ds.scan(projectionParkedMessageTable, func(_ string, x interface{}) {
+		r := x.(parkedMessageRow)
+		if r.PersistenceKey == pk && r.Version == v {
+			messages = append(messages, r.Message)
+		}
+	})
+
+	sort.Slice(messages, func(i, j int) bool {
+		return messages[i].Offset < messages[j].Offset
+	})
+
+	return messages, nil
+}
+
+// UnparkMessage removes the message at offset o from the parked messages.
+//
+// It returns false if the message is not parked.
+func (parkedMessageStore) UnparkMessage(
+	ctx context.Context,
+	ptx persistence.Tx,
+	pk string,
+	v uint64,
+	o uint64,
+) (bool, error) {
+	tx := extractTx(ptx)
+	k := parkedMessageKey(pk, v, o)
+
+	if _, ok := tx.lock(projectionParkedMessageTable, k); !ok {
+		return false, nil
+	}
+
+	tx.delete(projectionParkedMessageTable, k)
+
+	return true, nil
+}
+
+// ResetParkedMessages removes all of the parked messages.
+func (parkedMessageStore) ResetParkedMessages(
+	ctx context.Context,
+	ptx persistence.Tx,
+	pk string,
+	v uint64,
+) error {
+	tx := extractTx(ptx)
+
+	var keys []string
+
+	tx.scan(projectionParkedMessageTable, func(k string, x interface{}) {
+		r := x.(parkedMessageRow)
+		if r.PersistenceKey == pk && r.Version == v {
+			keys = append(keys, k)
+		}
+	})
+
+	for _, k := range keys {
+		tx.delete(projectionParkedMessageTable, k)
+	}
+
+	return nil
+}
+
+// parkedMessageKey returns the key of the row that stores the message at offset
+// o that has been parked by version v of the projector with the persistence key
+// pk.
+func parkedMessageKey(pk string, v, o uint64) string {
+	return fmt.Sprintf("%d:%s", o, offsetKey(pk, v))
+}
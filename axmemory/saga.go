@@ -0,0 +1,408 @@
+package axmemory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/jmalloc/ax/persistence"
+	"github.com/jmalloc/ax/saga"
+	"github.com/jmalloc/ax/saga/mapping/keyset"
+	"github.com/jmalloc/ax/saga/persistence/crud"
+	"github.com/jmalloc/ax/saga/persistence/eventsourcing"
+)
+
+// SagaKeySetRepository is a key-set repository backed by an in-memory data
+// store.
+var SagaKeySetRepository keyset.Repository = keySetRepository{}
+
+// SagaCRUDRepository is a CRUD saga repository backed by an in-memory data
+// store.
+var SagaCRUDRepository crud.Repository = crudRepository{}
+
+// SagaSnapshotRepository is a saga snapshot repository backed by an in-memory
+// data store.
+var SagaSnapshotRepository eventsourcing.SnapshotRepository = snapshotRepository{}
+
+const (
+	// sagaInstanceTable is the name of the table that stores saga instances,
+	// keyed by instance ID.
+	sagaInstanceTable = "saga_instance"
+
+	// sagaKeySetTable is the name of the table that stores saga mapping keys,
+	// keyed by persistence key and mapping key.
+	sagaKeySetTable = "saga_keyset"
+
+	// sagaSnapshotTable is the name of the table that stores saga snapshots,
+	// keyed by instance ID and revision.
+	sagaSnapshotTable = "saga_snapshot"
+)
+
+// sagaRow is a row in the saga instance or snapshot tables.
+type sagaRow struct {
+	PersistenceKey string
+	Instance       saga.Instance
+}
+
+// keySetRow is a row in the saga key-set table.
+type keySetRow struct {
+	PersistenceKey string
+	InstanceID     saga.InstanceID
+}
+
+// cloneInstance returns a deep copy of i.
+func cloneInstance(i saga.Instance) saga.Instance {
+	i.Data = proto.Clone(i.Data).(saga.Data)
+	return i
+}
+
+// crudRepository is an in-memory implementation of Ax's crud.Repository
+// interface.
+type crudRepository struct{}
+
+// LoadSagaInstance fetches a saga instance by its ID.
+//
+// It returns an false if the instance does not exist. It returns an error
+// if a problem occurs with the underlying data store.
+//
+// It returns an error if the instance is found, but belongs to a different
+// saga, as identified by pk, the saga's persistence key.
+//
+// It panics if the repository is not able to enlist in tx because it uses a
+// different underlying storage system.
+func (crudRepository) LoadSagaInstance(
+	ctx context.Context,
+	ptx persistence.Tx,
+	pk string,
+	id saga.InstanceID,
+) (saga.Instance, bool, error) {
+	tx := extractTx(ptx)
+
+	v, ok := tx.get(sagaInstanceTable, id.Get())
+	if !ok {
+		return saga.Instance{}, false, nil
+	}
+
+	r := v.(sagaRow)
+
+	if r.PersistenceKey != pk {
+		return r.Instance, false, fmt.Errorf(
+			"can not load saga instance %s for saga %s, it belongs to %s",
+			id,
+			pk,
+			r.PersistenceKey,
+		)
+	}
+
+	return cloneInstance(r.Instance), true, nil
+}
+
+// SaveSagaInstance persists a saga instance.
+//
+// It returns an error if i.Revision is not the current revision of the
+// instance as it exists within the store, or a problem occurs with the
+// underlying data store.
+//
+// It returns an error if the instance belongs to a different saga, as
+// identified by pk, the saga's persistence key.
+//
+// It panics if the repository is not able to enlist in tx because it uses a
+// different underlying storage system.
+func (r crudRepository) SaveSagaInstance(
+	ctx context.Context,
+	ptx persistence.Tx,
+	pk string,
+	i saga.Instance,
+) error {
+	tx := extractTx(ptx)
+
+	ok, err := r.lockInstance(tx, pk, i)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		// TODO: use OCC error https://github.com/jmalloc/ax/issues/93
+		return fmt.Errorf(
+			"can not update saga instance %s, revision %d is not the current revision",
+			i.InstanceID,
+			i.Revision,
+		)
+	}
+
+	i = cloneInstance(i)
+	i.Revision++
+	tx.put(sagaInstanceTable, i.InstanceID.Get(), sagaRow{pk, i})
+
+	return nil
+}
+
+// DeleteSagaInstance deletes a saga instance.
+//
+// It returns an error if i.Revision is not the current revision of the
+// instance as it exists within the store, or a problem occurs with the
+// underlying data store.
+//
+// It returns an error if the instance belongs to a different saga, as
+// identified by pk, the saga's persistence key.
+//
+// It panics if the repository is not able to enlist in tx because it uses a
+// different underlying storage system.
+func (r crudRepository) DeleteSagaInstance(
+	ctx context.Context,
+	ptx persistence.Tx,
+	pk string,
+	i saga.Instance,
+) error {
+	tx := extractTx(ptx)
+
+	ok, err := r.lockInstance(tx, pk, i)
+	if err != nil {
+		return err
+	}
+
+	if !ok || i.Revision == 0 {
+		// TODO: use OCC error https://github.com/jmalloc/ax/issues/93
+		return fmt.Errorf(
+			"can not delete saga instance %s, revision %d is not the current revision",
+			i.InstanceID,
+			i.Revision,
+		)
+	}
+
+	tx.delete(sagaInstanceTable, i.InstanceID.Get())
+
+	return nil
+}
+
+// lockInstance locks the row for an instance at the given revision.
+// It returns false if i.Revision is not the current revision. A revision of
+// zero is current if the instance does not exist.
+func (crudRepository) lockInstance(
+	tx *Tx,
+	pk string,
+	i saga.Instance,
+) (bool, error) {
+	v, ok := tx.lock(sagaInstanceTable, i.InstanceID.Get())
+	if !ok {
+		return i.Revision == 0, nil
+	}
+
+	r := v.(sagaRow)
+
+	if i.Revision != r.Instance.Revision {
+		return false, nil
+	}
+
+	if pk != r.PersistenceKey {
+		return false, fmt.Errorf(
+			"can not lock saga instance %s for saga %s, it belongs to %s",
+			i.InstanceID,
+			pk,
+			r.PersistenceKey,
+		)
+	}
+
+	return true, nil
+}
+
+// keySetRepository is an in-memory implementation of Ax's keyset.Repository
+// interface.
+type keySetRepository struct{}
+
+// FindByKey returns the ID of a saga instance that has a specific key in
+// its key set.
+//
+// pk is the saga's persistence key, mk is the mapping key.
+// ok is false if no saga instance has a key set containing mk.
+func (keySetRepository) FindByKey(
+	ctx context.Context,
+	ptx persistence.Tx,
+	pk, mk string,
+) (saga.InstanceID, bool, error) {
+	tx := extractTx(ptx)
+
+	v, ok := tx.get(sagaKeySetTable, keySetKey(pk, mk))
+	if !ok {
+		return saga.InstanceID{}, false, nil
+	}
+
+	return v.(keySetRow).InstanceID, true, nil
+}
+
+// SaveKeys associates a set of mapping keys with a saga instance.
+//
+// Key sets must be disjoint. That is, no two instances of the same saga
+// may share any keys.
+//
+// pk is the saga's persistence key. ks is the set of mapping keys.
+//
+// SaveKeys() may panic if ks contains duplicate keys.
+func (r keySetRepository) SaveKeys(
+	ctx context.Context,
+	ptx persistence.Tx,
+	pk string,
+	ks []string,
+	id saga.InstanceID,
+) error {
+	tx := extractTx(ptx)
+
+	r.deleteKeys(tx, pk, id)
+
+	for _, mk := range ks {
+		k := keySetKey(pk, mk)
+
+		if _, ok := tx.lock(sagaKeySetTable, k); ok {
+			return fmt.Errorf(
+				"can not save mapping keys for instance %s, the '%s' key is mapped to another instance",
+				id,
+				mk,
+			)
+		}
+
+		tx.put(sagaKeySetTable, k, keySetRow{pk, id})
+	}
+
+	return nil
+}
+
+// DeleteKeys removes any mapping keys associated with a saga instance.
+//
+// pk is the saga's persistence key.
+func (r keySetRepository) DeleteKeys(
+	ctx context.Context,
+	ptx persistence.Tx,
+	pk string,
+	id saga.InstanceID,
+) error {
+	r.deleteKeys(extractTx(ptx), pk, id)
+	return nil
+}
+
+func (keySetRepository) deleteKeys(
+	tx *Tx,
+	pk string,
+	id saga.InstanceID,
+) {
+	var keys []string
+
+	tx.scan(sagaKeySetTable, func(k string, v interface{}) {
+		r := v.(keySetRow)
+		if r.PersistenceKey == pk && r.InstanceID == id {
+			keys = append(keys, k)
+		}
+	})
+
+	for _, k := range keys {
+		tx.delete(sagaKeySetTable, k)
+	}
+}
+
+// keySetKey returns the key of the key-set row for the mapping key mk of the
+// saga with the persistence key pk.
+func keySetKey(pk, mk string) string {
+	return fmt.Sprintf("%d:%s%s", len(pk), pk, mk)
+}
+
+// snapshotRepository is an in-memory implementation of Ax's
+// eventsourcing.SnapshotRepository interface.
+type snapshotRepository struct{}
+
+// LoadSagaSnapshot loads the latest available snapshot from the store.
+//
+// It returns an error if a snapshot of this instance is found, but belongs to
+// a different saga, as identified by pk, the saga's persistence key.
+func (snapshotRepository) LoadSagaSnapshot(
+	ctx context.Context,
+	ptx persistence.Tx,
+	pk string,
+	id saga.InstanceID,
+) (saga.Instance, bool, error) {
+	tx := extractTx(ptx)
+
+	var rows []sagaRow
+
+	tx.scan(sagaSnapshotTable, func(_ string, v interface{}) {
+		r := v.(sagaRow)
+		if r.Instance.InstanceID == id {
+			rows = append(rows, r)
+		}
+	})
+
+	if len(rows) == 0 {
+		return saga.Instance{}, false, nil
+	}
+
+	sort.Slice(rows, func(i, j int) bool {
+		return rows[i].Instance.Revision > rows[j].Instance.Revision
+	})
+
+	r := rows[0]
+
+	if r.PersistenceKey != pk {
+		return r.Instance, false, fmt.Errorf(
+			"can not load saga snapshot of %s at revision %d for saga %s, it belongs to %s",
+			id,
+			r.Instance.Revision,
+			pk,
+			r.PersistenceKey,
+		)
+	}
+
+	return cloneInstance(r.Instance), true, nil
+}
+
+// SaveSagaSnapshot saves a snapshot to the store.
+//
+// This implementation does not verify the saga's persistence key against
+// existing snapshots of the same instance.
+func (snapshotRepository) SaveSagaSnapshot(
+	ctx context.Context,
+	ptx persistence.Tx,
+	pk string,
+	i saga.Instance,
+) error {
+	tx := extractTx(ptx)
+	k := fmt.Sprintf("%s:%d", i.InstanceID.Get(), i.Revision)
+
+	if _, ok := tx.lock(sagaSnapshotTable, k); ok {
+		return fmt.Errorf(
+			"can not save saga snapshot of %s at revision %d, it already exists",
+			i.InstanceID,
+			i.Revision,
+		)
+	}
+
+	tx.put(sagaSnapshotTable, k, sagaRow{pk, cloneInstance(i)})
+
+	return nil
+}
+
+// DeleteSagaSnapshots deletes any snapshots associated with a saga instance.
+//
+// This implementation does not verify the saga's persistence key. It simply
+// ignores any snapshots that match the instance ID, but not the persistence key.
+func (snapshotRepository) DeleteSagaSnapshots(
+	ctx context.Context,
+	ptx persistence.Tx,
+	pk string,
+	id saga.InstanceID,
+) error {
+	tx := extractTx(ptx)
+
+	var keys []string
+
+	tx.scan(sagaSnapshotTable, func(k string, v interface{}) {
+		r := v.(sagaRow)
+		if r.PersistenceKey == pk && r.Instance.InstanceID == id {
+			keys = append(keys, k)
+		}
+	})
+
+	for _, k := range keys {
+		tx.delete(sagaSnapshotTable, k)
+	}
+
+	return nil
+}
@@ -0,0 +1,102 @@
+package axmemory_test
+
+import (
+	"context"
+
+	. "github.com/jmalloc/ax/axmemory"
+	"github.com/jmalloc/ax/axtest/sagatests"
+	"github.com/jmalloc/ax/axtest/testmessages"
+	"github.com/jmalloc/ax/persistence"
+	"github.com/jmalloc/ax/saga"
+	"github.com/jmalloc/ax/saga/mapping/keyset"
+	"github.com/jmalloc/ax/saga/persistence/crud"
+	"github.com/jmalloc/ax/saga/persistence/eventsourcing"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe(
+	"SagaCRUDRepository",
+	sagatests.CRUDRepositorySuite(
+		func() persistence.DataStore {
+			return NewDataStore()
+		},
+		func() crud.Repository {
+			return SagaCRUDRepository
+		},
+	),
+)
+
+var _ = Describe(
+	"SagaKeySetRepository",
+	sagatests.KeySetRepositorySuite(
+		func() persistence.DataStore {
+			return NewDataStore()
+		},
+		func() keyset.Repository {
+			return SagaKeySetRepository
+		},
+	),
+)
+
+var _ = Describe(
+	"SagaSnapshotRepository",
+	sagatests.SnapshotRepositorySuite(
+		func() persistence.DataStore {
+			return NewDataStore()
+		},
+		func() eventsourcing.SnapshotRepository {
+			return SagaSnapshotRepository
+		},
+	),
+)
+
+var _ = Describe("SagaCRUDRepository (concurrency)", func() {
+	var (
+		ctx context.Context
+		ds  *DataStore
+		i   saga.Instance
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		ds = NewDataStore()
+
+		i = saga.Instance{
+			InstanceID: saga.GenerateInstanceID(),
+			Data:       &testmessages.Message{},
+		}
+
+		err := persistence.Atomically(
+			persistence.WithDataStore(ctx, ds),
+			func(ctx context.Context, tx persistence.Tx) error {
+				return SagaCRUDRepository.SaveSagaInstance(ctx, tx, "<pk>", i)
+			},
+		)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		i.Revision = 1
+	})
+
+	It("fails to commit if another transaction saves the same revision first", func() {
+		tx1, com1, err := ds.BeginTx(ctx)
+		Expect(err).ShouldNot(HaveOccurred())
+		defer com1.Rollback()
+
+		tx2, com2, err := ds.BeginTx(ctx)
+		Expect(err).ShouldNot(HaveOccurred())
+		defer com2.Rollback()
+
+		err = SagaCRUDRepository.SaveSagaInstance(ctx, tx1, "<pk>", i)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		err = SagaCRUDRepository.SaveSagaInstance(ctx, tx2, "<pk>", i)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		err = com1.Commit()
+		Expect(err).ShouldNot(HaveOccurred())
+
+		err = com2.Commit()
+		Expect(err).To(MatchError(ContainSubstring("modified by another transaction")))
+	})
+})
@@ -0,0 +1,85 @@
+package axmemory
+
+import (
+	"context"
+
+	"github.com/jmalloc/ax/messagestore/shredding"
+	"github.com/jmalloc/ax/persistence"
+)
+
+// ShreddingKeyStore is a crypto-shredding key store backed by an in-memory
+// data store.
+var ShreddingKeyStore shredding.KeyStore = keyStore{}
+
+// shreddingKeyTable is the name of the table that stores the encryption key of
+// each data subject, keyed by subject ID.
+const shreddingKeyTable = "shredding_key"
+
+// shreddingKey is the row stored for each data subject. Key is nil if the
+// subject's key has been deleted.
+type shreddingKey struct {
+	Key []byte
+}
+
+// keyStore is an in-memory implementation of Ax's shredding.KeyStore
+// interface.
+type keyStore struct{}
+
+// LoadOrCreateKey loads the encryption key for a data subject, creating a new
+// key if the subject does not have one.
+//
+// It returns false if the subject's key has been deleted.
+func (keyStore) LoadOrCreateKey(
+	ctx context.Context,
+	ptx persistence.Tx,
+	subject string,
+) ([]byte, bool, error) {
+	tx := extractTx(ptx)
+
+	if v, ok := tx.lock(shreddingKeyTable, subject); ok {
+		k := v.(shreddingKey).Key
+		return k, k != nil, nil
+	}
+
+	k, err := shredding.GenerateKey()
+	if err != nil {
+		return nil, false, err
+	}
+
+	tx.put(shreddingKeyTable, subject, shreddingKey{k})
+
+	return k, true, nil
+}
+
+// LoadKey loads the encryption key for a data subject.
+//
+// It returns false if the subject does not have a key, or if the key has been
+// deleted.
+func (keyStore) LoadKey(
+	ctx context.Context,
+	pds persistence.DataStore,
+	subject string,
+) ([]byte, bool, error) {
+	ds := extractDataStore(pds)
+
+	if v, ok := ds.get(shreddingKeyTable, subject); ok {
+		k := v.(shreddingKey).Key
+		return k, k != nil, nil
+	}
+
+	return nil, false, nil
+}
+
+// DeleteKey permanently deletes the encryption key for a data subject.
+func (keyStore) DeleteKey(
+	ctx context.Context,
+	ptx persistence.Tx,
+	subject string,
+) error {
+	tx := extractTx(ptx)
+
+	tx.lock(shreddingKeyTable, subject)
+	tx.put(shreddingKeyTable, subject, shreddingKey{})
+
+	return nil
+}
@@ -0,0 +1,346 @@
+package axmemory
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/jmalloc/ax/persistence"
+)
+
+// errTxDone is returned when an attempt is made to commit or rollback a
+// transaction that has already ended.
+var errTxDone = errors.New("transaction has already been committed or rolled back")
+
+// Tx is an in-memory implementation of Ax's persistence.Tx interface.
+//
+// It also implements persistence.Committer.
+type Tx struct {
+	ds *DataStore
+
+	m    sync.Mutex
+	done bool
+
+	// writes contains the rows that have been written within the transaction.
+	// A nil value indicates that the row has been deleted.
+	writes map[rowID]interface{}
+
+	// reads contains the versions of the rows that the transaction depends
+	// upon, as they were when they were first locked.
+	reads map[rowID]uint64
+
+	// onCommit is a list of functions that are called when the transaction is
+	// committed, after its writes have been applied.
+	onCommit []func()
+
+	// hooks contains the functions registered via AfterCommit() and
+	// AfterRollback(), which are called after the data store is unlocked.
+	hooks persistence.Hooks
+}
+
+// DataStore returns the DataStore that the transaction operates on.
+func (tx *Tx) DataStore() persistence.DataStore {
+	return tx.ds
+}
+
+// AfterCommit registers fn to be called after the transaction is committed.
+func (tx *Tx) AfterCommit(fn func()) {
+	tx.hooks.AfterCommit(fn)
+}
+
+// AfterRollback registers fn to be called after the transaction is rolled
+// back, including when the commit fails.
+func (tx *Tx) AfterRollback(fn func()) {
+	tx.hooks.AfterRollback(fn)
+}
+
+// Commit applies the changes to the data store.
+//
+// It returns an error if any row that was locked within the transaction has
+// since been modified by another transaction.
+func (tx *Tx) Commit() error {
+	err := tx.commit()
+
+	if err == nil {
+		tx.hooks.Committed()
+	} else if err != errTxDone {
+		tx.hooks.RolledBack()
+	}
+
+	return err
+}
+
+// Rollback discards the changes without applying them to the data store.
+func (tx *Tx) Rollback() error {
+	err := tx.rollback()
+
+	if err != errTxDone {
+		tx.hooks.RolledBack()
+	}
+
+	return err
+}
+
+// commit applies the changes to the data store.
+func (tx *Tx) commit() error {
+	tx.m.Lock()
+	defer tx.m.Unlock()
+
+	if tx.done {
+		return errTxDone
+	}
+
+	tx.ds.m.Lock()
+	defer tx.ds.m.Unlock()
+
+	tx.done = true
+	tx.releaseClaims()
+
+	for id, v := range tx.reads {
+		if tx.ds.versions[id] != v {
+			return fmt.Errorf(
+				"can not commit transaction, the '%s' row in the '%s' table was modified by another transaction",
+				id.Key,
+				id.Table,
+			)
+		}
+	}
+
+	for id, v := range tx.writes {
+		tx.ds.apply(id, v)
+	}
+
+	for _, fn := range tx.onCommit {
+		fn()
+	}
+
+	return nil
+}
+
+// rollback discards the changes without applying them to the data store.
+func (tx *Tx) rollback() error {
+	tx.m.Lock()
+	defer tx.m.Unlock()
+
+	if tx.done {
+		return errTxDone
+	}
+
+	tx.ds.m.Lock()
+	defer tx.ds.m.Unlock()
+
+	tx.done = true
+	tx.releaseClaims()
+
+	return nil
+}
+
+// get returns the value of a row as seen from within the transaction.
+func (tx *Tx) get(table, key string) (interface{}, bool) {
+	tx.m.Lock()
+	defer tx.m.Unlock()
+
+	return tx.read(rowID{table, key})
+}
+
+// lock returns the value of a row as seen from within the transaction, and
+// causes the transaction to fail to commit if the row is modified by another
+// transaction before then.
+//
+// The row need not exist. Locking a row that does not exist prevents it from
+// being inserted by another transaction.
+func (tx *Tx) lock(table, key string) (interface{}, bool) {
+	tx.m.Lock()
+	defer tx.m.Unlock()
+
+	id := rowID{table, key}
+
+	if _, ok := tx.reads[id]; !ok {
+		if _, ok := tx.writes[id]; !ok {
+			tx.ds.m.Lock()
+			v := tx.ds.versions[id]
+			tx.ds.m.Unlock()
+
+			if tx.reads == nil {
+				tx.reads = map[rowID]uint64{}
+			}
+			tx.reads[id] = v
+		}
+	}
+
+	return tx.read(id)
+}
+
+// put writes a row within the transaction.
+func (tx *Tx) put(table, key string, v interface{}) {
+	tx.m.Lock()
+	defer tx.m.Unlock()
+
+	if tx.writes == nil {
+		tx.writes = map[rowID]interface{}{}
+	}
+
+	tx.writes[rowID{table, key}] = v
+}
+
+// delete deletes a row within the transaction.
+func (tx *Tx) delete(table, key string) {
+	tx.put(table, key, nil)
+}
+
+// scan calls fn for each row in the given table, as seen from within the
+// transaction.
+//
+// fn must not call any other methods on tx.
+func (tx *Tx) scan(table string, fn func(key string, v interface{})) {
+	tx.m.Lock()
+	defer tx.m.Unlock()
+
+	for k, v := range tx.rows(table) {
+		fn(k, v)
+	}
+}
+
+// claim locks up to n rows in the given table for which match returns true,
+// skipping any rows that are already claimed by another transaction. The
+// claimed rows are returned in the order specified by less.
+//
+// Claimed rows are released when the transaction ends.
+func (tx *Tx) claim(
+	table string,
+	match func(v interface{}) bool,
+	less func(a, b interface{}) bool,
+	n int,
+) []interface{} {
+	tx.m.Lock()
+	defer tx.m.Unlock()
+
+	rows := tx.rows(table)
+
+	tx.ds.m.Lock()
+	defer tx.ds.m.Unlock()
+
+	var keys []string
+	for k, v := range rows {
+		if c, ok := tx.ds.claims[rowID{table, k}]; ok && c != tx {
+			continue
+		}
+
+		if match(v) {
+			keys = append(keys, k)
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return less(rows[keys[i]], rows[keys[j]])
+	})
+
+	if len(keys) > n {
+		keys = keys[:n]
+	}
+
+	if tx.ds.claims == nil {
+		tx.ds.claims = map[rowID]*Tx{}
+	}
+
+	values := make([]interface{}, len(keys))
+	for i, k := range keys {
+		tx.ds.claims[rowID{table, k}] = tx
+		values[i] = rows[k]
+	}
+
+	return values
+}
+
+// afterCommit registers fn to be called when the transaction is committed,
+// after its writes have been applied. The data store is locked while fn is
+// called.
+func (tx *Tx) afterCommit(fn func()) {
+	tx.m.Lock()
+	defer tx.m.Unlock()
+
+	tx.onCommit = append(tx.onCommit, fn)
+}
+
+// read returns the value of a row as seen from within the transaction.
+// tx.m must be locked.
+func (tx *Tx) read(id rowID) (interface{}, bool) {
+	if v, ok := tx.writes[id]; ok {
+		return v, v != nil
+	}
+
+	tx.ds.m.Lock()
+	defer tx.ds.m.Unlock()
+
+	v, ok := tx.ds.tables[id.Table][id.Key]
+	return v, ok
+}
+
+// rows returns the rows in the given table as seen from within the
+// transaction. tx.m must be locked.
+func (tx *Tx) rows(table string) map[string]interface{} {
+	rows := map[string]interface{}{}
+
+	tx.ds.m.Lock()
+	for k, v := range tx.ds.tables[table] {
+		rows[k] = v
+	}
+	tx.ds.m.Unlock()
+
+	for id, v := range tx.writes {
+		if id.Table != table {
+			continue
+		}
+
+		if v == nil {
+			delete(rows, id.Key)
+		} else {
+			rows[id.Key] = v
+		}
+	}
+
+	return rows
+}
+
+// releaseClaims releases all rows claimed by the transaction.
+// tx.ds.m must be locked.
+func (tx *Tx) releaseClaims() {
+	for id, c := range tx.ds.claims {
+		if c == tx {
+			delete(tx.ds.claims, id)
+		}
+	}
+}
+
+// apply writes a row to the committed state of the data store. A nil value
+// deletes the row. ds.m must be locked.
+func (ds *DataStore) apply(id rowID, v interface{}) {
+	if ds.versions == nil {
+		ds.versions = map[rowID]uint64{}
+	}
+	ds.versions[id]++
+
+	if v == nil {
+		delete(ds.tables[id.Table], id.Key)
+		return
+	}
+
+	if ds.tables == nil {
+		ds.tables = map[string]map[string]interface{}{}
+	}
+
+	t, ok := ds.tables[id.Table]
+	if !ok {
+		t = map[string]interface{}{}
+		ds.tables[id.Table] = t
+	}
+
+	t[id.Key] = v
+}
+
+// extractTx returns tx as a *Tx.
+// It panics if tx is not a *Tx.
+func extractTx(tx persistence.Tx) *Tx {
+	return tx.(*Tx)
+}
@@ -0,0 +1,56 @@
+package axmysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// NewMigrateCommand returns a CLI command that creates or upgrades the schema
+// used by every component in this package, using tables with the given prefix.
+func NewMigrateCommand(db *sql.DB, prefix string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Create or upgrade the Ax MySQL schema",
+		Args:  cobra.NoArgs,
+		RunE: func(c *cobra.Command, args []string) error {
+			timeout, err := c.Flags().GetDuration("timeout")
+			if err != nil {
+				return err
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+
+			c.SilenceUsage = true
+
+			applied, err := Migrate(ctx, db, prefix)
+
+			for _, a := range applied {
+				fmt.Fprintf(
+					c.OutOrStdout(),
+					"applied %s migration #%d: %s\n",
+					a.Component,
+					a.Version,
+					a.Description,
+				)
+			}
+
+			if err == nil && len(applied) == 0 {
+				fmt.Fprintln(c.OutOrStdout(), "schema is up to date")
+			}
+
+			return err
+		},
+	}
+
+	cmd.Flags().DurationP(
+		"timeout", "t",
+		5*time.Minute,
+		"sets the timeout for applying migrations",
+	)
+
+	return cmd
+}
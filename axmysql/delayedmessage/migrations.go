@@ -0,0 +1,35 @@
+package delayedmessage
+
+import "github.com/jmalloc/ax/axmysql/migration"
+
+// Migrations returns the schema migrations for the delayed message table.
+func (r Repository) Migrations() migration.Component {
+	return migration.Component{
+		Name: r.table("delayedmessage"),
+		Migrations: []migration.Migration{
+			{
+				Version:     1,
+				Description: "create the delayed message table",
+				Statements: []string{
+					// ax_delayed_message stores the messages that are not yet ready to be sent.
+					`CREATE TABLE IF NOT EXISTS ` + r.table("delayed_message") + ` (
+						message_id     VARBINARY(255) NOT NULL,
+						causation_id   VARBINARY(255) NOT NULL,
+						correlation_id VARBINARY(255) NOT NULL,
+						created_at     VARBINARY(255) NOT NULL,
+						send_at        VARBINARY(255) NOT NULL,
+						content_type   VARBINARY(255) NOT NULL,
+						data           LONGBLOB NOT NULL,
+						operation      INTEGER NOT NULL,
+						destination    VARBINARY(255) NOT NULL,
+
+						PRIMARY KEY (message_id),
+						INDEX (send_at),
+						INDEX (causation_id),
+						INDEX (correlation_id)
+					) ROW_FORMAT=COMPRESSED`,
+				},
+			},
+		},
+	}
+}
@@ -13,13 +13,15 @@ import (
 
 // Repository is a MySQL-backed implementation of Ax's delayedmessage.Repository
 // interface.
-type Repository struct{}
-
-// messageTable is the name of the SQL table that stores delayed messages.
-const messageTable = "ax_delayed_message"
+type Repository struct {
+	// TablePrefix is the prefix used for the names of the repository's
+	// tables. It may include a schema name, such as "billing.ax_". If it is
+	// empty, "ax_" is used.
+	TablePrefix string
+}
 
 // LoadNextMessage loads the next that is scheduled to be sent.
-func (Repository) LoadNextMessage(
+func (r Repository) LoadNextMessage(
 	ctx context.Context,
 	ds persistence.DataStore,
 ) (endpoint.OutboundEnvelope, bool, error) {
@@ -28,7 +30,7 @@ func (Repository) LoadNextMessage(
 	row := db.QueryRowContext(
 		ctx,
 		`SELECT `+envelopestore.Columns+`
-		FROM `+messageTable+`
+		FROM `+r.table("delayed_message")+`
 		ORDER BY send_at
 		LIMIT 1`,
 	)
@@ -45,14 +47,14 @@ func (Repository) LoadNextMessage(
 
 // SaveMessage saves a message to be sent at a later time.
 // If does NOT return an error if the message already exists in the repository.
-func (Repository) SaveMessage(
+func (r Repository) SaveMessage(
 	ctx context.Context,
 	ptx persistence.Tx,
 	env endpoint.OutboundEnvelope,
 ) error {
 	tx := mysqlpersistence.ExtractTx(ptx)
 
-	err := envelopestore.Insert(ctx, tx, messageTable, env)
+	err := envelopestore.Insert(ctx, tx, r.table("delayed_message"), env)
 
 	if sqlutil.IsDuplicateEntry(err) {
 		return nil
@@ -62,12 +64,18 @@ func (Repository) SaveMessage(
 }
 
 // MarkAsSent marks a message as sent, removing it from the repository.
-func (Repository) MarkAsSent(
+func (r Repository) MarkAsSent(
 	ctx context.Context,
 	ptx persistence.Tx,
 	env endpoint.OutboundEnvelope,
 ) error {
 	tx := mysqlpersistence.ExtractTx(ptx)
 
-	return envelopestore.Delete(ctx, tx, messageTable, env)
+	return envelopestore.Delete(ctx, tx, r.table("delayed_message"), env)
+}
+
+// table returns the name of the table with the given name, including the
+// table prefix.
+func (r Repository) table(name string) string {
+	return sqlutil.Table(r.TablePrefix, name)
 }
@@ -18,6 +18,8 @@ var _ = Describe("Repository", func() {
 	dsn := os.Getenv("AX_MYSQL_DSN")
 	var db *sql.DB
 
+	prefixed := Repository{TablePrefix: "axtest_"}
+
 	BeforeEach(func() {
 		var err error
 		db, err = sql.Open("mysql", dsn)
@@ -25,7 +27,11 @@ var _ = Describe("Repository", func() {
 			panic(err)
 		}
 
-		if err := schema.Create(db, "schema.sql"); err != nil {
+		if err := schema.Create(db, Repository{}.Migrations()); err != nil {
+			panic(err)
+		}
+
+		if err := schema.Create(db, prefixed.Migrations()); err != nil {
 			panic(err)
 		}
 	})
@@ -52,4 +58,16 @@ var _ = Describe("Repository", func() {
 			},
 		),
 	)
+
+	fn(
+		"Repository with a table prefix",
+		delayedmessagetests.RepositorySuite(
+			func() persistence.DataStore {
+				return axmysql.NewDataStore(db)
+			},
+			func() delayedmessage.Repository {
+				return prefixed
+			},
+		),
+	)
 })
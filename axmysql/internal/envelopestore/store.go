@@ -5,6 +5,7 @@ import (
 	"database/sql"
 
 	"github.com/jmalloc/ax"
+	"github.com/jmalloc/ax/axmysql/internal/sqlutil"
 	"github.com/jmalloc/ax/endpoint"
 	"github.com/jmalloc/ax/marshaling"
 )
@@ -47,17 +48,26 @@ func Insert(
 	return err
 }
 
-// Delete removes a message from the store.
+// Delete removes one or more messages from the store.
 func Delete(
 	ctx context.Context,
 	tx *sql.Tx,
 	table string,
-	env endpoint.OutboundEnvelope,
+	envs ...endpoint.OutboundEnvelope,
 ) error {
+	if len(envs) == 0 {
+		return nil
+	}
+
+	args := make([]interface{}, len(envs))
+	for i, env := range envs {
+		args[i] = env.MessageID
+	}
+
 	_, err := tx.ExecContext(
 		ctx,
-		`DELETE FROM `+table+` WHERE message_id = ?`,
-		env.MessageID,
+		`DELETE FROM `+table+` WHERE message_id IN (`+sqlutil.Placeholders(len(envs))+`)`,
+		args...,
 	)
 
 	return err
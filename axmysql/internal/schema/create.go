@@ -1,44 +1,46 @@
 package schema
 
 import (
+	"context"
 	"database/sql"
-	"io/ioutil"
 	"regexp"
-	"strings"
+
+	"github.com/jmalloc/ax/axmysql/internal/sqlutil"
+	"github.com/jmalloc/ax/axmysql/migration"
 )
 
-// Create executes DDL queries from the given SQL file.
+// Create creates the tables for the given component from scratch, for use in
+// tests.
 //
 // It uses a very naive regexp pattern to identify 'CREATE TABLE' queries in
-// order to first drop the table if it already exists.
-//
-// If you find yourself expanding the behavior of this function its probably
-// time to stop and look for a proper schema management solution.
-func Create(db *sql.DB, filename string) error {
-	buf, err := ioutil.ReadFile(filename)
-	if err != nil {
-		return err
-	}
-
-	str := string(buf)
-
-	for _, q := range strings.Split(str, ";") {
-		if strings.TrimSpace(q) == "" {
-			continue
-		}
-
-		if m := createTablePattern.FindStringSubmatch(q); m != nil {
-			if _, err := db.Exec(`DROP TABLE IF EXISTS ` + m[1]); err != nil {
-				return err
+// order to first drop the table if it already exists. It then applies all of
+// the component's migrations.
+func Create(db *sql.DB, c migration.Component) error {
+	for _, m := range c.Migrations {
+		for _, q := range m.Statements {
+			if m := createTablePattern.FindStringSubmatch(q); m != nil {
+				if _, err := db.Exec(`DROP TABLE IF EXISTS ` + m[1]); err != nil {
+					return err
+				}
 			}
 		}
+	}
 
-		if _, err := db.Exec(q); err != nil {
-			return err
-		}
+	// apply an empty set of components to ensure the version table exists
+	ctx := context.Background()
+	if _, err := migration.Apply(ctx, db, ""); err != nil {
+		return err
+	}
+
+	if _, err := db.Exec(
+		`DELETE FROM `+sqlutil.Table("", "schema_version")+` WHERE component = ?`,
+		c.Name,
+	); err != nil {
+		return err
 	}
 
-	return nil
+	_, err := migration.Apply(ctx, db, "", c)
+	return err
 }
 
-var createTablePattern = regexp.MustCompile(`(?i)CREATE\s+TABLE.*?([A-Z_]+)\s+\(`)
+var createTablePattern = regexp.MustCompile(`(?i)CREATE\s+TABLE.*?([A-Z0-9_.$]+)\s+\(`)
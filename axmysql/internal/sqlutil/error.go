@@ -1,22 +1,45 @@
 package sqlutil
 
 import (
+	"errors"
+
 	"github.com/go-sql-driver/mysql"
 )
 
 const (
-	mysqlDupEntry = 1062 // https://dev.mysql.com/doc/refman/5.5/en/error-messages-server.html#error_er_dup_entry
-	mysqlDeadLock = 1213 // https://dev.mysql.com/doc/refman/5.5/en/error-messages-server.html#error_er_lock_deadlock
+	mysqlDupEntry        = 1062 // https://dev.mysql.com/doc/refman/5.5/en/error-messages-server.html#error_er_dup_entry
+	mysqlDeadLock        = 1213 // https://dev.mysql.com/doc/refman/5.5/en/error-messages-server.html#error_er_lock_deadlock
+	mysqlLockWaitTimeout = 1205 // https://dev.mysql.com/doc/refman/5.5/en/error-messages-server.html#error_er_lock_wait_timeout
 )
 
 // IsDuplicateEntry returns true if err represents a MySQL duplicate entry error.
 func IsDuplicateEntry(err error) bool {
-	e, ok := err.(*mysql.MySQLError)
-	return ok && e.Number == mysqlDupEntry
+	return isError(err, mysqlDupEntry)
 }
 
 // IsDeadlock returns true if err represents a MySQL deadlock condition.
 func IsDeadlock(err error) bool {
-	e, ok := err.(*mysql.MySQLError)
-	return ok && e.Number == mysqlDeadLock
+	return isError(err, mysqlDeadLock)
+}
+
+// IsLockWaitTimeout returns true if err represents a MySQL lock wait timeout.
+func IsLockWaitTimeout(err error) bool {
+	return isError(err, mysqlLockWaitTimeout)
+}
+
+// IsTransient returns true if err represents a MySQL error that is likely to be
+// resolved by retrying the transaction.
+//
+// MySQL rolls back the entire transaction when a deadlock is detected, but only
+// the current statement when a lock wait timeout occurs. In both cases the
+// transaction must be retried from the beginning.
+func IsTransient(err error) bool {
+	return IsDeadlock(err) || IsLockWaitTimeout(err)
+}
+
+// isError returns true if err is, or wraps, a MySQL error with the given
+// error number.
+func isError(err error, n uint16) bool {
+	var e *mysql.MySQLError
+	return errors.As(err, &e) && e.Number == n
 }
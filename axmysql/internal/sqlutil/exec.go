@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 )
 
 // ExecSingleRow executes a query without returning any rows and verifies that
@@ -61,3 +62,9 @@ func ExecInsertOrUpdate(
 
 	return n == 1, err
 }
+
+// Placeholders returns a comma-separated list of n query placeholders, for use
+// within an "IN" clause.
+func Placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
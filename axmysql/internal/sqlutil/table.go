@@ -0,0 +1,17 @@
+package sqlutil
+
+// DefaultTablePrefix is the prefix used for the names of Ax's tables when no
+// other prefix is specified.
+const DefaultTablePrefix = "ax_"
+
+// Table returns the name of the table with the given name and prefix.
+//
+// If prefix is empty, DefaultTablePrefix is used. The prefix may include a
+// schema name, such as "billing.ax_".
+func Table(prefix, name string) string {
+	if prefix == "" {
+		prefix = DefaultTablePrefix
+	}
+
+	return prefix + name
+}
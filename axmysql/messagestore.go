@@ -6,4 +6,7 @@ import (
 )
 
 // MessageStore is a message store backed by a MySQL database.
+//
+// It also implements messagestore.QueryableStore and
+// messagestore.RetentionStore.
 var MessageStore messagestore.GloballyOrderedStore = mysqlmessagestore.Store{}
@@ -3,28 +3,30 @@ package messagestore
 import (
 	"context"
 	"database/sql"
+	"strings"
 
 	"github.com/jmalloc/ax"
 	"github.com/jmalloc/ax/axmysql/internal/sqlutil"
 	"github.com/jmalloc/ax/marshaling"
+	"github.com/jmalloc/ax/messagestore"
 )
 
 // insertStream inserts a new stream and returns its ID.
 //
-// s is the name of the stream, n is the initial value of the "next" offset.
+// name is the name of the stream, n is the initial value of the "next" offset.
 // It returns false if there is already a stream by this name.
-func insertStream(
+func (s Store) insertStream(
 	ctx context.Context,
 	tx *sql.Tx,
-	s string,
+	name string,
 	n uint64,
 ) (int64, bool, error) {
 	res, err := tx.ExecContext(
 		ctx,
-		`INSERT INTO ax_messagestore_stream SET
+		`INSERT INTO `+s.table("messagestore_stream")+` SET
 			name = ?,
 			next = ?`,
-		s,
+		name,
 		n,
 	)
 	if err != nil {
@@ -39,50 +41,64 @@ func insertStream(
 	return id, true, err
 }
 
-// incrStreamOffset increments the offset for the given stream by n.
+// lockStream returns the ID and the next unused offset of the given stream,
+// locking the stream's row until tx ends.
 //
-// s is the name of the stream. It returns the stream's ID.
-// It returns false if o is not the next free offset.
-func incrStreamOffset(
+// name is the name of the stream. It returns false if the stream does not
+// exist. It returns a *messagestore.DeletedStreamError if the stream has been
+// deleted.
+func (s Store) lockStream(
 	ctx context.Context,
 	tx *sql.Tx,
-	s string,
-	o uint64,
-	n uint64,
-) (int64, bool, error) {
+	name string,
+) (int64, uint64, bool, error) {
 	var (
-		id   int64
-		next uint64
+		id      int64
+		next    uint64
+		deleted bool
 	)
 
 	err := tx.QueryRowContext(
 		ctx,
 		`SELECT
 			stream_id,
-			next
-		FROM ax_messagestore_stream
+			next,
+			deleted
+		FROM `+s.table("messagestore_stream")+`
 		WHERE name = ?
 		FOR UPDATE`, // ensure stream row is locked at this revision
-		s,
+		name,
 	).Scan(
 		&id,
 		&next,
+		&deleted,
 	)
 
 	if err == sql.ErrNoRows {
-		return 0, false, nil
+		return 0, 0, false, nil
 	} else if err != nil {
-		return 0, false, err
+		return 0, 0, false, err
 	}
 
-	if o != next {
-		return 0, false, nil
+	if deleted {
+		return 0, 0, false, &messagestore.DeletedStreamError{Stream: name}
 	}
 
-	return id, true, sqlutil.ExecSingleRow(
+	return id, next, true, nil
+}
+
+// incrStreamOffset increments the offset for the stream with the given ID by
+// n. The stream's row must already be locked by tx.
+func (s Store) incrStreamOffset(
+	ctx context.Context,
+	tx *sql.Tx,
+	id int64,
+	n uint64,
+) error {
+	return sqlutil.ExecSingleRow(
 		ctx,
 		tx,
-		`UPDATE ax_messagestore_stream SET
+		`UPDATE `+s.table("messagestore_stream")+` SET
 			next = next + ?
 		WHERE stream_id = ?`,
 		n,
@@ -90,54 +106,184 @@ func incrStreamOffset(
 	)
 }
 
-// incrGlobalOffset increments the global stream offset by n.
+// loadMessageIDs returns the IDs of the messages in the stream with the given
+// ID, keyed by stream offset.
 //
-// It returns the offset before it was incremented.
-func incrGlobalOffset(
+// o is the offset of the first message to load, n is the number of messages
+// to load.
+func (s Store) loadMessageIDs(
 	ctx context.Context,
 	tx *sql.Tx,
+	id int64,
+	o uint64,
 	n uint64,
-) (uint64, error) {
-	// insert or update both cause the row to be locked in tx
-	inserted, err := sqlutil.ExecInsertOrUpdate(
+) (map[uint64]string, error) {
+	rows, err := tx.QueryContext(
 		ctx,
-		tx,
-		`INSERT INTO ax_messagestore_offset SET
-			next = ?
-		ON DUPLICATE KEY UPDATE
-			next = next + VALUE(next)`,
-		n,
+		`SELECT
+			stream_offset,
+			message_id
+		FROM `+s.table("messagestore_message")+`
+		WHERE stream_id = ?
+		AND stream_offset >= ?
+		AND stream_offset < ?`,
+		id,
+		o,
+		o+n,
 	)
 	if err != nil {
-		return 0, nil
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := map[uint64]string{}
+
+	for rows.Next() {
+		var (
+			offset uint64
+			mid    string
+		)
+
+		if err := rows.Scan(&offset, &mid); err != nil {
+			return nil, err
+		}
+
+		ids[offset] = mid
+	}
+
+	return ids, rows.Err()
+}
+
+// checkDuplicates returns a *messagestore.DuplicateMessageError if any of envs
+// have the same message ID as a message that is already stored, or as another
+// message in envs.
+//
+// stream and o are the name of the stream and the offset at which envs are to
+// be appended.
+func (s Store) checkDuplicates(
+	ctx context.Context,
+	tx *sql.Tx,
+	stream string,
+	o uint64,
+	envs []ax.Envelope,
+) error {
+	offsets := map[string]uint64{}
+
+	for i, env := range envs {
+		id := env.MessageID.Get()
+
+		if prev, ok := offsets[id]; ok {
+			return &messagestore.DuplicateMessageError{
+				MessageID:    env.MessageID,
+				Stream:       stream,
+				StreamOffset: prev,
+			}
+		}
+
+		offsets[id] = o + uint64(i)
 	}
 
-	if inserted {
-		return 0, nil
+	id, name, offset, ok, err := s.findMessage(ctx, tx, envs)
+	if !ok || err != nil {
+		return err
+	}
+
+	return &messagestore.DuplicateMessageError{
+		MessageID:    id,
+		Stream:       name,
+		StreamOffset: offset,
 	}
+}
+
+// findMessage returns the position of a message that has one of the given
+// message IDs. It returns false if none of the messages are stored.
+func (s Store) findMessage(
+	ctx context.Context,
+	tx *sql.Tx,
+	envs []ax.Envelope,
+) (ax.MessageID, string, uint64, bool, error) {
+	var (
+		id     ax.MessageID
+		stream string
+		offset uint64
+	)
 
-	var next uint64
-	err = tx.QueryRowContext(
+	args := make([]interface{}, len(envs))
+	for i, env := range envs {
+		args[i] = env.MessageID
+	}
+
+	err := tx.QueryRowContext(
 		ctx,
 		`SELECT
-			next
-		FROM ax_messagestore_offset`,
+			m.message_id,
+			s.name,
+			m.stream_offset
+		FROM `+fetchTables(s.TablePrefix)+`
+		WHERE m.message_id IN (?`+strings.Repeat(", ?", len(envs)-1)+`)
+		LIMIT 1`,
+		args...,
 	).Scan(
-		&next,
+		&id,
+		&stream,
+		&offset,
 	)
 
-	return next - n, err
+	if err == sql.ErrNoRows {
+		return ax.MessageID{}, "", 0, false, nil
+	} else if err != nil {
+		return ax.MessageID{}, "", 0, false, err
+	}
+
+	return id, stream, offset, true, nil
+}
+
+// duplicateMessageError returns a *messagestore.DuplicateMessageError for env,
+// which could not be inserted because a message with the same ID is already
+// stored.
+//
+// It uses a locking read so that the message is visible even if it was
+// committed after tx's snapshot was established.
+func (s Store) duplicateMessageError(
+	ctx context.Context,
+	tx *sql.Tx,
+	env ax.Envelope,
+) error {
+	e := &messagestore.DuplicateMessageError{
+		MessageID: env.MessageID,
+	}
+
+	err := tx.QueryRowContext(
+		ctx,
+		`SELECT
+			s.name,
+			m.stream_offset
+		FROM `+fetchTables(s.TablePrefix)+`
+		WHERE m.message_id = ?
+		LOCK IN SHARE MODE`,
+		env.MessageID,
+	).Scan(
+		&e.Stream,
+		&e.StreamOffset,
+	)
+
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	return e
 }
 
 // insertMessage inserts a message into the store.
 //
-// id is the stream ID. g and o are the global and stream offsets of the
-// message, respectively.
-func insertMessage(
+// id is the stream ID, c is the message's category and o is the stream offset of
+// the message. The message's global offset is assigned in the background
+// after it is committed.
+func (s Store) insertMessage(
 	ctx context.Context,
 	tx *sql.Tx,
 	id int64,
-	g uint64,
+	c string,
 	o uint64,
 	env ax.Envelope,
 ) error {
@@ -155,11 +301,12 @@ func insertMessage(
 
 	_, err = tx.ExecContext(
 		ctx,
-		`INSERT INTO ax_messagestore_message SET
-			global_offset = ?,
+		`INSERT INTO `+s.table("messagestore_message")+` SET
 			stream_id = ?,
+			category = ?,
 			stream_offset = ?,
 			description = ?,
+			message_type = ?,
 			message_id = ?,
 			causation_id = ?,
 			correlation_id = ?,
@@ -167,10 +314,11 @@ func insertMessage(
 			send_at = ?,
 			content_type = ?,
 			data = ?`,
-		g,
 		id,
+		c,
 		o,
 		descr,
+		env.Type().Name,
 		env.MessageID,
 		env.CausationID,
 		env.CorrelationID,
@@ -0,0 +1,79 @@
+package messagestore_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"testing"
+
+	"github.com/jmalloc/ax"
+	"github.com/jmalloc/ax/axmysql"
+	"github.com/jmalloc/ax/axmysql/internal/schema"
+	. "github.com/jmalloc/ax/axmysql/messagestore"
+	"github.com/jmalloc/ax/axtest/testmessages"
+)
+
+// BenchmarkAppendMessages measures the throughput of appends to distinct
+// streams at increasing levels of concurrency.
+//
+// Appends to different streams do not contend for a lock on the global offset,
+// so throughput should increase with concurrency until the database itself is
+// saturated.
+func BenchmarkAppendMessages(b *testing.B) {
+	dsn := os.Getenv("AX_MYSQL_DSN")
+	if dsn == "" {
+		b.Skip("AX_MYSQL_DSN is not set")
+	}
+
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer db.Close()
+
+	var store Store
+	ds := axmysql.NewDataStore(db)
+	ctx := context.Background()
+	env := ax.NewEnvelope(&testmessages.MessageA{Value: "<value>"})
+
+	var streams uint64
+
+	for _, p := range []int{1, 4, 16} {
+		b.Run(fmt.Sprintf("parallelism=%d", p), func(b *testing.B) {
+			if err := schema.Create(db, store.Migrations()); err != nil {
+				b.Fatal(err)
+			}
+
+			b.SetParallelism(p)
+			b.ResetTimer()
+
+			b.RunParallel(func(pb *testing.PB) {
+				stream := fmt.Sprintf("<stream-%d>", atomic.AddUint64(&streams, 1))
+				offset := uint64(0)
+
+				for pb.Next() {
+					tx, com, err := ds.BeginTx(ctx)
+					if err != nil {
+						b.Error(err)
+						return
+					}
+
+					if err := store.AppendMessages(ctx, tx, stream, offset, []ax.Envelope{env}); err != nil {
+						com.Rollback()
+						b.Error(err)
+						return
+					}
+
+					if err := com.Commit(); err != nil {
+						b.Error(err)
+						return
+					}
+
+					offset++
+				}
+			})
+		})
+	}
+}
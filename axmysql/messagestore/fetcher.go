@@ -3,16 +3,36 @@ package messagestore
 import (
 	"context"
 	"database/sql"
+
+	"github.com/jmalloc/ax"
+	"github.com/jmalloc/ax/axmysql/internal/sqlutil"
 )
 
-// fetchColumns is the ordered list of columns that must be SELECTed by fetchers.
-const fetchColumns = `message_id,
-					  causation_id,
-					  correlation_id,
-					  created_at,
-					  send_at,
-					  content_type,
-					  data`
+// fetchColumns is the ordered list of columns that must be SELECTed by fetchers,
+// after the column that contains the offset of the message.
+//
+// The message table is aliased as "m" and the stream table as "s". The insert
+// time is selected as the number of microseconds since the Unix epoch, so that
+// it does not depend on the session's time zone.
+const fetchColumns = `m.global_offset,
+					  m.stream_offset,
+					  s.name,
+					  m.category,
+					  CAST(UNIX_TIMESTAMP(m.insert_time) * 1000000 AS SIGNED),
+					  m.message_id,
+					  m.causation_id,
+					  m.correlation_id,
+					  m.created_at,
+					  m.send_at,
+					  m.content_type,
+					  m.data`
+
+// fetchTables returns the FROM clause used by fetchers.
+func fetchTables(prefix string) string {
+	return sqlutil.Table(prefix, "messagestore_message") + ` AS m
+		INNER JOIN ` + sqlutil.Table(prefix, "messagestore_stream") + ` AS s
+		ON s.stream_id = m.stream_id`
+}
 
 // Fetcher is an interface for fetching rows from the message store
 type Fetcher interface {
@@ -22,19 +42,20 @@ type Fetcher interface {
 
 // StreamFetcher is a fetcher that fetches rows for a specific stream.
 type StreamFetcher struct {
-	DB       *sql.DB
-	StreamID int64
+	DB          *sql.DB
+	TablePrefix string
+	StreamID    int64
 }
 
 // FetchRows fetches the n rows beginning at the given offset.
 func (f *StreamFetcher) FetchRows(ctx context.Context, offset, n uint64) (*sql.Rows, error) {
 	return f.DB.QueryContext(
 		ctx,
-		`SELECT `+fetchColumns+`
-		FROM ax_messagestore_message
-		WHERE stream_id = ?
-		AND stream_offset >= ?
-		ORDER BY stream_offset
+		`SELECT m.stream_offset, `+fetchColumns+`
+		FROM `+fetchTables(f.TablePrefix)+`
+		WHERE m.stream_id = ?
+		AND m.stream_offset >= ?
+		ORDER BY m.stream_offset
 		LIMIT ?`,
 		f.StreamID,
 		offset,
@@ -42,21 +63,99 @@ func (f *StreamFetcher) FetchRows(ctx context.Context, offset, n uint64) (*sql.R
 	)
 }
 
-// GlobalFetcher is a fetcher that fetches rows for the entire store
+// GlobalFetcher is a fetcher that fetches rows for the entire store.
+//
+// If Types is non-empty, only messages of those types are fetched. If
+// SequenceDB is non-nil, any committed messages that have not been assigned a
+// global offset are sequenced using SequenceDB before each fetch.
 type GlobalFetcher struct {
-	DB *sql.DB
+	DB          *sql.DB
+	SequenceDB  *sql.DB
+	TablePrefix string
+	Types       ax.MessageTypeSet
 }
 
 // FetchRows fetches the n rows beginning at the given offset.
 func (f *GlobalFetcher) FetchRows(ctx context.Context, offset, n uint64) (*sql.Rows, error) {
-	return f.DB.QueryContext(
+	if err := sequenceBeforeFetch(ctx, f.SequenceDB, f.TablePrefix); err != nil {
+		return nil, err
+	}
+
+	return fetchGlobal(ctx, f.DB, f.TablePrefix, "", nil, f.Types, offset, n)
+}
+
+// CategoryFetcher is a fetcher that fetches rows for all streams in a
+// category, in global order.
+//
+// If Types is non-empty, only messages of those types are fetched. If
+// SequenceDB is non-nil, any committed messages that have not been assigned a
+// global offset are sequenced using SequenceDB before each fetch.
+type CategoryFetcher struct {
+	DB          *sql.DB
+	SequenceDB  *sql.DB
+	TablePrefix string
+	Category    string
+	Types       ax.MessageTypeSet
+}
+
+// FetchRows fetches the n rows beginning at the given global offset.
+func (f *CategoryFetcher) FetchRows(ctx context.Context, offset, n uint64) (*sql.Rows, error) {
+	if err := sequenceBeforeFetch(ctx, f.SequenceDB, f.TablePrefix); err != nil {
+		return nil, err
+	}
+
+	return fetchGlobal(
 		ctx,
-		`SELECT `+fetchColumns+`
-		FROM ax_messagestore_message
-		WHERE global_offset >= ?
-		ORDER BY global_offset
-		LIMIT ?`,
+		f.DB,
+		f.TablePrefix,
+		` AND m.category = ?`,
+		[]interface{}{f.Category},
+		f.Types,
 		offset,
 		n,
 	)
 }
+
+// sequenceBeforeFetch assigns global offsets to any messages that were
+// committed but not sequenced, for example because the process that appended
+// them crashed, so that readers of the global order make progress even if no
+// further messages are appended. It does nothing if db is nil.
+func sequenceBeforeFetch(ctx context.Context, db *sql.DB, prefix string) error {
+	if db == nil {
+		return nil
+	}
+
+	return Store{TablePrefix: prefix}.sequence(ctx, db)
+}
+
+// fetchGlobal fetches the n rows beginning at the given global offset that
+// match the given additional WHERE condition and message types.
+func fetchGlobal(
+	ctx context.Context,
+	db *sql.DB,
+	prefix string,
+	cond string,
+	args []interface{},
+	types ax.MessageTypeSet,
+	offset, n uint64,
+) (*sql.Rows, error) {
+	query := `SELECT m.global_offset, ` + fetchColumns + `
+		FROM ` + fetchTables(prefix) + `
+		WHERE m.global_offset >= ?` + cond
+	args = append([]interface{}{offset}, args...)
+
+	if types.Len() != 0 {
+		query += ` AND m.message_type IN (` + sqlutil.Placeholders(types.Len()) + `)`
+
+		for _, mt := range types.Members() {
+			args = append(args, mt.Name)
+		}
+	}
+
+	query += `
+		ORDER BY m.global_offset
+		LIMIT ?`
+	args = append(args, n)
+
+	return db.QueryContext(ctx, query, args...)
+}
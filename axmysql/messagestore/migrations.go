@@ -0,0 +1,167 @@
+package messagestore
+
+import "github.com/jmalloc/ax/axmysql/migration"
+
+// Migrations returns the schema migrations for the message store tables.
+func (s Store) Migrations() migration.Component {
+	return migration.Component{
+		Name: s.table("messagestore"),
+		Migrations: []migration.Migration{
+			{
+				Version:     1,
+				Description: "create the message store tables",
+				Statements: []string{
+					// ax_messagestore_offset stores the next global message offset across all streams.
+					//
+					// It uses an ENUM field with a single value as the primary key to
+					// ensure there can only ever be a single row.
+					`CREATE TABLE IF NOT EXISTS ` + s.table("messagestore_offset") + ` (
+						_    ENUM('') NOT NULL PRIMARY KEY DEFAULT '',
+						next BIGINT UNSIGNED NOT NULL DEFAULT 0
+					)`,
+
+					// ax_messagestore_stream contains the streams that exist within the message store.
+					//
+					// next is the next unused offset on the stream.
+					`CREATE TABLE IF NOT EXISTS ` + s.table("messagestore_stream") + ` (
+						stream_id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+						name      VARBINARY(255) NOT NULL UNIQUE,
+						next      BIGINT UNSIGNED NOT NULL,
+
+						PRIMARY KEY (stream_id)
+					) ROW_FORMAT=COMPRESSED`,
+
+					// ax_messagestore_message contains the messages on each stream.
+					//
+					// The primary key includes the insert_time to allow for time-based
+					// partitioning.
+					`CREATE TABLE IF NOT EXISTS ` + s.table("messagestore_message") + ` (
+						global_offset  BIGINT UNSIGNED NOT NULL,
+						insert_time    TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+
+						stream_id      BIGINT UNSIGNED NOT NULL,
+						stream_offset  BIGINT UNSIGNED NOT NULL,
+						description    VARBINARY(255) NOT NULL,
+						message_id     VARBINARY(255) NOT NULL,
+						causation_id   VARBINARY(255) NOT NULL,
+						correlation_id VARBINARY(255) NOT NULL,
+						created_at     VARBINARY(255) NOT NULL,
+						send_at        VARBINARY(255) NOT NULL,
+						content_type   VARBINARY(255) NOT NULL,
+						data           LONGBLOB NOT NULL,
+
+						PRIMARY KEY (global_offset, insert_time),
+						INDEX (stream_id, stream_offset),
+						INDEX (message_id),
+						INDEX (causation_id),
+						INDEX (correlation_id)
+					) ROW_FORMAT=COMPRESSED`,
+				},
+			},
+			{
+				Version:     2,
+				Description: "assign global offsets after messages are committed",
+				Statements: []string{
+					// message_seq is allocated when a message is inserted. It does not
+					// reflect the order in which messages are committed, and may contain
+					// gaps.
+					//
+					// global_offset is NULL until the message is committed and
+					// "sequenced", at which point it is assigned the next global offset.
+					// The unique index permits any number of unsequenced messages, but
+					// prevents two messages from ever sharing a global offset.
+					`ALTER TABLE ` + s.table("messagestore_message") + `
+						DROP PRIMARY KEY,
+						MODIFY global_offset BIGINT UNSIGNED NULL,
+						ADD COLUMN message_seq BIGINT UNSIGNED NOT NULL AUTO_INCREMENT FIRST,
+						ADD PRIMARY KEY (message_seq, insert_time),
+						ADD UNIQUE INDEX (global_offset)`,
+				},
+			},
+			{
+				Version:     3,
+				Description: "add the message type column",
+				Statements: append(
+					// message_type is the fully-qualified Protocol Buffers name of the
+					// message type. It allows readers of the global stream to filter
+					// messages by type without unmarshaling them.
+					migration.UnlessColumnExists(
+						s.table("messagestore_message"),
+						"message_type",
+						`ALTER TABLE `+s.table("messagestore_message")+`
+							ADD COLUMN message_type VARBINARY(255) NOT NULL DEFAULT '',
+							ADD INDEX (message_type, global_offset)`,
+					),
+
+					// the message type of existing messages is obtained from the "proto"
+					// parameter of their content type.
+					`UPDATE `+s.table("messagestore_message")+` SET
+						message_type = SUBSTRING_INDEX(content_type, 'proto=', -1)`,
+				),
+			},
+			{
+				Version:     4,
+				Description: "index messages by insert time",
+				Statements: []string{
+					`ALTER TABLE ` + s.table("messagestore_message") + `
+						ADD INDEX (insert_time)`,
+				},
+			},
+			{
+				Version:     5,
+				Description: "add the stream category column",
+				Statements: append(
+					// category is the category that the message was appended to, which
+					// is the portion of the stream name before the last colon unless
+					// the appender specified otherwise. It allows the messages in a
+					// category to be read in global order.
+					migration.UnlessColumnExists(
+						s.table("messagestore_message"),
+						"category",
+						`ALTER TABLE `+s.table("messagestore_message")+`
+							ADD COLUMN category VARBINARY(255) NOT NULL DEFAULT '',
+							ADD INDEX (category, global_offset)`,
+					),
+
+					`UPDATE `+s.table("messagestore_message")+` AS m
+						INNER JOIN `+s.table("messagestore_stream")+` AS s
+						ON s.stream_id = m.stream_id
+						SET m.category = IF(
+							LOCATE(':', s.name) = 0,
+							'',
+							LEFT(s.name, LENGTH(s.name) - LENGTH(SUBSTRING_INDEX(s.name, ':', -1)) - 1)
+						)
+						WHERE m.category = ''`,
+				),
+			},
+			{
+				Version:     6,
+				Description: "add the stream metadata columns",
+				Statements: []string{
+					// deleted, truncate_before, max_age and max_count contain the
+					// stream's metadata. max_age is measured in microseconds. A stream
+					// that has metadata but no messages has a row with a next offset
+					// of zero.
+					`ALTER TABLE ` + s.table("messagestore_stream") + `
+						ADD COLUMN deleted         BOOLEAN NOT NULL DEFAULT FALSE,
+						ADD COLUMN truncate_before BIGINT UNSIGNED NOT NULL DEFAULT 0,
+						ADD COLUMN max_age         BIGINT UNSIGNED NOT NULL DEFAULT 0,
+						ADD COLUMN max_count       BIGINT UNSIGNED NOT NULL DEFAULT 0,
+						ADD INDEX (truncate_before)`,
+				},
+			},
+			{
+				Version:     7,
+				Description: "prevent the same message from being stored more than once",
+				Statements: []string{
+					// the unique index detects concurrent appends of the same message to
+					// different streams, which can not be detected by querying for
+					// existing messages before they are inserted.
+					`ALTER TABLE ` + s.table("messagestore_message") + `
+						DROP INDEX message_id,
+						ADD UNIQUE INDEX (message_id)`,
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,116 @@
+package messagestore_test
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"time"
+
+	"github.com/jmalloc/ax"
+	"github.com/jmalloc/ax/axmysql"
+	"github.com/jmalloc/ax/axmysql/internal/schema"
+	. "github.com/jmalloc/ax/axmysql/messagestore"
+	"github.com/jmalloc/ax/axmysql/migration"
+	"github.com/jmalloc/ax/axtest/testmessages"
+	"github.com/jmalloc/ax/messagestore"
+	"github.com/jmalloc/ax/persistence"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Store (migrations)", func() {
+	dsn := os.Getenv("AX_MYSQL_DSN")
+
+	var (
+		ctx    context.Context
+		cancel func()
+		db     *sql.DB
+		store  Store
+	)
+
+	// reapply simulates a failure to record the version of a migration after
+	// its statements were executed, then applies the migration again.
+	reapply := func(v uint64) error {
+		c := store.Migrations()
+
+		if _, err := db.ExecContext(
+			ctx,
+			`UPDATE ax_schema_version SET version = ? WHERE component = ?`,
+			v-1,
+			c.Name,
+		); err != nil {
+			return err
+		}
+
+		c.Migrations = c.Migrations[:v]
+		_, err := migration.Apply(ctx, db, "", c)
+		return err
+	}
+
+	BeforeEach(func() {
+		var fn func()
+		ctx, fn = context.WithTimeout(context.Background(), 10*time.Second)
+		cancel = fn // defeat go vet warning about unused cancel func
+
+		var err error
+		db, err = sql.Open("mysql", dsn)
+		if err != nil {
+			panic(err)
+		}
+
+		if err := schema.Create(db, store.Migrations()); err != nil {
+			panic(err)
+		}
+	})
+
+	AfterEach(func() {
+		cancel()
+
+		if err := db.Close(); err != nil {
+			panic(err)
+		}
+	})
+
+	fn := Describe
+	if dsn == "" {
+		fn = XDescribe
+	}
+
+	fn("Migrations", func() {
+		It("can apply version 3 again", func() {
+			err := reapply(3)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		It("can apply version 5 again", func() {
+			err := reapply(5)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		It("does not replace the category given by the appender when version 5 is applied again", func() {
+			ds := axmysql.NewDataStore(db)
+			env := ax.NewEnvelope(&testmessages.MessageA{})
+
+			err := persistence.Atomically(
+				persistence.WithDataStore(messagestore.WithCategory(ctx, "<category>"), ds),
+				func(ctx context.Context, tx persistence.Tx) error {
+					return store.AppendMessages(ctx, tx, "<stream>:1", 0, []ax.Envelope{env})
+				},
+			)
+			Expect(err).ShouldNot(HaveOccurred())
+			waitForSequencing(ctx, db)
+
+			err = reapply(5)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			var c string
+			err = db.QueryRowContext(
+				ctx,
+				`SELECT category FROM ax_messagestore_message WHERE message_id = ?`,
+				env.MessageID.Get(),
+			).Scan(&c)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(c).To(Equal("<category>"))
+		})
+	})
+})
@@ -0,0 +1,149 @@
+package messagestore
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jmalloc/ax"
+	mysqlpersistence "github.com/jmalloc/ax/axmysql/persistence"
+	"github.com/jmalloc/ax/messagestore"
+	"github.com/jmalloc/ax/persistence"
+)
+
+// LoadMessage loads the message with the given message ID.
+//
+// It returns false if the message does not exist. The message is read from
+// the data store's replica, if it has one.
+func (s Store) LoadMessage(
+	ctx context.Context,
+	ds persistence.DataStore,
+	id ax.MessageID,
+) (messagestore.StoredMessage, bool, error) {
+	messages, err := s.query(
+		ctx,
+		ds,
+		`WHERE m.message_id = ?
+		ORDER BY m.message_seq
+		LIMIT 1`,
+		id,
+	)
+	if err != nil || len(messages) == 0 {
+		return messagestore.StoredMessage{}, false, err
+	}
+
+	return messages[0], true, nil
+}
+
+// LoadMessagesByCorrelationID loads all messages with the given correlation
+// ID, in the order they were inserted.
+//
+// The messages are read from the data store's replica, if it has one.
+func (s Store) LoadMessagesByCorrelationID(
+	ctx context.Context,
+	ds persistence.DataStore,
+	id ax.MessageID,
+) ([]messagestore.StoredMessage, error) {
+	return s.query(
+		ctx,
+		ds,
+		`WHERE m.correlation_id = ?
+		ORDER BY m.message_seq`,
+		id,
+	)
+}
+
+// LoadMessagesByCausationID loads all messages that were caused by the message
+// with the given ID, in the order they were inserted.
+//
+// The messages are read from the data store's replica, if it has one.
+func (s Store) LoadMessagesByCausationID(
+	ctx context.Context,
+	ds persistence.DataStore,
+	id ax.MessageID,
+) ([]messagestore.StoredMessage, error) {
+	return s.query(
+		ctx,
+		ds,
+		`WHERE m.causation_id = ?
+		ORDER BY m.message_seq`,
+		id,
+	)
+}
+
+// LoadMessagesByInsertTime loads the messages that were inserted at or after
+// begin, and before end, in the order they were inserted.
+//
+// At most limit messages are returned. If limit is zero, all messages within
+// the time range are returned. The messages are read from the data store's
+// replica, if it has one.
+func (s Store) LoadMessagesByInsertTime(
+	ctx context.Context,
+	ds persistence.DataStore,
+	begin, end time.Time,
+	limit uint64,
+) ([]messagestore.StoredMessage, error) {
+	// the times are passed as microseconds since the Unix epoch, and converted
+	// to the session's time zone by FROM_UNIXTIME(), so that they are compared
+	// to insert_time in the same time zone. Only whole seconds are converted by
+	// FROM_UNIXTIME(), as adding an interval to the epoch in the session's
+	// time zone does not account for changes to its offset, such as DST.
+	query := `WHERE m.insert_time >= FROM_UNIXTIME(? DIV 1000000) + INTERVAL ? MOD 1000000 MICROSECOND
+		AND m.insert_time < FROM_UNIXTIME(? DIV 1000000) + INTERVAL ? MOD 1000000 MICROSECOND
+		ORDER BY m.insert_time, m.message_seq`
+
+	b := begin.UnixNano() / int64(time.Microsecond)
+	e := end.UnixNano() / int64(time.Microsecond)
+	args := []interface{}{b, b, e, e}
+
+	if limit != 0 {
+		query += ` LIMIT ?`
+		args = append(args, limit)
+	}
+
+	return s.query(ctx, ds, query, args...)
+}
+
+// query selects the messages that match the given WHERE clause, which may
+// include ORDER BY and LIMIT clauses.
+func (s Store) query(
+	ctx context.Context,
+	ds persistence.DataStore,
+	where string,
+	args ...interface{},
+) ([]messagestore.StoredMessage, error) {
+	rows, err := mysqlpersistence.ExtractReplicaDB(ds).QueryContext(
+		ctx,
+		`SELECT `+fetchColumns+`
+		FROM `+fetchTables(s.TablePrefix)+`
+		`+where,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanStoredMessages(rows)
+}
+
+// scanStoredMessages scans all of the rows in rows into stored messages.
+func scanStoredMessages(rows *sql.Rows) ([]messagestore.StoredMessage, error) {
+	var messages []messagestore.StoredMessage
+
+	for rows.Next() {
+		r, err := scanRow(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		m, err := r.stored()
+		if err != nil {
+			return nil, err
+		}
+
+		messages = append(messages, m)
+	}
+
+	return messages, rows.Err()
+}
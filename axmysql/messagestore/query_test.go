@@ -0,0 +1,230 @@
+package messagestore_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jmalloc/ax"
+	"github.com/jmalloc/ax/axmysql"
+	"github.com/jmalloc/ax/axmysql/internal/schema"
+	. "github.com/jmalloc/ax/axmysql/messagestore"
+	"github.com/jmalloc/ax/axtest"
+	"github.com/jmalloc/ax/axtest/testmessages"
+	"github.com/jmalloc/ax/messagestore"
+	"github.com/jmalloc/ax/persistence"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Store (queries)", func() {
+	dsn := os.Getenv("AX_MYSQL_DSN")
+
+	var (
+		ctx                            context.Context
+		cancel                         func()
+		db                             *sql.DB
+		ds                             persistence.DataStore
+		store                          Store
+		root, child, grandchild, other ax.Envelope
+	)
+
+	appendMessages := func(stream string, offset uint64, envs ...ax.Envelope) error {
+		tx, com, err := ds.BeginTx(ctx)
+		if err != nil {
+			return err
+		}
+		defer com.Rollback()
+
+		if err := store.AppendMessages(ctx, tx, stream, offset, envs); err != nil {
+			return err
+		}
+
+		if err := com.Commit(); err != nil {
+			return err
+		}
+
+		waitForSequencing(ctx, db)
+
+		return nil
+	}
+
+	envelopes := func(messages []messagestore.StoredMessage) []ax.Envelope {
+		var envs []ax.Envelope
+		for _, m := range messages {
+			envs = append(envs, m.Envelope)
+		}
+		return envs
+	}
+
+	BeforeEach(func() {
+		var fn func()
+		ctx, fn = context.WithTimeout(context.Background(), 10*time.Second)
+		cancel = fn // defeat go vet warning about unused cancel func
+
+		var err error
+		db, err = sql.Open("mysql", dsn)
+		if err != nil {
+			panic(err)
+		}
+
+		if err := schema.Create(db, store.Migrations()); err != nil {
+			panic(err)
+		}
+
+		ds = axmysql.NewDataStore(db)
+
+		root = ax.NewEnvelope(&testmessages.MessageA{Value: "<root>"})
+		child = root.NewChild(&testmessages.MessageB{Value: "<child>"})
+		grandchild = child.NewChild(&testmessages.MessageC{Value: "<grandchild>"})
+		other = ax.NewEnvelope(&testmessages.MessageA{Value: "<other>"})
+
+		err = appendMessages("<stream-1>", 0, root)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		err = appendMessages("<stream-2>", 0, child, grandchild)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		err = appendMessages("<stream-3>", 0, other)
+		Expect(err).ShouldNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		cancel()
+
+		if err := db.Close(); err != nil {
+			panic(err)
+		}
+	})
+
+	fn := Describe
+	if dsn == "" {
+		fn = XDescribe
+	}
+
+	fn("LoadMessage", func() {
+		It("returns the message with the given ID, along with its position within the store", func() {
+			m, ok, err := store.LoadMessage(ctx, ds, grandchild.MessageID)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(ok).To(BeTrue())
+			Expect(axtest.EnvelopesEqual(m.Envelope, grandchild)).To(BeTrue())
+			Expect(m.Stream).To(Equal("<stream-2>"))
+			Expect(m.StreamOffset).To(BeNumerically("==", 1))
+			Expect(m.GlobalOffset).To(BeNumerically("==", 2))
+			Expect(m.HasGlobalOffset).To(BeTrue())
+		})
+
+		It("returns false if the message does not exist", func() {
+			_, ok, err := store.LoadMessage(ctx, ds, ax.GenerateMessageID())
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(ok).To(BeFalse())
+		})
+	})
+
+	fn("LoadMessagesByCorrelationID", func() {
+		It("returns all messages in the correlation chain", func() {
+			messages, err := store.LoadMessagesByCorrelationID(ctx, ds, root.MessageID)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(axtest.ConsistsOfEnvelopes(envelopes(messages), root, child, grandchild)).To(BeTrue())
+		})
+	})
+
+	fn("LoadMessagesByCausationID", func() {
+		It("returns the messages caused by the given message", func() {
+			messages, err := store.LoadMessagesByCausationID(ctx, ds, child.MessageID)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(axtest.ConsistsOfEnvelopes(envelopes(messages), grandchild)).To(BeTrue())
+		})
+	})
+
+	fn("LoadMessagesByInsertTime", func() {
+		It("returns the messages inserted within the time range", func() {
+			now := time.Now()
+
+			messages, err := store.LoadMessagesByInsertTime(
+				ctx,
+				ds,
+				now.Add(-time.Minute),
+				now.Add(time.Minute),
+				0,
+			)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(axtest.ConsistsOfEnvelopes(envelopes(messages), root, child, grandchild, other)).To(BeTrue())
+		})
+
+		It("does not return messages inserted outside the time range", func() {
+			now := time.Now()
+
+			messages, err := store.LoadMessagesByInsertTime(
+				ctx,
+				ds,
+				now.Add(time.Minute),
+				now.Add(2*time.Minute),
+				0,
+			)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(messages).To(BeEmpty())
+		})
+
+		It("returns the messages inserted within the time range when the session time zone is not UTC", func() {
+			// the offset of Asia/Singapore has changed since the Unix epoch.
+			sep := "?"
+			if strings.Contains(dsn, "?") {
+				sep = "&"
+			}
+
+			tzdb, err := sql.Open("mysql", dsn+sep+"time_zone=%27Asia%2FSingapore%27")
+			Expect(err).ShouldNot(HaveOccurred())
+			defer tzdb.Close()
+
+			if err := tzdb.PingContext(ctx); err != nil {
+				var e *mysql.MySQLError
+				if errors.As(err, &e) && e.Number == 1298 { // ER_UNKNOWN_TIME_ZONE
+					Skip("the MySQL server does not have time zone data")
+				}
+				Expect(err).ShouldNot(HaveOccurred())
+			}
+
+			ds = axmysql.NewDataStore(tzdb)
+
+			env := ax.NewEnvelope(&testmessages.MessageA{Value: "<value>"})
+			err = appendMessages("<stream-4>", 0, env)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			now := time.Now()
+
+			messages, err := store.LoadMessagesByInsertTime(
+				ctx,
+				ds,
+				now.Add(-5*time.Second),
+				now.Add(5*time.Second),
+				0,
+			)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			var ids []ax.MessageID
+			for _, m := range messages {
+				ids = append(ids, m.Envelope.MessageID)
+			}
+			Expect(ids).To(ContainElement(env.MessageID))
+		})
+
+		It("returns at most limit messages", func() {
+			now := time.Now()
+
+			messages, err := store.LoadMessagesByInsertTime(
+				ctx,
+				ds,
+				now.Add(-time.Minute),
+				now.Add(time.Minute),
+				2,
+			)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(messages).To(HaveLen(2))
+		})
+	})
+})
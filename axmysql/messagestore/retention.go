@@ -0,0 +1,305 @@
+package messagestore
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	mysqlpersistence "github.com/jmalloc/ax/axmysql/persistence"
+	"github.com/jmalloc/ax/messagestore"
+	"github.com/jmalloc/ax/persistence"
+)
+
+// LoadStreamMetadata loads the metadata for a stream.
+//
+// It returns the zero-value if the stream has no metadata, including when the
+// stream does not exist. The stream's row is locked until ptx ends, so that
+// the metadata can be modified safely.
+func (s Store) LoadStreamMetadata(
+	ctx context.Context,
+	ptx persistence.Tx,
+	stream string,
+) (messagestore.StreamMetadata, error) {
+	tx := mysqlpersistence.ExtractTx(ptx)
+
+	var (
+		md     messagestore.StreamMetadata
+		maxAge int64
+	)
+
+	err := tx.QueryRowContext(
+		ctx,
+		`SELECT
+			deleted,
+			truncate_before,
+			max_age,
+			max_count
+		FROM `+s.table("messagestore_stream")+`
+		WHERE name = ?
+		FOR UPDATE`,
+		stream,
+	).Scan(
+		&md.Deleted,
+		&md.TruncateBefore,
+		&maxAge,
+		&md.MaxCount,
+	)
+
+	if err == sql.ErrNoRows {
+		return messagestore.StreamMetadata{}, nil
+	} else if err != nil {
+		return messagestore.StreamMetadata{}, err
+	}
+
+	md.MaxAge = time.Duration(maxAge) * time.Microsecond
+
+	return md, nil
+}
+
+// SaveStreamMetadata saves the metadata for a stream.
+//
+// The stream need not exist. Changes that would restore a deleted stream,
+// decrease TruncateBefore, or advance it beyond the end of the stream are
+// ignored.
+func (s Store) SaveStreamMetadata(
+	ctx context.Context,
+	ptx persistence.Tx,
+	stream string,
+	md messagestore.StreamMetadata,
+) error {
+	tx := mysqlpersistence.ExtractTx(ptx)
+
+	_, err := tx.ExecContext(
+		ctx,
+		`INSERT INTO `+s.table("messagestore_stream")+` SET
+			name = ?,
+			next = 0,
+			deleted = ?,
+			truncate_before = 0,
+			max_age = ?,
+			max_count = ?
+		ON DUPLICATE KEY UPDATE
+			deleted = deleted OR VALUES(deleted),
+			truncate_before = LEAST(GREATEST(truncate_before, ?), next),
+			max_age = VALUES(max_age),
+			max_count = VALUES(max_count)`,
+		stream,
+		md.Deleted,
+		md.MaxAge.Nanoseconds()/int64(time.Microsecond),
+		md.MaxCount,
+		md.TruncateBefore,
+	)
+
+	return err
+}
+
+// retentionBatchSize is the maximum number of streams that have their maximum
+// age and count applied within a single transaction.
+const retentionBatchSize = 100
+
+// RemoveExpiredMessages removes up to n messages that are no longer retained
+// according to the metadata of their streams. It returns the number of
+// messages removed.
+//
+// The truncation offsets of streams that have a maximum age or count are
+// advanced before messages are removed. The truncation offsets are advanced in
+// batches of streams, each within its own transaction, so that the streams are
+// not locked for the duration of ptx.
+func (s Store) RemoveExpiredMessages(
+	ctx context.Context,
+	ptx persistence.Tx,
+	n int,
+) (int, error) {
+	db := mysqlpersistence.ExtractDB(ptx.DataStore())
+	tx := mysqlpersistence.ExtractTx(ptx)
+
+	if err := s.applyRetention(ctx, db); err != nil {
+		return 0, err
+	}
+
+	seqs, err := s.selectExpired(ctx, tx, n)
+	if err != nil || len(seqs) == 0 {
+		return 0, err
+	}
+
+	args := make([]interface{}, len(seqs))
+	for i, seq := range seqs {
+		args[i] = seq
+	}
+
+	res, err := tx.ExecContext(
+		ctx,
+		`DELETE FROM `+s.table("messagestore_message")+`
+		WHERE message_seq IN (?`+strings.Repeat(", ?", len(seqs)-1)+`)`,
+		args...,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	count, err := res.RowsAffected()
+	return int(count), err
+}
+
+// applyRetention advances the truncation offsets of streams that have a
+// maximum age or a maximum message count.
+//
+// The streams are visited in order of their ID, in batches of up to
+// retentionBatchSize streams.
+func (s Store) applyRetention(ctx context.Context, db *sql.DB) error {
+	var after int64
+
+	for {
+		ids, err := s.applyRetentionBatch(ctx, db, after)
+		if err != nil {
+			return err
+		}
+
+		if len(ids) < retentionBatchSize {
+			return nil
+		}
+
+		after = ids[len(ids)-1]
+	}
+}
+
+// applyRetentionBatch advances the truncation offsets of up to
+// retentionBatchSize streams with IDs greater than after, within a single
+// transaction. It returns the IDs of the streams in the batch.
+func (s Store) applyRetentionBatch(
+	ctx context.Context,
+	db *sql.DB,
+	after int64,
+) ([]int64, error) {
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelReadCommitted})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	ids, err := s.selectIDs(
+		ctx,
+		tx,
+		`SELECT
+			stream_id
+		FROM `+s.table("messagestore_stream")+`
+		WHERE stream_id > ?
+		AND (max_count != 0 OR max_age != 0)
+		AND NOT deleted
+		ORDER BY stream_id
+		LIMIT ?`,
+		after,
+		retentionBatchSize,
+	)
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+
+	in := `(?` + strings.Repeat(", ?", len(ids)-1) + `)`
+
+	if _, err := tx.ExecContext(
+		ctx,
+		`UPDATE `+s.table("messagestore_stream")+` SET
+			truncate_before = next - max_count
+		WHERE stream_id IN `+in+`
+		AND max_count != 0
+		AND NOT deleted
+		AND next > truncate_before + max_count`,
+		args...,
+	); err != nil {
+		return nil, err
+	}
+
+	// the stream is truncated before its earliest message that has not expired,
+	// or at the end of the stream if all of its messages have expired.
+	if _, err := tx.ExecContext(
+		ctx,
+		`UPDATE `+s.table("messagestore_stream")+` AS s SET
+			s.truncate_before = COALESCE(
+				(
+					SELECT MIN(m.stream_offset)
+					FROM `+s.table("messagestore_message")+` AS m
+					WHERE m.stream_id = s.stream_id
+					AND m.stream_offset >= s.truncate_before
+					AND m.insert_time >= NOW(6) - INTERVAL s.max_age MICROSECOND
+				),
+				s.next
+			)
+		WHERE s.stream_id IN `+in+`
+		AND s.max_age != 0
+		AND NOT s.deleted`,
+		args...,
+	); err != nil {
+		return nil, err
+	}
+
+	return ids, tx.Commit()
+}
+
+// selectExpired returns the sequence numbers of up to n messages that belong
+// to deleted streams, or are before the truncation offset of their stream.
+func (s Store) selectExpired(ctx context.Context, tx *sql.Tx, n int) ([]int64, error) {
+	seqs, err := s.selectIDs(
+		ctx,
+		tx,
+		`SELECT
+			m.message_seq
+		FROM `+fetchTables(s.TablePrefix)+`
+		WHERE s.deleted
+		LIMIT ?`,
+		n,
+	)
+	if err != nil || len(seqs) == n {
+		return seqs, err
+	}
+
+	truncated, err := s.selectIDs(
+		ctx,
+		tx,
+		`SELECT
+			m.message_seq
+		FROM `+fetchTables(s.TablePrefix)+`
+		AND m.stream_offset < s.truncate_before
+		WHERE s.truncate_before != 0
+		AND NOT s.deleted
+		LIMIT ?`,
+		n-len(seqs),
+	)
+
+	return append(seqs, truncated...), err
+}
+
+// selectIDs returns the integer IDs selected by the given query, such as
+// message sequence numbers or stream IDs.
+func (s Store) selectIDs(
+	ctx context.Context,
+	tx *sql.Tx,
+	q string,
+	args ...interface{},
+) ([]int64, error) {
+	rows, err := tx.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+
+	for rows.Next() {
+		var id int64
+
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
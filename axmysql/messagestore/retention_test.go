@@ -0,0 +1,335 @@
+package messagestore_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/jmalloc/ax"
+	"github.com/jmalloc/ax/axmysql"
+	"github.com/jmalloc/ax/axmysql/internal/schema"
+	. "github.com/jmalloc/ax/axmysql/messagestore"
+	"github.com/jmalloc/ax/axtest"
+	"github.com/jmalloc/ax/axtest/testmessages"
+	"github.com/jmalloc/ax/messagestore"
+	"github.com/jmalloc/ax/persistence"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Store (retention)", func() {
+	dsn := os.Getenv("AX_MYSQL_DSN")
+
+	var (
+		ctx              context.Context
+		cancel           func()
+		db               *sql.DB
+		ds               persistence.DataStore
+		store            Store
+		env1, env2, env3 ax.Envelope
+	)
+
+	atomically := func(fn func(tx persistence.Tx) error) error {
+		tx, com, err := ds.BeginTx(ctx)
+		if err != nil {
+			return err
+		}
+		defer com.Rollback()
+
+		if err := fn(tx); err != nil {
+			return err
+		}
+
+		return com.Commit()
+	}
+
+	appendMessages := func(stream string, offset uint64, envs ...ax.Envelope) error {
+		if err := atomically(func(tx persistence.Tx) error {
+			return store.AppendMessages(ctx, tx, stream, offset, envs)
+		}); err != nil {
+			return err
+		}
+
+		waitForSequencing(ctx, db)
+
+		return nil
+	}
+
+	saveMetadata := func(stream string, md messagestore.StreamMetadata) {
+		err := atomically(func(tx persistence.Tx) error {
+			return store.SaveStreamMetadata(ctx, tx, stream, md)
+		})
+		Expect(err).ShouldNot(HaveOccurred())
+	}
+
+	loadMetadata := func(stream string) messagestore.StreamMetadata {
+		var md messagestore.StreamMetadata
+
+		err := atomically(func(tx persistence.Tx) error {
+			var err error
+			md, err = store.LoadStreamMetadata(ctx, tx, stream)
+			return err
+		})
+		Expect(err).ShouldNot(HaveOccurred())
+
+		return md
+	}
+
+	removeExpired := func(n int) int {
+		var count int
+
+		err := atomically(func(tx persistence.Tx) error {
+			var err error
+			count, err = store.RemoveExpiredMessages(ctx, tx, n)
+			return err
+		})
+		Expect(err).ShouldNot(HaveOccurred())
+
+		return count
+	}
+
+	readStream := func(stream string) []ax.Envelope {
+		s, ok, err := store.OpenStream(ctx, ds, stream, 0)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(ok).To(BeTrue())
+		defer s.Close()
+
+		var envs []ax.Envelope
+
+		for {
+			ok, err := s.TryNext(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			if !ok {
+				return envs
+			}
+
+			env, err := s.Get(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			envs = append(envs, env)
+		}
+	}
+
+	countMessages := func() int {
+		var n int
+		err := db.QueryRowContext(
+			ctx,
+			`SELECT COUNT(*) FROM ax_messagestore_message`,
+		).Scan(&n)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		return n
+	}
+
+	BeforeEach(func() {
+		var fn func()
+		ctx, fn = context.WithTimeout(context.Background(), 10*time.Second)
+		cancel = fn // defeat go vet warning about unused cancel func
+
+		var err error
+		db, err = sql.Open("mysql", dsn)
+		if err != nil {
+			panic(err)
+		}
+
+		if err := schema.Create(db, store.Migrations()); err != nil {
+			panic(err)
+		}
+
+		ds = axmysql.NewDataStore(db)
+
+		env1 = ax.NewEnvelope(&testmessages.MessageA{Value: "<foo>"})
+		env2 = ax.NewEnvelope(&testmessages.MessageB{Value: "<bar>"})
+		env3 = ax.NewEnvelope(&testmessages.MessageC{Value: "<baz>"})
+
+		err = appendMessages("<stream>", 0, env1, env2, env3)
+		Expect(err).ShouldNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		cancel()
+
+		if err := db.Close(); err != nil {
+			panic(err)
+		}
+	})
+
+	fn := Describe
+	if dsn == "" {
+		fn = XDescribe
+	}
+
+	fn("LoadStreamMetadata", func() {
+		It("returns the zero-value if the stream does not exist", func() {
+			md := loadMetadata("<unknown>")
+			Expect(md).To(Equal(messagestore.StreamMetadata{}))
+		})
+
+		It("returns the saved metadata", func() {
+			expected := messagestore.StreamMetadata{
+				TruncateBefore: 1,
+				MaxAge:         time.Hour,
+				MaxCount:       10,
+			}
+
+			saveMetadata("<stream>", expected)
+
+			md := loadMetadata("<stream>")
+			Expect(md).To(Equal(expected))
+		})
+	})
+
+	fn("SaveStreamMetadata", func() {
+		It("does not restore a deleted stream", func() {
+			saveMetadata("<stream>", messagestore.StreamMetadata{Deleted: true})
+			saveMetadata("<stream>", messagestore.StreamMetadata{})
+
+			md := loadMetadata("<stream>")
+			Expect(md.Deleted).To(BeTrue())
+		})
+
+		It("does not decrease the truncation offset", func() {
+			saveMetadata("<stream>", messagestore.StreamMetadata{TruncateBefore: 2})
+			saveMetadata("<stream>", messagestore.StreamMetadata{TruncateBefore: 1})
+
+			md := loadMetadata("<stream>")
+			Expect(md.TruncateBefore).To(BeNumerically("==", 2))
+		})
+
+		It("does not advance the truncation offset beyond the end of the stream", func() {
+			saveMetadata("<stream>", messagestore.StreamMetadata{TruncateBefore: 10})
+
+			md := loadMetadata("<stream>")
+			Expect(md.TruncateBefore).To(BeNumerically("==", 3))
+		})
+	})
+
+	fn("DeleteStream", func() {
+		BeforeEach(func() {
+			err := atomically(func(tx persistence.Tx) error {
+				return messagestore.DeleteStream(ctx, tx, store, "<stream>")
+			})
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		It("prevents messages from being appended to the stream", func() {
+			err := appendMessages("<stream>", 3, ax.NewEnvelope(&testmessages.MessageA{}))
+			Expect(messagestore.IsDeletedStream(err)).To(BeTrue())
+		})
+
+		It("prevents the stream from being opened", func() {
+			_, ok, err := store.OpenStream(ctx, ds, "<stream>", 0)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(ok).To(BeFalse())
+		})
+
+		It("prevents a stream that does not exist from being created", func() {
+			err := atomically(func(tx persistence.Tx) error {
+				return messagestore.DeleteStream(ctx, tx, store, "<unknown>")
+			})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = appendMessages("<unknown>", 0, ax.NewEnvelope(&testmessages.MessageA{}))
+			Expect(messagestore.IsDeletedStream(err)).To(BeTrue())
+		})
+	})
+
+	fn("TruncateStream", func() {
+		BeforeEach(func() {
+			err := atomically(func(tx persistence.Tx) error {
+				return messagestore.TruncateStream(ctx, tx, store, "<stream>", 2)
+			})
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		It("does not return messages before the truncation offset", func() {
+			envs := readStream("<stream>")
+			Expect(axtest.ConsistsOfEnvelopes(envs, env3)).To(BeTrue())
+		})
+
+		It("allows messages to be appended to the stream", func() {
+			env := ax.NewEnvelope(&testmessages.MessageA{})
+			err := appendMessages("<stream>", 3, env)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			envs := readStream("<stream>")
+			Expect(axtest.ConsistsOfEnvelopes(envs, env3, env)).To(BeTrue())
+		})
+	})
+
+	fn("RemoveExpiredMessages", func() {
+		BeforeEach(func() {
+			err := appendMessages("<other>", 0, ax.NewEnvelope(&testmessages.MessageA{}))
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		It("removes the messages in deleted streams", func() {
+			saveMetadata("<stream>", messagestore.StreamMetadata{Deleted: true})
+
+			Expect(removeExpired(10)).To(Equal(3))
+			Expect(countMessages()).To(Equal(1))
+		})
+
+		It("removes the messages before the truncation offset", func() {
+			saveMetadata("<stream>", messagestore.StreamMetadata{TruncateBefore: 2})
+
+			Expect(removeExpired(10)).To(Equal(2))
+			Expect(countMessages()).To(Equal(2))
+		})
+
+		It("removes messages in batches", func() {
+			saveMetadata("<stream>", messagestore.StreamMetadata{Deleted: true})
+
+			Expect(removeExpired(2)).To(Equal(2))
+			Expect(removeExpired(2)).To(Equal(1))
+			Expect(removeExpired(2)).To(Equal(0))
+		})
+
+		It("retains only the most recent messages in streams with a maximum count", func() {
+			saveMetadata("<stream>", messagestore.StreamMetadata{MaxCount: 1})
+
+			Expect(removeExpired(10)).To(Equal(2))
+
+			envs := readStream("<stream>")
+			Expect(axtest.ConsistsOfEnvelopes(envs, env3)).To(BeTrue())
+		})
+
+		It("applies the maximum count of streams in more than one batch", func() {
+			const streams = 150
+
+			for i := 0; i < streams; i++ {
+				stream := fmt.Sprintf("<stream-%d>", i)
+
+				err := appendMessages(
+					stream,
+					0,
+					ax.NewEnvelope(&testmessages.MessageA{}),
+					ax.NewEnvelope(&testmessages.MessageB{}),
+				)
+				Expect(err).ShouldNot(HaveOccurred())
+
+				saveMetadata(stream, messagestore.StreamMetadata{MaxCount: 1})
+			}
+
+			Expect(removeExpired(1000)).To(Equal(streams))
+			Expect(loadMetadata("<stream-149>").TruncateBefore).To(BeNumerically("==", 1))
+		})
+
+		It("removes messages that are older than the maximum age of their stream", func() {
+			saveMetadata("<stream>", messagestore.StreamMetadata{MaxAge: 100 * time.Millisecond})
+			time.Sleep(1100 * time.Millisecond)
+
+			Expect(removeExpired(10)).To(Equal(3))
+			Expect(loadMetadata("<stream>").TruncateBefore).To(BeNumerically("==", 3))
+		})
+
+		It("does not remove messages that are younger than the maximum age of their stream", func() {
+			saveMetadata("<stream>", messagestore.StreamMetadata{MaxAge: time.Hour})
+
+			Expect(removeExpired(10)).To(Equal(0))
+		})
+	})
+})
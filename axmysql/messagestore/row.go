@@ -0,0 +1,89 @@
+package messagestore
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/jmalloc/ax"
+	"github.com/jmalloc/ax/marshaling"
+	"github.com/jmalloc/ax/messagestore"
+)
+
+// row holds the columns of a message selected using fetchColumns.
+type row struct {
+	globalOffset sql.NullInt64
+	streamOffset uint64
+	stream       string
+	category     string
+	insertTime   int64 // microseconds since the Unix epoch
+	env          ax.Envelope
+	contentType  string
+	data         []byte
+	createdAt    string
+	sendAt       string
+}
+
+// scanRow scans the current row of rows into a new row value.
+//
+// dest is a list of additional destinations for columns that are selected
+// before fetchColumns.
+func scanRow(rows *sql.Rows, dest ...interface{}) (row, error) {
+	var r row
+
+	err := rows.Scan(
+		append(
+			dest,
+			&r.globalOffset,
+			&r.streamOffset,
+			&r.stream,
+			&r.category,
+			&r.insertTime,
+			&r.env.MessageID,
+			&r.env.CausationID,
+			&r.env.CorrelationID,
+			&r.createdAt,
+			&r.sendAt,
+			&r.contentType,
+			&r.data,
+		)...,
+	)
+
+	return r, err
+}
+
+// envelope returns the envelope containing the message in r.
+func (r *row) envelope() (ax.Envelope, error) {
+	env := r.env
+
+	err := marshaling.UnmarshalTime(r.createdAt, &env.CreatedAt)
+	if err != nil {
+		return ax.Envelope{}, err
+	}
+
+	err = marshaling.UnmarshalTime(r.sendAt, &env.SendAt)
+	if err != nil {
+		return ax.Envelope{}, err
+	}
+
+	env.Message, err = ax.UnmarshalMessage(r.contentType, r.data)
+
+	return env, err
+}
+
+// stored returns the message in r, along with its position within the store.
+func (r *row) stored() (messagestore.StoredMessage, error) {
+	env, err := r.envelope()
+	if err != nil {
+		return messagestore.StoredMessage{}, err
+	}
+
+	return messagestore.StoredMessage{
+		Envelope:        env,
+		Stream:          r.stream,
+		StreamOffset:    r.streamOffset,
+		Category:        r.category,
+		GlobalOffset:    uint64(r.globalOffset.Int64),
+		HasGlobalOffset: r.globalOffset.Valid,
+		InsertTime:      time.Unix(0, r.insertTime*int64(time.Microsecond)),
+	}, nil
+}
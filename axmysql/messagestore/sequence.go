@@ -0,0 +1,284 @@
+package messagestore
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/jmalloc/ax/axmysql/internal/sqlutil"
+	"github.com/jmalloc/twelf/src/twelf"
+)
+
+// DefaultSequenceTimeout is the maximum time spent by each background
+// assignment of global offsets to messages after they are appended.
+const DefaultSequenceTimeout = 10 * time.Second
+
+// sequenceBatchSize is the maximum number of messages that are assigned
+// global offsets within a single transaction.
+const sequenceBatchSize = 500
+
+// sequence assigns global offsets to messages that have been committed but
+// not yet sequenced.
+//
+// Messages are only visible to readers of the global stream once they are
+// sequenced. Because only committed messages are sequenced, and sequencing is
+// serialized by a lock on the global offset row, the global offsets are
+// contiguous and readers never observe a gap that is later filled.
+//
+// The messages on any given stream are always committed in order, so they are
+// also sequenced in order.
+func (s Store) sequence(ctx context.Context, db *sql.DB) error {
+	// avoid contending for the lock on the global offset row when there is
+	// nothing to sequence, as this is called each time readers poll.
+	ok, err := s.hasUnsequenced(ctx, db)
+	if !ok || err != nil {
+		return err
+	}
+
+	// sequencing within this process is serialized before the lock on the
+	// global offset row is acquired, so that the background sequencer and
+	// readers do not contend for the row lock, which would hold a connection
+	// for each waiter.
+	q := s.sequencer(db)
+
+	select {
+	case q.sem <- struct{}{}:
+		defer func() { <-q.sem }()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	for {
+		n, err := s.sequenceBatch(ctx, db)
+		if err != nil {
+			return err
+		}
+
+		if n < sequenceBatchSize {
+			return nil
+		}
+	}
+}
+
+// sequenceInBackground assigns global offsets to messages that have been
+// committed but not yet sequenced, without blocking the caller. Readers are
+// notified once the messages are sequenced.
+func (s Store) sequenceInBackground(db *sql.DB) {
+	q := s.sequencer(db)
+
+	q.m.Lock()
+	defer q.m.Unlock()
+
+	q.pending = true
+
+	if !q.running {
+		q.running = true
+		go q.run(s, db)
+	}
+}
+
+// sequencer returns the sequencer for the store's tables in db.
+func (s Store) sequencer(db *sql.DB) *sequencer {
+	k := sequencerKey{db, s.TablePrefix}
+
+	if v, ok := sequencers.Load(k); ok {
+		return v.(*sequencer)
+	}
+
+	v, _ := sequencers.LoadOrStore(
+		k,
+		&sequencer{sem: make(chan struct{}, 1)},
+	)
+
+	return v.(*sequencer)
+}
+
+// sequencers is a map of sequencerKey to the *sequencer for each database and
+// table prefix.
+var sequencers sync.Map
+
+// sequencerKey identifies the tables that a sequencer assigns global offsets
+// within.
+type sequencerKey struct {
+	db     *sql.DB
+	prefix string
+}
+
+// sequencer coordinates the assignment of global offsets within this process.
+//
+// At most one background goroutine runs for each sequencer. Appends that are
+// committed while it is running are sequenced by the same goroutine, in
+// batches, rather than each append waiting for its own turn on the global
+// offset row.
+type sequencer struct {
+	// sem is a semaphore that serializes calls to Store.sequence().
+	sem chan struct{}
+
+	m       sync.Mutex
+	running bool
+	pending bool
+}
+
+// run sequences messages until there are no further pending requests.
+func (q *sequencer) run(s Store, db *sql.DB) {
+	for q.next() {
+		ctx, cancel := context.WithTimeout(context.Background(), DefaultSequenceTimeout)
+		err := s.sequence(ctx, db)
+		cancel()
+
+		if err != nil {
+			twelf.Log(
+				s.Logger,
+				"unable to assign global offsets to appended messages: %s",
+				err,
+			)
+		}
+
+		s.notifier().Notify()
+	}
+}
+
+// next returns true if messages have been committed since the last call to
+// next(). Otherwise, it marks q as not running and returns false.
+func (q *sequencer) next() bool {
+	q.m.Lock()
+	defer q.m.Unlock()
+
+	if q.pending {
+		q.pending = false
+		return true
+	}
+
+	q.running = false
+	return false
+}
+
+// hasUnsequenced returns true if there are any committed messages that do not
+// yet have a global offset.
+func (s Store) hasUnsequenced(ctx context.Context, db *sql.DB) (bool, error) {
+	var ok bool
+
+	err := db.QueryRowContext(
+		ctx,
+		`SELECT EXISTS (
+			SELECT *
+			FROM `+s.table("messagestore_message")+`
+			WHERE global_offset IS NULL
+		)`,
+	).Scan(
+		&ok,
+	)
+
+	return ok, err
+}
+
+// sequenceBatch assigns global offsets to up to sequenceBatchSize messages
+// within a single transaction. It returns the number of messages sequenced.
+func (s Store) sequenceBatch(ctx context.Context, db *sql.DB) (int, error) {
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelReadCommitted})
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	next, err := s.lockGlobalOffset(ctx, tx)
+	if err != nil {
+		return 0, err
+	}
+
+	seqs, err := s.selectUnsequenced(ctx, tx)
+	if err != nil || len(seqs) == 0 {
+		return 0, err
+	}
+
+	for _, seq := range seqs {
+		if err := sqlutil.ExecSingleRow(
+			ctx,
+			tx,
+			`UPDATE `+s.table("messagestore_message")+` SET
+				global_offset = ?
+			WHERE message_seq = ?`,
+			next,
+			seq,
+		); err != nil {
+			return 0, err
+		}
+
+		next++
+	}
+
+	if _, err := tx.ExecContext(
+		ctx,
+		`UPDATE `+s.table("messagestore_offset")+` SET
+			next = ?`,
+		next,
+	); err != nil {
+		return 0, err
+	}
+
+	return len(seqs), tx.Commit()
+}
+
+// lockGlobalOffset locks the global offset row, creating it if necessary, and
+// returns the next unused global offset.
+func (s Store) lockGlobalOffset(ctx context.Context, tx *sql.Tx) (uint64, error) {
+	// insert or update both cause the row to be locked in tx
+	if _, err := tx.ExecContext(
+		ctx,
+		`INSERT INTO `+s.table("messagestore_offset")+` SET
+			next = 0
+		ON DUPLICATE KEY UPDATE
+			next = next`,
+	); err != nil {
+		return 0, err
+	}
+
+	var next uint64
+	err := tx.QueryRowContext(
+		ctx,
+		`SELECT
+			next
+		FROM `+s.table("messagestore_offset"),
+	).Scan(
+		&next,
+	)
+
+	return next, err
+}
+
+// selectUnsequenced returns the sequence numbers of up to sequenceBatchSize
+// committed messages that do not yet have a global offset, in the order they
+// were inserted.
+//
+// This is a non-locking read. Messages that are not yet committed are not
+// visible, and are sequenced by a later call once they are committed.
+func (s Store) selectUnsequenced(ctx context.Context, tx *sql.Tx) ([]uint64, error) {
+	rows, err := tx.QueryContext(
+		ctx,
+		`SELECT
+			message_seq
+		FROM `+s.table("messagestore_message")+`
+		WHERE global_offset IS NULL
+		ORDER BY message_seq
+		LIMIT ?`,
+		sequenceBatchSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var seqs []uint64
+
+	for rows.Next() {
+		var seq uint64
+		if err := rows.Scan(&seq); err != nil {
+			return nil, err
+		}
+
+		seqs = append(seqs, seq)
+	}
+
+	return seqs, rows.Err()
+}
@@ -3,23 +3,56 @@ package messagestore
 import (
 	"context"
 	"database/sql"
-	"fmt"
 
 	"github.com/jmalloc/ax"
+	"github.com/jmalloc/ax/axmysql/internal/sqlutil"
 	mysqlpersistence "github.com/jmalloc/ax/axmysql/persistence"
 	"github.com/jmalloc/ax/messagestore"
 	"github.com/jmalloc/ax/persistence"
+	"github.com/jmalloc/twelf/src/twelf"
 )
 
 // Store is a MySQL-backed implementation of Ax's
-// messagestore.GloballyOrderedStore interface.
-type Store struct{}
+// messagestore.GloballyOrderedStore, messagestore.QueryableStore and
+// messagestore.RetentionStore interfaces.
+type Store struct {
+	// TablePrefix is the prefix used for the names of the store's
+	// tables. It may include a schema name, such as "billing.ax_". If it is
+	// empty, "ax_" is used.
+	TablePrefix string
+
+	// Notifier is used to wake readers within this process when messages are
+	// appended, so they do not have to wait for the next poll. If it is nil, a
+	// notifier shared by all stores within this process is used.
+	Notifier messagestore.Notifier
+
+	// Logger is used to log errors that occur when assigning global offsets
+	// after messages are appended. If it is nil, twelf.DefaultLogger is used.
+	Logger twelf.Logger
+}
+
+// defaultNotifier is the notifier used by stores that do not specify one.
+var defaultNotifier = &messagestore.LocalNotifier{}
 
 // AppendMessages appends one or more messages to a named stream.
 //
-// offset is a zero-based index into the stream. An error is returned if
-// offset is not the next unused offset in the stream.
-func (Store) AppendMessages(
+// offset is a zero-based index into the stream. If offset is not the next
+// unused offset in the stream, it returns a *messagestore.ConflictError,
+// unless the messages already stored at offset have the same message IDs as
+// envs, in which case those messages are not appended again.
+//
+// It returns a *messagestore.DuplicateMessageError if a message with the same
+// ID as one of envs is already stored at some other position, including when
+// the same message is appended to different streams concurrently.
+//
+// It returns a *messagestore.DeletedStreamError if the stream has been
+// deleted.
+//
+// The messages are assigned global offsets in the background after ptx is
+// committed, so that concurrent appends to different streams do not contend
+// for a single lock, and the caller does not wait for global offsets to be
+// assigned.
+func (s Store) AppendMessages(
 	ctx context.Context,
 	ptx persistence.Tx,
 	stream string,
@@ -28,62 +61,107 @@ func (Store) AppendMessages(
 ) error {
 	tx := mysqlpersistence.ExtractTx(ptx)
 
-	n := uint64(len(envs))
+	id, next, exists, err := s.lockStream(ctx, tx, stream)
+	if err != nil {
+		return err
+	}
 
-	var (
-		id  int64
-		ok  bool
-		err error
-	)
+	if offset > next {
+		return &messagestore.ConflictError{Stream: stream, Offset: offset}
+	}
 
-	if offset == 0 {
-		id, ok, err = insertStream(ctx, tx, stream, n)
-	} else {
-		id, ok, err = incrStreamOffset(ctx, tx, stream, offset, n)
+	// skip any messages that are already stored at the expected offsets, as
+	// they have been appended by a prior attempt.
+	if offset < next && len(envs) > 0 {
+		n := next - offset
+		if n > uint64(len(envs)) {
+			n = uint64(len(envs))
+		}
+
+		ids, err := s.loadMessageIDs(ctx, tx, id, offset, n)
+		if err != nil {
+			return err
+		}
+
+		for _, env := range envs[:n] {
+			if ids[offset] != env.MessageID.Get() {
+				return &messagestore.ConflictError{Stream: stream, Offset: offset}
+			}
+
+			offset++
+		}
+
+		envs = envs[n:]
 	}
 
-	if err != nil {
+	if len(envs) == 0 {
+		return nil
+	}
+
+	if err := s.checkDuplicates(ctx, tx, stream, offset, envs); err != nil {
 		return err
 	}
 
-	if !ok {
-		// TODO: use OCC error https://github.com/jmalloc/ax/issues/93
-		return fmt.Errorf(
-			"can not append to stream %s, %d is not the next free offset",
-			stream,
-			offset,
-		)
+	n := uint64(len(envs))
+
+	if exists {
+		err = s.incrStreamOffset(ctx, tx, id, n)
+	} else {
+		var ok bool
+		id, ok, err = s.insertStream(ctx, tx, stream, n)
+
+		if err == nil && !ok {
+			// the stream was created by a concurrent transaction.
+			return &messagestore.ConflictError{Stream: stream, Offset: offset}
+		}
 	}
 
-	g, err := incrGlobalOffset(ctx, tx, n)
 	if err != nil {
 		return err
 	}
 
+	c := messagestore.StreamCategory(ctx, stream)
+
 	for _, env := range envs {
-		if err := insertMessage(
+		if err := s.insertMessage(
 			ctx,
 			tx,
 			id,
-			g,
+			c,
 			offset,
 			env,
 		); err != nil {
+			if sqlutil.IsDuplicateEntry(err) {
+				// the message was stored by a concurrent transaction after the
+				// check for duplicates was performed.
+				return s.duplicateMessageError(ctx, tx, env)
+			}
+
 			return err
 		}
 
-		g++
 		offset++
 	}
 
+	// assign global offsets in the background once the messages are visible
+	// to other transactions, so that the caller is not blocked by the lock on
+	// the global offset row. any messages that are not sequenced in the
+	// background, for example because of a crash, are sequenced when global
+	// and category streams next poll for messages.
+	db := mysqlpersistence.ExtractDB(ptx.DataStore())
+	ptx.AfterCommit(func() {
+		s.sequenceInBackground(db)
+	})
+
 	return nil
 }
 
 // OpenStream opens a stream of messages for reading from a specific offset.
 //
 // The offset may be past the end of the stream. It returns false if the stream
-// does not exist.
-func (Store) OpenStream(
+// does not exist, or has been deleted. If the stream has been truncated, the
+// messages before the truncation offset are not read.
+func (s Store) OpenStream(
 	ctx context.Context,
 	ds persistence.DataStore,
 	stream string,
@@ -91,56 +169,121 @@ func (Store) OpenStream(
 ) (messagestore.Stream, bool, error) {
 	db := mysqlpersistence.ExtractDB(ds)
 
-	id, ok, err := lookupStreamID(ctx, db, stream)
+	id, truncateBefore, ok, err := s.lookupStream(ctx, db, stream)
 	if !ok || err != nil {
 		return nil, false, err
 	}
 
+	if offset < truncateBefore {
+		offset = truncateBefore
+	}
+
 	return &Stream{
 		Fetcher: &StreamFetcher{
-			DB:       db,
-			StreamID: id,
+			DB:          db,
+			TablePrefix: s.TablePrefix,
+			StreamID:    id,
 		},
+		Notifier:   s.notifier(),
 		NextOffset: offset,
 	}, true, nil
 }
 
 // OpenGlobal opens the entire store for reading as a single stream.
 //
-// The offset may be beyond the end of the stream.
-func (Store) OpenGlobal(
+// The offset may be beyond the end of the stream. If types is non-empty, only
+// messages of those types are read. Messages are read from the data store's
+// replica, if it has one.
+func (s Store) OpenGlobal(
 	ctx context.Context,
 	ds persistence.DataStore,
 	offset uint64,
+	types ax.MessageTypeSet,
 ) (messagestore.Stream, error) {
 	return &Stream{
 		Fetcher: &GlobalFetcher{
-			DB: mysqlpersistence.ExtractDB(ds),
+			DB:          mysqlpersistence.ExtractReplicaDB(ds),
+			SequenceDB:  mysqlpersistence.ExtractDB(ds),
+			TablePrefix: s.TablePrefix,
+			Types:       types,
+		},
+		Notifier:   s.notifier(),
+		NextOffset: offset,
+	}, nil
+}
+
+// OpenCategory opens all messages in a category for reading as a single stream.
+// The category of each message is determined when it is appended, as per
+// messagestore.StreamCategory().
+//
+// The offset may be beyond the end of the stream. If types is non-empty, only
+// messages of those types are read. Messages are read from the data store's
+// replica, if it has one.
+func (s Store) OpenCategory(
+	ctx context.Context,
+	ds persistence.DataStore,
+	category string,
+	offset uint64,
+	types ax.MessageTypeSet,
+) (messagestore.Stream, error) {
+	return &Stream{
+		Fetcher: &CategoryFetcher{
+			DB:          mysqlpersistence.ExtractReplicaDB(ds),
+			SequenceDB:  mysqlpersistence.ExtractDB(ds),
+			TablePrefix: s.TablePrefix,
+			Category:    category,
+			Types:       types,
 		},
+		Notifier:   s.notifier(),
 		NextOffset: offset,
 	}, nil
 }
 
-// lookupStreamID returns the ID of the stream named s.
-func lookupStreamID(ctx context.Context, db *sql.DB, s string) (int64, bool, error) {
-	var id int64
+// notifier returns the notifier used to wake readers of the store.
+func (s Store) notifier() messagestore.Notifier {
+	if s.Notifier != nil {
+		return s.Notifier
+	}
+
+	return defaultNotifier
+}
+
+// lookupStream returns the ID of the stream named n, and the offset before
+// which it is truncated. It returns false if the stream does not exist or has
+// been deleted.
+func (s Store) lookupStream(ctx context.Context, db *sql.DB, n string) (int64, uint64, bool, error) {
+	var (
+		id             int64
+		truncateBefore uint64
+		deleted        bool
+	)
 
 	err := db.QueryRowContext(
 		ctx,
 		`SELECT
-			stream_id
-		FROM ax_messagestore_stream
+			stream_id,
+			truncate_before,
+			deleted
+		FROM `+s.table("messagestore_stream")+`
 		WHERE name = ?`,
-		s,
+		n,
 	).Scan(
 		&id,
+		&truncateBefore,
+		&deleted,
 	)
 
-	if err == sql.ErrNoRows {
-		return 0, false, nil
+	if err == sql.ErrNoRows || deleted {
+		return 0, 0, false, nil
 	} else if err != nil {
-		return 0, false, err
+		return 0, 0, false, err
 	}
 
-	return id, true, nil
+	return id, truncateBefore, true, nil
+}
+
+// table returns the name of the table with the given name, including the
+// table prefix.
+func (s Store) table(name string) string {
+	return sqlutil.Table(s.TablePrefix, name)
 }
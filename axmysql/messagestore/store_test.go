@@ -0,0 +1,494 @@
+package messagestore_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/jmalloc/ax"
+	"github.com/jmalloc/ax/axmysql"
+	"github.com/jmalloc/ax/axmysql/internal/schema"
+	. "github.com/jmalloc/ax/axmysql/messagestore"
+	"github.com/jmalloc/ax/axtest"
+	"github.com/jmalloc/ax/axtest/testmessages"
+	"github.com/jmalloc/ax/messagestore"
+	"github.com/jmalloc/ax/persistence"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Store", func() {
+	dsn := os.Getenv("AX_MYSQL_DSN")
+
+	var (
+		ctx              context.Context
+		cancel           func()
+		db               *sql.DB
+		ds               persistence.DataStore
+		store            Store
+		env1, env2, env3 ax.Envelope
+		env4, env5, env6 ax.Envelope
+	)
+
+	appendMessages := func(stream string, offset uint64, envs ...ax.Envelope) error {
+		tx, com, err := ds.BeginTx(ctx)
+		if err != nil {
+			return err
+		}
+		defer com.Rollback()
+
+		if err := store.AppendMessages(ctx, tx, stream, offset, envs); err != nil {
+			return err
+		}
+
+		if err := com.Commit(); err != nil {
+			return err
+		}
+
+		waitForSequencing(ctx, db)
+
+		return nil
+	}
+
+	readAll := func(s messagestore.Stream) ([]ax.Envelope, []uint64) {
+		var (
+			envs    []ax.Envelope
+			offsets []uint64
+		)
+
+		for {
+			ok, err := s.TryNext(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			if !ok {
+				return envs, offsets
+			}
+
+			env, err := s.Get(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			o, err := s.Offset()
+			Expect(err).ShouldNot(HaveOccurred())
+
+			envs = append(envs, env)
+			offsets = append(offsets, o)
+		}
+	}
+
+	BeforeEach(func() {
+		var fn func()
+		ctx, fn = context.WithTimeout(context.Background(), 10*time.Second)
+		cancel = fn // defeat go vet warning about unused cancel func
+
+		var err error
+		db, err = sql.Open("mysql", dsn)
+		if err != nil {
+			panic(err)
+		}
+
+		if err := schema.Create(db, store.Migrations()); err != nil {
+			panic(err)
+		}
+
+		ds = axmysql.NewDataStore(db)
+
+		env1 = ax.NewEnvelope(&testmessages.MessageA{Value: "<foo>"})
+		env2 = ax.NewEnvelope(&testmessages.MessageB{Value: "<bar>"})
+		env3 = ax.NewEnvelope(&testmessages.MessageC{Value: "<baz>"})
+		env4 = ax.NewEnvelope(&testmessages.MessageA{Value: "<qux>"})
+		env5 = ax.NewEnvelope(&testmessages.MessageB{Value: "<quux>"})
+		env6 = ax.NewEnvelope(&testmessages.MessageC{Value: "<corge>"})
+
+		err = appendMessages("<stream-1>", 0, env1)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		err = appendMessages("<stream-2>", 0, env2)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		err = appendMessages("<stream-1>", 1, env3)
+		Expect(err).ShouldNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		cancel()
+
+		if err := db.Close(); err != nil {
+			panic(err)
+		}
+	})
+
+	fn := Describe
+	if dsn == "" {
+		fn = XDescribe
+	}
+
+	fn("AppendMessages", func() {
+		It("returns a conflict error if the stream already exists and the offset is zero", func() {
+			err := appendMessages("<stream-1>", 0, env2)
+			Expect(messagestore.IsConflict(err)).To(BeTrue())
+		})
+
+		It("returns a conflict error if the offset is not the next free offset", func() {
+			err := appendMessages("<stream-1>", 1, env2)
+			Expect(messagestore.IsConflict(err)).To(BeTrue())
+
+			err = appendMessages("<stream-1>", 3, env2)
+			Expect(messagestore.IsConflict(err)).To(BeTrue())
+		})
+
+		It("does not append messages that are already stored at the same offsets", func() {
+			err := appendMessages("<stream-1>", 0, env1, env3)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = appendMessages("<stream-1>", 1, env3, env4)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			s, ok, err := store.OpenStream(ctx, ds, "<stream-1>", 0)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(ok).To(BeTrue())
+			defer s.Close()
+
+			envs, offsets := readAll(s)
+			Expect(axtest.ConsistsOfEnvelopes(envs, env1, env3, env4)).To(BeTrue())
+			Expect(offsets).To(Equal([]uint64{0, 1, 2}))
+		})
+
+		It("returns a duplicate message error if the message is already stored elsewhere", func() {
+			err := appendMessages("<stream-3>", 0, env4, env3)
+			Expect(err).To(Equal(&messagestore.DuplicateMessageError{
+				MessageID:    env3.MessageID,
+				Stream:       "<stream-1>",
+				StreamOffset: 1,
+			}))
+
+			err = appendMessages("<stream-3>", 0, env4, env4)
+			Expect(messagestore.IsDuplicateMessage(err)).To(BeTrue())
+		})
+
+		It("returns a duplicate message error if the message is stored elsewhere by a concurrent transaction", func() {
+			tx, com, err := ds.BeginTx(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+			defer com.Rollback()
+
+			// establish tx's snapshot before the message is stored elsewhere, so
+			// that it is not found when checking for duplicates.
+			err = store.AppendMessages(ctx, tx, "<stream-4>", 0, []ax.Envelope{env5})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = appendMessages("<stream-3>", 0, env4)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = store.AppendMessages(ctx, tx, "<stream-4>", 1, []ax.Envelope{env4})
+			Expect(err).To(Equal(&messagestore.DuplicateMessageError{
+				MessageID:    env4.MessageID,
+				Stream:       "<stream-3>",
+				StreamOffset: 0,
+			}))
+		})
+
+		It("assigns contiguous global offsets to concurrent appends", func() {
+			const streams, messages = 5, 10
+
+			var g sync.WaitGroup
+			g.Add(streams)
+
+			for i := 0; i < streams; i++ {
+				go func(stream string) {
+					defer GinkgoRecover()
+					defer g.Done()
+
+					for o := uint64(0); o < messages; o++ {
+						env := ax.NewEnvelope(&testmessages.MessageA{Value: stream})
+						err := appendMessages(stream, o, env)
+						Expect(err).ShouldNot(HaveOccurred())
+					}
+				}(fmt.Sprintf("<concurrent-%d>", i))
+			}
+
+			g.Wait()
+
+			s, err := store.OpenGlobal(ctx, ds, 0, ax.MessageTypeSet{})
+			Expect(err).ShouldNot(HaveOccurred())
+			defer s.Close()
+
+			envs, offsets := readAll(s)
+			Expect(envs).To(HaveLen(3 + streams*messages))
+
+			for i, o := range offsets {
+				Expect(o).To(BeNumerically("==", i))
+			}
+		})
+
+		It("does not allow two messages to share a global offset", func() {
+			_, err := db.ExecContext(
+				ctx,
+				`UPDATE ax_messagestore_message SET
+					global_offset = 0
+				WHERE message_id = ?`,
+				env2.MessageID.Get(),
+			)
+			Expect(err).Should(HaveOccurred())
+		})
+
+		It("does not block the caller while global offsets are assigned", func() {
+			// hold the lock on the global offset row, which must be acquired to
+			// assign global offsets.
+			tx, err := db.BeginTx(ctx, nil)
+			Expect(err).ShouldNot(HaveOccurred())
+			defer tx.Rollback()
+
+			_, err = tx.ExecContext(ctx, "SELECT `next` FROM ax_messagestore_offset FOR UPDATE")
+			Expect(err).ShouldNot(HaveOccurred())
+
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				defer close(done)
+
+				tx, com, err := ds.BeginTx(ctx)
+				Expect(err).ShouldNot(HaveOccurred())
+				defer com.Rollback()
+
+				err = store.AppendMessages(ctx, tx, "<stream-2>", 1, []ax.Envelope{env4})
+				Expect(err).ShouldNot(HaveOccurred())
+
+				err = com.Commit()
+				Expect(err).ShouldNot(HaveOccurred())
+			}()
+
+			Eventually(done).Should(BeClosed())
+
+			err = tx.Rollback()
+			Expect(err).ShouldNot(HaveOccurred())
+
+			waitForSequencing(ctx, db)
+		})
+	})
+
+	fn("OpenStream", func() {
+		It("returns false if the stream does not exist", func() {
+			_, ok, err := store.OpenStream(ctx, ds, "<unknown>", 0)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(ok).To(BeFalse())
+		})
+
+		It("returns the messages in the stream, with their stream offsets", func() {
+			s, ok, err := store.OpenStream(ctx, ds, "<stream-1>", 0)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(ok).To(BeTrue())
+			defer s.Close()
+
+			envs, offsets := readAll(s)
+			Expect(axtest.ConsistsOfEnvelopes(envs, env1, env3)).To(BeTrue())
+			Expect(offsets).To(Equal([]uint64{0, 1}))
+		})
+	})
+
+	fn("GetStored", func() {
+		It("returns the message along with its position within the store", func() {
+			s, err := store.OpenGlobal(ctx, ds, 2, ax.MessageTypeSet{})
+			Expect(err).ShouldNot(HaveOccurred())
+			defer s.Close()
+
+			err = s.Next(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			m, err := s.GetStored(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(axtest.EnvelopesEqual(m.Envelope, env3)).To(BeTrue())
+			Expect(m.Stream).To(Equal("<stream-1>"))
+			Expect(m.StreamOffset).To(BeNumerically("==", 1))
+			Expect(m.GlobalOffset).To(BeNumerically("==", 2))
+			Expect(m.HasGlobalOffset).To(BeTrue())
+			Expect(m.InsertTime).To(BeTemporally("~", time.Now(), 5*time.Second))
+		})
+	})
+
+	fn("OpenCategory", func() {
+		BeforeEach(func() {
+			err := appendMessages("account:1", 0, env4)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = appendMessages("account:2", 0, env5, env6)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		It("returns only messages from streams in the category, with their global offsets", func() {
+			s, err := store.OpenCategory(ctx, ds, "account", 0, ax.MessageTypeSet{})
+			Expect(err).ShouldNot(HaveOccurred())
+			defer s.Close()
+
+			envs, offsets := readAll(s)
+			Expect(axtest.ConsistsOfEnvelopes(envs, env4, env5, env6)).To(BeTrue())
+			Expect(offsets).To(Equal([]uint64{3, 4, 5}))
+		})
+
+		It("returns messages that were appended with the category in the context", func() {
+			env := ax.NewEnvelope(&testmessages.MessageA{Value: "<categorized>"})
+
+			err := persistence.Atomically(
+				persistence.WithDataStore(messagestore.WithCategory(ctx, "account"), ds),
+				func(ctx context.Context, tx persistence.Tx) error {
+					return store.AppendMessages(ctx, tx, "<stream>", 0, []ax.Envelope{env})
+				},
+			)
+			Expect(err).ShouldNot(HaveOccurred())
+			waitForSequencing(ctx, db)
+
+			s, err := store.OpenCategory(ctx, ds, "account", 0, ax.MessageTypeSet{})
+			Expect(err).ShouldNot(HaveOccurred())
+			defer s.Close()
+
+			envs, _ := readAll(s)
+			Expect(axtest.ConsistsOfEnvelopes(envs, env4, env5, env6, env)).To(BeTrue())
+		})
+
+		It("returns only messages of the given types", func() {
+			s, err := store.OpenCategory(
+				ctx,
+				ds,
+				"account",
+				0,
+				ax.TypesOf(&testmessages.MessageA{}, &testmessages.MessageC{}),
+			)
+			Expect(err).ShouldNot(HaveOccurred())
+			defer s.Close()
+
+			envs, offsets := readAll(s)
+			Expect(axtest.ConsistsOfEnvelopes(envs, env4, env6)).To(BeTrue())
+			Expect(offsets).To(Equal([]uint64{3, 5}))
+		})
+	})
+
+	fn("OpenGlobal", func() {
+		It("returns the messages in all streams, with their global offsets", func() {
+			s, err := store.OpenGlobal(ctx, ds, 1, ax.MessageTypeSet{})
+			Expect(err).ShouldNot(HaveOccurred())
+			defer s.Close()
+
+			envs, offsets := readAll(s)
+			Expect(axtest.ConsistsOfEnvelopes(envs, env2, env3)).To(BeTrue())
+			Expect(offsets).To(Equal([]uint64{1, 2}))
+		})
+
+		It("returns only messages of the given types, with their global offsets", func() {
+			s, err := store.OpenGlobal(
+				ctx,
+				ds,
+				0,
+				ax.TypesOf(&testmessages.MessageA{}, &testmessages.MessageC{}),
+			)
+			Expect(err).ShouldNot(HaveOccurred())
+			defer s.Close()
+
+			envs, offsets := readAll(s)
+			Expect(axtest.ConsistsOfEnvelopes(envs, env1, env3)).To(BeTrue())
+			Expect(offsets).To(Equal([]uint64{0, 2}))
+		})
+
+		It("returns from Next() as soon as a message is appended within the same process", func() {
+			s, err := store.OpenGlobal(ctx, ds, 3, ax.MessageTypeSet{})
+			Expect(err).ShouldNot(HaveOccurred())
+			defer s.Close()
+
+			// disable polling, so that only the notification can wake the stream
+			s.(*Stream).PollInterval = time.Hour
+
+			go func() {
+				defer GinkgoRecover()
+
+				time.Sleep(10 * time.Millisecond)
+				err := appendMessages("<stream-2>", 1, env4)
+				Expect(err).ShouldNot(HaveOccurred())
+			}()
+
+			err = s.Next(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			env, err := s.Get(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(axtest.EnvelopesEqual(env, env4)).To(BeTrue())
+		})
+
+		It("does not return messages that are not yet committed", func() {
+			tx, com, err := ds.BeginTx(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+			defer com.Rollback()
+
+			err = store.AppendMessages(
+				ctx,
+				tx,
+				"<stream-3>",
+				0,
+				[]ax.Envelope{ax.NewEnvelope(&testmessages.MessageA{})},
+			)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = appendMessages("<stream-2>", 1, env4)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			s, err := store.OpenGlobal(ctx, ds, 0, ax.MessageTypeSet{})
+			Expect(err).ShouldNot(HaveOccurred())
+			defer s.Close()
+
+			envs, offsets := readAll(s)
+			Expect(axtest.ConsistsOfEnvelopes(envs, env1, env2, env3, env4)).To(BeTrue())
+			Expect(offsets).To(Equal([]uint64{0, 1, 2, 3}))
+		})
+
+		It("returns messages that were committed but not sequenced without waiting for another append", func() {
+			s, err := store.OpenGlobal(ctx, ds, 3, ax.MessageTypeSet{})
+			Expect(err).ShouldNot(HaveOccurred())
+			defer s.Close()
+
+			ok, err := s.TryNext(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(ok).To(BeFalse())
+
+			err = appendMessages("<stream-2>", 1, env4)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			// simulate a crash between committing the append and sequencing it
+			_, err = db.ExecContext(
+				ctx,
+				`UPDATE ax_messagestore_message SET
+					global_offset = NULL
+				WHERE message_id = ?`,
+				env4.MessageID.Get(),
+			)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			_, err = db.ExecContext(
+				ctx,
+				"UPDATE ax_messagestore_offset SET `next` = `next` - 1",
+			)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			envs, offsets := readAll(s)
+			Expect(axtest.ConsistsOfEnvelopes(envs, env4)).To(BeTrue())
+			Expect(offsets).To(Equal([]uint64{3}))
+		})
+	})
+})
+
+// waitForSequencing waits until global offsets have been assigned to all of
+// the committed messages in db, which occurs in the background after each
+// append is committed.
+func waitForSequencing(ctx context.Context, db *sql.DB) {
+	Eventually(func() (bool, error) {
+		var ok bool
+		err := db.QueryRowContext(
+			ctx,
+			`SELECT EXISTS (
+				SELECT *
+				FROM ax_messagestore_message
+				WHERE global_offset IS NULL
+			)`,
+		).Scan(&ok)
+		return ok, err
+	}).Should(BeFalse())
+}
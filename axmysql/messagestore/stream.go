@@ -6,7 +6,7 @@ import (
 	"time"
 
 	"github.com/jmalloc/ax"
-	"github.com/jmalloc/ax/marshaling"
+	"github.com/jmalloc/ax/messagestore"
 )
 
 const (
@@ -16,13 +16,24 @@ const (
 
 	// DefaultPollInterval is the default time to wait between polls in
 	// MessageStream.Next().
+	//
+	// Streams that have a notifier are woken as soon as messages are appended
+	// within the same process, so polling is only necessary to observe
+	// messages appended by other processes.
 	DefaultPollInterval = 500 * time.Millisecond
 )
 
 // Stream is a MySQL-backed implementation of Ax's messagestore.Stream
 // interface.
+//
+// The offsets of the messages in the stream are not necessarily contiguous,
+// for example when the fetcher filters messages by type.
+//
+// If Notifier is non-nil, Next() checks for new messages as soon as it is
+// notified, in addition to polling every PollInterval.
 type Stream struct {
 	Fetcher      Fetcher
+	Notifier     messagestore.Notifier
 	NextOffset   uint64
 	Limit        uint64
 	PollInterval time.Duration
@@ -30,17 +41,14 @@ type Stream struct {
 	rows     *sql.Rows
 	rowLimit uint64
 	rowCount uint64
+	row      row
+	offset   uint64
 }
 
 // Next advances the stream to the next message.
 //
 // It blocks until a message is available, or ctx is canceled.
 func (s *Stream) Next(ctx context.Context) error {
-	ok, err := s.TryNext(ctx)
-	if ok || err != nil {
-		return err
-	}
-
 	p := s.PollInterval
 	if p == 0 {
 		p = DefaultPollInterval
@@ -50,14 +58,24 @@ func (s *Stream) Next(ctx context.Context) error {
 	defer tick.Stop()
 
 	for {
+		// the ready channel must be obtained before checking for messages, so
+		// that a notification that occurs after the check is not missed. a nil
+		// channel is never ready.
+		var ready <-chan struct{}
+		if s.Notifier != nil {
+			ready = s.Notifier.Ready()
+		}
+
+		ok, err := s.TryNext(ctx)
+		if ok || err != nil {
+			return err
+		}
+
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
+		case <-ready:
 		case <-tick.C:
-			ok, err := s.TryNext(ctx)
-			if ok || err != nil {
-				return err
-			}
 		}
 	}
 }
@@ -74,7 +92,13 @@ func (s *Stream) TryNext(ctx context.Context) (bool, error) {
 		}
 
 		if s.rows.Next() {
-			s.NextOffset++
+			var err error
+			s.row, err = scanRow(s.rows, &s.offset)
+			if err != nil {
+				return false, err
+			}
+
+			s.NextOffset = s.offset + 1
 			s.rowCount++
 			return true, nil
 		}
@@ -94,40 +118,21 @@ func (s *Stream) Get(ctx context.Context) (ax.Envelope, error) {
 		panic("Next() must be called before Get()")
 	}
 
-	var (
-		env         ax.Envelope
-		contentType string
-		data        []byte
-		createdAt   string
-		sendAt      string
-	)
-
-	err := s.rows.Scan(
-		&env.MessageID,
-		&env.CausationID,
-		&env.CorrelationID,
-		&createdAt,
-		&sendAt,
-		&contentType,
-		&data,
-	)
-	if err != nil {
-		return ax.Envelope{}, err
-	}
-
-	err = marshaling.UnmarshalTime(createdAt, &env.CreatedAt)
-	if err != nil {
-		return ax.Envelope{}, err
-	}
+	return s.row.envelope()
+}
 
-	err = marshaling.UnmarshalTime(sendAt, &env.SendAt)
-	if err != nil {
-		return ax.Envelope{}, err
+// GetStored returns the message at the current offset in the stream, along
+// with its position within the store.
+//
+// Messages read from a specific stream may not have been assigned a global
+// offset yet, as global offsets are assigned in the background after the
+// append is committed.
+func (s *Stream) GetStored(ctx context.Context) (messagestore.StoredMessage, error) {
+	if s.rows == nil {
+		panic("Next() must be called before GetStored()")
 	}
 
-	env.Message, err = ax.UnmarshalMessage(contentType, data)
-
-	return env, err
+	return s.row.stored()
 }
 
 // Offset returns the offset of the message returned by Get().
@@ -136,7 +141,7 @@ func (s *Stream) Offset() (uint64, error) {
 		panic("Next() must be called before Offset()")
 	}
 
-	return s.NextOffset - 1, nil
+	return s.offset, nil
 }
 
 // Close closes the stream.
@@ -0,0 +1,48 @@
+package axmysql
+
+import (
+	"context"
+	"database/sql"
+
+	mysqldelayedmessage "github.com/jmalloc/ax/axmysql/delayedmessage"
+	"github.com/jmalloc/ax/axmysql/internal/sqlutil"
+	mysqlmessagestore "github.com/jmalloc/ax/axmysql/messagestore"
+	"github.com/jmalloc/ax/axmysql/migration"
+	mysqloutbox "github.com/jmalloc/ax/axmysql/outbox"
+	mysqlprojection "github.com/jmalloc/ax/axmysql/projection"
+	mysqlsaga "github.com/jmalloc/ax/axmysql/saga"
+	mysqlshredding "github.com/jmalloc/ax/axmysql/shredding"
+)
+
+// DefaultTablePrefix is the prefix used for the names of Ax's tables when no
+// other prefix is specified.
+const DefaultTablePrefix = sqlutil.DefaultTablePrefix
+
+// Migrations returns the schema migrations for every component in this
+// package.
+//
+// prefix is the prefix used for the names of the tables. It may include a
+// schema name, such as "billing.ax_". If it is empty, DefaultTablePrefix is
+// used.
+func Migrations(prefix string) []migration.Component {
+	return []migration.Component{
+		mysqloutbox.Repository{TablePrefix: prefix}.Migrations(),
+		mysqldelayedmessage.Repository{TablePrefix: prefix}.Migrations(),
+		mysqlmessagestore.Store{TablePrefix: prefix}.Migrations(),
+		mysqlprojection.OffsetStore{TablePrefix: prefix}.Migrations(),
+		mysqlprojection.ParkedMessageStore{TablePrefix: prefix}.Migrations(),
+		mysqlsaga.CRUDRepository{TablePrefix: prefix}.Migrations(),
+		mysqlsaga.KeySetRepository{TablePrefix: prefix}.Migrations(),
+		mysqlsaga.SnapshotRepository{TablePrefix: prefix}.Migrations(),
+		mysqlshredding.KeyStore{TablePrefix: prefix}.Migrations(),
+	}
+}
+
+// Migrate creates or upgrades the schema used by every component in this
+// package, using tables with the given prefix.
+//
+// It returns the migrations that were applied. It is safe to call Migrate()
+// concurrently from multiple processes.
+func Migrate(ctx context.Context, db *sql.DB, prefix string) ([]migration.Applied, error) {
+	return migration.Apply(ctx, db, prefix, Migrations(prefix)...)
+}
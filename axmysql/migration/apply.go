@@ -0,0 +1,258 @@
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jmalloc/ax/axmysql/internal/sqlutil"
+)
+
+// lockTimeout is the number of seconds to wait to acquire the lock that is
+// held while migrations are applied.
+const lockTimeout = 60
+
+// Apply applies any migrations of the given components that have not already
+// been applied to db.
+//
+// prefix is the prefix used for the name of the table that records the schema
+// version of each component, and of the MySQL user-level lock that prevents
+// concurrent migration attempts. It may include a schema name, such as
+// "billing.ax_". If it is empty, "ax_" is used.
+//
+// It returns the migrations that were applied, in the order that they were
+// applied. It is safe to call Apply() concurrently from multiple processes.
+func Apply(
+	ctx context.Context,
+	db *sql.DB,
+	prefix string,
+	components ...Component,
+) ([]Applied, error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err := lock(ctx, conn, prefix); err != nil {
+		return nil, err
+	}
+	defer unlock(conn, prefix)
+
+	if err := createVersionTable(ctx, conn, prefix); err != nil {
+		return nil, err
+	}
+
+	var applied []Applied
+
+	for _, c := range components {
+		a, err := apply(ctx, conn, prefix, c)
+		applied = append(applied, a...)
+
+		if err != nil {
+			return applied, err
+		}
+	}
+
+	return applied, nil
+}
+
+// Versions returns the current schema version of each component that has had
+// at least one migration applied.
+//
+// prefix is the prefix used for the name of the table that records the schema
+// versions, as passed to Apply().
+func Versions(ctx context.Context, db *sql.DB, prefix string) (map[string]uint64, error) {
+	rows, err := db.QueryContext(
+		ctx,
+		`SELECT
+			component,
+			version
+		FROM `+versionTable(prefix),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := map[string]uint64{}
+
+	for rows.Next() {
+		var (
+			c string
+			v uint64
+		)
+
+		if err := rows.Scan(&c, &v); err != nil {
+			return nil, err
+		}
+
+		versions[c] = v
+	}
+
+	return versions, rows.Err()
+}
+
+// apply applies the migrations of a single component.
+func apply(
+	ctx context.Context,
+	conn *sql.Conn,
+	prefix string,
+	c Component,
+) ([]Applied, error) {
+	current, err := loadVersion(ctx, conn, prefix, c.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	if n := uint64(len(c.Migrations)); current > n {
+		return nil, fmt.Errorf(
+			"can not migrate the '%s' component, the database is at version %d which is newer than the latest known version (%d)",
+			c.Name,
+			current,
+			n,
+		)
+	}
+
+	var applied []Applied
+
+	for i, m := range c.Migrations {
+		if m.Version != uint64(i+1) {
+			return applied, fmt.Errorf(
+				"can not migrate the '%s' component, migration #%d has version %d, expected %d",
+				c.Name,
+				i+1,
+				m.Version,
+				i+1,
+			)
+		}
+
+		if m.Version <= current {
+			continue
+		}
+
+		for _, q := range m.Statements {
+			if _, err := conn.ExecContext(ctx, q); err != nil {
+				return applied, fmt.Errorf(
+					"can not migrate the '%s' component to version %d: %s",
+					c.Name,
+					m.Version,
+					err,
+				)
+			}
+		}
+
+		if err := storeVersion(ctx, conn, prefix, c.Name, m.Version); err != nil {
+			return applied, err
+		}
+
+		applied = append(applied, Applied{c.Name, m.Version, m.Description})
+	}
+
+	return applied, nil
+}
+
+// createVersionTable creates the table that records the version of each
+// component.
+func createVersionTable(ctx context.Context, conn *sql.Conn, prefix string) error {
+	_, err := conn.ExecContext(
+		ctx,
+		`CREATE TABLE IF NOT EXISTS `+versionTable(prefix)+` (
+			component   VARBINARY(255) NOT NULL,
+			version     BIGINT UNSIGNED NOT NULL,
+			update_time TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6) ON UPDATE CURRENT_TIMESTAMP(6),
+
+			PRIMARY KEY (component)
+		)`,
+	)
+
+	return err
+}
+
+// loadVersion returns the current version of component c.
+func loadVersion(ctx context.Context, conn *sql.Conn, prefix, c string) (uint64, error) {
+	var v uint64
+
+	err := conn.QueryRowContext(
+		ctx,
+		`SELECT
+			version
+		FROM `+versionTable(prefix)+`
+		WHERE component = ?`,
+		c,
+	).Scan(
+		&v,
+	)
+
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+
+	return v, err
+}
+
+// storeVersion records v as the current version of component c.
+func storeVersion(ctx context.Context, conn *sql.Conn, prefix, c string, v uint64) error {
+	_, err := conn.ExecContext(
+		ctx,
+		`INSERT INTO `+versionTable(prefix)+` SET
+			component = ?,
+			version = ?
+		ON DUPLICATE KEY UPDATE
+			version = VALUES(version)`,
+		c,
+		v,
+	)
+
+	return err
+}
+
+// lock acquires the lock that prevents concurrent migration attempts.
+func lock(ctx context.Context, conn *sql.Conn, prefix string) error {
+	var ok sql.NullBool
+
+	if err := conn.QueryRowContext(
+		ctx,
+		`SELECT GET_LOCK(?, ?)`,
+		lockName(prefix),
+		lockTimeout,
+	).Scan(
+		&ok,
+	); err != nil {
+		return err
+	}
+
+	if !ok.Bool {
+		return errors.New("can not apply migrations, timed-out waiting for another migration attempt to complete")
+	}
+
+	return nil
+}
+
+// unlock releases the lock acquired by lock().
+func unlock(conn *sql.Conn, prefix string) {
+	// use a new context so the lock is released even if the context passed to
+	// Apply() has been canceled.
+	conn.ExecContext(
+		context.Background(),
+		`DO RELEASE_LOCK(?)`,
+		lockName(prefix),
+	)
+}
+
+// versionTable returns the name of the table that records the schema version
+// of each component.
+func versionTable(prefix string) string {
+	return sqlutil.Table(prefix, "schema_version")
+}
+
+// lockName returns the name of the MySQL user-level lock that is held while
+// migrations are applied, preventing concurrent migration attempts.
+//
+// Lock names are global to the MySQL server, so the name includes the prefix
+// to allow migrations of tables with different prefixes, or in different
+// schemas, to be applied independently.
+func lockName(prefix string) string {
+	return sqlutil.Table(prefix, "schema_migration")
+}
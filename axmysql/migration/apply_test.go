@@ -0,0 +1,172 @@
+package migration_test
+
+import (
+	"context"
+	"database/sql"
+	"os"
+
+	_ "github.com/go-sql-driver/mysql"
+	. "github.com/jmalloc/ax/axmysql/migration"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Apply", func() {
+	dsn := os.Getenv("AX_MYSQL_DSN")
+
+	var (
+		ctx       context.Context
+		db        *sql.DB
+		component Component
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+
+		var err error
+		db, err = sql.Open("mysql", dsn)
+		if err != nil {
+			panic(err)
+		}
+
+		for _, q := range []string{
+			`DROP TABLE IF EXISTS ax_migration_test`,
+			`DROP TABLE IF EXISTS ax_schema_version`,
+			`DROP TABLE IF EXISTS axtest_schema_version`,
+		} {
+			if _, err := db.Exec(q); err != nil {
+				panic(err)
+			}
+		}
+
+		component = Component{
+			Name: "test",
+			Migrations: []Migration{
+				{
+					Version:     1,
+					Description: "create the test table",
+					Statements: []string{
+						`CREATE TABLE IF NOT EXISTS ax_migration_test (
+							id INTEGER NOT NULL PRIMARY KEY
+						)`,
+					},
+				},
+				{
+					Version:     2,
+					Description: "add a column to the test table",
+					Statements: []string{
+						`ALTER TABLE ax_migration_test ADD COLUMN value INTEGER`,
+					},
+				},
+			},
+		}
+	})
+
+	AfterEach(func() {
+		if err := db.Close(); err != nil {
+			panic(err)
+		}
+	})
+
+	fn := Describe
+	if dsn == "" {
+		fn = XDescribe
+	}
+
+	fn("Apply", func() {
+		It("applies all migrations to a new database", func() {
+			applied, err := Apply(ctx, db, "", component)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(applied).To(Equal([]Applied{
+				{"test", 1, "create the test table"},
+				{"test", 2, "add a column to the test table"},
+			}))
+
+			_, err = db.Exec(`INSERT INTO ax_migration_test SET id = 1, value = 2`)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		It("records the version of each component", func() {
+			_, err := Apply(ctx, db, "", component)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			versions, err := Versions(ctx, db, "")
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(versions).To(Equal(map[string]uint64{"test": 2}))
+		})
+
+		It("records the versions in a table with the given prefix", func() {
+			_, err := Apply(ctx, db, "axtest_", component)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			versions, err := Versions(ctx, db, "axtest_")
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(versions).To(Equal(map[string]uint64{"test": 2}))
+
+			_, err = Versions(ctx, db, "")
+			Expect(err).Should(HaveOccurred()) // ax_schema_version does not exist
+		})
+
+		It("only applies migrations that have not already been applied", func() {
+			all := component.Migrations
+			component.Migrations = all[:1]
+
+			_, err := Apply(ctx, db, "", component)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			component.Migrations = all
+
+			applied, err := Apply(ctx, db, "", component)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(applied).To(Equal([]Applied{
+				{"test", 2, "add a column to the test table"},
+			}))
+
+			applied, err = Apply(ctx, db, "", component)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(applied).To(BeEmpty())
+		})
+
+		It("allows a migration that uses UnlessColumnExists() to be applied again", func() {
+			component.Migrations[1].Statements = UnlessColumnExists(
+				"ax_migration_test",
+				"value",
+				`ALTER TABLE ax_migration_test ADD COLUMN value INTEGER`,
+			)
+
+			_, err := Apply(ctx, db, "", component)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			// simulate a failure to record the version after the migration's
+			// statements were executed.
+			_, err = db.Exec(`UPDATE ax_schema_version SET version = 1 WHERE component = 'test'`)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			applied, err := Apply(ctx, db, "", component)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(applied).To(Equal([]Applied{
+				{"test", 2, "add a column to the test table"},
+			}))
+
+			_, err = db.Exec(`INSERT INTO ax_migration_test SET id = 1, value = 2`)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		It("returns an error if the versions are not contiguous", func() {
+			component.Migrations[1].Version = 3
+
+			_, err := Apply(ctx, db, "", component)
+			Expect(err).Should(HaveOccurred())
+		})
+
+		It("returns an error if the database is newer than the known migrations", func() {
+			_, err := Apply(ctx, db, "", component)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			component.Migrations = component.Migrations[:1]
+
+			_, err = Apply(ctx, db, "", component)
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,75 @@
+package migration
+
+import "strings"
+
+// Migration is a single change to the schema of a component.
+type Migration struct {
+	// Version is the version of the component's schema after the migration is
+	// applied. Versions begin at 1 and must be contiguous.
+	Version uint64
+
+	// Description is a human-readable description of the change.
+	Description string
+
+	// Statements are the SQL statements that perform the migration.
+	//
+	// MySQL commits DDL statements implicitly, so a migration can not be rolled
+	// back if one of its statements fails. Statements should be written such
+	// that they can be executed again without error, for example by using
+	// "CREATE TABLE IF NOT EXISTS", or UnlessColumnExists() for statements that
+	// add columns.
+	Statements []string
+}
+
+// Component is the set of migrations for a single Ax component.
+type Component struct {
+	// Name uniquely identifies the component, for example "outbox".
+	Name string
+
+	// Migrations is the ordered list of the component's migrations.
+	Migrations []Migration
+}
+
+// Applied describes a migration that has been applied to the database.
+type Applied struct {
+	Component   string
+	Version     uint64
+	Description string
+}
+
+// UnlessColumnExists returns statements that execute the statement q only if
+// the table t does not have a column named c.
+//
+// MySQL does not support "ADD COLUMN IF NOT EXISTS", so it allows an "ALTER
+// TABLE" statement that adds c to be executed again without error. t may
+// include a schema name, such as "billing.ax_outbox".
+func UnlessColumnExists(t, c, q string) []string {
+	schema := "DATABASE()"
+	if i := strings.LastIndexByte(t, '.'); i != -1 {
+		schema = quote(t[:i])
+		t = t[i+1:]
+	}
+
+	return []string{
+		`SET @ax_migration = IF(
+			EXISTS (
+				SELECT * FROM information_schema.COLUMNS
+				WHERE TABLE_SCHEMA = ` + schema + `
+				AND TABLE_NAME = ` + quote(t) + `
+				AND COLUMN_NAME = ` + quote(c) + `
+			),
+			'SET @ax_migration = NULL',
+			` + quote(q) + `
+		)`,
+		`PREPARE ax_migration FROM @ax_migration`,
+		`EXECUTE ax_migration`,
+		`DEALLOCATE PREPARE ax_migration`,
+	}
+}
+
+// quote returns s as an SQL string literal.
+func quote(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, `'`, `''`, -1)
+	return `'` + s + `'`
+}
@@ -0,0 +1,8 @@
+// Package migration applies versioned schema migrations to a MySQL database.
+//
+// Each Ax component that persists data, such as the outbox or the message
+// store, provides its own set of migrations. The version of each component's
+// schema is recorded in the ax_schema_version table, or the equivalent table
+// with a different prefix, so that only those migrations that have not yet
+// been applied are executed.
+package migration
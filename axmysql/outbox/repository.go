@@ -2,9 +2,12 @@ package outbox
 
 import (
 	"context"
+	"database/sql"
+	"time"
 
 	"github.com/jmalloc/ax"
 	"github.com/jmalloc/ax/axmysql/internal/envelopestore"
+	"github.com/jmalloc/ax/axmysql/internal/sqlutil"
 	mysqlpersistence "github.com/jmalloc/ax/axmysql/persistence"
 	"github.com/jmalloc/ax/endpoint"
 	"github.com/jmalloc/ax/persistence"
@@ -12,14 +15,16 @@ import (
 
 // Repository is a MySQL-backed implementation of Ax's outbox.Repository
 // interface.
-type Repository struct{}
-
-// messageTable is the name of the SQL table that stores outbox messages.
-const messageTable = "ax_outbox_message"
+type Repository struct {
+	// TablePrefix is the prefix used for the names of the repository's
+	// tables. It may include a schema name, such as "billing.ax_". If it is
+	// empty, "ax_" is used.
+	TablePrefix string
+}
 
 // LoadOutbox loads the unsent outbound messages that were produced when the
 // message identified by id was first processed.
-func (Repository) LoadOutbox(
+func (r Repository) LoadOutbox(
 	ctx context.Context,
 	ds persistence.DataStore,
 	id ax.MessageID,
@@ -29,7 +34,7 @@ func (Repository) LoadOutbox(
 	row := db.QueryRowContext(
 		ctx,
 		`SELECT EXISTS (
-			SELECT * FROM ax_outbox WHERE causation_id = ?
+			SELECT * FROM `+r.table("outbox")+` WHERE causation_id = ?
 		)`,
 		id,
 	)
@@ -46,7 +51,7 @@ func (Repository) LoadOutbox(
 	rows, err := db.QueryContext(
 		ctx,
 		`SELECT `+envelopestore.Columns+`
-		FROM `+messageTable+`
+		FROM `+r.table("outbox_message")+`
 		WHERE causation_id = ?`,
 		id,
 	)
@@ -71,7 +76,7 @@ func (Repository) LoadOutbox(
 
 // SaveOutbox saves a set of unsent outbound messages that were produced
 // when the message identified by id was processed.
-func (Repository) SaveOutbox(
+func (r Repository) SaveOutbox(
 	ctx context.Context,
 	ptx persistence.Tx,
 	id ax.MessageID,
@@ -81,14 +86,36 @@ func (Repository) SaveOutbox(
 
 	if _, err := tx.ExecContext(
 		ctx,
-		`INSERT INTO ax_outbox SET causation_id = ?`,
+		`INSERT INTO `+r.table("outbox")+` SET causation_id = ?`,
 		id,
 	); err != nil {
 		return err
 	}
 
 	for _, env := range envs {
-		if err := envelopestore.Insert(ctx, tx, messageTable, env); err != nil {
+		if err := envelopestore.Insert(ctx, tx, r.table("outbox_message"), env); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SaveMessages saves a set of unsent outbound messages that were not
+// produced by processing an inbound message, such as those sent via a
+// TransactionalSender.
+//
+// These messages are returned by ClaimUnsentMessages() regardless of their
+// age.
+func (r Repository) SaveMessages(
+	ctx context.Context,
+	ptx persistence.Tx,
+	envs []endpoint.OutboundEnvelope,
+) error {
+	tx := mysqlpersistence.ExtractTx(ptx)
+
+	for _, env := range envs {
+		if err := envelopestore.Insert(ctx, tx, r.table("outbox_standalone_message"), env); err != nil {
 			return err
 		}
 	}
@@ -96,13 +123,130 @@ func (Repository) SaveOutbox(
 	return nil
 }
 
-// MarkAsSent marks a message as sent, removing it from the outbox.
-func (Repository) MarkAsSent(
+// MarkAsSent marks one or more messages as sent, removing them from the
+// outbox.
+func (r Repository) MarkAsSent(
 	ctx context.Context,
 	ptx persistence.Tx,
-	env endpoint.OutboundEnvelope,
+	envs ...endpoint.OutboundEnvelope,
 ) error {
 	tx := mysqlpersistence.ExtractTx(ptx)
 
-	return envelopestore.Delete(ctx, tx, messageTable, env)
+	if err := envelopestore.Delete(ctx, tx, r.table("outbox_message"), envs...); err != nil {
+		return err
+	}
+
+	return envelopestore.Delete(ctx, tx, r.table("outbox_standalone_message"), envs...)
+}
+
+// ClaimUnsentMessages loads up to n unsent outbound messages from outboxes
+// that were saved at least d ago, and messages saved by SaveMessages().
+//
+// The returned messages are locked within tx. They are not returned by
+// calls to ClaimUnsentMessages() within other transactions until tx is
+// committed or rolled back.
+func (r Repository) ClaimUnsentMessages(
+	ctx context.Context,
+	ptx persistence.Tx,
+	d time.Duration,
+	n int,
+) ([]endpoint.OutboundEnvelope, error) {
+	tx := mysqlpersistence.ExtractTx(ptx)
+
+	envelopes, err := r.claim(
+		ctx,
+		tx,
+		`SELECT `+envelopestore.Columns+`
+		FROM `+r.table("outbox_standalone_message")+`
+		LIMIT ?
+		FOR UPDATE SKIP LOCKED`, // skip messages claimed by other transactions
+		n,
+	)
+	if err != nil || len(envelopes) == n {
+		return envelopes, err
+	}
+
+	more, err := r.claim(
+		ctx,
+		tx,
+		`SELECT `+envelopestore.Columns+`
+		FROM `+r.table("outbox_message")+`
+		WHERE causation_id IN (
+			SELECT causation_id FROM `+r.table("outbox")+`
+			WHERE insert_time < NOW(6) - INTERVAL ? MICROSECOND
+		)
+		LIMIT ?
+		FOR UPDATE SKIP LOCKED`, // skip messages claimed by other transactions
+		d.Nanoseconds()/int64(time.Microsecond),
+		n-len(envelopes),
+	)
+
+	return append(envelopes, more...), err
+}
+
+// claim executes a query that selects and locks outbox messages.
+func (r Repository) claim(
+	ctx context.Context,
+	tx *sql.Tx,
+	query string,
+	args ...interface{},
+) ([]endpoint.OutboundEnvelope, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var envelopes []endpoint.OutboundEnvelope
+
+	for rows.Next() {
+		env, err := envelopestore.Scan(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		envelopes = append(envelopes, env)
+	}
+
+	return envelopes, rows.Err()
+}
+
+// PurgeOutboxes deletes up to n outboxes that contain no unsent messages
+// and were saved at least d ago. It returns the number of outboxes deleted.
+//
+// Once an outbox is deleted, the message that produced it is no longer
+// deduplicated, and is processed again if it is redelivered.
+func (r Repository) PurgeOutboxes(
+	ctx context.Context,
+	ptx persistence.Tx,
+	d time.Duration,
+	n int,
+) (int, error) {
+	tx := mysqlpersistence.ExtractTx(ptx)
+
+	res, err := tx.ExecContext(
+		ctx,
+		`DELETE FROM `+r.table("outbox")+`
+		WHERE insert_time < NOW(6) - INTERVAL ? MICROSECOND
+		AND NOT EXISTS (
+			SELECT * FROM `+r.table("outbox_message")+` AS m
+			WHERE m.causation_id = `+r.table("outbox")+`.causation_id
+		)
+		ORDER BY insert_time
+		LIMIT ?`,
+		d.Nanoseconds()/int64(time.Microsecond),
+		n,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	count, err := res.RowsAffected()
+	return int(count), err
+}
+
+// table returns the name of the table with the given name, including the
+// table prefix.
+func (r Repository) table(name string) string {
+	return sqlutil.Table(r.TablePrefix, name)
 }
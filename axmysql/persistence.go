@@ -13,6 +13,19 @@ func NewDataStore(db *sql.DB) persistence.DataStore {
 	return &mysqlpersistence.DataStore{DB: db}
 }
 
+// NewReplicatedDataStore returns a new data store that is backed by a MySQL
+// database, with a read-only replica of that database.
+//
+// The replica is used for reads that can tolerate replication lag, such as
+// those performed by a projection that is catching up on the global message
+// stream. All other reads and writes use the primary database.
+func NewReplicatedDataStore(primary, replica *sql.DB) persistence.DataStore {
+	return &mysqlpersistence.DataStore{
+		DB:        primary,
+		ReplicaDB: replica,
+	}
+}
+
 // GetDB returns the SQL database contained in ctx.
 //
 // It panics if ctx does not contain a MySQL-specific SQL database.
@@ -21,6 +34,16 @@ func GetDB(ctx context.Context) *sql.DB {
 	return mysqlpersistence.ExtractDB(ds)
 }
 
+// GetReplicaDB returns the SQL database contained in ctx that is used for
+// reads that can tolerate replication lag.
+//
+// It returns the primary database if the data store does not have a replica.
+// It panics if ctx does not contain a MySQL-specific SQL database.
+func GetReplicaDB(ctx context.Context) *sql.DB {
+	ds, _ := persistence.GetDataStore(ctx)
+	return mysqlpersistence.ExtractReplicaDB(ds)
+}
+
 // GetTx returns the SQL transaction contained in ctx.
 //
 // It panics if ctx does not contain a MySQL-specific SQL transaction.
@@ -4,13 +4,19 @@ import (
 	"context"
 	"database/sql"
 
+	"github.com/jmalloc/ax/axmysql/internal/sqlutil"
 	"github.com/jmalloc/ax/persistence"
 )
 
 // DataStore is a MySQL-backed implementation of Ax's persistence.DataStore
 // interface.
+//
+// All writes, and any reads that require consistency, use DB. Reads that can
+// tolerate replication lag, such as those performed by a projection that is
+// catching up on the global message stream, use ReplicaDB if it is non-nil.
 type DataStore struct {
-	DB *sql.DB
+	DB        *sql.DB
+	ReplicaDB *sql.DB
 }
 
 // BeginTx starts a new transaction.
@@ -20,7 +26,14 @@ func (ds *DataStore) BeginTx(ctx context.Context) (persistence.Tx, persistence.C
 		return nil, nil, err
 	}
 
-	return &Tx{ds, tx}, tx, nil
+	t := &Tx{ds: ds, sqlTx: tx}
+	return t, t, nil
+}
+
+// IsTransientError returns true if err is caused by a MySQL deadlock or lock
+// wait timeout.
+func (ds *DataStore) IsTransientError(err error) bool {
+	return sqlutil.IsTransient(err)
 }
 
 // txOptions is the set of options used when starting a new SQL transaction.
@@ -33,3 +46,18 @@ var txOptions = &sql.TxOptions{
 func ExtractDB(ds persistence.DataStore) *sql.DB {
 	return ds.(*DataStore).DB
 }
+
+// ExtractReplicaDB returns the SQL database within ds that is used for reads
+// that can tolerate replication lag.
+//
+// It returns the primary database if ds does not have a replica. It panics if
+// ds is not a *DataStore.
+func ExtractReplicaDB(ds persistence.DataStore) *sql.DB {
+	d := ds.(*DataStore)
+
+	if d.ReplicaDB != nil {
+		return d.ReplicaDB
+	}
+
+	return d.DB
+}
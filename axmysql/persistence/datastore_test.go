@@ -0,0 +1,118 @@
+package persistence_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/go-sql-driver/mysql"
+	. "github.com/jmalloc/ax/axmysql/persistence"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("DataStore", func() {
+	Describe("IsTransientError", func() {
+		ds := &DataStore{}
+
+		DescribeTable(
+			"it classifies errors",
+			func(err error, expected bool) {
+				Expect(ds.IsTransientError(err)).To(Equal(expected))
+			},
+			Entry("deadlock", &mysql.MySQLError{Number: 1213}, true),
+			Entry("lock wait timeout", &mysql.MySQLError{Number: 1205}, true),
+			Entry("wrapped deadlock", fmt.Errorf("<context>: %w", &mysql.MySQLError{Number: 1213}), true),
+			Entry("duplicate entry", &mysql.MySQLError{Number: 1062}, false),
+			Entry("non-MySQL error", errors.New("<error>"), false),
+			Entry("nil", nil, false),
+		)
+	})
+	Describe("ExtractReplicaDB", func() {
+		var primary, replica *sql.DB
+
+		BeforeEach(func() {
+			primary = &sql.DB{}
+			replica = &sql.DB{}
+		})
+
+		It("returns the replica database", func() {
+			ds := &DataStore{DB: primary, ReplicaDB: replica}
+			Expect(ExtractReplicaDB(ds)).To(BeIdenticalTo(replica))
+		})
+
+		It("returns the primary database if there is no replica", func() {
+			ds := &DataStore{DB: primary}
+			Expect(ExtractReplicaDB(ds)).To(BeIdenticalTo(primary))
+		})
+	})
+
+	dsn := os.Getenv("AX_MYSQL_DSN")
+
+	fn := Describe
+	if dsn == "" {
+		fn = XDescribe
+	}
+
+	fn("BeginTx", func() {
+		var (
+			ctx context.Context
+			db  *sql.DB
+			ds  *DataStore
+		)
+
+		BeforeEach(func() {
+			var err error
+			db, err = sql.Open("mysql", dsn)
+			if err != nil {
+				panic(err)
+			}
+
+			ctx = context.Background()
+			ds = &DataStore{DB: db}
+		})
+
+		AfterEach(func() {
+			if err := db.Close(); err != nil {
+				panic(err)
+			}
+		})
+
+		It("calls the after-commit hooks when the transaction is committed", func() {
+			tx, com, err := ds.BeginTx(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+			defer com.Rollback()
+
+			var committed, rolledBack bool
+			tx.AfterCommit(func() { committed = true })
+			tx.AfterRollback(func() { rolledBack = true })
+
+			err = com.Commit()
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = com.Rollback()
+			Expect(err).Should(HaveOccurred())
+
+			Expect(committed).To(BeTrue())
+			Expect(rolledBack).To(BeFalse())
+		})
+
+		It("calls the after-rollback hooks when the transaction is rolled back", func() {
+			tx, com, err := ds.BeginTx(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			var committed, rolledBack bool
+			tx.AfterCommit(func() { committed = true })
+			tx.AfterRollback(func() { rolledBack = true })
+
+			err = com.Rollback()
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(committed).To(BeFalse())
+			Expect(rolledBack).To(BeTrue())
+		})
+	})
+})
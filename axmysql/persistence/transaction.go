@@ -6,10 +6,12 @@ import (
 	"github.com/jmalloc/ax/persistence"
 )
 
-// Tx is a MySQL-backed implementation of Ax's persistence.Tx interface.
+// Tx is a MySQL-backed implementation of Ax's persistence.Tx and
+// persistence.Committer interfaces.
 type Tx struct {
 	ds    *DataStore
 	sqlTx *sql.Tx
+	hooks persistence.Hooks
 }
 
 // DataStore returns the DataStore that the transaction operates on.
@@ -17,6 +19,41 @@ func (tx *Tx) DataStore() persistence.DataStore {
 	return tx.ds
 }
 
+// AfterCommit registers fn to be called after the transaction is committed.
+func (tx *Tx) AfterCommit(fn func()) {
+	tx.hooks.AfterCommit(fn)
+}
+
+// AfterRollback registers fn to be called after the transaction is rolled
+// back, including when the commit fails.
+func (tx *Tx) AfterRollback(fn func()) {
+	tx.hooks.AfterRollback(fn)
+}
+
+// Commit applies the changes to the data store.
+func (tx *Tx) Commit() error {
+	err := tx.sqlTx.Commit()
+
+	if err == nil {
+		tx.hooks.Committed()
+	} else if err != sql.ErrTxDone {
+		tx.hooks.RolledBack()
+	}
+
+	return err
+}
+
+// Rollback discards the changes without applying them to the data store.
+func (tx *Tx) Rollback() error {
+	err := tx.sqlTx.Rollback()
+
+	if err != sql.ErrTxDone {
+		tx.hooks.RolledBack()
+	}
+
+	return err
+}
+
 // ExtractTx returns the SQL transaction within tx.
 // It panics if tx is not a *Tx.
 func ExtractTx(tx persistence.Tx) *sql.Tx {
@@ -8,6 +8,10 @@ import (
 // ProjectionOffsetStore is an offset store backed by a MySQL database.
 var ProjectionOffsetStore projection.OffsetStore = mysqlprojection.OffsetStore{}
 
+// ProjectionParkedMessageStore is a parked message store backed by a MySQL
+// database.
+var ProjectionParkedMessageStore projection.ParkedMessageStore = mysqlprojection.ParkedMessageStore{}
+
 // NewReadModelProjector returns a new projector that builds a MySQL based
 // read-model from a stream of events.
 func NewReadModelProjector(rm mysqlprojection.ReadModel) projection.Projector {
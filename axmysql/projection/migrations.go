@@ -0,0 +1,78 @@
+package projection
+
+import "github.com/jmalloc/ax/axmysql/migration"
+
+// Migrations returns the schema migrations for the projection offset table.
+func (s OffsetStore) Migrations() migration.Component {
+	return migration.Component{
+		Name: s.table("projection_offsetstore"),
+		Migrations: []migration.Migration{
+			{
+				Version:     1,
+				Description: "create the projection offset table",
+				Statements: []string{
+					// ax_projection_offset stores the next offset to be read by a projection consumer.
+					`CREATE TABLE IF NOT EXISTS ` + s.table("projection_offset") + ` (
+						persistence_key VARBINARY(255) NOT NULL,
+						next_offset     BIGINT UNSIGNED NOT NULL,
+
+						PRIMARY KEY (persistence_key)
+					) ROW_FORMAT=COMPRESSED`,
+				},
+			},
+			{
+				Version:     2,
+				Description: "store offsets for each version of a projector",
+				Statements: append(
+					// version is the projector's version, as per
+					// projection.VersionedProjector. is_active is true for the
+					// version of the projector that readers should use.
+					//
+					// is_active is added with a default of TRUE so that existing
+					// offsets, which belong to unversioned projectors that have been
+					// in use up until now, are activated by the same statement.
+					migration.UnlessColumnExists(
+						s.table("projection_offset"),
+						"version",
+						`ALTER TABLE `+s.table("projection_offset")+`
+							ADD COLUMN version BIGINT UNSIGNED NOT NULL DEFAULT 0,
+							ADD COLUMN is_active BOOLEAN NOT NULL DEFAULT TRUE,
+							DROP PRIMARY KEY,
+							ADD PRIMARY KEY (persistence_key, version)`,
+					),
+					`ALTER TABLE `+s.table("projection_offset")+`
+						ALTER COLUMN is_active SET DEFAULT FALSE`,
+				),
+			},
+		},
+	}
+}
+
+// Migrations returns the schema migrations for the parked message table.
+func (s ParkedMessageStore) Migrations() migration.Component {
+	return migration.Component{
+		Name: s.table("projection_parkedmessagestore"),
+		Migrations: []migration.Migration{
+			{
+				Version:     1,
+				Description: "create the parked message table",
+				Statements: []string{
+					// ax_projection_parked_message stores the messages that a
+					// projection consumer has skipped because the projector failed to
+					// apply them.
+					`CREATE TABLE IF NOT EXISTS ` + s.table("projection_parked_message") + ` (
+						persistence_key VARBINARY(255) NOT NULL,
+						version         BIGINT UNSIGNED NOT NULL,
+						global_offset   BIGINT UNSIGNED NOT NULL,
+						message_id      VARBINARY(255) NOT NULL,
+						message_type    VARBINARY(255) NOT NULL,
+						error_message   TEXT NOT NULL,
+						parked_at       TIMESTAMP(6) NOT NULL,
+
+						PRIMARY KEY (persistence_key, version, global_offset)
+					) ROW_FORMAT=COMPRESSED`,
+				},
+			},
+		},
+	}
+}
@@ -3,25 +3,31 @@ package projection
 import (
 	"context"
 	"database/sql"
-	"fmt"
 
 	"github.com/jmalloc/ax/axmysql/internal/sqlutil"
 	mysqlpersistence "github.com/jmalloc/ax/axmysql/persistence"
 	"github.com/jmalloc/ax/persistence"
+	"github.com/jmalloc/ax/projection"
 )
 
 // OffsetStore is a MySQL-backed implementation of Ax's projection.OffsetStore
 // interface.
-type OffsetStore struct{}
+type OffsetStore struct {
+	// TablePrefix is the prefix used for the names of the offset store's
+	// tables. It may include a schema name, such as "billing.ax_". If it is
+	// empty, "ax_" is used.
+	TablePrefix string
+}
 
 // LoadOffset returns the offset at which a consumer should resume
 // reading from the stream.
 //
-// pk is the projector's persistence key.
-func (OffsetStore) LoadOffset(
+// pk is the projector's persistence key, and v is its version.
+func (s OffsetStore) LoadOffset(
 	ctx context.Context,
 	ds persistence.DataStore,
 	pk string,
+	v uint64,
 ) (uint64, error) {
 	db := mysqlpersistence.ExtractDB(ds)
 
@@ -31,9 +37,11 @@ func (OffsetStore) LoadOffset(
 		ctx,
 		`SELECT
 			next_offset
-		FROM ax_projection_offset
-		WHERE persistence_key = ?`,
+		FROM `+s.table("projection_offset")+`
+		WHERE persistence_key = ?
+		AND version = ?`,
 		pk,
+		v,
 	).Scan(
 		&offset,
 	)
@@ -47,103 +55,257 @@ func (OffsetStore) LoadOffset(
 	return offset, nil
 }
 
-// IncrementOffset increments the offset at which a consumer should resume
-// reading from the stream by one.
+// SaveOffset sets the offset at which a consumer should resume reading from
+// the stream.
 //
-// pk is the projector's persitence key. c is the offset that is currently
-// stored, as returned by LoadOffset(). If c is not the offset that is
-// currently stored, the increment fails and a non-nil error is returned.
-func (s OffsetStore) IncrementOffset(
+// pk is the projector's persistence key, and v is its version. c is the offset
+// that is currently stored, as returned by LoadOffset(), and n is the new
+// offset. If c is not the offset that is currently stored, the save fails and
+// a *projection.ConflictError is returned.
+func (s OffsetStore) SaveOffset(
 	ctx context.Context,
 	ptx persistence.Tx,
 	pk string,
-	c uint64,
+	v uint64,
+	c, n uint64,
 ) error {
 	tx := mysqlpersistence.ExtractTx(ptx)
 
-	var (
-		ok  bool
-		err error
-	)
+	current, exists, err := s.lockOffset(ctx, tx, pk, v)
+	if err != nil {
+		return err
+	}
 
-	if c == 0 {
-		ok, err = s.insertOffset(ctx, tx, pk)
-	} else {
-		ok, err = s.updateOffset(ctx, tx, pk, c)
+	ok := c == current
+	if ok {
+		if exists {
+			err = s.updateOffset(ctx, tx, pk, v, n)
+		} else {
+			ok, err = s.insertOffset(ctx, tx, pk, v, n)
+		}
 	}
 
 	if ok || err != nil {
 		return err
 	}
 
-	// TODO: use OCC error https://github.com/jmalloc/ax/issues/93
-	return fmt.Errorf(
-		"can not increment projection offset for persistence key %s, offset %d is not the current offset",
+	return &projection.ConflictError{PersistenceKey: pk, Version: v, Offset: c}
+}
+
+// ResetOffset sets the offset at which a consumer should resume reading from
+// the stream back to zero.
+//
+// pk is the projector's persistence key, and v is its version. The version
+// remains active if it was active before the reset.
+//
+// The offset's row is created if it does not already exist, so that it is
+// locked until tx ends even if no offset has been saved.
+func (s OffsetStore) ResetOffset(
+	ctx context.Context,
+	ptx persistence.Tx,
+	pk string,
+	v uint64,
+) error {
+	tx := mysqlpersistence.ExtractTx(ptx)
+
+	_, err := tx.ExecContext(
+		ctx,
+		`INSERT INTO `+s.table("projection_offset")+` SET
+			persistence_key = ?,
+			version = ?,
+			next_offset = 0
+		ON DUPLICATE KEY UPDATE
+			next_offset = 0`,
 		pk,
-		c,
+		v,
 	)
+
+	return err
 }
 
-// insertOffset inserts an entry for the saga pk with the offset set to 1.
-// It returns false if an entry already exists.
-func (OffsetStore) insertOffset(
+// ActivateVersion makes v the active version of the projector with the
+// persistence key pk, unless a later version is already active.
+//
+// It returns true if v is the active version.
+func (s OffsetStore) ActivateVersion(
 	ctx context.Context,
-	tx *sql.Tx,
+	ptx persistence.Tx,
 	pk string,
+	v uint64,
 ) (bool, error) {
-	_, err := tx.ExecContext(
+	tx := mysqlpersistence.ExtractTx(ptx)
+
+	active, ok, err := s.selectActiveVersion(
+		tx.QueryRowContext(
+			ctx,
+			`SELECT
+				version
+			FROM `+s.table("projection_offset")+`
+			WHERE persistence_key = ?
+			AND is_active
+			FOR UPDATE`,
+			pk,
+		),
+	)
+	if err != nil {
+		return false, err
+	}
+
+	if ok && active >= v {
+		return active == v, nil
+	}
+
+	// the version may not have saved an offset yet, for example if there are
+	// no messages in the store.
+	if _, err := tx.ExecContext(
 		ctx,
-		`INSERT INTO ax_projection_offset SET
+		`INSERT IGNORE INTO `+s.table("projection_offset")+` SET
 			persistence_key = ?,
-			next_offset = 1`,
+			version = ?,
+			next_offset = 0`,
+		pk,
+		v,
+	); err != nil {
+		return false, err
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`UPDATE `+s.table("projection_offset")+` SET
+			is_active = (version = ?)
+		WHERE persistence_key = ?`,
+		v,
 		pk,
 	)
 
-	if sqlutil.IsDuplicateEntry(err) {
-		return false, nil
-	}
+	return err == nil, err
+}
 
-	return true, err
+// LoadActiveVersion returns the active version of the projector with the
+// persistence key pk.
+//
+// It returns false if no version of the projector has been activated.
+func (s OffsetStore) LoadActiveVersion(
+	ctx context.Context,
+	ds persistence.DataStore,
+	pk string,
+) (uint64, bool, error) {
+	db := mysqlpersistence.ExtractDB(ds)
+
+	return s.selectActiveVersion(
+		db.QueryRowContext(
+			ctx,
+			`SELECT
+				version
+			FROM `+s.table("projection_offset")+`
+			WHERE persistence_key = ?
+			AND is_active`,
+			pk,
+		),
+	)
 }
 
-// updateOffset increments the entry for the saga pk.
-// It returns false if c is not the currently stored offset.
-func (OffsetStore) updateOffset(
+// lockOffset returns the offset that is currently stored for version v of the
+// projector pk, and locks its row until tx ends. It returns false if there is
+// no stored offset.
+func (s OffsetStore) lockOffset(
 	ctx context.Context,
 	tx *sql.Tx,
 	pk string,
-	c uint64,
-) (bool, error) {
+	v uint64,
+) (uint64, bool, error) {
 	var current uint64
 
 	err := tx.QueryRowContext(
 		ctx,
 		`SELECT
 			next_offset
-		FROM ax_projection_offset
+		FROM `+s.table("projection_offset")+`
 		WHERE persistence_key = ?
+		AND version = ?
 		FOR UPDATE`,
 		pk,
+		v,
 	).Scan(
 		&current,
 	)
 
 	if err == sql.ErrNoRows {
-		return false, nil
+		return 0, false, nil
 	} else if err != nil {
-		return false, err
+		return 0, false, err
 	}
 
-	if c != current {
+	return current, true, nil
+}
+
+// insertOffset inserts an entry for version v of the projector pk with the
+// offset set to n. It returns false if an entry already exists.
+func (s OffsetStore) insertOffset(
+	ctx context.Context,
+	tx *sql.Tx,
+	pk string,
+	v uint64,
+	n uint64,
+) (bool, error) {
+	_, err := tx.ExecContext(
+		ctx,
+		`INSERT INTO `+s.table("projection_offset")+` SET
+			persistence_key = ?,
+			version = ?,
+			next_offset = ?`,
+		pk,
+		v,
+		n,
+	)
+
+	if sqlutil.IsDuplicateEntry(err) {
 		return false, nil
 	}
 
-	return true, sqlutil.ExecSingleRow(
+	return true, err
+}
+
+// updateOffset sets the offset for version v of the projector pk to n.
+func (s OffsetStore) updateOffset(
+	ctx context.Context,
+	tx *sql.Tx,
+	pk string,
+	v uint64,
+	n uint64,
+) error {
+	_, err := tx.ExecContext(
 		ctx,
-		tx,
-		`UPDATE ax_projection_offset SET
-			next_offset = next_offset + 1
-		WHERE persistence_key = ?`,
+		`UPDATE `+s.table("projection_offset")+` SET
+			next_offset = ?
+		WHERE persistence_key = ?
+		AND version = ?`,
+		n,
 		pk,
+		v,
 	)
+
+	return err
+}
+
+// selectActiveVersion returns the version selected by row. It returns false if
+// there is no such row.
+func (s OffsetStore) selectActiveVersion(row *sql.Row) (uint64, bool, error) {
+	var v uint64
+
+	err := row.Scan(&v)
+
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	} else if err != nil {
+		return 0, false, err
+	}
+
+	return v, true, nil
+}
+
+// table returns the name of the table with the given name, including the
+// table prefix.
+func (s OffsetStore) table(name string) string {
+	return sqlutil.Table(s.TablePrefix, name)
 }
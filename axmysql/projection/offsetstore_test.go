@@ -0,0 +1,294 @@
+package projection_test
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/jmalloc/ax/axmysql"
+	"github.com/jmalloc/ax/axmysql/internal/schema"
+	"github.com/jmalloc/ax/axmysql/migration"
+	. "github.com/jmalloc/ax/axmysql/projection"
+	"github.com/jmalloc/ax/persistence"
+	"github.com/jmalloc/ax/projection"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("OffsetStore", func() {
+	dsn := os.Getenv("AX_MYSQL_DSN")
+
+	var (
+		ctx    context.Context
+		cancel func()
+		db     *sql.DB
+		ds     persistence.DataStore
+		store  OffsetStore
+	)
+
+	atomically := func(fn func(tx persistence.Tx) error) error {
+		tx, com, err := ds.BeginTx(ctx)
+		if err != nil {
+			return err
+		}
+		defer com.Rollback()
+
+		if err := fn(tx); err != nil {
+			return err
+		}
+
+		return com.Commit()
+	}
+
+	saveVersionOffset := func(v, c, n uint64) error {
+		return atomically(func(tx persistence.Tx) error {
+			return store.SaveOffset(ctx, tx, "<pk>", v, c, n)
+		})
+	}
+
+	saveOffset := func(c, n uint64) error {
+		return saveVersionOffset(0, c, n)
+	}
+
+	loadVersionOffset := func(v uint64) uint64 {
+		o, err := store.LoadOffset(ctx, ds, "<pk>", v)
+		Expect(err).ShouldNot(HaveOccurred())
+		return o
+	}
+
+	loadOffset := func() uint64 {
+		return loadVersionOffset(0)
+	}
+
+	activateVersion := func(v uint64) bool {
+		var ok bool
+		err := atomically(func(tx persistence.Tx) error {
+			var err error
+			ok, err = store.ActivateVersion(ctx, tx, "<pk>", v)
+			return err
+		})
+		Expect(err).ShouldNot(HaveOccurred())
+		return ok
+	}
+
+	loadActiveVersion := func() (uint64, bool) {
+		v, ok, err := store.LoadActiveVersion(ctx, ds, "<pk>")
+		Expect(err).ShouldNot(HaveOccurred())
+		return v, ok
+	}
+
+	BeforeEach(func() {
+		var fn func()
+		ctx, fn = context.WithTimeout(context.Background(), 10*time.Second)
+		cancel = fn // defeat go vet warning about unused cancel func
+
+		var err error
+		db, err = sql.Open("mysql", dsn)
+		if err != nil {
+			panic(err)
+		}
+
+		if err := schema.Create(db, store.Migrations()); err != nil {
+			panic(err)
+		}
+
+		ds = axmysql.NewDataStore(db)
+	})
+
+	AfterEach(func() {
+		if db != nil {
+			db.Close()
+		}
+
+		cancel()
+	})
+
+	fn := Describe
+	if dsn == "" {
+		fn = XDescribe
+	}
+
+	fn("SaveOffset", func() {
+		It("saves the offset", func() {
+			err := saveOffset(0, 1)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = saveOffset(1, 2)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(loadOffset()).To(BeNumerically("==", 2))
+		})
+
+		It("returns a conflict error if the current offset is not the expected offset", func() {
+			err := saveOffset(0, 1)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = saveOffset(0, 1)
+			Expect(projection.IsConflict(err)).To(BeTrue())
+
+			err = saveOffset(2, 3)
+			Expect(projection.IsConflict(err)).To(BeTrue())
+		})
+
+		It("saves a separate offset for each version", func() {
+			err := saveVersionOffset(1, 0, 5)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = saveVersionOffset(2, 0, 3)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(loadOffset()).To(BeNumerically("==", 0))
+			Expect(loadVersionOffset(1)).To(BeNumerically("==", 5))
+			Expect(loadVersionOffset(2)).To(BeNumerically("==", 3))
+		})
+
+		It("saves the offset of a version that was activated before any offset was saved", func() {
+			Expect(activateVersion(1)).To(BeTrue())
+
+			err := saveVersionOffset(1, 0, 1)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(loadVersionOffset(1)).To(BeNumerically("==", 1))
+		})
+	})
+
+	fn("ResetOffset", func() {
+		It("resets the offset to zero", func() {
+			err := saveOffset(0, 5)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = atomically(func(tx persistence.Tx) error {
+				return store.ResetOffset(ctx, tx, "<pk>", 0)
+			})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(loadOffset()).To(BeNumerically("==", 0))
+
+			err = saveOffset(5, 6)
+			Expect(projection.IsConflict(err)).To(BeTrue())
+
+			err = saveOffset(0, 1)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		It("allows an offset to be saved if no offset was saved before the reset", func() {
+			err := atomically(func(tx persistence.Tx) error {
+				return store.ResetOffset(ctx, tx, "<pk>", 0)
+			})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = saveOffset(0, 1)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(loadOffset()).To(BeNumerically("==", 1))
+		})
+
+		It("does not deactivate the version", func() {
+			Expect(activateVersion(1)).To(BeTrue())
+
+			err := saveVersionOffset(1, 0, 5)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = atomically(func(tx persistence.Tx) error {
+				return store.ResetOffset(ctx, tx, "<pk>", 1)
+			})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			v, ok := loadActiveVersion()
+			Expect(ok).To(BeTrue())
+			Expect(v).To(BeNumerically("==", 1))
+		})
+	})
+
+	fn("ActivateVersion", func() {
+		It("activates the version", func() {
+			Expect(activateVersion(1)).To(BeTrue())
+
+			v, ok := loadActiveVersion()
+			Expect(ok).To(BeTrue())
+			Expect(v).To(BeNumerically("==", 1))
+		})
+
+		It("replaces an earlier active version", func() {
+			Expect(activateVersion(1)).To(BeTrue())
+			Expect(activateVersion(2)).To(BeTrue())
+
+			v, _ := loadActiveVersion()
+			Expect(v).To(BeNumerically("==", 2))
+		})
+
+		It("does not replace a later active version", func() {
+			Expect(activateVersion(2)).To(BeTrue())
+			Expect(activateVersion(1)).To(BeFalse())
+
+			v, _ := loadActiveVersion()
+			Expect(v).To(BeNumerically("==", 2))
+		})
+
+		It("does not change the stored offsets", func() {
+			err := saveVersionOffset(1, 0, 5)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(activateVersion(1)).To(BeTrue())
+			Expect(activateVersion(2)).To(BeTrue())
+
+			Expect(loadVersionOffset(1)).To(BeNumerically("==", 5))
+			Expect(loadVersionOffset(2)).To(BeNumerically("==", 0))
+		})
+	})
+
+	fn("Migrations", func() {
+		It("activates existing offsets when upgrading from version 1, and can be applied again", func() {
+			c := store.Migrations()
+			all := c.Migrations
+
+			_, err := db.Exec(`DROP TABLE ax_projection_offset`)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			_, err = db.Exec(`DELETE FROM ax_schema_version WHERE component = ?`, c.Name)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			c.Migrations = all[:1]
+			_, err = migration.Apply(ctx, db, "", c)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			_, err = db.Exec(`INSERT INTO ax_projection_offset SET persistence_key = '<pk>', next_offset = 5`)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			c.Migrations = all
+			_, err = migration.Apply(ctx, db, "", c)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			// simulate a failure to record the version after the migration's
+			// statements were executed.
+			_, err = db.Exec(`UPDATE ax_schema_version SET version = 1 WHERE component = ?`, c.Name)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			_, err = migration.Apply(ctx, db, "", c)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			v, ok := loadActiveVersion()
+			Expect(ok).To(BeTrue())
+			Expect(v).To(BeNumerically("==", 0))
+			Expect(loadOffset()).To(BeNumerically("==", 5))
+
+			err = saveVersionOffset(1, 0, 1)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			v, _ = loadActiveVersion()
+			Expect(v).To(BeNumerically("==", 0))
+		})
+	})
+
+	fn("LoadActiveVersion", func() {
+		It("returns false if no version has been activated", func() {
+			err := saveVersionOffset(1, 0, 5)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			_, ok := loadActiveVersion()
+			Expect(ok).To(BeFalse())
+		})
+	})
+})
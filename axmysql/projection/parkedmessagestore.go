@@ -0,0 +1,171 @@
+package projection
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmalloc/ax/axmysql/internal/sqlutil"
+	mysqlpersistence "github.com/jmalloc/ax/axmysql/persistence"
+	"github.com/jmalloc/ax/persistence"
+	"github.com/jmalloc/ax/projection"
+)
+
+// ParkedMessageStore is a MySQL-backed implementation of Ax's
+// projection.ParkedMessageStore interface.
+type ParkedMessageStore struct {
+	// TablePrefix is the prefix used for the names of the parked message
+	// store's tables. It may include a schema name, such as "billing.ax_". If
+	// it is empty, "ax_" is used.
+	TablePrefix string
+}
+
+// ParkMessage records that the message at offset m.Offset has been parked.
+//
+// If the message is already parked, its details are replaced with m.
+func (s ParkedMessageStore) ParkMessage(
+	ctx context.Context,
+	ptx persistence.Tx,
+	pk string,
+	v uint64,
+	m projection.ParkedMessage,
+) error {
+	tx := mysqlpersistence.ExtractTx(ptx)
+
+	_, err := tx.ExecContext(
+		ctx,
+		`INSERT INTO `+s.table("projection_parked_message")+` SET
+			persistence_key = ?,
+			version = ?,
+			global_offset = ?,
+			message_id = ?,
+			message_type = ?,
+			error_message = ?,
+			parked_at = FROM_UNIXTIME(0) + INTERVAL ? MICROSECOND
+		ON DUPLICATE KEY UPDATE
+			message_id = VALUES(message_id),
+			message_type = VALUES(message_type),
+			error_message = VALUES(error_message),
+			parked_at = VALUES(parked_at)`,
+		pk,
+		v,
+		m.Offset,
+		m.MessageID.Get(),
+		m.MessageType,
+		m.Error,
+		m.ParkedAt.UnixNano()/int64(time.Microsecond),
+	)
+
+	return err
+}
+
+// LoadParkedMessages returns the parked messages, in order of their offset.
+func (s ParkedMessageStore) LoadParkedMessages(
+	ctx context.Context,
+	ds persistence.DataStore,
+	pk string,
+	v uint64,
+) ([]projection.ParkedMessage, error) {
+	db := mysqlpersistence.ExtractDB(ds)
+
+	rows, err := db.QueryContext(
+		ctx,
+		`SELECT
+			global_offset,
+			message_id,
+			message_type,
+			error_message,
+			CAST(UNIX_TIMESTAMP(parked_at) * 1000000 AS SIGNED)
+		FROM `+s.table("projection_parked_message")+`
+		WHERE persistence_key = ?
+		AND version = ?
+		ORDER BY global_offset`,
+		pk,
+		v,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []projection.ParkedMessage
+
+	for rows.Next() {
+		var (
+			m        projection.ParkedMessage
+			parkedAt int64 // microseconds since the Unix epoch
+		)
+
+		if err := rows.Scan(
+			&m.Offset,
+			&m.MessageID,
+			&m.MessageType,
+			&m.Error,
+			&parkedAt,
+		); err != nil {
+			return nil, err
+		}
+
+		m.ParkedAt = time.Unix(0, parkedAt*int64(time.Microsecond))
+		messages = append(messages, m)
+	}
+
+	return messages, rows.Err()
+}
+
+// UnparkMessage removes the message at offset o from the parked messages.
+//
+// It returns false if the message is not parked.
+func (s ParkedMessageStore) UnparkMessage(
+	ctx context.Context,
+	ptx persistence.Tx,
+	pk string,
+	v uint64,
+	o uint64,
+) (bool, error) {
+	tx := mysqlpersistence.ExtractTx(ptx)
+
+	res, err := tx.ExecContext(
+		ctx,
+		`DELETE FROM `+s.table("projection_parked_message")+`
+		WHERE persistence_key = ?
+		AND version = ?
+		AND global_offset = ?`,
+		pk,
+		v,
+		o,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+
+	return n == 1, err
+}
+
+// ResetParkedMessages removes all of the parked messages.
+func (s ParkedMessageStore) ResetParkedMessages(
+	ctx context.Context,
+	ptx persistence.Tx,
+	pk string,
+	v uint64,
+) error {
+	tx := mysqlpersistence.ExtractTx(ptx)
+
+	_, err := tx.ExecContext(
+		ctx,
+		`DELETE FROM `+s.table("projection_parked_message")+`
+		WHERE persistence_key = ?
+		AND version = ?`,
+		pk,
+		v,
+	)
+
+	return err
+}
+
+// table returns the name of the table with the given name, including the
+// table prefix.
+func (s ParkedMessageStore) table(name string) string {
+	return sqlutil.Table(s.TablePrefix, name)
+}
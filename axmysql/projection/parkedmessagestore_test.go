@@ -0,0 +1,191 @@
+package projection_test
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/jmalloc/ax"
+	"github.com/jmalloc/ax/axmysql"
+	"github.com/jmalloc/ax/axmysql/internal/schema"
+	. "github.com/jmalloc/ax/axmysql/projection"
+	"github.com/jmalloc/ax/persistence"
+	"github.com/jmalloc/ax/projection"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ParkedMessageStore", func() {
+	dsn := os.Getenv("AX_MYSQL_DSN")
+
+	var (
+		ctx    context.Context
+		cancel func()
+		db     *sql.DB
+		ds     persistence.DataStore
+		store  ParkedMessageStore
+		m1, m2 projection.ParkedMessage
+	)
+
+	atomically := func(fn func(tx persistence.Tx) error) error {
+		tx, com, err := ds.BeginTx(ctx)
+		if err != nil {
+			return err
+		}
+		defer com.Rollback()
+
+		if err := fn(tx); err != nil {
+			return err
+		}
+
+		return com.Commit()
+	}
+
+	park := func(v uint64, m projection.ParkedMessage) {
+		err := atomically(func(tx persistence.Tx) error {
+			return store.ParkMessage(ctx, tx, "<pk>", v, m)
+		})
+		Expect(err).ShouldNot(HaveOccurred())
+	}
+
+	load := func(v uint64) []projection.ParkedMessage {
+		m, err := store.LoadParkedMessages(ctx, ds, "<pk>", v)
+		Expect(err).ShouldNot(HaveOccurred())
+		return m
+	}
+
+	BeforeEach(func() {
+		var fn func()
+		ctx, fn = context.WithTimeout(context.Background(), 10*time.Second)
+		cancel = fn // defeat go vet warning about unused cancel func
+
+		var err error
+		db, err = sql.Open("mysql", dsn)
+		if err != nil {
+			panic(err)
+		}
+
+		if err := schema.Create(db, store.Migrations()); err != nil {
+			panic(err)
+		}
+
+		ds = axmysql.NewDataStore(db)
+
+		// parked_at is truncated to whole seconds so that it survives the round
+		// trip regardless of the server's timestamp precision.
+		parkedAt := time.Now().Truncate(time.Second)
+
+		m1 = projection.ParkedMessage{
+			Offset:      5,
+			MessageID:   ax.MustParseMessageID("<message-1>"),
+			MessageType: "ax.test.MessageA",
+			Error:       "<error 1>",
+			ParkedAt:    parkedAt,
+		}
+
+		m2 = projection.ParkedMessage{
+			Offset:      2,
+			MessageID:   ax.MustParseMessageID("<message-2>"),
+			MessageType: "ax.test.MessageB",
+			Error:       "<error 2>",
+			ParkedAt:    parkedAt,
+		}
+	})
+
+	AfterEach(func() {
+		if db != nil {
+			db.Close()
+		}
+
+		cancel()
+	})
+
+	fn := Describe
+	if dsn == "" {
+		fn = XDescribe
+	}
+
+	matchMessages := func(actual, expected []projection.ParkedMessage) {
+		Expect(actual).To(HaveLen(len(expected)))
+
+		for i, m := range expected {
+			Expect(actual[i].ParkedAt.Equal(m.ParkedAt)).To(BeTrue())
+			actual[i].ParkedAt = m.ParkedAt
+			Expect(actual[i]).To(Equal(m))
+		}
+	}
+
+	fn("ParkMessage", func() {
+		It("parks the message", func() {
+			park(0, m1)
+			park(0, m2)
+
+			matchMessages(load(0), []projection.ParkedMessage{m2, m1})
+		})
+
+		It("replaces the details of a message that is already parked", func() {
+			park(0, m1)
+
+			m1.Error = "<updated>"
+			park(0, m1)
+
+			matchMessages(load(0), []projection.ParkedMessage{m1})
+		})
+
+		It("parks messages separately for each version", func() {
+			park(1, m1)
+			park(2, m2)
+
+			Expect(load(0)).To(BeEmpty())
+			matchMessages(load(1), []projection.ParkedMessage{m1})
+			matchMessages(load(2), []projection.ParkedMessage{m2})
+		})
+	})
+
+	fn("UnparkMessage", func() {
+		It("removes the message", func() {
+			park(0, m1)
+			park(0, m2)
+
+			var ok bool
+			err := atomically(func(tx persistence.Tx) error {
+				var err error
+				ok, err = store.UnparkMessage(ctx, tx, "<pk>", 0, m1.Offset)
+				return err
+			})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(ok).To(BeTrue())
+
+			matchMessages(load(0), []projection.ParkedMessage{m2})
+		})
+
+		It("returns false if the message is not parked", func() {
+			var ok bool
+			err := atomically(func(tx persistence.Tx) error {
+				var err error
+				ok, err = store.UnparkMessage(ctx, tx, "<pk>", 0, m1.Offset)
+				return err
+			})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(ok).To(BeFalse())
+		})
+	})
+
+	fn("ResetParkedMessages", func() {
+		It("removes all messages for the version", func() {
+			park(0, m1)
+			park(0, m2)
+			park(1, m1)
+
+			err := atomically(func(tx persistence.Tx) error {
+				return store.ResetParkedMessages(ctx, tx, "<pk>", 0)
+			})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(load(0)).To(BeEmpty())
+			Expect(load(1)).To(HaveLen(1))
+		})
+	})
+})
@@ -44,6 +44,33 @@ type ReadModel interface {
 	PersistenceKey() string
 }
 
+// ReadModelResetter is an interface for read-models that can discard their
+// state, so that they can be rebuilt from the beginning of the stream.
+type ReadModelResetter interface {
+	ReadModel
+
+	// ResetReadModel discards all of the read-model's state, for example by
+	// deleting all of the rows in its tables.
+	//
+	// It must not commit tx, either explicitly or by executing a statement
+	// that causes an implicit commit, such as TRUNCATE TABLE.
+	ResetReadModel(ctx context.Context, tx *sql.Tx) error
+}
+
+// VersionedReadModel is an interface for read-models that declare a version.
+//
+// The version must be incremented whenever a change to the read-model requires
+// it to be rebuilt, such as a change to the structure of its tables. Each
+// version must store its state separately, for example in tables with the
+// version in their name. Readers should use the active version, as per
+// projection.OffsetStore.LoadActiveVersion().
+type VersionedReadModel interface {
+	ReadModel
+
+	// ProjectionVersion returns the read-model's version.
+	ProjectionVersion() uint64
+}
+
 // ReadModelProjector is a projector that applies events to a ReadModel.
 type ReadModelProjector struct {
 	ReadModel  ReadModel
@@ -91,6 +118,18 @@ func (p ReadModelProjector) PersistenceKey() string {
 	return p.ReadModel.PersistenceKey()
 }
 
+// ProjectionVersion returns the version of the projector.
+//
+// If the read-model implements VersionedReadModel, its ProjectionVersion()
+// method is called. Otherwise, the version is zero.
+func (p ReadModelProjector) ProjectionVersion() uint64 {
+	if v, ok := p.ReadModel.(VersionedReadModel); ok {
+		return v.ProjectionVersion()
+	}
+
+	return 0
+}
+
 // MessageTypes returns the set of messages that the projector intends
 // to handle.
 //
@@ -123,6 +162,23 @@ func (p ReadModelProjector) ApplyMessage(ctx context.Context, mctx ax.MessageCon
 	return nil
 }
 
+// ResetProjection discards all of the state produced by the projector.
+//
+// If the read-model implements ReadModelResetter, its ResetReadModel() method
+// is called. Otherwise, the read-model's state is retained, and the read-model
+// must tolerate events being applied to it again.
+func (p ReadModelProjector) ResetProjection(ctx context.Context) error {
+	r, ok := p.ReadModel.(ReadModelResetter)
+	if !ok {
+		return nil
+	}
+
+	ptx, _ := persistence.GetTx(ctx)
+	tx := mysqlpersistence.ExtractTx(ptx)
+
+	return r.ResetReadModel(ctx, tx)
+}
+
 var (
 	readModelApplySignature = &typeswitch.Signature{
 		In: []reflect.Type{
@@ -3,4 +3,6 @@ package projection_test
 import "github.com/jmalloc/ax/projection"
 import . "github.com/jmalloc/ax/axmysql/projection"
 
-var _ projection.Projector = (*ReadModelProjector)(nil) // ensure ReadModelProjector implements Projector
+var _ projection.Projector = (*ReadModelProjector)(nil)          // ensure ReadModelProjector implements Projector
+var _ projection.Resetter = (*ReadModelProjector)(nil)           // ensure ReadModelProjector implements Resetter
+var _ projection.VersionedProjector = (*ReadModelProjector)(nil) // ensure ReadModelProjector implements VersionedProjector
@@ -13,7 +13,12 @@ import (
 
 // CRUDRepository is a MySQL-backed implementation of Ax's crud.Repository
 // interface.
-type CRUDRepository struct{}
+type CRUDRepository struct {
+	// TablePrefix is the prefix used for the names of the repository's
+	// tables. It may include a schema name, such as "billing.ax_". If it is
+	// empty, "ax_" is used.
+	TablePrefix string
+}
 
 // LoadSagaInstance fetches a saga instance by its ID.
 //
@@ -48,7 +53,7 @@ func (r CRUDRepository) LoadSagaInstance(
 			persistence_key,
 			content_type,
 			data
-		FROM ax_saga_instance
+		FROM `+r.table("saga_instance")+`
 		WHERE instance_id = ?`,
 		id,
 	).Scan(
@@ -156,7 +161,7 @@ func (r CRUDRepository) DeleteSagaInstance(
 }
 
 // insertInstance inserts a new saga instance.
-func (CRUDRepository) insertInstance(
+func (r CRUDRepository) insertInstance(
 	ctx context.Context,
 	tx *sql.Tx,
 	pk string,
@@ -173,7 +178,7 @@ func (CRUDRepository) insertInstance(
 
 	_, err := tx.ExecContext(
 		ctx,
-		`INSERT INTO ax_saga_instance SET
+		`INSERT INTO `+r.table("saga_instance")+` SET
 			instance_id = ?,
 			revision = 1,
 			persistence_key = ?,
@@ -196,7 +201,7 @@ func (CRUDRepository) insertInstance(
 
 // lockInstance selects and locks an instance at the given revision.
 // It returns false if i.Revision is not the current revision.
-func (CRUDRepository) lockInstance(
+func (r CRUDRepository) lockInstance(
 	ctx context.Context,
 	tx *sql.Tx,
 	pk string,
@@ -212,7 +217,7 @@ func (CRUDRepository) lockInstance(
 		`SELECT
 			revision,
 			persistence_key
-		FROM ax_saga_instance
+		FROM `+r.table("saga_instance")+`
 		WHERE instance_id = ?
 		FOR UPDATE`,
 		i.InstanceID,
@@ -261,7 +266,7 @@ func (r CRUDRepository) updateInstance(
 	return true, sqlutil.ExecSingleRow(
 		ctx,
 		tx,
-		`UPDATE ax_saga_instance SET
+		`UPDATE `+r.table("saga_instance")+` SET
 			revision = revision + 1,
 			description = ?,
 			content_type = ?,
@@ -290,7 +295,13 @@ func (r CRUDRepository) deleteInstance(
 	return true, sqlutil.ExecSingleRow(
 		ctx,
 		tx,
-		`DELETE FROM ax_saga_instance WHERE instance_id = ?`,
+		`DELETE FROM `+r.table("saga_instance")+` WHERE instance_id = ?`,
 		i.InstanceID,
 	)
 }
+
+// table returns the name of the table with the given name, including the
+// table prefix.
+func (r CRUDRepository) table(name string) string {
+	return sqlutil.Table(r.TablePrefix, name)
+}
@@ -13,14 +13,19 @@ import (
 
 // KeySetRepository is a MySQL-backed implementation of Ax's keyset.Repository
 // interface.
-type KeySetRepository struct{}
+type KeySetRepository struct {
+	// TablePrefix is the prefix used for the names of the repository's
+	// tables. It may include a schema name, such as "billing.ax_". If it is
+	// empty, "ax_" is used.
+	TablePrefix string
+}
 
 // FindByKey returns the ID of a saga instance that has a specific key in
 // its key set.
 //
 // pk is the saga's persistence key, mk is the mapping key.
 // ok is false if no saga instance has a key set containing mk.
-func (KeySetRepository) FindByKey(
+func (r KeySetRepository) FindByKey(
 	ctx context.Context,
 	ptx persistence.Tx,
 	pk, mk string,
@@ -31,7 +36,7 @@ func (KeySetRepository) FindByKey(
 		ctx,
 		`SELECT
 			instance_id
-		FROM ax_saga_keyset
+		FROM `+r.table("saga_keyset")+`
 		WHERE persistence_key = ?
 		AND mapping_key = ?`,
 		pk,
@@ -73,7 +78,7 @@ func (r KeySetRepository) SaveKeys(
 	for _, mk := range ks {
 		if _, err := tx.ExecContext(
 			ctx,
-			`INSERT INTO ax_saga_keyset SET
+			`INSERT INTO `+r.table("saga_keyset")+` SET
 				persistence_key = ?,
 				mapping_key = ?,
 				instance_id = ?`,
@@ -110,7 +115,7 @@ func (r KeySetRepository) DeleteKeys(
 	return r.deleteKeys(ctx, tx, pk, id)
 }
 
-func (KeySetRepository) deleteKeys(
+func (r KeySetRepository) deleteKeys(
 	ctx context.Context,
 	tx *sql.Tx,
 	pk string,
@@ -118,7 +123,7 @@ func (KeySetRepository) deleteKeys(
 ) error {
 	_, err := tx.ExecContext(
 		ctx,
-		`DELETE FROM ax_saga_keyset
+		`DELETE FROM `+r.table("saga_keyset")+`
 		WHERE persistence_key = ?
 		AND instance_id = ?`,
 		pk,
@@ -127,3 +132,9 @@ func (KeySetRepository) deleteKeys(
 
 	return err
 }
+
+// table returns the name of the table with the given name, including the
+// table prefix.
+func (r KeySetRepository) table(name string) string {
+	return sqlutil.Table(r.TablePrefix, name)
+}
@@ -0,0 +1,83 @@
+package saga
+
+import "github.com/jmalloc/ax/axmysql/migration"
+
+// Migrations returns the schema migrations for the CRUD saga instance table.
+func (r CRUDRepository) Migrations() migration.Component {
+	return migration.Component{
+		Name: r.table("saga_crud"),
+		Migrations: []migration.Migration{
+			{
+				Version:     1,
+				Description: "create the saga instance table",
+				Statements: []string{
+					// ax_saga_instance stores saga.Data instances for each instance of a CRUD saga.
+					`CREATE TABLE IF NOT EXISTS ` + r.table("saga_instance") + ` (
+						instance_id     VARBINARY(255) NOT NULL,
+						revision        BIGINT UNSIGNED NOT NULL,
+						persistence_key VARBINARY(255) NOT NULL,
+						description     VARBINARY(255) NOT NULL,
+						content_type    VARBINARY(255) NOT NULL,
+						data            LONGBLOB NOT NULL,
+						insert_time     TIMESTAMP(6) DEFAULT CURRENT_TIMESTAMP(6),
+						update_time     TIMESTAMP(6) DEFAULT CURRENT_TIMESTAMP(6) ON UPDATE CURRENT_TIMESTAMP(6),
+
+						PRIMARY KEY (instance_id)
+					) ROW_FORMAT=COMPRESSED`,
+				},
+			},
+		},
+	}
+}
+
+// Migrations returns the schema migrations for the saga key-set table.
+func (r KeySetRepository) Migrations() migration.Component {
+	return migration.Component{
+		Name: r.table("saga_keyset"),
+		Migrations: []migration.Migration{
+			{
+				Version:     1,
+				Description: "create the saga key-set table",
+				Statements: []string{
+					// ax_saga_keyset contains the "key sets" that are associated with saga instances
+					// that use keyset.Mapper.
+					`CREATE TABLE IF NOT EXISTS ` + r.table("saga_keyset") + ` (
+						persistence_key VARBINARY(255) NOT NULL,
+						mapping_key     VARBINARY(255) NOT NULL,
+						instance_id     VARBINARY(255) NOT NULL,
+
+						PRIMARY KEY (persistence_key, mapping_key),
+						INDEX (instance_id)
+					) ROW_FORMAT=COMPRESSED`,
+				},
+			},
+		},
+	}
+}
+
+// Migrations returns the schema migrations for the saga snapshot table.
+func (r SnapshotRepository) Migrations() migration.Component {
+	return migration.Component{
+		Name: r.table("saga_snapshot"),
+		Migrations: []migration.Migration{
+			{
+				Version:     1,
+				Description: "create the saga snapshot table",
+				Statements: []string{
+					// ax_saga_snapshot stores snapshots saga.Data instances for eventsourced sagas.
+					`CREATE TABLE IF NOT EXISTS ` + r.table("saga_snapshot") + ` (
+						instance_id     VARBINARY(255) NOT NULL,
+						revision        BIGINT UNSIGNED NOT NULL,
+						persistence_key VARBINARY(255) NOT NULL,
+						description     VARBINARY(255) NOT NULL,
+						content_type    VARBINARY(255) NOT NULL,
+						data            LONGBLOB NOT NULL,
+						insert_time     TIMESTAMP(6) DEFAULT CURRENT_TIMESTAMP(6),
+
+						PRIMARY KEY (instance_id, revision)
+					) ROW_FORMAT=COMPRESSED`,
+				},
+			},
+		},
+	}
+}
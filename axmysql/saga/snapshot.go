@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"fmt"
 
+	"github.com/jmalloc/ax/axmysql/internal/sqlutil"
 	mysqlpersistence "github.com/jmalloc/ax/axmysql/persistence"
 	"github.com/jmalloc/ax/persistence"
 	"github.com/jmalloc/ax/saga"
@@ -12,13 +13,18 @@ import (
 
 // SnapshotRepository is a MySQL-backed implementation of Ax's
 // eventsourcing.SnapshotRepository interface.
-type SnapshotRepository struct{}
+type SnapshotRepository struct {
+	// TablePrefix is the prefix used for the names of the repository's
+	// tables. It may include a schema name, such as "billing.ax_". If it is
+	// empty, "ax_" is used.
+	TablePrefix string
+}
 
 // LoadSagaSnapshot loads the latest available snapshot from the store.
 //
 // It returns an error if a snapshot of this instance is found, but belongs to
 // a different saga, as identified by pk, the saga's persistence key.
-func (SnapshotRepository) LoadSagaSnapshot(
+func (r SnapshotRepository) LoadSagaSnapshot(
 	ctx context.Context,
 	ptx persistence.Tx,
 	pk string,
@@ -41,7 +47,7 @@ func (SnapshotRepository) LoadSagaSnapshot(
 			persistence_key,
 			content_type,
 			data
-		FROM ax_saga_snapshot
+		FROM `+r.table("saga_snapshot")+`
 		WHERE instance_id = ?
 		ORDER BY revision DESC
 		LIMIT 1`,
@@ -79,7 +85,7 @@ func (SnapshotRepository) LoadSagaSnapshot(
 //
 // This implementation does not verify the saga's persistence key against
 // existing snapshots of the same instance.
-func (SnapshotRepository) SaveSagaSnapshot(
+func (r SnapshotRepository) SaveSagaSnapshot(
 	ctx context.Context,
 	ptx persistence.Tx,
 	pk string,
@@ -101,7 +107,7 @@ func (SnapshotRepository) SaveSagaSnapshot(
 
 	_, err = tx.ExecContext(
 		ctx,
-		`INSERT INTO ax_saga_snapshot SET
+		`INSERT INTO `+r.table("saga_snapshot")+` SET
 			instance_id = ?,
 			revision = ?,
 			persistence_key = ?,
@@ -123,7 +129,7 @@ func (SnapshotRepository) SaveSagaSnapshot(
 //
 // This implementation does not verify the saga's persistence key. It simply
 // ignores any snapshots that match the instance ID, but not the persistence key.
-func (SnapshotRepository) DeleteSagaSnapshots(
+func (r SnapshotRepository) DeleteSagaSnapshots(
 	ctx context.Context,
 	ptx persistence.Tx,
 	pk string,
@@ -133,7 +139,7 @@ func (SnapshotRepository) DeleteSagaSnapshots(
 
 	_, err := tx.ExecContext(
 		ctx,
-		`DELETE FROM ax_saga_snapshot
+		`DELETE FROM `+r.table("saga_snapshot")+`
 		WHERE persistence_key = ?
 		AND instance_id = ?`,
 		pk,
@@ -142,3 +148,9 @@ func (SnapshotRepository) DeleteSagaSnapshots(
 
 	return err
 }
+
+// table returns the name of the table with the given name, including the
+// table prefix.
+func (r SnapshotRepository) table(name string) string {
+	return sqlutil.Table(r.TablePrefix, name)
+}
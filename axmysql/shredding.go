@@ -0,0 +1,10 @@
+package axmysql
+
+import (
+	mysqlshredding "github.com/jmalloc/ax/axmysql/shredding"
+	"github.com/jmalloc/ax/messagestore/shredding"
+)
+
+// ShreddingKeyStore is a crypto-shredding key store backed by a MySQL
+// database.
+var ShreddingKeyStore shredding.KeyStore = mysqlshredding.KeyStore{}
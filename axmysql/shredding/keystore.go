@@ -0,0 +1,162 @@
+package shredding
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jmalloc/ax/axmysql/internal/sqlutil"
+	mysqlpersistence "github.com/jmalloc/ax/axmysql/persistence"
+	"github.com/jmalloc/ax/messagestore/shredding"
+	"github.com/jmalloc/ax/persistence"
+)
+
+// KeyStore is a MySQL-backed implementation of Ax's shredding.KeyStore
+// interface.
+type KeyStore struct {
+	// TablePrefix is the prefix used for the names of the key store's tables.
+	// It may include a schema name, such as "billing.ax_". If it is empty,
+	// "ax_" is used.
+	TablePrefix string
+}
+
+// LoadOrCreateKey loads the encryption key for a data subject, creating a new
+// key if the subject does not have one.
+//
+// It returns false if the subject's key has been deleted. The subject's row is
+// locked until ptx ends, so that the key can not be deleted by another
+// transaction while it is in use.
+func (s KeyStore) LoadOrCreateKey(
+	ctx context.Context,
+	ptx persistence.Tx,
+	subject string,
+) ([]byte, bool, error) {
+	tx := mysqlpersistence.ExtractTx(ptx)
+
+	k, exists, err := s.lockKey(ctx, tx, subject)
+	if exists || err != nil {
+		return k, k != nil, err
+	}
+
+	k, err = shredding.GenerateKey()
+	if err != nil {
+		return nil, false, err
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`INSERT INTO `+s.table("shredding_key")+` SET
+			subject = ?,
+			encryption_key = ?`,
+		subject,
+		k,
+	)
+
+	if sqlutil.IsDuplicateEntry(err) {
+		// another transaction has created or deleted the subject's key since
+		// it was loaded above.
+		k, _, err = s.lockKey(ctx, tx, subject)
+		return k, k != nil, err
+	} else if err != nil {
+		return nil, false, err
+	}
+
+	return k, true, nil
+}
+
+// LoadKey loads the encryption key for a data subject.
+//
+// It returns false if the subject does not have a key, or if the key has been
+// deleted. The key is always read from the primary database, so that a
+// deleted key is never read from a replica that has not yet caught up.
+func (s KeyStore) LoadKey(
+	ctx context.Context,
+	ds persistence.DataStore,
+	subject string,
+) ([]byte, bool, error) {
+	return scanKey(mysqlpersistence.ExtractDB(ds).QueryRowContext(
+		ctx,
+		`SELECT
+			encryption_key
+		FROM `+s.table("shredding_key")+`
+		WHERE subject = ?`,
+		subject,
+	))
+}
+
+// DeleteKey permanently deletes the encryption key for a data subject.
+//
+// A row is retained for the subject, so that a new key is never created for
+// them.
+func (s KeyStore) DeleteKey(
+	ctx context.Context,
+	ptx persistence.Tx,
+	subject string,
+) error {
+	tx := mysqlpersistence.ExtractTx(ptx)
+
+	_, err := tx.ExecContext(
+		ctx,
+		`INSERT INTO `+s.table("shredding_key")+` SET
+			subject = ?,
+			encryption_key = NULL,
+			delete_time = CURRENT_TIMESTAMP(6)
+		ON DUPLICATE KEY UPDATE
+			encryption_key = NULL,
+			delete_time = COALESCE(delete_time, VALUES(delete_time))`,
+		subject,
+	)
+
+	return err
+}
+
+// lockKey loads the encryption key for a data subject, and locks the subject's
+// row until tx ends. It returns false if there is no row for the subject. The
+// key is nil if it has been deleted.
+func (s KeyStore) lockKey(
+	ctx context.Context,
+	tx *sql.Tx,
+	subject string,
+) ([]byte, bool, error) {
+	var k []byte
+
+	err := tx.QueryRowContext(
+		ctx,
+		`SELECT
+			encryption_key
+		FROM `+s.table("shredding_key")+`
+		WHERE subject = ?
+		FOR UPDATE`,
+		subject,
+	).Scan(
+		&k,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+
+	return k, true, nil
+}
+
+// scanKey returns the encryption key in row.
+func scanKey(row *sql.Row) ([]byte, bool, error) {
+	var k []byte
+
+	err := row.Scan(&k)
+
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+
+	return k, k != nil, nil
+}
+
+// table returns the name of the table with the given name, including the
+// table prefix.
+func (s KeyStore) table(name string) string {
+	return sqlutil.Table(s.TablePrefix, name)
+}
@@ -0,0 +1,206 @@
+package shredding_test
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/jmalloc/ax/axmysql"
+	"github.com/jmalloc/ax/axmysql/internal/schema"
+	. "github.com/jmalloc/ax/axmysql/shredding"
+	"github.com/jmalloc/ax/messagestore/shredding"
+	"github.com/jmalloc/ax/persistence"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("KeyStore", func() {
+	dsn := os.Getenv("AX_MYSQL_DSN")
+
+	var (
+		ctx    context.Context
+		cancel func()
+		db     *sql.DB
+		ds     persistence.DataStore
+		store  KeyStore
+	)
+
+	atomically := func(fn func(tx persistence.Tx) error) error {
+		tx, com, err := ds.BeginTx(ctx)
+		if err != nil {
+			return err
+		}
+		defer com.Rollback()
+
+		if err := fn(tx); err != nil {
+			return err
+		}
+
+		return com.Commit()
+	}
+
+	loadOrCreateKey := func(subject string) ([]byte, bool) {
+		var (
+			k  []byte
+			ok bool
+		)
+
+		err := atomically(func(tx persistence.Tx) error {
+			var err error
+			k, ok, err = store.LoadOrCreateKey(ctx, tx, subject)
+			return err
+		})
+		Expect(err).ShouldNot(HaveOccurred())
+
+		return k, ok
+	}
+
+	deleteKey := func(subject string) {
+		err := atomically(func(tx persistence.Tx) error {
+			return store.DeleteKey(ctx, tx, subject)
+		})
+		Expect(err).ShouldNot(HaveOccurred())
+	}
+
+	BeforeEach(func() {
+		var fn func()
+		ctx, fn = context.WithTimeout(context.Background(), 10*time.Second)
+		cancel = fn // defeat go vet warning about unused cancel func
+
+		var err error
+		db, err = sql.Open("mysql", dsn)
+		if err != nil {
+			panic(err)
+		}
+
+		if err := schema.Create(db, store.Migrations()); err != nil {
+			panic(err)
+		}
+
+		ds = axmysql.NewDataStore(db)
+	})
+
+	AfterEach(func() {
+		if db != nil {
+			db.Close()
+		}
+
+		cancel()
+	})
+
+	fn := Describe
+	if dsn == "" {
+		fn = XDescribe
+	}
+
+	fn("LoadOrCreateKey", func() {
+		It("creates a new key for a subject that does not have one", func() {
+			k, ok := loadOrCreateKey("<subject>")
+			Expect(ok).To(BeTrue())
+			Expect(k).To(HaveLen(shredding.KeySize))
+		})
+
+		It("returns the existing key for a subject", func() {
+			k1, _ := loadOrCreateKey("<subject>")
+			k2, ok := loadOrCreateKey("<subject>")
+			Expect(ok).To(BeTrue())
+			Expect(k2).To(Equal(k1))
+		})
+
+		It("creates a different key for each subject", func() {
+			k1, _ := loadOrCreateKey("<subject-1>")
+			k2, _ := loadOrCreateKey("<subject-2>")
+			Expect(k2).NotTo(Equal(k1))
+		})
+
+		It("returns false if the key has been deleted", func() {
+			loadOrCreateKey("<subject>")
+			deleteKey("<subject>")
+
+			_, ok := loadOrCreateKey("<subject>")
+			Expect(ok).To(BeFalse())
+		})
+
+		It("returns the key created by a concurrent transaction", func() {
+			tx1, com1, err := ds.BeginTx(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+			defer com1.Rollback()
+
+			k1, _, err := store.LoadOrCreateKey(ctx, tx1, "<subject>")
+			Expect(err).ShouldNot(HaveOccurred())
+
+			type result struct {
+				k   []byte
+				err error
+			}
+
+			done := make(chan result, 1)
+			go func() {
+				var r result
+				r.err = atomically(func(tx persistence.Tx) error {
+					var err error
+					r.k, _, err = store.LoadOrCreateKey(ctx, tx, "<subject>")
+					return err
+				})
+				done <- r
+			}()
+
+			// give the second transaction time to block on the first
+			time.Sleep(100 * time.Millisecond)
+
+			err = com1.Commit()
+			Expect(err).ShouldNot(HaveOccurred())
+
+			r := <-done
+			Expect(r.err).ShouldNot(HaveOccurred())
+			Expect(r.k).To(Equal(k1))
+		})
+
+		It("does not create a key for a subject that was forgotten before they had one", func() {
+			deleteKey("<subject>")
+
+			_, ok := loadOrCreateKey("<subject>")
+			Expect(ok).To(BeFalse())
+		})
+	})
+
+	fn("LoadKey", func() {
+		It("returns the subject's key", func() {
+			k1, _ := loadOrCreateKey("<subject>")
+
+			k2, ok, err := store.LoadKey(ctx, ds, "<subject>")
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(ok).To(BeTrue())
+			Expect(k2).To(Equal(k1))
+		})
+
+		It("returns false if the subject does not have a key", func() {
+			_, ok, err := store.LoadKey(ctx, ds, "<subject>")
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(ok).To(BeFalse())
+		})
+
+		It("returns false if the key has been deleted", func() {
+			loadOrCreateKey("<subject>")
+			deleteKey("<subject>")
+
+			_, ok, err := store.LoadKey(ctx, ds, "<subject>")
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(ok).To(BeFalse())
+		})
+	})
+
+	fn("DeleteKey", func() {
+		It("can be called repeatedly", func() {
+			loadOrCreateKey("<subject>")
+			deleteKey("<subject>")
+			deleteKey("<subject>")
+
+			_, ok, err := store.LoadKey(ctx, ds, "<subject>")
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(ok).To(BeFalse())
+		})
+	})
+})
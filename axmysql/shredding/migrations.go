@@ -0,0 +1,29 @@
+package shredding
+
+import "github.com/jmalloc/ax/axmysql/migration"
+
+// Migrations returns the schema migrations for the crypto-shredding key
+// table.
+func (s KeyStore) Migrations() migration.Component {
+	return migration.Component{
+		Name: s.table("shredding_keystore"),
+		Migrations: []migration.Migration{
+			{
+				Version:     1,
+				Description: "create the crypto-shredding key table",
+				Statements: []string{
+					// ax_shredding_key stores the encryption key for each data subject.
+					// encryption_key is NULL if the key has been deleted.
+					`CREATE TABLE IF NOT EXISTS ` + s.table("shredding_key") + ` (
+						subject        VARBINARY(255) NOT NULL,
+						encryption_key VARBINARY(255),
+						insert_time    TIMESTAMP(6) DEFAULT CURRENT_TIMESTAMP(6),
+						delete_time    TIMESTAMP(6) NULL,
+
+						PRIMARY KEY (subject)
+					) ROW_FORMAT=COMPRESSED`,
+				},
+			},
+		},
+	}
+}
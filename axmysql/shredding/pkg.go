@@ -0,0 +1,3 @@
+// Package shredding provides MySQL-specific implementations of the
+// interfaces in Ax's "messagestore/shredding" package.
+package shredding
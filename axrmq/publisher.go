@@ -41,26 +41,103 @@ type channel struct {
 	Confirm chan amqp.Confirmation
 }
 
-// PublishUnicast sends a unicast message directly to a specific endpoint.
-func (p *publisher) PublishUnicast(ctx context.Context, pub amqp.Publishing, ep string) error {
-	return p.publish(
-		ctx,
+// publication is a message to be published to a specific exchange.
+type publication struct {
+	Exchange  string
+	Key       string
+	Mandatory bool
+	Msg       amqp.Publishing
+}
+
+// unicastPublication returns a publication that sends a unicast message
+// directly to a specific endpoint.
+func unicastPublication(pub amqp.Publishing, ep string) publication {
+	return publication{
 		unicastExchange,
 		unicastRoutingKey(pub.Type, ep),
 		true, // mandatory
 		pub,
-	)
+	}
 }
 
-// PublishMulticast sends a multicast message to the its subscribers.
-func (p *publisher) PublishMulticast(ctx context.Context, pub amqp.Publishing) error {
-	return p.publish(
-		ctx,
+// multicastPublication returns a publication that sends a multicast message to
+// its subscribers.
+func multicastPublication(pub amqp.Publishing) publication {
+	return publication{
 		multicastExchange,
 		multicastRoutingKey(pub.Type),
 		false, // mandatory
 		pub,
-	)
+	}
+}
+
+// PublishUnicast sends a unicast message directly to a specific endpoint.
+func (p *publisher) PublishUnicast(ctx context.Context, pub amqp.Publishing, ep string) error {
+	u := unicastPublication(pub, ep)
+	return p.publish(ctx, u.Exchange, u.Key, u.Mandatory, u.Msg)
+}
+
+// PublishMulticast sends a multicast message to the its subscribers.
+func (p *publisher) PublishMulticast(ctx context.Context, pub amqp.Publishing) error {
+	m := multicastPublication(pub)
+	return p.publish(ctx, m.Exchange, m.Key, m.Mandatory, m.Msg)
+}
+
+// PublishBatch sends several messages to the broker on a single channel.
+//
+// Each message is published without waiting for the confirmation of the
+// previous one, then it blocks until all confirmations are received. It returns
+// an error if the broker does not acknowledge publication of any one of the
+// messages.
+func (p *publisher) PublishBatch(ctx context.Context, pubs []publication) error {
+	ch, err := p.acquire()
+	if err != nil {
+		return err
+	}
+
+	// confirmations are consumed while publishing so that the AMQP library
+	// never blocks on delivering a confirmation to a full Go channel.
+	result := make(chan confirmResult, 1)
+	go func() {
+		result <- awaitConfirms(ch, len(pubs))
+	}()
+
+	for _, pub := range pubs {
+		pub.Msg.DeliveryMode = 2 // persistent
+
+		if err := ch.Channel.Publish(
+			pub.Exchange,
+			pub.Key,
+			pub.Mandatory,
+			false, // immediate
+			pub.Msg,
+		); err != nil {
+			// the number of outstanding confirmations is now unknown, so the
+			// channel can not be reused.
+			_ = ch.Channel.Close()
+			return err
+		}
+	}
+
+	select {
+	case r := <-result:
+		if r.Reusable {
+			p.release(ch)
+		}
+
+		return r.Err
+
+	case <-ctx.Done():
+		// if our context is canceled before we receive the confirmations,
+		// return the channel to the pool only after they have been consumed.
+		go func() {
+			if r := <-result; r.Reusable {
+				p.release(ch)
+			}
+		}()
+
+		return ctx.Err()
+	}
 }
 
 func (p *publisher) RepublishAsError(ctx context.Context, del amqp.Delivery) error {
@@ -197,3 +274,48 @@ func (p *publisher) confirmThenRelease(ch *channel) {
 	case <-ch.Close:
 	}
 }
+
+// confirmResult is the result of waiting for the confirmation of a batch of
+// messages.
+type confirmResult struct {
+	// Err is the error that caused the batch to fail, if any.
+	Err error
+
+	// Reusable is true if all confirmations were received, and hence the
+	// channel can be returned to the pool.
+	Reusable bool
+}
+
+// awaitConfirms waits for n confirmations on ch.
+func awaitConfirms(ch *channel, n int) confirmResult {
+	var err error
+
+	for n > 0 {
+		select {
+		case r := <-ch.Return:
+			// a returned message is still confirmed by the broker, so keep
+			// waiting for the confirmation.
+			if err == nil {
+				err = errors.New("broker could not route message, " + r.ReplyText)
+			}
+
+		case c := <-ch.Confirm:
+			n--
+
+			// there's no more meaningful error to be returned here, see
+			// publish().
+			if !c.Ack && err == nil {
+				err = errors.New("broker did not confirm message publication")
+			}
+
+		case e := <-ch.Close:
+			if e != nil {
+				return confirmResult{Err: e}
+			}
+
+			return confirmResult{Err: errors.New("channel closed before all messages were confirmed")}
+		}
+	}
+
+	return confirmResult{Err: err, Reusable: true}
+}
@@ -103,6 +103,33 @@ func (t *Transport) Send(ctx context.Context, env endpoint.OutboundEnvelope) err
 	}
 }
 
+// SendBatch sends envs via the transport.
+//
+// The messages are published on a single AMQP channel without waiting for each
+// publisher confirmation before sending the next message. If an error is
+// returned, some, all or none of the messages may have been sent.
+func (t *Transport) SendBatch(ctx context.Context, envs []endpoint.OutboundEnvelope) error {
+	pubs := make([]publication, len(envs))
+
+	for i, env := range envs {
+		pub, err := marshalMessage(t.ep, env, t.Tracer)
+		if err != nil {
+			return err
+		}
+
+		switch env.Operation {
+		case endpoint.OpSendUnicast:
+			pubs[i] = unicastPublication(pub, env.DestinationEndpoint)
+		case endpoint.OpSendMulticast:
+			pubs[i] = multicastPublication(pub)
+		default:
+			panic(fmt.Sprintf("unrecognized outbound operation: %d", env.Operation))
+		}
+	}
+
+	return t.pub.PublishBatch(ctx, pubs)
+}
+
 // Receive returns the next message sent to this endpoint.
 // It blocks until a message is available, or ctx is canceled.
 func (t *Transport) Receive(ctx context.Context) (env endpoint.InboundEnvelope, ack endpoint.Acknowledger, err error) {
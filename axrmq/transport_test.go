@@ -6,6 +6,7 @@ import (
 )
 
 var (
-	_ endpoint.InboundTransport  = (*Transport)(nil) // ensure Transport implements InboundTransport
-	_ endpoint.OutboundTransport = (*Transport)(nil) // ensure Transport implements OutboundTransport
+	_ endpoint.InboundTransport       = (*Transport)(nil) // ensure Transport implements InboundTransport
+	_ endpoint.OutboundTransport      = (*Transport)(nil) // ensure Transport implements OutboundTransport
+	_ endpoint.BatchOutboundTransport = (*Transport)(nil) // ensure Transport implements BatchOutboundTransport
 )
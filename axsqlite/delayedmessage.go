@@ -0,0 +1,10 @@
+package axsqlite
+
+import (
+	sqlitedelayedmessage "github.com/jmalloc/ax/axsqlite/delayedmessage"
+	"github.com/jmalloc/ax/delayedmessage"
+)
+
+// DelayedMessageRepository is a delayed message repository backed by an
+// SQLite database.
+var DelayedMessageRepository delayedmessage.Repository = sqlitedelayedmessage.Repository{}
@@ -0,0 +1,3 @@
+// Package delayedmessage provides SQLite-specific implementations of the
+// interfaces in Ax's top-level "delayedmessage" package.
+package delayedmessage
@@ -0,0 +1,66 @@
+package delayedmessage_test
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/jmalloc/ax/axsqlite"
+	. "github.com/jmalloc/ax/axsqlite/delayedmessage"
+	"github.com/jmalloc/ax/axsqlite/internal/schema"
+	"github.com/jmalloc/ax/axtest/delayedmessagetests"
+	"github.com/jmalloc/ax/delayedmessage"
+	"github.com/jmalloc/ax/persistence"
+	_ "github.com/mattn/go-sqlite3"
+	. "github.com/onsi/ginkgo"
+)
+
+var _ = Describe("Repository", func() {
+	var (
+		dir string
+		db  *sql.DB
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "ax-sqlite-")
+		if err != nil {
+			panic(err)
+		}
+
+		db, err = sql.Open(
+			"sqlite3",
+			filepath.Join(dir, "ax.db")+"?_busy_timeout=5000&_journal_mode=WAL&_txlock=immediate",
+		)
+		if err != nil {
+			panic(err)
+		}
+
+		if err := schema.Create(db, "schema.sql"); err != nil {
+			panic(err)
+		}
+	})
+
+	AfterEach(func() {
+		if err := db.Close(); err != nil {
+			panic(err)
+		}
+
+		if err := os.RemoveAll(dir); err != nil {
+			panic(err)
+		}
+	})
+
+	Describe(
+		"Repository",
+		delayedmessagetests.RepositorySuite(
+			func() persistence.DataStore {
+				return axsqlite.NewDataStore(db)
+			},
+			func() delayedmessage.Repository {
+				return Repository{}
+			},
+		),
+	)
+})
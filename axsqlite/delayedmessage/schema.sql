@@ -0,0 +1,20 @@
+--
+-- ax_delayed_message stores the messages that are not yet ready to be sent.
+--
+CREATE TABLE IF NOT EXISTS ax_delayed_message (
+    message_id     TEXT NOT NULL,
+    causation_id   TEXT NOT NULL,
+    correlation_id TEXT NOT NULL,
+    created_at     TEXT NOT NULL,
+    send_at        TEXT NOT NULL,
+    content_type   TEXT NOT NULL,
+    data           BLOB NOT NULL,
+    operation      INTEGER NOT NULL,
+    destination    TEXT NOT NULL,
+
+    PRIMARY KEY (message_id)
+);
+
+CREATE INDEX IF NOT EXISTS ax_delayed_message_send_at ON ax_delayed_message (send_at);
+CREATE INDEX IF NOT EXISTS ax_delayed_message_causation_id ON ax_delayed_message (causation_id);
+CREATE INDEX IF NOT EXISTS ax_delayed_message_correlation_id ON ax_delayed_message (correlation_id);
@@ -0,0 +1,3 @@
+// Package envelopestore provides common implementation used by repositories
+// that persist outbound envelopes.
+package envelopestore
@@ -0,0 +1,81 @@
+package envelopestore
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jmalloc/ax"
+	"github.com/jmalloc/ax/axsqlite/internal/sqlutil"
+	"github.com/jmalloc/ax/endpoint"
+	"github.com/jmalloc/ax/marshaling"
+)
+
+// Insert adds a message to the store.
+//
+// cols and args are additional columns and their values, respectively.
+func Insert(
+	ctx context.Context,
+	tx *sql.Tx,
+	table string,
+	env endpoint.OutboundEnvelope,
+	cols string,
+	args ...interface{},
+) error {
+	ct, data, err := ax.MarshalMessage(env.Message)
+	if err != nil {
+		return err
+	}
+
+	args = append(
+		[]interface{}{
+			env.MessageID,
+			env.CausationID,
+			env.CorrelationID,
+			marshaling.MarshalTime(env.CreatedAt),
+			marshaling.MarshalTime(env.SendAt),
+			ct,
+			data,
+			env.Operation,
+			env.DestinationEndpoint,
+		},
+		args...,
+	)
+
+	if cols != "" {
+		cols = ", " + cols
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`INSERT INTO `+table+` (`+Columns+cols+`)
+		VALUES (`+sqlutil.Placeholders(len(args))+`)`,
+		args...,
+	)
+
+	return err
+}
+
+// Delete removes one or more messages from the store.
+func Delete(
+	ctx context.Context,
+	tx *sql.Tx,
+	table string,
+	envs ...endpoint.OutboundEnvelope,
+) error {
+	if len(envs) == 0 {
+		return nil
+	}
+
+	args := make([]interface{}, len(envs))
+	for i, env := range envs {
+		args[i] = env.MessageID
+	}
+
+	_, err := tx.ExecContext(
+		ctx,
+		`DELETE FROM `+table+` WHERE message_id IN (`+sqlutil.Placeholders(len(envs))+`)`,
+		args...,
+	)
+
+	return err
+}
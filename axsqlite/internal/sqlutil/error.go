@@ -0,0 +1,27 @@
+package sqlutil
+
+import (
+	"errors"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// IsDuplicateEntry returns true if err represents an SQLite primary key or
+// unique constraint violation.
+func IsDuplicateEntry(err error) bool {
+	e, ok := err.(sqlite3.Error)
+	return ok && (e.ExtendedCode == sqlite3.ErrConstraintPrimaryKey ||
+		e.ExtendedCode == sqlite3.ErrConstraintUnique)
+}
+
+// IsTransient returns true if err represents an SQLite error that is likely to
+// be resolved by retrying the transaction.
+//
+// SQLite returns SQLITE_BUSY if the database is locked by another connection
+// for longer than the busy timeout, and SQLITE_LOCKED if a table is locked by
+// another connection that shares the same cache.
+func IsTransient(err error) bool {
+	var e sqlite3.Error
+	return errors.As(err, &e) && (e.Code == sqlite3.ErrBusy ||
+		e.Code == sqlite3.ErrLocked)
+}
@@ -0,0 +1,74 @@
+package sqlutil
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ExecSingleRow executes a query without returning any rows and verifies that
+// exactly one row was affected.
+//
+// The args are for any placeholder parameters in the query. It returns an error
+// if more than one row was affected.
+func ExecSingleRow(
+	ctx context.Context,
+	tx *sql.Tx,
+	query string,
+	args ...interface{},
+) error {
+	ok, err := ExecConditional(ctx, tx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return fmt.Errorf("execution of query on single row actually affected no rows")
+	}
+
+	return nil
+}
+
+// ExecConditional executes a query that affects at most one row, such as an
+// UPDATE with a WHERE clause that includes the expected current values of
+// the row.
+//
+// It returns false if no rows were affected. It returns an error if more than
+// one row was affected.
+func ExecConditional(
+	ctx context.Context,
+	tx *sql.Tx,
+	query string,
+	args ...interface{},
+) (bool, error) {
+	res, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	if n > 1 {
+		return false, fmt.Errorf("execution of query on single row actually affected %d rows", n)
+	}
+
+	return n == 1, nil
+}
+
+// Placeholders returns a comma-separated list of n query placeholders, for use
+// within an "IN" clause or "VALUES" list.
+func Placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// Timestamp returns t as the number of nanoseconds since the Unix epoch, for
+// use with the INTEGER columns that store times that are compared within
+// queries.
+func Timestamp(t time.Time) int64 {
+	return t.UnixNano()
+}
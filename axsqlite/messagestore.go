@@ -0,0 +1,9 @@
+package axsqlite
+
+import (
+	sqlitemessagestore "github.com/jmalloc/ax/axsqlite/messagestore"
+	"github.com/jmalloc/ax/messagestore"
+)
+
+// MessageStore is a message store backed by an SQLite database.
+var MessageStore messagestore.GloballyOrderedStore = sqlitemessagestore.Store{}
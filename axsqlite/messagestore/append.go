@@ -0,0 +1,323 @@
+package messagestore
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/jmalloc/ax"
+	"github.com/jmalloc/ax/axsqlite/internal/sqlutil"
+	"github.com/jmalloc/ax/marshaling"
+	"github.com/jmalloc/ax/messagestore"
+)
+
+// insertStream inserts a new stream and returns its ID.
+//
+// s is the name of the stream, n is the initial value of the "next" offset.
+// It returns false if there is already a stream by this name.
+func insertStream(
+	ctx context.Context,
+	tx *sql.Tx,
+	s string,
+	n uint64,
+) (int64, bool, error) {
+	res, err := tx.ExecContext(
+		ctx,
+		`INSERT INTO ax_messagestore_stream (
+			name,
+			next
+		) VALUES (?, ?)`,
+		s,
+		n,
+	)
+	if err != nil {
+		if sqlutil.IsDuplicateEntry(err) {
+			return 0, false, nil
+		}
+
+		return 0, false, err
+	}
+
+	id, err := res.LastInsertId()
+	return id, true, err
+}
+
+// selectStream returns the ID and the next unused offset of the given stream.
+//
+// s is the name of the stream. It returns false if the stream does not exist.
+func selectStream(
+	ctx context.Context,
+	tx *sql.Tx,
+	s string,
+) (int64, uint64, bool, error) {
+	var (
+		id   int64
+		next uint64
+	)
+
+	err := tx.QueryRowContext(
+		ctx,
+		`SELECT
+			stream_id,
+			next
+		FROM ax_messagestore_stream
+		WHERE name = ?`,
+		s,
+	).Scan(
+		&id,
+		&next,
+	)
+
+	if err == sql.ErrNoRows {
+		return 0, 0, false, nil
+	} else if err != nil {
+		return 0, 0, false, err
+	}
+
+	return id, next, true, nil
+}
+
+// incrStreamOffset increments the offset for the stream with the given ID by
+// n.
+//
+// It returns false if o is no longer the next free offset.
+func incrStreamOffset(
+	ctx context.Context,
+	tx *sql.Tx,
+	id int64,
+	o uint64,
+	n uint64,
+) (bool, error) {
+	// the update only succeeds if o is still the next free offset, as SQLite
+	// does not support locking the row when it is selected
+	return sqlutil.ExecConditional(
+		ctx,
+		tx,
+		`UPDATE ax_messagestore_stream SET
+			next = next + ?
+		WHERE stream_id = ?
+		AND next = ?`,
+		n,
+		id,
+		o,
+	)
+}
+
+// loadMessageIDs returns the IDs of the messages in the stream with the given
+// ID, keyed by stream offset.
+//
+// o is the offset of the first message to load, n is the number of messages
+// to load.
+func loadMessageIDs(
+	ctx context.Context,
+	tx *sql.Tx,
+	id int64,
+	o uint64,
+	n uint64,
+) (map[uint64]string, error) {
+	rows, err := tx.QueryContext(
+		ctx,
+		`SELECT
+			stream_offset,
+			message_id
+		FROM ax_messagestore_message
+		WHERE stream_id = ?
+		AND stream_offset >= ?
+		AND stream_offset < ?`,
+		id,
+		o,
+		o+n,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := map[uint64]string{}
+
+	for rows.Next() {
+		var (
+			offset uint64
+			mid    string
+		)
+
+		if err := rows.Scan(&offset, &mid); err != nil {
+			return nil, err
+		}
+
+		ids[offset] = mid
+	}
+
+	return ids, rows.Err()
+}
+
+// checkDuplicates returns a *messagestore.DuplicateMessageError if any of envs
+// have the same message ID as a message that is already stored, or as another
+// message in envs.
+//
+// s and o are the name of the stream and the offset at which envs are to be
+// appended.
+func checkDuplicates(
+	ctx context.Context,
+	tx *sql.Tx,
+	s string,
+	o uint64,
+	envs []ax.Envelope,
+) error {
+	offsets := map[string]uint64{}
+
+	for i, env := range envs {
+		id := env.MessageID.Get()
+
+		if prev, ok := offsets[id]; ok {
+			return &messagestore.DuplicateMessageError{
+				MessageID:    env.MessageID,
+				Stream:       s,
+				StreamOffset: prev,
+			}
+		}
+
+		offsets[id] = o + uint64(i)
+	}
+
+	id, name, offset, ok, err := findMessage(ctx, tx, envs)
+	if !ok || err != nil {
+		return err
+	}
+
+	return &messagestore.DuplicateMessageError{
+		MessageID:    id,
+		Stream:       name,
+		StreamOffset: offset,
+	}
+}
+
+// findMessage returns the position of a message that has one of the given
+// message IDs. It returns false if none of the messages are stored.
+func findMessage(
+	ctx context.Context,
+	tx *sql.Tx,
+	envs []ax.Envelope,
+) (ax.MessageID, string, uint64, bool, error) {
+	var (
+		id     ax.MessageID
+		stream string
+		offset uint64
+	)
+
+	args := make([]interface{}, len(envs))
+	for i, env := range envs {
+		args[i] = env.MessageID
+	}
+
+	err := tx.QueryRowContext(
+		ctx,
+		`SELECT
+			m.message_id,
+			s.name,
+			m.stream_offset
+		FROM `+fetchTables+`
+		WHERE m.message_id IN (?`+strings.Repeat(", ?", len(envs)-1)+`)
+		LIMIT 1`,
+		args...,
+	).Scan(
+		&id,
+		&stream,
+		&offset,
+	)
+
+	if err == sql.ErrNoRows {
+		return ax.MessageID{}, "", 0, false, nil
+	} else if err != nil {
+		return ax.MessageID{}, "", 0, false, err
+	}
+
+	return id, stream, offset, true, nil
+}
+
+// incrGlobalOffset increments the global stream offset by n.
+//
+// It returns the offset before it was incremented.
+func incrGlobalOffset(
+	ctx context.Context,
+	tx *sql.Tx,
+	n uint64,
+) (uint64, error) {
+	if _, err := tx.ExecContext(
+		ctx,
+		`INSERT INTO ax_messagestore_offset (
+			_,
+			next
+		) VALUES (0, ?)
+		ON CONFLICT (_) DO UPDATE SET
+			next = next + excluded.next`,
+		n,
+	); err != nil {
+		return 0, err
+	}
+
+	var next uint64
+	err := tx.QueryRowContext(
+		ctx,
+		`SELECT
+			next
+		FROM ax_messagestore_offset`,
+	).Scan(
+		&next,
+	)
+
+	return next - n, err
+}
+
+// insertMessage inserts a message into the store.
+//
+// id is the stream ID and c is the message's category. g and o are the global
+// and stream offsets of the message, respectively.
+func insertMessage(
+	ctx context.Context,
+	tx *sql.Tx,
+	id int64,
+	c string,
+	g uint64,
+	o uint64,
+	env ax.Envelope,
+) error {
+	contentType, data, err := ax.MarshalMessage(env.Message)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`INSERT INTO ax_messagestore_message (
+			global_offset,
+			stream_id,
+			category,
+			stream_offset,
+			description,
+			message_type,
+			message_id,
+			causation_id,
+			correlation_id,
+			created_at,
+			send_at,
+			content_type,
+			data
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		g,
+		id,
+		c,
+		o,
+		env.Message.MessageDescription(),
+		env.Type().Name,
+		env.MessageID,
+		env.CausationID,
+		env.CorrelationID,
+		marshaling.MarshalTime(env.CreatedAt),
+		marshaling.MarshalTime(env.SendAt),
+		contentType,
+		data,
+	)
+
+	return err
+}
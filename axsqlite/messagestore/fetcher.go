@@ -0,0 +1,126 @@
+package messagestore
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jmalloc/ax"
+	"github.com/jmalloc/ax/axsqlite/internal/sqlutil"
+)
+
+// fetchColumns is the ordered list of columns that must be SELECTed by fetchers,
+// after the column that contains the offset of the message.
+//
+// The message table is aliased as "m" and the stream table as "s".
+const fetchColumns = `m.global_offset,
+					  m.stream_offset,
+					  s.name,
+					  m.category,
+					  m.insert_time,
+					  m.message_id,
+					  m.causation_id,
+					  m.correlation_id,
+					  m.created_at,
+					  m.send_at,
+					  m.content_type,
+					  m.data`
+
+// fetchTables is the FROM clause used by fetchers.
+const fetchTables = `ax_messagestore_message AS m
+		INNER JOIN ax_messagestore_stream AS s
+		ON s.stream_id = m.stream_id`
+
+// Fetcher is an interface for fetching rows from the message store
+type Fetcher interface {
+	// FetchRows fetches the n rows beginning at the given offset.
+	FetchRows(ctx context.Context, offset, n uint64) (*sql.Rows, error)
+}
+
+// StreamFetcher is a fetcher that fetches rows for a specific stream.
+type StreamFetcher struct {
+	DB       *sql.DB
+	StreamID int64
+}
+
+// FetchRows fetches the n rows beginning at the given offset.
+func (f *StreamFetcher) FetchRows(ctx context.Context, offset, n uint64) (*sql.Rows, error) {
+	return f.DB.QueryContext(
+		ctx,
+		`SELECT m.stream_offset, `+fetchColumns+`
+		FROM `+fetchTables+`
+		WHERE m.stream_id = ?
+		AND m.stream_offset >= ?
+		ORDER BY m.stream_offset
+		LIMIT ?`,
+		f.StreamID,
+		offset,
+		n,
+	)
+}
+
+// GlobalFetcher is a fetcher that fetches rows for the entire store.
+//
+// If Types is non-empty, only messages of those types are fetched.
+type GlobalFetcher struct {
+	DB    *sql.DB
+	Types ax.MessageTypeSet
+}
+
+// FetchRows fetches the n rows beginning at the given offset.
+func (f *GlobalFetcher) FetchRows(ctx context.Context, offset, n uint64) (*sql.Rows, error) {
+	return fetchGlobal(ctx, f.DB, "", nil, f.Types, offset, n)
+}
+
+// CategoryFetcher is a fetcher that fetches rows for all streams in a
+// category, in global order.
+//
+// If Types is non-empty, only messages of those types are fetched.
+type CategoryFetcher struct {
+	DB       *sql.DB
+	Category string
+	Types    ax.MessageTypeSet
+}
+
+// FetchRows fetches the n rows beginning at the given global offset.
+func (f *CategoryFetcher) FetchRows(ctx context.Context, offset, n uint64) (*sql.Rows, error) {
+	return fetchGlobal(
+		ctx,
+		f.DB,
+		` AND m.category = ?`,
+		[]interface{}{f.Category},
+		f.Types,
+		offset,
+		n,
+	)
+}
+
+// fetchGlobal fetches the n rows beginning at the given global offset that
+// match the given additional WHERE condition and message types.
+func fetchGlobal(
+	ctx context.Context,
+	db *sql.DB,
+	cond string,
+	args []interface{},
+	types ax.MessageTypeSet,
+	offset, n uint64,
+) (*sql.Rows, error) {
+	query := `SELECT m.global_offset, ` + fetchColumns + `
+		FROM ` + fetchTables + `
+		WHERE m.global_offset >= ?` + cond
+	args = append([]interface{}{offset}, args...)
+
+	if types.Len() != 0 {
+		query += ` AND m.message_type IN (` + sqlutil.Placeholders(types.Len()) + `)`
+
+		for _, mt := range types.Members() {
+			args = append(args, mt.Name)
+		}
+	}
+
+	query += `
+		ORDER BY m.global_offset
+		LIMIT ?`
+	args = append(args, n)
+
+	return db.QueryContext(ctx, query, args...)
+}
@@ -0,0 +1,3 @@
+// Package messagestore provides SQLite-specific implementations of the
+// interfaces in Ax's top-level "messagestore" package.
+package messagestore
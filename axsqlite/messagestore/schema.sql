@@ -0,0 +1,51 @@
+--
+-- ax_messagestore_offset stores the next global message offset across all streams.
+--
+-- The CHECK constraint on the primary key ensures there can only ever be a
+-- single row.
+--
+CREATE TABLE IF NOT EXISTS ax_messagestore_offset (
+    _    INTEGER NOT NULL PRIMARY KEY CHECK (_ = 0),
+    next INTEGER NOT NULL DEFAULT 0
+);
+
+--
+-- ax_messagestore_stream contains the streams that exist within the message store.
+--
+-- next is the next unused offset on the stream.
+--
+CREATE TABLE IF NOT EXISTS ax_messagestore_stream (
+    stream_id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    name      TEXT NOT NULL UNIQUE,
+    next      INTEGER NOT NULL
+);
+
+--
+-- ax_messagestore_message contains the messages on each stream.
+--
+CREATE TABLE IF NOT EXISTS ax_messagestore_message (
+    global_offset  INTEGER NOT NULL,
+    insert_time    TEXT NOT NULL DEFAULT (STRFTIME('%Y-%m-%d %H:%M:%f', 'now')),
+
+    stream_id      INTEGER NOT NULL,
+    category       TEXT NOT NULL,
+    stream_offset  INTEGER NOT NULL,
+    description    TEXT NOT NULL,
+    message_type   TEXT NOT NULL,
+    message_id     TEXT NOT NULL,
+    causation_id   TEXT NOT NULL,
+    correlation_id TEXT NOT NULL,
+    created_at     TEXT NOT NULL,
+    send_at        TEXT NOT NULL,
+    content_type   TEXT NOT NULL,
+    data           BLOB NOT NULL,
+
+    PRIMARY KEY (global_offset)
+);
+
+CREATE INDEX IF NOT EXISTS ax_messagestore_message_stream ON ax_messagestore_message (stream_id, stream_offset);
+CREATE INDEX IF NOT EXISTS ax_messagestore_message_category ON ax_messagestore_message (category, global_offset);
+CREATE INDEX IF NOT EXISTS ax_messagestore_message_message_type ON ax_messagestore_message (message_type, global_offset);
+CREATE INDEX IF NOT EXISTS ax_messagestore_message_message_id ON ax_messagestore_message (message_id);
+CREATE INDEX IF NOT EXISTS ax_messagestore_message_causation_id ON ax_messagestore_message (causation_id);
+CREATE INDEX IF NOT EXISTS ax_messagestore_message_correlation_id ON ax_messagestore_message (correlation_id);
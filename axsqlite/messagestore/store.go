@@ -0,0 +1,211 @@
+package messagestore
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jmalloc/ax"
+	sqlitepersistence "github.com/jmalloc/ax/axsqlite/persistence"
+	"github.com/jmalloc/ax/messagestore"
+	"github.com/jmalloc/ax/persistence"
+)
+
+// Store is an SQLite-backed implementation of Ax's
+// messagestore.GloballyOrderedStore interface.
+type Store struct{}
+
+// AppendMessages appends one or more messages to a named stream.
+//
+// offset is a zero-based index into the stream. If offset is not the next
+// unused offset in the stream, it returns a *messagestore.ConflictError,
+// unless the messages already stored at offset have the same message IDs as
+// envs, in which case those messages are not appended again.
+//
+// It returns a *messagestore.DuplicateMessageError if a message with the same
+// ID as one of envs is already stored at some other position.
+func (Store) AppendMessages(
+	ctx context.Context,
+	ptx persistence.Tx,
+	stream string,
+	offset uint64,
+	envs []ax.Envelope,
+) error {
+	tx := sqlitepersistence.ExtractTx(ptx)
+
+	id, next, exists, err := selectStream(ctx, tx, stream)
+	if err != nil {
+		return err
+	}
+
+	if offset > next {
+		return &messagestore.ConflictError{Stream: stream, Offset: offset}
+	}
+
+	// skip any messages that are already stored at the expected offsets, as
+	// they have been appended by a prior attempt.
+	if offset < next && len(envs) > 0 {
+		n := next - offset
+		if n > uint64(len(envs)) {
+			n = uint64(len(envs))
+		}
+
+		ids, err := loadMessageIDs(ctx, tx, id, offset, n)
+		if err != nil {
+			return err
+		}
+
+		for _, env := range envs[:n] {
+			if ids[offset] != env.MessageID.Get() {
+				return &messagestore.ConflictError{Stream: stream, Offset: offset}
+			}
+
+			offset++
+		}
+
+		envs = envs[n:]
+	}
+
+	if len(envs) == 0 {
+		return nil
+	}
+
+	if err := checkDuplicates(ctx, tx, stream, offset, envs); err != nil {
+		return err
+	}
+
+	n := uint64(len(envs))
+
+	var ok bool
+	if exists {
+		ok, err = incrStreamOffset(ctx, tx, id, offset, n)
+	} else {
+		id, ok, err = insertStream(ctx, tx, stream, n)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		// the stream was modified by a concurrent transaction.
+		return &messagestore.ConflictError{Stream: stream, Offset: offset}
+	}
+
+	g, err := incrGlobalOffset(ctx, tx, n)
+	if err != nil {
+		return err
+	}
+
+	c := messagestore.StreamCategory(ctx, stream)
+
+	for _, env := range envs {
+		if err := insertMessage(
+			ctx,
+			tx,
+			id,
+			c,
+			g,
+			offset,
+			env,
+		); err != nil {
+			return err
+		}
+
+		g++
+		offset++
+	}
+
+	return nil
+}
+
+// OpenStream opens a stream of messages for reading from a specific offset.
+//
+// The offset may be past the end of the stream. It returns false if the stream
+// does not exist.
+func (Store) OpenStream(
+	ctx context.Context,
+	ds persistence.DataStore,
+	stream string,
+	offset uint64,
+) (messagestore.Stream, bool, error) {
+	db := sqlitepersistence.ExtractDB(ds)
+
+	id, ok, err := lookupStreamID(ctx, db, stream)
+	if !ok || err != nil {
+		return nil, false, err
+	}
+
+	return &Stream{
+		Fetcher: &StreamFetcher{
+			DB:       db,
+			StreamID: id,
+		},
+		NextOffset: offset,
+	}, true, nil
+}
+
+// OpenGlobal opens the entire store for reading as a single stream.
+//
+// The offset may be beyond the end of the stream. If types is non-empty, only
+// messages of those types are read.
+func (Store) OpenGlobal(
+	ctx context.Context,
+	ds persistence.DataStore,
+	offset uint64,
+	types ax.MessageTypeSet,
+) (messagestore.Stream, error) {
+	return &Stream{
+		Fetcher: &GlobalFetcher{
+			DB:    sqlitepersistence.ExtractDB(ds),
+			Types: types,
+		},
+		NextOffset: offset,
+	}, nil
+}
+
+// OpenCategory opens all messages in a category for reading as a single stream.
+// The category of each message is determined when it is appended, as per
+// messagestore.StreamCategory().
+//
+// The offset may be beyond the end of the stream. If types is non-empty, only
+// messages of those types are read.
+func (Store) OpenCategory(
+	ctx context.Context,
+	ds persistence.DataStore,
+	category string,
+	offset uint64,
+	types ax.MessageTypeSet,
+) (messagestore.Stream, error) {
+	return &Stream{
+		Fetcher: &CategoryFetcher{
+			DB:       sqlitepersistence.ExtractDB(ds),
+			Category: category,
+			Types:    types,
+		},
+		NextOffset: offset,
+	}, nil
+}
+
+// lookupStreamID returns the ID of the stream named s.
+func lookupStreamID(ctx context.Context, db *sql.DB, s string) (int64, bool, error) {
+	var id int64
+
+	err := db.QueryRowContext(
+		ctx,
+		`SELECT
+			stream_id
+		FROM ax_messagestore_stream
+		WHERE name = ?`,
+		s,
+	).Scan(
+		&id,
+	)
+
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	} else if err != nil {
+		return 0, false, err
+	}
+
+	return id, true, nil
+}
@@ -0,0 +1,295 @@
+package messagestore_test
+
+import (
+	"context"
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/jmalloc/ax"
+	"github.com/jmalloc/ax/axsqlite"
+	"github.com/jmalloc/ax/axsqlite/internal/schema"
+	. "github.com/jmalloc/ax/axsqlite/messagestore"
+	"github.com/jmalloc/ax/axtest"
+	"github.com/jmalloc/ax/axtest/testmessages"
+	"github.com/jmalloc/ax/messagestore"
+	"github.com/jmalloc/ax/persistence"
+	_ "github.com/mattn/go-sqlite3"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Store", func() {
+	var (
+		ctx              context.Context
+		cancel           func()
+		dir              string
+		db               *sql.DB
+		ds               persistence.DataStore
+		store            Store
+		env1, env2, env3 ax.Envelope
+		env4, env5, env6 ax.Envelope
+	)
+
+	BeforeEach(func() {
+		var fn func()
+		ctx, fn = context.WithTimeout(context.Background(), 5*time.Second)
+		cancel = fn // defeat go vet warning about unused cancel func
+
+		var err error
+		dir, err = ioutil.TempDir("", "ax-sqlite-")
+		if err != nil {
+			panic(err)
+		}
+
+		db, err = sql.Open(
+			"sqlite3",
+			filepath.Join(dir, "ax.db")+"?_busy_timeout=5000&_journal_mode=WAL&_txlock=immediate",
+		)
+		if err != nil {
+			panic(err)
+		}
+
+		if err := schema.Create(db, "schema.sql"); err != nil {
+			panic(err)
+		}
+
+		ds = axsqlite.NewDataStore(db)
+
+		env1 = ax.NewEnvelope(&testmessages.MessageA{Value: "<foo>"})
+		env2 = ax.NewEnvelope(&testmessages.MessageB{Value: "<bar>"})
+		env3 = ax.NewEnvelope(&testmessages.MessageC{Value: "<baz>"})
+		env4 = ax.NewEnvelope(&testmessages.MessageA{Value: "<qux>"})
+		env5 = ax.NewEnvelope(&testmessages.MessageB{Value: "<quux>"})
+		env6 = ax.NewEnvelope(&testmessages.MessageC{Value: "<corge>"})
+	})
+
+	AfterEach(func() {
+		cancel()
+
+		if err := db.Close(); err != nil {
+			panic(err)
+		}
+
+		if err := os.RemoveAll(dir); err != nil {
+			panic(err)
+		}
+	})
+
+	appendMessages := func(stream string, offset uint64, envs ...ax.Envelope) error {
+		tx, com, err := ds.BeginTx(ctx)
+		if err != nil {
+			return err
+		}
+		defer com.Rollback()
+
+		if err := store.AppendMessages(ctx, tx, stream, offset, envs); err != nil {
+			return err
+		}
+
+		return com.Commit()
+	}
+
+	readAll := func(s messagestore.Stream) ([]ax.Envelope, []uint64) {
+		var (
+			envs    []ax.Envelope
+			offsets []uint64
+		)
+
+		for {
+			ok, err := s.TryNext(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			if !ok {
+				return envs, offsets
+			}
+
+			env, err := s.Get(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			o, err := s.Offset()
+			Expect(err).ShouldNot(HaveOccurred())
+
+			envs = append(envs, env)
+			offsets = append(offsets, o)
+		}
+	}
+
+	BeforeEach(func() {
+		err := appendMessages("<stream-1>", 0, env1)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		err = appendMessages("<stream-2>", 0, env2)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		err = appendMessages("<stream-1>", 1, env3)
+		Expect(err).ShouldNot(HaveOccurred())
+	})
+
+	Describe("AppendMessages", func() {
+		It("returns a conflict error if the stream already exists and the offset is zero", func() {
+			err := appendMessages("<stream-1>", 0, env2)
+			Expect(messagestore.IsConflict(err)).To(BeTrue())
+		})
+
+		It("returns a conflict error if the offset is not the next free offset", func() {
+			err := appendMessages("<stream-1>", 1, env2)
+			Expect(messagestore.IsConflict(err)).To(BeTrue())
+
+			err = appendMessages("<stream-1>", 3, env2)
+			Expect(messagestore.IsConflict(err)).To(BeTrue())
+		})
+
+		It("does not append messages that are already stored at the same offsets", func() {
+			err := appendMessages("<stream-1>", 0, env1, env3)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = appendMessages("<stream-1>", 1, env3, env4)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			s, ok, err := store.OpenStream(ctx, ds, "<stream-1>", 0)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(ok).To(BeTrue())
+			defer s.Close()
+
+			envs, offsets := readAll(s)
+			Expect(axtest.ConsistsOfEnvelopes(envs, env1, env3, env4)).To(BeTrue())
+			Expect(offsets).To(Equal([]uint64{0, 1, 2}))
+		})
+
+		It("returns a duplicate message error if the message is already stored elsewhere", func() {
+			err := appendMessages("<stream-3>", 0, env4, env3)
+			Expect(err).To(Equal(&messagestore.DuplicateMessageError{
+				MessageID:    env3.MessageID,
+				Stream:       "<stream-1>",
+				StreamOffset: 1,
+			}))
+
+			err = appendMessages("<stream-3>", 0, env4, env4)
+			Expect(messagestore.IsDuplicateMessage(err)).To(BeTrue())
+		})
+	})
+
+	Describe("OpenStream", func() {
+		It("returns false if the stream does not exist", func() {
+			_, ok, err := store.OpenStream(ctx, ds, "<unknown>", 0)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(ok).To(BeFalse())
+		})
+
+		It("returns the messages in the stream, with their stream offsets", func() {
+			s, ok, err := store.OpenStream(ctx, ds, "<stream-1>", 0)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(ok).To(BeTrue())
+			defer s.Close()
+
+			envs, offsets := readAll(s)
+			Expect(axtest.ConsistsOfEnvelopes(envs, env1, env3)).To(BeTrue())
+			Expect(offsets).To(Equal([]uint64{0, 1}))
+		})
+	})
+
+	Describe("GetStored", func() {
+		It("returns the message along with its position within the store", func() {
+			s, err := store.OpenGlobal(ctx, ds, 2, ax.MessageTypeSet{})
+			Expect(err).ShouldNot(HaveOccurred())
+			defer s.Close()
+
+			err = s.Next(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			m, err := s.GetStored(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(axtest.EnvelopesEqual(m.Envelope, env3)).To(BeTrue())
+			Expect(m.Stream).To(Equal("<stream-1>"))
+			Expect(m.StreamOffset).To(BeNumerically("==", 1))
+			Expect(m.GlobalOffset).To(BeNumerically("==", 2))
+			Expect(m.HasGlobalOffset).To(BeTrue())
+			Expect(m.InsertTime).To(BeTemporally("~", time.Now(), 5*time.Second))
+		})
+	})
+
+	Describe("OpenCategory", func() {
+		BeforeEach(func() {
+			err := appendMessages("account:1", 0, env4)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = appendMessages("account:2", 0, env5, env6)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		It("returns only messages from streams in the category, with their global offsets", func() {
+			s, err := store.OpenCategory(ctx, ds, "account", 0, ax.MessageTypeSet{})
+			Expect(err).ShouldNot(HaveOccurred())
+			defer s.Close()
+
+			envs, offsets := readAll(s)
+			Expect(axtest.ConsistsOfEnvelopes(envs, env4, env5, env6)).To(BeTrue())
+			Expect(offsets).To(Equal([]uint64{3, 4, 5}))
+		})
+
+		It("returns messages that were appended with the category in the context", func() {
+			env := ax.NewEnvelope(&testmessages.MessageA{Value: "<categorized>"})
+
+			err := persistence.Atomically(
+				persistence.WithDataStore(messagestore.WithCategory(ctx, "account"), ds),
+				func(ctx context.Context, tx persistence.Tx) error {
+					return store.AppendMessages(ctx, tx, "<stream>", 0, []ax.Envelope{env})
+				},
+			)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			s, err := store.OpenCategory(ctx, ds, "account", 0, ax.MessageTypeSet{})
+			Expect(err).ShouldNot(HaveOccurred())
+			defer s.Close()
+
+			envs, _ := readAll(s)
+			Expect(axtest.ConsistsOfEnvelopes(envs, env4, env5, env6, env)).To(BeTrue())
+		})
+
+		It("returns only messages of the given types", func() {
+			s, err := store.OpenCategory(
+				ctx,
+				ds,
+				"account",
+				0,
+				ax.TypesOf(&testmessages.MessageA{}, &testmessages.MessageC{}),
+			)
+			Expect(err).ShouldNot(HaveOccurred())
+			defer s.Close()
+
+			envs, offsets := readAll(s)
+			Expect(axtest.ConsistsOfEnvelopes(envs, env4, env6)).To(BeTrue())
+			Expect(offsets).To(Equal([]uint64{3, 5}))
+		})
+	})
+
+	Describe("OpenGlobal", func() {
+		It("returns the messages in all streams, with their global offsets", func() {
+			s, err := store.OpenGlobal(ctx, ds, 1, ax.MessageTypeSet{})
+			Expect(err).ShouldNot(HaveOccurred())
+			defer s.Close()
+
+			envs, offsets := readAll(s)
+			Expect(axtest.ConsistsOfEnvelopes(envs, env2, env3)).To(BeTrue())
+			Expect(offsets).To(Equal([]uint64{1, 2}))
+		})
+
+		It("returns only messages of the given types, with their global offsets", func() {
+			s, err := store.OpenGlobal(
+				ctx,
+				ds,
+				0,
+				ax.TypesOf(&testmessages.MessageA{}, &testmessages.MessageC{}),
+			)
+			Expect(err).ShouldNot(HaveOccurred())
+			defer s.Close()
+
+			envs, offsets := readAll(s)
+			Expect(axtest.ConsistsOfEnvelopes(envs, env1, env3)).To(BeTrue())
+			Expect(offsets).To(Equal([]uint64{0, 2}))
+		})
+	})
+})
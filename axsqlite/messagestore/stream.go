@@ -0,0 +1,242 @@
+package messagestore
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jmalloc/ax"
+	"github.com/jmalloc/ax/marshaling"
+	"github.com/jmalloc/ax/messagestore"
+)
+
+const (
+	// DefaultFetchLimit is the number of messages to fetch in each select query on
+	// a message stream.
+	DefaultFetchLimit = 100
+
+	// DefaultPollInterval is the default time to wait between polls in
+	// MessageStream.Next().
+	DefaultPollInterval = 500 * time.Millisecond
+)
+
+// Stream is an SQLite-backed implementation of Ax's messagestore.Stream
+// interface.
+//
+// The offsets of the messages in the stream are not necessarily contiguous,
+// for example when the fetcher filters messages by type.
+type Stream struct {
+	Fetcher      Fetcher
+	NextOffset   uint64
+	Limit        uint64
+	PollInterval time.Duration
+
+	rows     *sql.Rows
+	rowLimit uint64
+	rowCount uint64
+	row      row
+}
+
+// row holds the columns of the message at the current offset.
+type row struct {
+	offset       uint64
+	globalOffset uint64
+	streamOffset uint64
+	stream       string
+	category     string
+	insertTime   string
+	env          ax.Envelope
+	contentType  string
+	data         []byte
+	createdAt    string
+	sendAt       string
+}
+
+// insertTimeLayout is the layout of the values in the insert_time column, as
+// produced by SQLite's STRFTIME('%Y-%m-%d %H:%M:%f') in UTC.
+const insertTimeLayout = "2006-01-02 15:04:05.999"
+
+// Next advances the stream to the next message.
+//
+// It blocks until a message is available, or ctx is canceled.
+func (s *Stream) Next(ctx context.Context) error {
+	ok, err := s.TryNext(ctx)
+	if ok || err != nil {
+		return err
+	}
+
+	p := s.PollInterval
+	if p == 0 {
+		p = DefaultPollInterval
+	}
+
+	tick := time.NewTicker(p)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-tick.C:
+			ok, err := s.TryNext(ctx)
+			if ok || err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// TryNext advances the stream to the next message.
+//
+// It returns false if there are no more messages in the stream.
+func (s *Stream) TryNext(ctx context.Context) (bool, error) {
+	for {
+		if s.rows == nil {
+			if err := s.fetchRows(ctx); err != nil {
+				return false, err
+			}
+		}
+
+		if s.rows.Next() {
+			if err := s.scanRow(); err != nil {
+				return false, err
+			}
+
+			s.NextOffset = s.row.offset + 1
+			s.rowCount++
+			return true, nil
+		}
+
+		more := s.rowCount == s.rowLimit
+		err := s.replaceRows(nil, 0)
+
+		if !more || err != nil {
+			return false, err
+		}
+	}
+}
+
+// Get returns the message at the current offset in the stream.
+func (s *Stream) Get(ctx context.Context) (ax.Envelope, error) {
+	if s.rows == nil {
+		panic("Next() must be called before Get()")
+	}
+
+	env := s.row.env
+
+	err := marshaling.UnmarshalTime(s.row.createdAt, &env.CreatedAt)
+	if err != nil {
+		return ax.Envelope{}, err
+	}
+
+	err = marshaling.UnmarshalTime(s.row.sendAt, &env.SendAt)
+	if err != nil {
+		return ax.Envelope{}, err
+	}
+
+	env.Message, err = ax.UnmarshalMessage(s.row.contentType, s.row.data)
+
+	return env, err
+}
+
+// GetStored returns the message at the current offset in the stream, along
+// with its position within the store.
+func (s *Stream) GetStored(ctx context.Context) (messagestore.StoredMessage, error) {
+	if s.rows == nil {
+		panic("Next() must be called before GetStored()")
+	}
+
+	env, err := s.Get(ctx)
+	if err != nil {
+		return messagestore.StoredMessage{}, err
+	}
+
+	t, err := time.Parse(insertTimeLayout, s.row.insertTime)
+	if err != nil {
+		return messagestore.StoredMessage{}, err
+	}
+
+	return messagestore.StoredMessage{
+		Envelope:        env,
+		Stream:          s.row.stream,
+		StreamOffset:    s.row.streamOffset,
+		Category:        s.row.category,
+		GlobalOffset:    s.row.globalOffset,
+		HasGlobalOffset: true,
+		InsertTime:      t,
+	}, nil
+}
+
+// Offset returns the offset of the message returned by Get().
+func (s *Stream) Offset() (uint64, error) {
+	if s.rows == nil {
+		panic("Next() must be called before Offset()")
+	}
+
+	return s.row.offset, nil
+}
+
+// Close closes the stream.
+func (s *Stream) Close() error {
+	return s.replaceRows(nil, 0)
+}
+
+// fetchRows selects the next batch of messages from the stream.
+func (s *Stream) fetchRows(ctx context.Context) error {
+	n := s.Limit
+	if n == 0 {
+		n = DefaultFetchLimit
+	}
+
+	rows, err := s.Fetcher.FetchRows(ctx, s.NextOffset, n)
+	if err != nil {
+		return err
+	}
+
+	return s.replaceRows(rows, n)
+}
+
+// scanRow scans the current row of s.rows into s.row.
+func (s *Stream) scanRow() error {
+	var r row
+
+	err := s.rows.Scan(
+		&r.offset,
+		&r.globalOffset,
+		&r.streamOffset,
+		&r.stream,
+		&r.category,
+		&r.insertTime,
+		&r.env.MessageID,
+		&r.env.CausationID,
+		&r.env.CorrelationID,
+		&r.createdAt,
+		&r.sendAt,
+		&r.contentType,
+		&r.data,
+	)
+	if err != nil {
+		return err
+	}
+
+	s.row = r
+
+	return nil
+}
+
+// replaceRows replaces s.rows with r, closing the existing s.rows value if it
+// is not nil.
+func (s *Stream) replaceRows(r *sql.Rows, n uint64) error {
+	prev := s.rows
+	s.rows = r
+	s.rowLimit = n
+	s.rowCount = 0
+
+	if prev != nil {
+		if err := prev.Close(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
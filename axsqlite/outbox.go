@@ -0,0 +1,9 @@
+package axsqlite
+
+import (
+	sqliteoutbox "github.com/jmalloc/ax/axsqlite/outbox"
+	"github.com/jmalloc/ax/outbox"
+)
+
+// OutboxRepository is an outbox repository backed by an SQLite database.
+var OutboxRepository outbox.Repository = sqliteoutbox.Repository{}
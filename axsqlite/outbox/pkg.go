@@ -0,0 +1,3 @@
+// Package outbox provides SQLite-specific implementations of the
+// interfaces in Ax's top-level "outbox" package.
+package outbox
@@ -0,0 +1,270 @@
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmalloc/ax"
+	"github.com/jmalloc/ax/axsqlite/internal/envelopestore"
+	"github.com/jmalloc/ax/axsqlite/internal/sqlutil"
+	sqlitepersistence "github.com/jmalloc/ax/axsqlite/persistence"
+	"github.com/jmalloc/ax/endpoint"
+	"github.com/jmalloc/ax/persistence"
+)
+
+// Repository is an SQLite-backed implementation of Ax's outbox.Repository
+// interface.
+type Repository struct{}
+
+const (
+	// messageTable is the name of the SQL table that stores outbox messages.
+	messageTable = "ax_outbox_message"
+
+	// standaloneMessageTable is the name of the SQL table that stores messages
+	// that are not associated with an inbound message.
+	standaloneMessageTable = "ax_outbox_standalone_message"
+)
+
+// LoadOutbox loads the unsent outbound messages that were produced when the
+// message identified by id was first processed.
+func (Repository) LoadOutbox(
+	ctx context.Context,
+	ds persistence.DataStore,
+	id ax.MessageID,
+) ([]endpoint.OutboundEnvelope, bool, error) {
+	db := sqlitepersistence.ExtractDB(ds)
+
+	row := db.QueryRowContext(
+		ctx,
+		`SELECT EXISTS (
+			SELECT * FROM ax_outbox WHERE causation_id = ?
+		)`,
+		id,
+	)
+
+	var ok bool
+	if err := row.Scan(&ok); err != nil {
+		return nil, false, err
+	}
+
+	if !ok {
+		return nil, false, nil
+	}
+
+	rows, err := db.QueryContext(
+		ctx,
+		`SELECT `+envelopestore.Columns+`
+		FROM `+messageTable+`
+		WHERE causation_id = ?`,
+		id,
+	)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	var envelopes []endpoint.OutboundEnvelope
+
+	for rows.Next() {
+		env, err := envelopestore.Scan(rows)
+		if err != nil {
+			return nil, false, err
+		}
+
+		envelopes = append(envelopes, env)
+	}
+
+	return envelopes, true, rows.Err()
+}
+
+// SaveOutbox saves a set of unsent outbound messages that were produced
+// when the message identified by id was processed.
+func (Repository) SaveOutbox(
+	ctx context.Context,
+	ptx persistence.Tx,
+	id ax.MessageID,
+	envs []endpoint.OutboundEnvelope,
+) error {
+	tx := sqlitepersistence.ExtractTx(ptx)
+
+	if _, err := tx.ExecContext(
+		ctx,
+		`INSERT INTO ax_outbox (
+			causation_id,
+			insert_time
+		) VALUES (?, ?)`,
+		id,
+		sqlutil.Timestamp(time.Now()),
+	); err != nil {
+		return err
+	}
+
+	for _, env := range envs {
+		if err := envelopestore.Insert(ctx, tx, messageTable, env, ""); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SaveMessages saves a set of unsent outbound messages that were not
+// produced by processing an inbound message, such as those sent via a
+// TransactionalSender.
+//
+// These messages are returned by ClaimUnsentMessages() regardless of their
+// age.
+func (Repository) SaveMessages(
+	ctx context.Context,
+	ptx persistence.Tx,
+	envs []endpoint.OutboundEnvelope,
+) error {
+	tx := sqlitepersistence.ExtractTx(ptx)
+	now := sqlutil.Timestamp(time.Now())
+
+	for _, env := range envs {
+		if err := envelopestore.Insert(
+			ctx,
+			tx,
+			standaloneMessageTable,
+			env,
+			"insert_time",
+			now,
+		); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// MarkAsSent marks one or more messages as sent, removing them from the
+// outbox.
+func (Repository) MarkAsSent(
+	ctx context.Context,
+	ptx persistence.Tx,
+	envs ...endpoint.OutboundEnvelope,
+) error {
+	tx := sqlitepersistence.ExtractTx(ptx)
+
+	if err := envelopestore.Delete(ctx, tx, messageTable, envs...); err != nil {
+		return err
+	}
+
+	return envelopestore.Delete(ctx, tx, standaloneMessageTable, envs...)
+}
+
+// ClaimUnsentMessages loads up to n unsent outbound messages from outboxes
+// that were saved at least d ago, and messages saved by SaveMessages().
+//
+// The returned messages are claimed by tx. They are not returned by calls to
+// ClaimUnsentMessages() within other transactions started from the same data
+// store until tx is committed or rolled back.
+func (r Repository) ClaimUnsentMessages(
+	ctx context.Context,
+	ptx persistence.Tx,
+	d time.Duration,
+	n int,
+) ([]endpoint.OutboundEnvelope, error) {
+	envelopes, err := r.claim(
+		ctx,
+		ptx,
+		n,
+		`SELECT `+envelopestore.Columns+`
+		FROM `+standaloneMessageTable+`
+		ORDER BY insert_time`,
+	)
+	if err != nil || len(envelopes) == n {
+		return envelopes, err
+	}
+
+	more, err := r.claim(
+		ctx,
+		ptx,
+		n-len(envelopes),
+		`SELECT `+envelopestore.Columns+`
+		FROM `+messageTable+`
+		WHERE causation_id IN (
+			SELECT causation_id FROM ax_outbox
+			WHERE insert_time < ?
+		)`,
+		sqlutil.Timestamp(time.Now().Add(-d)),
+	)
+
+	return append(envelopes, more...), err
+}
+
+// claim executes a query that selects outbox messages, and claims up to n of
+// the messages that are not already claimed by another transaction.
+func (Repository) claim(
+	ctx context.Context,
+	ptx persistence.Tx,
+	n int,
+	query string,
+	args ...interface{},
+) ([]endpoint.OutboundEnvelope, error) {
+	tx := sqlitepersistence.ExtractTx(ptx)
+
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var envelopes []endpoint.OutboundEnvelope
+
+	for len(envelopes) < n && rows.Next() {
+		env, err := envelopestore.Scan(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		if sqlitepersistence.Claim(ptx, claimKey(env)) {
+			envelopes = append(envelopes, env)
+		}
+	}
+
+	return envelopes, rows.Err()
+}
+
+// claimKey returns the key used to claim the row containing env.
+func claimKey(env endpoint.OutboundEnvelope) string {
+	return "outbox:" + env.MessageID.Get()
+}
+
+// PurgeOutboxes deletes up to n outboxes that contain no unsent messages
+// and were saved at least d ago. It returns the number of outboxes deleted.
+//
+// Once an outbox is deleted, the message that produced it is no longer
+// deduplicated, and is processed again if it is redelivered.
+func (Repository) PurgeOutboxes(
+	ctx context.Context,
+	ptx persistence.Tx,
+	d time.Duration,
+	n int,
+) (int, error) {
+	tx := sqlitepersistence.ExtractTx(ptx)
+
+	res, err := tx.ExecContext(
+		ctx,
+		`DELETE FROM ax_outbox
+		WHERE causation_id IN (
+			SELECT causation_id FROM ax_outbox AS o
+			WHERE insert_time < ?
+			AND NOT EXISTS (
+				SELECT * FROM `+messageTable+` AS m
+				WHERE m.causation_id = o.causation_id
+			)
+			ORDER BY insert_time
+			LIMIT ?
+		)`,
+		sqlutil.Timestamp(time.Now().Add(-d)),
+		n,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	count, err := res.RowsAffected()
+	return int(count), err
+}
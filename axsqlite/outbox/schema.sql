@@ -0,0 +1,60 @@
+--
+-- ax_outbox stores the time at which an outbox was created.
+--
+-- The presence of a row in this table indicates that the message has already
+-- been handled, even if the outbox is now empty.
+--
+-- insert_time is the number of nanoseconds since the Unix epoch.
+--
+CREATE TABLE IF NOT EXISTS ax_outbox (
+    causation_id TEXT NOT NULL,
+    insert_time  INTEGER NOT NULL,
+
+    PRIMARY KEY (causation_id)
+);
+
+CREATE INDEX IF NOT EXISTS ax_outbox_insert_time ON ax_outbox (insert_time);
+
+--
+-- ax_outbox_message stores the messages within a single outbox.
+--
+CREATE TABLE IF NOT EXISTS ax_outbox_message (
+    message_id     TEXT NOT NULL,
+    causation_id   TEXT NOT NULL, -- ax_outbox.causation_id
+    correlation_id TEXT NOT NULL,
+    created_at     TEXT NOT NULL,
+    send_at        TEXT NOT NULL,
+    content_type   TEXT NOT NULL,
+    data           BLOB NOT NULL,
+    operation      INTEGER NOT NULL,
+    destination    TEXT NOT NULL,
+
+    PRIMARY KEY (message_id)
+);
+
+CREATE INDEX IF NOT EXISTS ax_outbox_message_causation_id ON ax_outbox_message (causation_id);
+CREATE INDEX IF NOT EXISTS ax_outbox_message_correlation_id ON ax_outbox_message (correlation_id);
+
+--
+-- ax_outbox_standalone_message stores unsent messages that were not produced
+-- by processing an inbound message, such as those sent via
+-- outbox.TransactionalSender.
+--
+-- insert_time is the number of nanoseconds since the Unix epoch.
+--
+CREATE TABLE IF NOT EXISTS ax_outbox_standalone_message (
+    message_id     TEXT NOT NULL,
+    causation_id   TEXT NOT NULL,
+    correlation_id TEXT NOT NULL,
+    created_at     TEXT NOT NULL,
+    send_at        TEXT NOT NULL,
+    content_type   TEXT NOT NULL,
+    data           BLOB NOT NULL,
+    operation      INTEGER NOT NULL,
+    destination    TEXT NOT NULL,
+    insert_time    INTEGER NOT NULL,
+
+    PRIMARY KEY (message_id)
+);
+
+CREATE INDEX IF NOT EXISTS ax_outbox_standalone_message_insert_time ON ax_outbox_standalone_message (insert_time);
@@ -0,0 +1,34 @@
+package axsqlite
+
+import (
+	"context"
+	"database/sql"
+
+	sqlitepersistence "github.com/jmalloc/ax/axsqlite/persistence"
+	"github.com/jmalloc/ax/persistence"
+)
+
+// NewDataStore returns a new data store that is backed by an SQLite database.
+//
+// Rows claimed by a transaction are only skipped by other transactions
+// started from the same data store, so an application should use a single data
+// store for each database.
+func NewDataStore(db *sql.DB) persistence.DataStore {
+	return &sqlitepersistence.DataStore{DB: db}
+}
+
+// GetDB returns the SQL database contained in ctx.
+//
+// It panics if ctx does not contain an SQLite-specific SQL database.
+func GetDB(ctx context.Context) *sql.DB {
+	ds, _ := persistence.GetDataStore(ctx)
+	return sqlitepersistence.ExtractDB(ds)
+}
+
+// GetTx returns the SQL transaction contained in ctx.
+//
+// It panics if ctx does not contain an SQLite-specific SQL transaction.
+func GetTx(ctx context.Context) *sql.Tx {
+	tx, _ := persistence.GetTx(ctx)
+	return sqlitepersistence.ExtractTx(tx)
+}
@@ -0,0 +1,83 @@
+package persistence
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+
+	"github.com/jmalloc/ax/axsqlite/internal/sqlutil"
+	"github.com/jmalloc/ax/persistence"
+)
+
+// DataStore is an SQLite-backed implementation of Ax's persistence.DataStore
+// interface.
+//
+// SQLite does not support row-level locks, so rows that are "claimed" by a
+// transaction, such as unsent outbox messages, are tracked by the data store
+// itself. Claims are therefore only honored by transactions started from the
+// same DataStore value.
+//
+// The SQL database should be opened with the "_txlock=immediate" DSN parameter,
+// so that transactions acquire the write lock when they begin. Otherwise, a
+// transaction that reads before it writes may fail with SQLITE_BUSY when it
+// attempts to upgrade its lock, without waiting for the busy timeout.
+type DataStore struct {
+	DB *sql.DB
+
+	m      sync.Mutex
+	claims map[string]*Tx
+}
+
+// BeginTx starts a new transaction.
+func (ds *DataStore) BeginTx(ctx context.Context) (persistence.Tx, persistence.Committer, error) {
+	tx, err := ds.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	t := &Tx{ds: ds, sqlTx: tx}
+	return t, t, nil
+}
+
+// IsTransientError returns true if err is caused by the database, or one of its
+// tables, being locked by another connection.
+func (ds *DataStore) IsTransientError(err error) bool {
+	return sqlutil.IsTransient(err)
+}
+
+// claim claims the row identified by k for tx.
+// It returns false if the row is already claimed by another transaction.
+func (ds *DataStore) claim(tx *Tx, k string) bool {
+	ds.m.Lock()
+	defer ds.m.Unlock()
+
+	if c, ok := ds.claims[k]; ok {
+		return c == tx
+	}
+
+	if ds.claims == nil {
+		ds.claims = map[string]*Tx{}
+	}
+
+	ds.claims[k] = tx
+
+	return true
+}
+
+// release releases all rows claimed by tx.
+func (ds *DataStore) release(tx *Tx) {
+	ds.m.Lock()
+	defer ds.m.Unlock()
+
+	for k, c := range ds.claims {
+		if c == tx {
+			delete(ds.claims, k)
+		}
+	}
+}
+
+// ExtractDB returns the SQL database within ds.
+// It panics if ds is not a *DataStore.
+func ExtractDB(ds persistence.DataStore) *sql.DB {
+	return ds.(*DataStore).DB
+}
@@ -0,0 +1,206 @@
+package persistence_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/jmalloc/ax/axsqlite/persistence"
+	"github.com/mattn/go-sqlite3"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("DataStore", func() {
+	Describe("IsTransientError", func() {
+		ds := &DataStore{}
+
+		DescribeTable(
+			"it classifies errors",
+			func(err error, expected bool) {
+				Expect(ds.IsTransientError(err)).To(Equal(expected))
+			},
+			Entry("busy", sqlite3.Error{Code: sqlite3.ErrBusy}, true),
+			Entry("busy snapshot", sqlite3.Error{Code: sqlite3.ErrBusy, ExtendedCode: sqlite3.ErrBusySnapshot}, true),
+			Entry("locked", sqlite3.Error{Code: sqlite3.ErrLocked}, true),
+			Entry("wrapped busy", fmt.Errorf("<context>: %w", sqlite3.Error{Code: sqlite3.ErrBusy}), true),
+			Entry("constraint violation", sqlite3.Error{Code: sqlite3.ErrConstraint}, false),
+			Entry("non-SQLite error", errors.New("<error>"), false),
+			Entry("nil", nil, false),
+		)
+	})
+
+	var (
+		ctx    context.Context
+		cancel func()
+		dir    string
+		db     *sql.DB
+		ds     *DataStore
+	)
+
+	BeforeEach(func() {
+		var fn func()
+		ctx, fn = context.WithTimeout(context.Background(), 5*time.Second)
+		cancel = fn // defeat go vet warning about unused cancel func
+
+		var err error
+		dir, err = ioutil.TempDir("", "ax-sqlite-")
+		if err != nil {
+			panic(err)
+		}
+
+		db, err = sql.Open(
+			"sqlite3",
+			filepath.Join(dir, "ax.db")+"?_busy_timeout=5000&_journal_mode=WAL&_txlock=immediate",
+		)
+		if err != nil {
+			panic(err)
+		}
+
+		if _, err := db.Exec(`CREATE TABLE counter (n INTEGER NOT NULL)`); err != nil {
+			panic(err)
+		}
+
+		ds = &DataStore{DB: db}
+	})
+
+	AfterEach(func() {
+		cancel()
+
+		if err := db.Close(); err != nil {
+			panic(err)
+		}
+
+		if err := os.RemoveAll(dir); err != nil {
+			panic(err)
+		}
+	})
+
+	Describe("BeginTx", func() {
+		It("calls the after-commit hooks when the transaction is committed", func() {
+			tx, com, err := ds.BeginTx(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+			defer com.Rollback()
+
+			var committed, rolledBack bool
+			tx.AfterCommit(func() { committed = true })
+			tx.AfterRollback(func() { rolledBack = true })
+
+			err = com.Commit()
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = com.Rollback()
+			Expect(err).Should(HaveOccurred())
+
+			Expect(committed).To(BeTrue())
+			Expect(rolledBack).To(BeFalse())
+		})
+
+		It("calls the after-rollback hooks when the transaction is rolled back", func() {
+			tx, com, err := ds.BeginTx(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			var committed, rolledBack bool
+			tx.AfterCommit(func() { committed = true })
+			tx.AfterRollback(func() { rolledBack = true })
+
+			err = com.Rollback()
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(committed).To(BeFalse())
+			Expect(rolledBack).To(BeTrue())
+		})
+
+		It("waits for other transactions that read before they write", func() {
+			increment := func(d time.Duration) error {
+				tx, com, err := ds.BeginTx(ctx)
+				if err != nil {
+					return err
+				}
+				defer com.Rollback()
+
+				stx := ExtractTx(tx)
+
+				var n int
+				if err := stx.QueryRowContext(
+					ctx,
+					`SELECT COUNT(*) FROM counter`,
+				).Scan(&n); err != nil {
+					return err
+				}
+
+				time.Sleep(d)
+
+				if _, err := stx.ExecContext(
+					ctx,
+					`INSERT INTO counter VALUES (?)`,
+					n+1,
+				); err != nil {
+					return err
+				}
+
+				return com.Commit()
+			}
+
+			done := make(chan error, 1)
+			go func() {
+				done <- increment(50 * time.Millisecond)
+			}()
+
+			err := increment(50 * time.Millisecond)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(<-done).ShouldNot(HaveOccurred())
+
+			var max int
+			err = db.QueryRowContext(ctx, `SELECT MAX(n) FROM counter`).Scan(&max)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(max).To(Equal(2))
+		})
+	})
+
+	Describe("Claim", func() {
+		It("returns true if the row is not claimed by another transaction", func() {
+			tx, com, err := ds.BeginTx(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+			defer com.Rollback()
+
+			Expect(Claim(tx, "<key>")).To(BeTrue())
+			Expect(Claim(tx, "<key>")).To(BeTrue())
+		})
+
+		It("returns false if the row is claimed by another transaction", func() {
+			// the transactions are started from a second data store that does
+			// not use "_txlock=immediate", so that both can be open at once.
+			other, err := sql.Open(
+				"sqlite3",
+				filepath.Join(dir, "ax.db")+"?_busy_timeout=5000&_journal_mode=WAL",
+			)
+			Expect(err).ShouldNot(HaveOccurred())
+			defer other.Close()
+
+			ds = &DataStore{DB: other}
+
+			tx1, com1, err := ds.BeginTx(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+			defer com1.Rollback()
+
+			tx2, com2, err := ds.BeginTx(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+			defer com2.Rollback()
+
+			Expect(Claim(tx1, "<key>")).To(BeTrue())
+			Expect(Claim(tx2, "<key>")).To(BeFalse())
+
+			err = com1.Commit()
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(Claim(tx2, "<key>")).To(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,3 @@
+// Package persistence provides SQLite-specific implementations of the
+// interfaces in Ax's top-level "persistence" package.
+package persistence
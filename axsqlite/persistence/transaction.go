@@ -0,0 +1,76 @@
+package persistence
+
+import (
+	"database/sql"
+
+	"github.com/jmalloc/ax/persistence"
+)
+
+// Tx is an SQLite-backed implementation of Ax's persistence.Tx interface.
+//
+// It also implements persistence.Committer.
+type Tx struct {
+	ds    *DataStore
+	sqlTx *sql.Tx
+	hooks persistence.Hooks
+}
+
+// DataStore returns the DataStore that the transaction operates on.
+func (tx *Tx) DataStore() persistence.DataStore {
+	return tx.ds
+}
+
+// AfterCommit registers fn to be called after the transaction is committed.
+func (tx *Tx) AfterCommit(fn func()) {
+	tx.hooks.AfterCommit(fn)
+}
+
+// AfterRollback registers fn to be called after the transaction is rolled
+// back, including when the commit fails.
+func (tx *Tx) AfterRollback(fn func()) {
+	tx.hooks.AfterRollback(fn)
+}
+
+// Commit applies the changes to the data store and releases any rows claimed
+// by the transaction.
+func (tx *Tx) Commit() error {
+	err := tx.sqlTx.Commit()
+	tx.ds.release(tx)
+
+	if err == nil {
+		tx.hooks.Committed()
+	} else if err != sql.ErrTxDone {
+		tx.hooks.RolledBack()
+	}
+
+	return err
+}
+
+// Rollback discards the changes without applying them to the data store and
+// releases any rows claimed by the transaction.
+func (tx *Tx) Rollback() error {
+	err := tx.sqlTx.Rollback()
+	tx.ds.release(tx)
+
+	if err != sql.ErrTxDone {
+		tx.hooks.RolledBack()
+	}
+
+	return err
+}
+
+// ExtractTx returns the SQL transaction within tx.
+// It panics if tx is not a *Tx.
+func ExtractTx(tx persistence.Tx) *sql.Tx {
+	return tx.(*Tx).sqlTx
+}
+
+// Claim claims the row identified by k for tx, such that it is skipped by
+// other transactions until tx is committed or rolled back.
+//
+// k must uniquely identify the row across all tables. It returns false if the
+// row is already claimed by another transaction. It panics if tx is not a *Tx.
+func Claim(tx persistence.Tx, k string) bool {
+	t := tx.(*Tx)
+	return t.ds.claim(t, k)
+}
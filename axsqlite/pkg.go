@@ -0,0 +1,14 @@
+// Package axsqlite provides SQLite-backed implementations of various
+// interfaces consumed by Ax.
+//
+// The SQL database should be opened with a busy timeout, such as by using the
+// "_busy_timeout" DSN parameter of the github.com/mattn/go-sqlite3 driver, as
+// SQLite allows only one transaction to write to the database at a time.
+//
+// Transactions should also acquire the write lock when they begin, such as by
+// using the "_txlock=immediate" DSN parameter, otherwise SQLite may fail a
+// transaction that reads before it writes without waiting for the busy
+// timeout. For example:
+//
+//	db, err := sql.Open("sqlite3", "ax.db?_busy_timeout=5000&_txlock=immediate")
+package axsqlite
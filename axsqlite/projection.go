@@ -0,0 +1,15 @@
+package axsqlite
+
+import (
+	sqliteprojection "github.com/jmalloc/ax/axsqlite/projection"
+	"github.com/jmalloc/ax/projection"
+)
+
+// ProjectionOffsetStore is an offset store backed by an SQLite database.
+var ProjectionOffsetStore projection.OffsetStore = sqliteprojection.OffsetStore{}
+
+// NewReadModelProjector returns a new projector that builds an SQLite-based
+// read-model from a stream of events.
+func NewReadModelProjector(rm sqliteprojection.ReadModel) projection.Projector {
+	return sqliteprojection.NewReadModelProjector(rm)
+}
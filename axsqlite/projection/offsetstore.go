@@ -0,0 +1,265 @@
+package projection
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jmalloc/ax/axsqlite/internal/sqlutil"
+	sqlitepersistence "github.com/jmalloc/ax/axsqlite/persistence"
+	"github.com/jmalloc/ax/persistence"
+	"github.com/jmalloc/ax/projection"
+)
+
+// OffsetStore is an SQLite-backed implementation of Ax's projection.OffsetStore
+// interface.
+type OffsetStore struct{}
+
+// LoadOffset returns the offset at which a consumer should resume
+// reading from the stream.
+//
+// pk is the projector's persistence key, and v is its version.
+func (OffsetStore) LoadOffset(
+	ctx context.Context,
+	ds persistence.DataStore,
+	pk string,
+	v uint64,
+) (uint64, error) {
+	db := sqlitepersistence.ExtractDB(ds)
+
+	var offset uint64
+
+	err := db.QueryRowContext(
+		ctx,
+		`SELECT
+			next_offset
+		FROM ax_projection_offset
+		WHERE persistence_key = ?
+		AND version = ?`,
+		pk,
+		v,
+	).Scan(
+		&offset,
+	)
+
+	if err == sql.ErrNoRows {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	return offset, nil
+}
+
+// SaveOffset sets the offset at which a consumer should resume reading from
+// the stream.
+//
+// pk is the projector's persistence key, and v is its version. c is the offset
+// that is currently stored, as returned by LoadOffset(), and n is the new
+// offset. If c is not the offset that is currently stored, the save fails and
+// a *projection.ConflictError is returned.
+func (s OffsetStore) SaveOffset(
+	ctx context.Context,
+	ptx persistence.Tx,
+	pk string,
+	v uint64,
+	c, n uint64,
+) error {
+	tx := sqlitepersistence.ExtractTx(ptx)
+
+	var (
+		ok  bool
+		err error
+	)
+
+	if c == 0 {
+		ok, err = s.insertOffset(ctx, tx, pk, v, n)
+	}
+
+	// an entry with an offset of zero may already exist if the offset has
+	// been reset, or the version has been activated.
+	if !ok && err == nil {
+		ok, err = s.updateOffset(ctx, tx, pk, v, c, n)
+	}
+
+	if ok || err != nil {
+		return err
+	}
+
+	return &projection.ConflictError{PersistenceKey: pk, Version: v, Offset: c}
+}
+
+// ResetOffset sets the offset at which a consumer should resume reading from
+// the stream back to zero.
+//
+// pk is the projector's persistence key, and v is its version. The version
+// remains active if it was active before the reset.
+func (OffsetStore) ResetOffset(
+	ctx context.Context,
+	ptx persistence.Tx,
+	pk string,
+	v uint64,
+) error {
+	tx := sqlitepersistence.ExtractTx(ptx)
+
+	_, err := tx.ExecContext(
+		ctx,
+		`UPDATE ax_projection_offset SET
+			next_offset = 0
+		WHERE persistence_key = ?
+		AND version = ?`,
+		pk,
+		v,
+	)
+
+	return err
+}
+
+// ActivateVersion makes v the active version of the projector with the
+// persistence key pk, unless a later version is already active.
+//
+// It returns true if v is the active version.
+func (s OffsetStore) ActivateVersion(
+	ctx context.Context,
+	ptx persistence.Tx,
+	pk string,
+	v uint64,
+) (bool, error) {
+	tx := sqlitepersistence.ExtractTx(ptx)
+
+	active, ok, err := s.selectActiveVersion(
+		tx.QueryRowContext(
+			ctx,
+			`SELECT
+				version
+			FROM ax_projection_offset
+			WHERE persistence_key = ?
+			AND is_active`,
+			pk,
+		),
+	)
+	if err != nil {
+		return false, err
+	}
+
+	if ok && active >= v {
+		return active == v, nil
+	}
+
+	// the version may not have saved an offset yet, for example if there are
+	// no messages in the store.
+	if _, err := tx.ExecContext(
+		ctx,
+		`INSERT OR IGNORE INTO ax_projection_offset (
+			persistence_key,
+			version,
+			next_offset
+		) VALUES (?, ?, 0)`,
+		pk,
+		v,
+	); err != nil {
+		return false, err
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`UPDATE ax_projection_offset SET
+			is_active = (version = ?)
+		WHERE persistence_key = ?`,
+		v,
+		pk,
+	)
+
+	return err == nil, err
+}
+
+// LoadActiveVersion returns the active version of the projector with the
+// persistence key pk.
+//
+// It returns false if no version of the projector has been activated.
+func (s OffsetStore) LoadActiveVersion(
+	ctx context.Context,
+	ds persistence.DataStore,
+	pk string,
+) (uint64, bool, error) {
+	db := sqlitepersistence.ExtractDB(ds)
+
+	return s.selectActiveVersion(
+		db.QueryRowContext(
+			ctx,
+			`SELECT
+				version
+			FROM ax_projection_offset
+			WHERE persistence_key = ?
+			AND is_active`,
+			pk,
+		),
+	)
+}
+
+// insertOffset inserts an entry for version v of the projector pk with the
+// offset set to n. It returns false if an entry already exists.
+func (OffsetStore) insertOffset(
+	ctx context.Context,
+	tx *sql.Tx,
+	pk string,
+	v uint64,
+	n uint64,
+) (bool, error) {
+	_, err := tx.ExecContext(
+		ctx,
+		`INSERT INTO ax_projection_offset (
+			persistence_key,
+			version,
+			next_offset
+		) VALUES (?, ?, ?)`,
+		pk,
+		v,
+		n,
+	)
+
+	if sqlutil.IsDuplicateEntry(err) {
+		return false, nil
+	}
+
+	return true, err
+}
+
+// updateOffset sets the offset for version v of the projector pk to n.
+// It returns false if c is not the currently stored offset.
+func (OffsetStore) updateOffset(
+	ctx context.Context,
+	tx *sql.Tx,
+	pk string,
+	v uint64,
+	c, n uint64,
+) (bool, error) {
+	return sqlutil.ExecConditional(
+		ctx,
+		tx,
+		`UPDATE ax_projection_offset SET
+			next_offset = ?
+		WHERE persistence_key = ?
+		AND version = ?
+		AND next_offset = ?`,
+		n,
+		pk,
+		v,
+		c,
+	)
+}
+
+// selectActiveVersion returns the version selected by row. It returns false if
+// there is no such row.
+func (OffsetStore) selectActiveVersion(row *sql.Row) (uint64, bool, error) {
+	var v uint64
+
+	err := row.Scan(&v)
+
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	} else if err != nil {
+		return 0, false, err
+	}
+
+	return v, true, nil
+}
@@ -0,0 +1,12 @@
+--
+-- ax_projection_offset stores the next offset to be read by a projection consumer,
+-- for each version of each projector.
+--
+CREATE TABLE IF NOT EXISTS ax_projection_offset (
+    persistence_key TEXT NOT NULL,
+    version         INTEGER NOT NULL DEFAULT 0,
+    next_offset     INTEGER NOT NULL,
+    is_active       INTEGER NOT NULL DEFAULT 0,
+
+    PRIMARY KEY (persistence_key, version)
+);
@@ -0,0 +1,258 @@
+package projection_test
+
+import (
+	"context"
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/jmalloc/ax/axsqlite"
+	"github.com/jmalloc/ax/axsqlite/internal/schema"
+	. "github.com/jmalloc/ax/axsqlite/projection"
+	"github.com/jmalloc/ax/persistence"
+	"github.com/jmalloc/ax/projection"
+	_ "github.com/mattn/go-sqlite3"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("OffsetStore", func() {
+	var (
+		ctx    context.Context
+		cancel func()
+		dir    string
+		db     *sql.DB
+		ds     persistence.DataStore
+		store  OffsetStore
+	)
+
+	atomically := func(fn func(tx persistence.Tx) error) error {
+		tx, com, err := ds.BeginTx(ctx)
+		if err != nil {
+			return err
+		}
+		defer com.Rollback()
+
+		if err := fn(tx); err != nil {
+			return err
+		}
+
+		return com.Commit()
+	}
+
+	saveVersionOffset := func(v, c, n uint64) error {
+		return atomically(func(tx persistence.Tx) error {
+			return store.SaveOffset(ctx, tx, "<pk>", v, c, n)
+		})
+	}
+
+	saveOffset := func(c, n uint64) error {
+		return saveVersionOffset(0, c, n)
+	}
+
+	loadVersionOffset := func(v uint64) uint64 {
+		o, err := store.LoadOffset(ctx, ds, "<pk>", v)
+		Expect(err).ShouldNot(HaveOccurred())
+		return o
+	}
+
+	loadOffset := func() uint64 {
+		return loadVersionOffset(0)
+	}
+
+	activateVersion := func(v uint64) bool {
+		var ok bool
+		err := atomically(func(tx persistence.Tx) error {
+			var err error
+			ok, err = store.ActivateVersion(ctx, tx, "<pk>", v)
+			return err
+		})
+		Expect(err).ShouldNot(HaveOccurred())
+		return ok
+	}
+
+	loadActiveVersion := func() (uint64, bool) {
+		v, ok, err := store.LoadActiveVersion(ctx, ds, "<pk>")
+		Expect(err).ShouldNot(HaveOccurred())
+		return v, ok
+	}
+
+	BeforeEach(func() {
+		var fn func()
+		ctx, fn = context.WithTimeout(context.Background(), 10*time.Second)
+		cancel = fn // defeat go vet warning about unused cancel func
+
+		var err error
+		dir, err = ioutil.TempDir("", "ax-sqlite-")
+		if err != nil {
+			panic(err)
+		}
+
+		db, err = sql.Open(
+			"sqlite3",
+			filepath.Join(dir, "ax.db")+"?_busy_timeout=5000&_journal_mode=WAL&_txlock=immediate",
+		)
+		if err != nil {
+			panic(err)
+		}
+
+		if err := schema.Create(db, "offsetstore.sql"); err != nil {
+			panic(err)
+		}
+
+		ds = axsqlite.NewDataStore(db)
+	})
+
+	AfterEach(func() {
+		cancel()
+
+		if err := db.Close(); err != nil {
+			panic(err)
+		}
+
+		if err := os.RemoveAll(dir); err != nil {
+			panic(err)
+		}
+	})
+
+	Describe("SaveOffset", func() {
+		It("saves the offset", func() {
+			err := saveOffset(0, 1)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = saveOffset(1, 2)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(loadOffset()).To(BeNumerically("==", 2))
+		})
+
+		It("returns a conflict error if the current offset is not the expected offset", func() {
+			err := saveOffset(0, 1)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = saveOffset(0, 1)
+			Expect(projection.IsConflict(err)).To(BeTrue())
+
+			err = saveOffset(2, 3)
+			Expect(projection.IsConflict(err)).To(BeTrue())
+		})
+
+		It("saves a separate offset for each version", func() {
+			err := saveVersionOffset(1, 0, 5)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = saveVersionOffset(2, 0, 3)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(loadOffset()).To(BeNumerically("==", 0))
+			Expect(loadVersionOffset(1)).To(BeNumerically("==", 5))
+			Expect(loadVersionOffset(2)).To(BeNumerically("==", 3))
+		})
+
+		It("saves the offset of a version that was activated before any offset was saved", func() {
+			Expect(activateVersion(1)).To(BeTrue())
+
+			err := saveVersionOffset(1, 0, 1)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(loadVersionOffset(1)).To(BeNumerically("==", 1))
+		})
+	})
+
+	Describe("ResetOffset", func() {
+		It("resets the offset to zero", func() {
+			err := saveOffset(0, 5)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = atomically(func(tx persistence.Tx) error {
+				return store.ResetOffset(ctx, tx, "<pk>", 0)
+			})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(loadOffset()).To(BeNumerically("==", 0))
+
+			err = saveOffset(5, 6)
+			Expect(projection.IsConflict(err)).To(BeTrue())
+
+			err = saveOffset(0, 1)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		It("allows an offset to be saved if no offset was saved before the reset", func() {
+			err := atomically(func(tx persistence.Tx) error {
+				return store.ResetOffset(ctx, tx, "<pk>", 0)
+			})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = saveOffset(0, 1)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(loadOffset()).To(BeNumerically("==", 1))
+		})
+
+		It("does not deactivate the version", func() {
+			Expect(activateVersion(1)).To(BeTrue())
+
+			err := saveVersionOffset(1, 0, 5)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = atomically(func(tx persistence.Tx) error {
+				return store.ResetOffset(ctx, tx, "<pk>", 1)
+			})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			v, ok := loadActiveVersion()
+			Expect(ok).To(BeTrue())
+			Expect(v).To(BeNumerically("==", 1))
+		})
+	})
+
+	Describe("ActivateVersion", func() {
+		It("activates the version", func() {
+			Expect(activateVersion(1)).To(BeTrue())
+
+			v, ok := loadActiveVersion()
+			Expect(ok).To(BeTrue())
+			Expect(v).To(BeNumerically("==", 1))
+		})
+
+		It("replaces an earlier active version", func() {
+			Expect(activateVersion(1)).To(BeTrue())
+			Expect(activateVersion(2)).To(BeTrue())
+
+			v, _ := loadActiveVersion()
+			Expect(v).To(BeNumerically("==", 2))
+		})
+
+		It("does not replace a later active version", func() {
+			Expect(activateVersion(2)).To(BeTrue())
+			Expect(activateVersion(1)).To(BeFalse())
+
+			v, _ := loadActiveVersion()
+			Expect(v).To(BeNumerically("==", 2))
+		})
+
+		It("does not change the stored offsets", func() {
+			err := saveVersionOffset(1, 0, 5)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(activateVersion(1)).To(BeTrue())
+			Expect(activateVersion(2)).To(BeTrue())
+
+			Expect(loadVersionOffset(1)).To(BeNumerically("==", 5))
+			Expect(loadVersionOffset(2)).To(BeNumerically("==", 0))
+		})
+	})
+
+	Describe("LoadActiveVersion", func() {
+		It("returns false if no version has been activated", func() {
+			err := saveVersionOffset(1, 0, 5)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			_, ok := loadActiveVersion()
+			Expect(ok).To(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,206 @@
+package projection
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+
+	"github.com/jmalloc/ax"
+	sqlitepersistence "github.com/jmalloc/ax/axsqlite/persistence"
+	"github.com/jmalloc/ax/internal/typeswitch"
+	"github.com/jmalloc/ax/persistence"
+)
+
+// ReadModel is an interface for application defined read-model projectors.
+//
+// Read-model projectors are a specialization of projectors that are designed to
+// produce an application read-model from a series of events, and persist that
+// read-model in an SQLite database.
+//
+// For each event type to be applied to the read-model, the projector must
+// implement an "apply" method that adheres to one of the following signatures:
+//
+//     func (ctx context.Context, tx *sql.Tx, ev *<T>) error
+//     func (ctx context.Context, tx *sql.Tx, ev *<T>, mctx ax.MessageContext) error
+//
+// Where T is a struct type that implements ax.Event.
+//
+// Applier methods are responsible for mutating the read-model state. The
+// appropriate applier is called for each message encountered in the stream. Any
+// messages in the stream that do not have an associated applier method are
+// ignored.
+//
+// The names of handler methods are meaningful. Each handler method's name must
+// begin with "When". By convention these prefixes are followed by the message
+// name, such as:
+//
+//     func (*BankAccount) WhenAccountCredited(*messages.AccountCredited)
+type ReadModel interface {
+	// PersistenceKey returns a unique name for the read-model.
+	//
+	// The persistence key is used to relate persisted data with the read-model
+	// implementation that owns it. Persistence keys should not be changed once
+	// the read-model's projector has been started.
+	PersistenceKey() string
+}
+
+// ReadModelResetter is an interface for read-models that can discard their
+// state, so that they can be rebuilt from the beginning of the stream.
+type ReadModelResetter interface {
+	ReadModel
+
+	// ResetReadModel discards all of the read-model's state, for example by
+	// deleting all of the rows in its tables.
+	//
+	// It must not commit tx.
+	ResetReadModel(ctx context.Context, tx *sql.Tx) error
+}
+
+// VersionedReadModel is an interface for read-models that declare a version.
+//
+// The version must be incremented whenever a change to the read-model requires
+// it to be rebuilt, such as a change to the structure of its tables. Each
+// version must store its state separately, for example in tables with the
+// version in their name. Readers should use the active version, as per
+// projection.OffsetStore.LoadActiveVersion().
+type VersionedReadModel interface {
+	ReadModel
+
+	// ProjectionVersion returns the read-model's version.
+	ProjectionVersion() uint64
+}
+
+// ReadModelProjector is a projector that applies events to a ReadModel.
+type ReadModelProjector struct {
+	ReadModel  ReadModel
+	EventTypes ax.MessageTypeSet
+	Apply      typeswitch.Switch
+}
+
+// NewReadModelProjector returns a new projector that applies events to a
+// read-model.
+func NewReadModelProjector(rm ReadModel) *ReadModelProjector {
+	p := &ReadModelProjector{
+		ReadModel: rm,
+	}
+
+	sw, _, err := typeswitch.New(
+		[]reflect.Type{
+			reflect.TypeOf(rm),
+			reflect.TypeOf((*ax.Event)(nil)).Elem(),
+			reflect.TypeOf((*ax.MessageContext)(nil)).Elem(),
+			reflect.TypeOf((*context.Context)(nil)).Elem(),
+			reflect.TypeOf((*sql.Tx)(nil)),
+		},
+		[]reflect.Type{
+			reflect.TypeOf((*error)(nil)).Elem(),
+		},
+		readModelApplySignature,
+		readModelApplySignatureWithMessageContext,
+	)
+	if err != nil {
+		panic(err)
+	}
+
+	p.Apply = sw
+	p.EventTypes = ax.TypesByGoType(sw.Types()...)
+
+	return p
+}
+
+// PersistenceKey returns a unique name for the projector.
+//
+// The persistence key is used to relate persisted data with the projector
+// implementation that owns it. Persistence keys should not be changed once
+// a projection has been started.
+func (p ReadModelProjector) PersistenceKey() string {
+	return p.ReadModel.PersistenceKey()
+}
+
+// ProjectionVersion returns the version of the projector.
+//
+// If the read-model implements VersionedReadModel, its ProjectionVersion()
+// method is called. Otherwise, the version is zero.
+func (p ReadModelProjector) ProjectionVersion() uint64 {
+	if v, ok := p.ReadModel.(VersionedReadModel); ok {
+		return v.ProjectionVersion()
+	}
+
+	return 0
+}
+
+// MessageTypes returns the set of messages that the projector intends
+// to handle.
+//
+// The return value should be constant as it may be cached.
+func (p ReadModelProjector) MessageTypes() ax.MessageTypeSet {
+	return p.EventTypes
+}
+
+// ApplyMessage invokes application-defined logic that updates the
+// application state to reflect the occurrence of a message.
+//
+// It may panic if env.Message is not one of the types described by
+// MessageTypes().
+func (p ReadModelProjector) ApplyMessage(ctx context.Context, mctx ax.MessageContext) error {
+	ptx, _ := persistence.GetTx(ctx)
+	tx := sqlitepersistence.ExtractTx(ptx)
+
+	out := p.Apply.Dispatch(
+		p.ReadModel,
+		mctx.Envelope.Message.(ax.Event),
+		mctx,
+		ctx,
+		tx,
+	)
+
+	if err := out[0]; err != nil {
+		return err.(error)
+	}
+
+	return nil
+}
+
+// ResetProjection discards all of the state produced by the projector.
+//
+// If the read-model implements ReadModelResetter, its ResetReadModel() method
+// is called. Otherwise, the read-model's state is retained, and the read-model
+// must tolerate events being applied to it again.
+func (p ReadModelProjector) ResetProjection(ctx context.Context) error {
+	r, ok := p.ReadModel.(ReadModelResetter)
+	if !ok {
+		return nil
+	}
+
+	ptx, _ := persistence.GetTx(ctx)
+	tx := sqlitepersistence.ExtractTx(ptx)
+
+	return r.ResetReadModel(ctx, tx)
+}
+
+var (
+	readModelApplySignature = &typeswitch.Signature{
+		In: []reflect.Type{
+			reflect.TypeOf((*ReadModel)(nil)).Elem(),
+			reflect.TypeOf((*context.Context)(nil)).Elem(),
+			reflect.TypeOf((*sql.Tx)(nil)),
+			reflect.TypeOf((*ax.Event)(nil)).Elem(),
+		},
+		Out: []reflect.Type{
+			reflect.TypeOf((*error)(nil)).Elem(),
+		},
+	}
+
+	readModelApplySignatureWithMessageContext = &typeswitch.Signature{
+		In: []reflect.Type{
+			reflect.TypeOf((*ReadModel)(nil)).Elem(),
+			reflect.TypeOf((*context.Context)(nil)).Elem(),
+			reflect.TypeOf((*sql.Tx)(nil)),
+			reflect.TypeOf((*ax.Event)(nil)).Elem(),
+			reflect.TypeOf((*ax.MessageContext)(nil)).Elem(),
+		},
+		Out: []reflect.Type{
+			reflect.TypeOf((*error)(nil)).Elem(),
+		},
+	}
+)
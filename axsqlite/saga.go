@@ -0,0 +1,17 @@
+package axsqlite
+
+import (
+	sqlitesaga "github.com/jmalloc/ax/axsqlite/saga"
+	"github.com/jmalloc/ax/saga/mapping/keyset"
+	"github.com/jmalloc/ax/saga/persistence/crud"
+	"github.com/jmalloc/ax/saga/persistence/eventsourcing"
+)
+
+// SagaKeySetRepository is a key-set repository backed by an SQLite database.
+var SagaKeySetRepository keyset.Repository = sqlitesaga.KeySetRepository{}
+
+// SagaCRUDRepository is a CRUD saga repository backed by an SQLite database.
+var SagaCRUDRepository crud.Repository = sqlitesaga.CRUDRepository{}
+
+// SagaSnapshotRepository is a saga snapshot repository backed by an SQLite database.
+var SagaSnapshotRepository eventsourcing.SnapshotRepository = sqlitesaga.SnapshotRepository{}
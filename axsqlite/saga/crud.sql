@@ -0,0 +1,15 @@
+--
+-- ax_saga_instance stores saga.Data instances for each instance of a CRUD saga.
+--
+CREATE TABLE IF NOT EXISTS ax_saga_instance (
+    instance_id     TEXT NOT NULL,
+    revision        INTEGER NOT NULL,
+    persistence_key TEXT NOT NULL,
+    description     TEXT NOT NULL,
+    content_type    TEXT NOT NULL,
+    data            BLOB NOT NULL,
+    insert_time     TEXT NOT NULL DEFAULT (STRFTIME('%Y-%m-%d %H:%M:%f', 'now')),
+    update_time     TEXT NOT NULL DEFAULT (STRFTIME('%Y-%m-%d %H:%M:%f', 'now')),
+
+    PRIMARY KEY (instance_id)
+);
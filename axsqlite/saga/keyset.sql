@@ -0,0 +1,13 @@
+--
+-- ax_saga_keyset contains the "key sets" that are associated with saga instances
+-- that use keyset.Mapper.
+--
+CREATE TABLE IF NOT EXISTS ax_saga_keyset (
+    persistence_key TEXT NOT NULL,
+    mapping_key     TEXT NOT NULL,
+    instance_id     TEXT NOT NULL,
+
+    PRIMARY KEY (persistence_key, mapping_key)
+);
+
+CREATE INDEX IF NOT EXISTS ax_saga_keyset_instance_id ON ax_saga_keyset (instance_id);
@@ -0,0 +1,3 @@
+// Package saga provides SQLite-specific implementations of the interfaces in
+// Ax's top-level "saga" package.
+package saga
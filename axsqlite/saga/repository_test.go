@@ -0,0 +1,92 @@
+package saga_test
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/jmalloc/ax/axsqlite"
+	"github.com/jmalloc/ax/axsqlite/internal/schema"
+	. "github.com/jmalloc/ax/axsqlite/saga"
+	"github.com/jmalloc/ax/axtest/sagatests"
+	"github.com/jmalloc/ax/persistence"
+	"github.com/jmalloc/ax/saga/mapping/keyset"
+	"github.com/jmalloc/ax/saga/persistence/crud"
+	"github.com/jmalloc/ax/saga/persistence/eventsourcing"
+	_ "github.com/mattn/go-sqlite3"
+	. "github.com/onsi/ginkgo"
+)
+
+var _ = Describe("Repositories", func() {
+	var (
+		dir string
+		db  *sql.DB
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "ax-sqlite-")
+		if err != nil {
+			panic(err)
+		}
+
+		db, err = sql.Open(
+			"sqlite3",
+			filepath.Join(dir, "ax.db")+"?_busy_timeout=5000&_journal_mode=WAL&_txlock=immediate",
+		)
+		if err != nil {
+			panic(err)
+		}
+
+		for _, f := range []string{"crud.sql", "keyset.sql", "snapshot.sql"} {
+			if err := schema.Create(db, f); err != nil {
+				panic(err)
+			}
+		}
+	})
+
+	AfterEach(func() {
+		if err := db.Close(); err != nil {
+			panic(err)
+		}
+
+		if err := os.RemoveAll(dir); err != nil {
+			panic(err)
+		}
+	})
+
+	getStore := func() persistence.DataStore {
+		return axsqlite.NewDataStore(db)
+	}
+
+	Describe(
+		"CRUDRepository",
+		sagatests.CRUDRepositorySuite(
+			getStore,
+			func() crud.Repository {
+				return CRUDRepository{}
+			},
+		),
+	)
+
+	Describe(
+		"KeySetRepository",
+		sagatests.KeySetRepositorySuite(
+			getStore,
+			func() keyset.Repository {
+				return KeySetRepository{}
+			},
+		),
+	)
+
+	Describe(
+		"SnapshotRepository",
+		sagatests.SnapshotRepositorySuite(
+			getStore,
+			func() eventsourcing.SnapshotRepository {
+				return SnapshotRepository{}
+			},
+		),
+	)
+})
@@ -0,0 +1,14 @@
+--
+-- ax_saga_snapshot stores snapshots saga.Data instances for eventsourced sagas.
+--
+CREATE TABLE IF NOT EXISTS ax_saga_snapshot (
+    instance_id     TEXT NOT NULL,
+    revision        INTEGER NOT NULL,
+    persistence_key TEXT NOT NULL,
+    description     TEXT NOT NULL,
+    content_type    TEXT NOT NULL,
+    data            BLOB NOT NULL,
+    insert_time     TEXT NOT NULL DEFAULT (STRFTIME('%Y-%m-%d %H:%M:%f', 'now')),
+
+    PRIMARY KEY (instance_id, revision)
+);
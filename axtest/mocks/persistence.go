@@ -175,7 +175,9 @@ func (mock *DataStoreMock) BeginTxCalls() []struct {
 }
 
 var (
-	lockTxMockDataStore sync.RWMutex
+	lockTxMockAfterCommit   sync.RWMutex
+	lockTxMockAfterRollback sync.RWMutex
+	lockTxMockDataStore     sync.RWMutex
 )
 
 // Ensure, that TxMock does implement Tx.
@@ -188,6 +190,12 @@ var _ persistence.Tx = &TxMock{}
 //
 //         // make and configure a mocked Tx
 //         mockedTx := &TxMock{
+//             AfterCommitFunc: func(fn func())  {
+// 	               panic("mock out the AfterCommit method")
+//             },
+//             AfterRollbackFunc: func(fn func())  {
+// 	               panic("mock out the AfterRollback method")
+//             },
 //             DataStoreFunc: func() persistence.DataStore {
 // 	               panic("mock out the DataStore method")
 //             },
@@ -198,17 +206,95 @@ var _ persistence.Tx = &TxMock{}
 //
 //     }
 type TxMock struct {
+	// AfterCommitFunc mocks the AfterCommit method.
+	AfterCommitFunc func(fn func())
+
+	// AfterRollbackFunc mocks the AfterRollback method.
+	AfterRollbackFunc func(fn func())
+
 	// DataStoreFunc mocks the DataStore method.
 	DataStoreFunc func() persistence.DataStore
 
 	// calls tracks calls to the methods.
 	calls struct {
+		// AfterCommit holds details about calls to the AfterCommit method.
+		AfterCommit []struct {
+			// Fn is the fn argument value.
+			Fn func()
+		}
+		// AfterRollback holds details about calls to the AfterRollback method.
+		AfterRollback []struct {
+			// Fn is the fn argument value.
+			Fn func()
+		}
 		// DataStore holds details about calls to the DataStore method.
 		DataStore []struct {
 		}
 	}
 }
 
+// AfterCommit calls AfterCommitFunc.
+func (mock *TxMock) AfterCommit(fn func()) {
+	if mock.AfterCommitFunc == nil {
+		panic("TxMock.AfterCommitFunc: method is nil but Tx.AfterCommit was just called")
+	}
+	callInfo := struct {
+		Fn func()
+	}{
+		Fn: fn,
+	}
+	lockTxMockAfterCommit.Lock()
+	mock.calls.AfterCommit = append(mock.calls.AfterCommit, callInfo)
+	lockTxMockAfterCommit.Unlock()
+	mock.AfterCommitFunc(fn)
+}
+
+// AfterCommitCalls gets all the calls that were made to AfterCommit.
+// Check the length with:
+//     len(mockedTx.AfterCommitCalls())
+func (mock *TxMock) AfterCommitCalls() []struct {
+	Fn func()
+} {
+	var calls []struct {
+		Fn func()
+	}
+	lockTxMockAfterCommit.RLock()
+	calls = mock.calls.AfterCommit
+	lockTxMockAfterCommit.RUnlock()
+	return calls
+}
+
+// AfterRollback calls AfterRollbackFunc.
+func (mock *TxMock) AfterRollback(fn func()) {
+	if mock.AfterRollbackFunc == nil {
+		panic("TxMock.AfterRollbackFunc: method is nil but Tx.AfterRollback was just called")
+	}
+	callInfo := struct {
+		Fn func()
+	}{
+		Fn: fn,
+	}
+	lockTxMockAfterRollback.Lock()
+	mock.calls.AfterRollback = append(mock.calls.AfterRollback, callInfo)
+	lockTxMockAfterRollback.Unlock()
+	mock.AfterRollbackFunc(fn)
+}
+
+// AfterRollbackCalls gets all the calls that were made to AfterRollback.
+// Check the length with:
+//     len(mockedTx.AfterRollbackCalls())
+func (mock *TxMock) AfterRollbackCalls() []struct {
+	Fn func()
+} {
+	var calls []struct {
+		Fn func()
+	}
+	lockTxMockAfterRollback.RLock()
+	calls = mock.calls.AfterRollback
+	lockTxMockAfterRollback.RUnlock()
+	return calls
+}
+
 // DataStore calls DataStoreFunc.
 func (mock *TxMock) DataStore() persistence.DataStore {
 	if mock.DataStoreFunc == nil {
@@ -142,6 +142,27 @@ func RepositorySuite(
 						axtest.ConsistsOfOutboundEnvelopes(envs, m2),
 					).To(m.BeTrue())
 				})
+				g.It("does not return messages that are marked as sent in a batch", func() {
+					tx, com, err := store.BeginTx(ctx)
+					m.Expect(err).ShouldNot(m.HaveOccurred())
+					defer com.Rollback()
+
+					err = repo.MarkAsSent(
+						ctx,
+						tx,
+						m1,
+						m2,
+					)
+					m.Expect(err).ShouldNot(m.HaveOccurred())
+
+					err = com.Commit()
+					m.Expect(err).ShouldNot(m.HaveOccurred())
+
+					envs, ok, err := repo.LoadOutbox(ctx, store, causationID)
+					m.Expect(err).ShouldNot(m.HaveOccurred())
+					m.Expect(ok).To(m.BeTrue())
+					m.Expect(envs).To(m.BeEmpty())
+				})
 			})
 
 			g.Context("when the outbox exists but contains no messages", func() {
@@ -251,6 +272,83 @@ func RepositorySuite(
 			})
 		})
 
+		g.Describe("SaveMessages", func() {
+			var env endpoint.OutboundEnvelope
+
+			g.BeforeEach(func() {
+				env = endpoint.OutboundEnvelope{
+					Envelope: ax.Envelope{
+						MessageID:     causationID,
+						CausationID:   causationID,
+						CorrelationID: correlationID,
+						CreatedAt:     time.Now(),
+						SendAt:        time.Now(),
+						Message:       &testmessages.Message{},
+					},
+					Operation:           endpoint.OpSendUnicast,
+					DestinationEndpoint: "<dest>",
+				}
+
+				tx, com, err := store.BeginTx(ctx)
+				if err != nil {
+					panic(err)
+				}
+				defer com.Rollback()
+
+				err = repo.SaveMessages(
+					ctx,
+					tx,
+					[]endpoint.OutboundEnvelope{env},
+				)
+				if err != nil {
+					panic(err)
+				}
+
+				err = com.Commit()
+				if err != nil {
+					panic(err)
+				}
+			})
+
+			g.It("does not create an outbox for the message", func() {
+				_, ok, err := repo.LoadOutbox(ctx, store, env.MessageID)
+				m.Expect(err).ShouldNot(m.HaveOccurred())
+				m.Expect(ok).To(m.BeFalse())
+			})
+
+			g.It("makes the messages available to ClaimUnsentMessages() regardless of their age", func() {
+				tx, com, err := store.BeginTx(ctx)
+				m.Expect(err).ShouldNot(m.HaveOccurred())
+				defer com.Rollback()
+
+				envs, err := repo.ClaimUnsentMessages(ctx, tx, 1*time.Hour, 10)
+				m.Expect(err).ShouldNot(m.HaveOccurred())
+				m.Expect(
+					axtest.ConsistsOfOutboundEnvelopes(envs, env),
+				).To(m.BeTrue())
+			})
+
+			g.It("does not make messages that are marked as sent available to ClaimUnsentMessages()", func() {
+				tx, com, err := store.BeginTx(ctx)
+				m.Expect(err).ShouldNot(m.HaveOccurred())
+				defer com.Rollback()
+
+				err = repo.MarkAsSent(ctx, tx, env)
+				m.Expect(err).ShouldNot(m.HaveOccurred())
+
+				err = com.Commit()
+				m.Expect(err).ShouldNot(m.HaveOccurred())
+
+				tx, com, err = store.BeginTx(ctx)
+				m.Expect(err).ShouldNot(m.HaveOccurred())
+				defer com.Rollback()
+
+				envs, err := repo.ClaimUnsentMessages(ctx, tx, 0, 10)
+				m.Expect(err).ShouldNot(m.HaveOccurred())
+				m.Expect(envs).To(m.BeEmpty())
+			})
+		})
+
 		g.Describe("MarkAsSent", func() {
 			g.It("does not return an error if the message has already been marked as sent", func() {
 				tx, com, err := store.BeginTx(ctx)
@@ -292,5 +390,197 @@ func RepositorySuite(
 				m.Expect(err).ShouldNot(m.HaveOccurred())
 			})
 		})
+
+		g.Describe("ClaimUnsentMessages", func() {
+			var env endpoint.OutboundEnvelope
+
+			g.BeforeEach(func() {
+				env = endpoint.OutboundEnvelope{
+					Envelope: ax.Envelope{
+						MessageID:     ax.GenerateMessageID(),
+						CausationID:   causationID,
+						CorrelationID: correlationID,
+						CreatedAt:     time.Now(),
+						SendAt:        time.Now(),
+						Message:       &testmessages.Message{},
+					},
+					Operation:           endpoint.OpSendUnicast,
+					DestinationEndpoint: "<dest>",
+				}
+
+				tx, com, err := store.BeginTx(ctx)
+				if err != nil {
+					panic(err)
+				}
+				defer com.Rollback()
+
+				err = repo.SaveOutbox(
+					ctx,
+					tx,
+					causationID,
+					[]endpoint.OutboundEnvelope{env},
+				)
+				if err != nil {
+					panic(err)
+				}
+
+				err = com.Commit()
+				if err != nil {
+					panic(err)
+				}
+			})
+
+			g.It("returns messages from outboxes older than the threshold", func() {
+				tx, com, err := store.BeginTx(ctx)
+				m.Expect(err).ShouldNot(m.HaveOccurred())
+				defer com.Rollback()
+
+				envs, err := repo.ClaimUnsentMessages(ctx, tx, 0, 10)
+				m.Expect(err).ShouldNot(m.HaveOccurred())
+				m.Expect(
+					axtest.ConsistsOfOutboundEnvelopes(envs, env),
+				).To(m.BeTrue())
+			})
+
+			g.It("does not return messages from outboxes newer than the threshold", func() {
+				tx, com, err := store.BeginTx(ctx)
+				m.Expect(err).ShouldNot(m.HaveOccurred())
+				defer com.Rollback()
+
+				envs, err := repo.ClaimUnsentMessages(ctx, tx, 1*time.Hour, 10)
+				m.Expect(err).ShouldNot(m.HaveOccurred())
+				m.Expect(envs).To(m.BeEmpty())
+			})
+
+			g.It("does not return messages that are marked as sent", func() {
+				tx, com, err := store.BeginTx(ctx)
+				m.Expect(err).ShouldNot(m.HaveOccurred())
+				defer com.Rollback()
+
+				err = repo.MarkAsSent(ctx, tx, env)
+				m.Expect(err).ShouldNot(m.HaveOccurred())
+
+				err = com.Commit()
+				m.Expect(err).ShouldNot(m.HaveOccurred())
+
+				tx, com, err = store.BeginTx(ctx)
+				m.Expect(err).ShouldNot(m.HaveOccurred())
+				defer com.Rollback()
+
+				envs, err := repo.ClaimUnsentMessages(ctx, tx, 0, 10)
+				m.Expect(err).ShouldNot(m.HaveOccurred())
+				m.Expect(envs).To(m.BeEmpty())
+			})
+
+			g.It("does not return messages claimed by another transaction", func() {
+				tx1, com1, err := store.BeginTx(ctx)
+				m.Expect(err).ShouldNot(m.HaveOccurred())
+				defer com1.Rollback()
+
+				envs, err := repo.ClaimUnsentMessages(ctx, tx1, 0, 10)
+				m.Expect(err).ShouldNot(m.HaveOccurred())
+				m.Expect(envs).To(m.HaveLen(1))
+
+				tx2, com2, err := store.BeginTx(ctx)
+				m.Expect(err).ShouldNot(m.HaveOccurred())
+				defer com2.Rollback()
+
+				envs, err = repo.ClaimUnsentMessages(ctx, tx2, 0, 10)
+				m.Expect(err).ShouldNot(m.HaveOccurred())
+				m.Expect(envs).To(m.BeEmpty())
+			})
+		})
+
+		g.Describe("PurgeOutboxes", func() {
+			save := func(id ax.MessageID, envs ...endpoint.OutboundEnvelope) {
+				tx, com, err := store.BeginTx(ctx)
+				if err != nil {
+					panic(err)
+				}
+				defer com.Rollback()
+
+				if err := repo.SaveOutbox(ctx, tx, id, envs); err != nil {
+					panic(err)
+				}
+
+				if err := com.Commit(); err != nil {
+					panic(err)
+				}
+			}
+
+			purge := func(d time.Duration, n int) int {
+				tx, com, err := store.BeginTx(ctx)
+				m.Expect(err).ShouldNot(m.HaveOccurred())
+				defer com.Rollback()
+
+				count, err := repo.PurgeOutboxes(ctx, tx, d, n)
+				m.Expect(err).ShouldNot(m.HaveOccurred())
+
+				err = com.Commit()
+				m.Expect(err).ShouldNot(m.HaveOccurred())
+
+				return count
+			}
+
+			g.It("deletes empty outboxes older than the threshold", func() {
+				save(causationID)
+
+				count := purge(0, 10)
+				m.Expect(count).To(m.Equal(1))
+
+				_, ok, err := repo.LoadOutbox(ctx, store, causationID)
+				m.Expect(err).ShouldNot(m.HaveOccurred())
+				m.Expect(ok).To(m.BeFalse())
+			})
+
+			g.It("does not delete outboxes newer than the threshold", func() {
+				save(causationID)
+
+				count := purge(1*time.Hour, 10)
+				m.Expect(count).To(m.Equal(0))
+
+				_, ok, err := repo.LoadOutbox(ctx, store, causationID)
+				m.Expect(err).ShouldNot(m.HaveOccurred())
+				m.Expect(ok).To(m.BeTrue())
+			})
+
+			g.It("does not delete outboxes that contain unsent messages", func() {
+				save(
+					causationID,
+					endpoint.OutboundEnvelope{
+						Envelope: ax.Envelope{
+							MessageID:     ax.GenerateMessageID(),
+							CausationID:   causationID,
+							CorrelationID: correlationID,
+							CreatedAt:     time.Now(),
+							SendAt:        time.Now(),
+							Message:       &testmessages.Message{},
+						},
+						Operation:           endpoint.OpSendUnicast,
+						DestinationEndpoint: "<dest>",
+					},
+				)
+
+				count := purge(0, 10)
+				m.Expect(count).To(m.Equal(0))
+
+				envs, ok, err := repo.LoadOutbox(ctx, store, causationID)
+				m.Expect(err).ShouldNot(m.HaveOccurred())
+				m.Expect(ok).To(m.BeTrue())
+				m.Expect(envs).To(m.HaveLen(1))
+			})
+
+			g.It("deletes at most n outboxes", func() {
+				save(causationID)
+				save(ax.GenerateMessageID())
+				save(ax.GenerateMessageID())
+
+				count := purge(0, 2)
+				m.Expect(count).To(m.Equal(2))
+
+				count = purge(0, 2)
+				m.Expect(count).To(m.Equal(1))
+			})
+		})
 	}
 }
@@ -0,0 +1,175 @@
+package sagatests
+
+import (
+	"context"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/jmalloc/ax/axtest/testmessages"
+	"github.com/jmalloc/ax/persistence"
+	"github.com/jmalloc/ax/saga"
+	"github.com/jmalloc/ax/saga/persistence/crud"
+	g "github.com/onsi/ginkgo"
+	m "github.com/onsi/gomega"
+)
+
+// CRUDRepositorySuite returns a test suite for implementations of
+// crud.Repository.
+func CRUDRepositorySuite(
+	getStore func() persistence.DataStore,
+	getRepo func() crud.Repository,
+) func() {
+	return func() {
+		var (
+			store  persistence.DataStore
+			repo   crud.Repository
+			ctx    context.Context
+			cancel func()
+			id     saga.InstanceID
+		)
+
+		g.BeforeEach(func() {
+			store = getStore()
+			repo = getRepo()
+
+			var fn func()
+			ctx, fn = context.WithTimeout(context.Background(), 15*time.Second)
+			cancel = fn // defeat go vet warning about unused cancel func
+
+			id = saga.GenerateInstanceID()
+		})
+
+		g.AfterEach(func() {
+			cancel()
+		})
+
+		save := func(pk string, i saga.Instance) error {
+			return atomically(ctx, store, func(tx persistence.Tx) error {
+				return repo.SaveSagaInstance(ctx, tx, pk, i)
+			})
+		}
+
+		load := func(pk string) (i saga.Instance, ok bool, err error) {
+			err = atomically(ctx, store, func(tx persistence.Tx) error {
+				var err error
+				i, ok, err = repo.LoadSagaInstance(ctx, tx, pk, id)
+				return err
+			})
+			return
+		}
+
+		g.Describe("LoadSagaInstance", func() {
+			g.It("returns false if the instance does not exist", func() {
+				_, ok, err := load("<pk>")
+				m.Expect(err).ShouldNot(m.HaveOccurred())
+				m.Expect(ok).To(m.BeFalse())
+			})
+
+			g.It("returns the saved instance at the first revision", func() {
+				data := &testmessages.Message{Value: "<value>"}
+
+				err := save("<pk>", saga.Instance{InstanceID: id, Data: data})
+				m.Expect(err).ShouldNot(m.HaveOccurred())
+
+				i, ok, err := load("<pk>")
+				m.Expect(err).ShouldNot(m.HaveOccurred())
+				m.Expect(ok).To(m.BeTrue())
+				m.Expect(i.InstanceID).To(m.Equal(id))
+				m.Expect(i.Revision).To(m.BeEquivalentTo(1))
+				m.Expect(proto.Equal(i.Data, data)).To(m.BeTrue())
+			})
+
+			g.It("returns an error if the instance belongs to a different saga", func() {
+				err := save("<pk>", saga.Instance{InstanceID: id, Data: &testmessages.Message{}})
+				m.Expect(err).ShouldNot(m.HaveOccurred())
+
+				_, _, err = load("<other>")
+				m.Expect(err).Should(m.HaveOccurred())
+			})
+		})
+
+		g.Describe("SaveSagaInstance", func() {
+			g.BeforeEach(func() {
+				err := save("<pk>", saga.Instance{InstanceID: id, Data: &testmessages.Message{Value: "<first>"}})
+				m.Expect(err).ShouldNot(m.HaveOccurred())
+			})
+
+			g.It("increments the revision and replaces the data", func() {
+				data := &testmessages.Message{Value: "<second>"}
+
+				err := save("<pk>", saga.Instance{InstanceID: id, Revision: 1, Data: data})
+				m.Expect(err).ShouldNot(m.HaveOccurred())
+
+				i, _, err := load("<pk>")
+				m.Expect(err).ShouldNot(m.HaveOccurred())
+				m.Expect(i.Revision).To(m.BeEquivalentTo(2))
+				m.Expect(proto.Equal(i.Data, data)).To(m.BeTrue())
+			})
+
+			g.It("returns an error if a new instance has the same ID as an existing instance", func() {
+				err := save("<pk>", saga.Instance{InstanceID: id, Data: &testmessages.Message{}})
+				m.Expect(err).Should(m.HaveOccurred())
+			})
+
+			g.It("returns an error if the revision is not the current revision", func() {
+				err := save("<pk>", saga.Instance{InstanceID: id, Revision: 1, Data: &testmessages.Message{}})
+				m.Expect(err).ShouldNot(m.HaveOccurred())
+
+				// save again using the revision that has just been superseded
+				err = save("<pk>", saga.Instance{InstanceID: id, Revision: 1, Data: &testmessages.Message{Value: "<stale>"}})
+				m.Expect(err).Should(m.HaveOccurred())
+
+				i, _, err := load("<pk>")
+				m.Expect(err).ShouldNot(m.HaveOccurred())
+				m.Expect(i.Revision).To(m.BeEquivalentTo(2))
+				m.Expect(i.Data.(*testmessages.Message).Value).To(m.Equal(""))
+			})
+
+			g.It("returns an error if the instance belongs to a different saga", func() {
+				err := save("<other>", saga.Instance{InstanceID: id, Revision: 1, Data: &testmessages.Message{}})
+				m.Expect(err).Should(m.HaveOccurred())
+			})
+		})
+
+		g.Describe("DeleteSagaInstance", func() {
+			del := func(pk string, rev saga.Revision) error {
+				return atomically(ctx, store, func(tx persistence.Tx) error {
+					return repo.DeleteSagaInstance(
+						ctx,
+						tx,
+						pk,
+						saga.Instance{InstanceID: id, Revision: rev, Data: &testmessages.Message{}},
+					)
+				})
+			}
+
+			g.BeforeEach(func() {
+				err := save("<pk>", saga.Instance{InstanceID: id, Data: &testmessages.Message{}})
+				m.Expect(err).ShouldNot(m.HaveOccurred())
+			})
+
+			g.It("deletes the instance", func() {
+				err := del("<pk>", 1)
+				m.Expect(err).ShouldNot(m.HaveOccurred())
+
+				_, ok, err := load("<pk>")
+				m.Expect(err).ShouldNot(m.HaveOccurred())
+				m.Expect(ok).To(m.BeFalse())
+			})
+
+			g.It("returns an error if the revision is not the current revision", func() {
+				err := del("<pk>", 2)
+				m.Expect(err).Should(m.HaveOccurred())
+
+				_, ok, err := load("<pk>")
+				m.Expect(err).ShouldNot(m.HaveOccurred())
+				m.Expect(ok).To(m.BeTrue())
+			})
+
+			g.It("returns an error if the instance belongs to a different saga", func() {
+				err := del("<other>", 1)
+				m.Expect(err).Should(m.HaveOccurred())
+			})
+		})
+	}
+}
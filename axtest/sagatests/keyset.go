@@ -0,0 +1,149 @@
+package sagatests
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmalloc/ax/persistence"
+	"github.com/jmalloc/ax/saga"
+	"github.com/jmalloc/ax/saga/mapping/keyset"
+	g "github.com/onsi/ginkgo"
+	m "github.com/onsi/gomega"
+)
+
+// KeySetRepositorySuite returns a test suite for implementations of
+// keyset.Repository.
+func KeySetRepositorySuite(
+	getStore func() persistence.DataStore,
+	getRepo func() keyset.Repository,
+) func() {
+	return func() {
+		var (
+			store  persistence.DataStore
+			repo   keyset.Repository
+			ctx    context.Context
+			cancel func()
+			id     saga.InstanceID
+		)
+
+		g.BeforeEach(func() {
+			store = getStore()
+			repo = getRepo()
+
+			var fn func()
+			ctx, fn = context.WithTimeout(context.Background(), 15*time.Second)
+			cancel = fn // defeat go vet warning about unused cancel func
+
+			id = saga.GenerateInstanceID()
+		})
+
+		g.AfterEach(func() {
+			cancel()
+		})
+
+		save := func(pk string, ks []string, id saga.InstanceID) error {
+			return atomically(ctx, store, func(tx persistence.Tx) error {
+				return repo.SaveKeys(ctx, tx, pk, ks, id)
+			})
+		}
+
+		find := func(pk, mk string) (id saga.InstanceID, ok bool, err error) {
+			err = atomically(ctx, store, func(tx persistence.Tx) error {
+				var err error
+				id, ok, err = repo.FindByKey(ctx, tx, pk, mk)
+				return err
+			})
+			return
+		}
+
+		g.Describe("FindByKey", func() {
+			g.It("returns false if no instance has the key", func() {
+				_, ok, err := find("<pk>", "<key>")
+				m.Expect(err).ShouldNot(m.HaveOccurred())
+				m.Expect(ok).To(m.BeFalse())
+			})
+
+			g.It("returns the ID of the instance that has the key", func() {
+				err := save("<pk>", []string{"<key-1>", "<key-2>"}, id)
+				m.Expect(err).ShouldNot(m.HaveOccurred())
+
+				i, ok, err := find("<pk>", "<key-2>")
+				m.Expect(err).ShouldNot(m.HaveOccurred())
+				m.Expect(ok).To(m.BeTrue())
+				m.Expect(i).To(m.Equal(id))
+			})
+
+			g.It("does not find keys that belong to a different saga", func() {
+				err := save("<pk>", []string{"<key>"}, id)
+				m.Expect(err).ShouldNot(m.HaveOccurred())
+
+				_, ok, err := find("<other>", "<key>")
+				m.Expect(err).ShouldNot(m.HaveOccurred())
+				m.Expect(ok).To(m.BeFalse())
+			})
+		})
+
+		g.Describe("SaveKeys", func() {
+			g.BeforeEach(func() {
+				err := save("<pk>", []string{"<key-1>", "<key-2>"}, id)
+				m.Expect(err).ShouldNot(m.HaveOccurred())
+			})
+
+			g.It("replaces the existing keys of the instance", func() {
+				err := save("<pk>", []string{"<key-2>", "<key-3>"}, id)
+				m.Expect(err).ShouldNot(m.HaveOccurred())
+
+				_, ok, err := find("<pk>", "<key-1>")
+				m.Expect(err).ShouldNot(m.HaveOccurred())
+				m.Expect(ok).To(m.BeFalse())
+
+				i, ok, err := find("<pk>", "<key-3>")
+				m.Expect(err).ShouldNot(m.HaveOccurred())
+				m.Expect(ok).To(m.BeTrue())
+				m.Expect(i).To(m.Equal(id))
+			})
+
+			g.It("returns an error if a key is mapped to another instance", func() {
+				err := save("<pk>", []string{"<key-2>"}, saga.GenerateInstanceID())
+				m.Expect(err).Should(m.HaveOccurred())
+
+				i, ok, err := find("<pk>", "<key-2>")
+				m.Expect(err).ShouldNot(m.HaveOccurred())
+				m.Expect(ok).To(m.BeTrue())
+				m.Expect(i).To(m.Equal(id))
+			})
+
+			g.It("allows instances of different sagas to share keys", func() {
+				other := saga.GenerateInstanceID()
+
+				err := save("<other>", []string{"<key-1>"}, other)
+				m.Expect(err).ShouldNot(m.HaveOccurred())
+
+				i, ok, err := find("<other>", "<key-1>")
+				m.Expect(err).ShouldNot(m.HaveOccurred())
+				m.Expect(ok).To(m.BeTrue())
+				m.Expect(i).To(m.Equal(other))
+			})
+		})
+
+		g.Describe("DeleteKeys", func() {
+			g.It("removes the keys of the instance", func() {
+				err := save("<pk>", []string{"<key-1>", "<key-2>"}, id)
+				m.Expect(err).ShouldNot(m.HaveOccurred())
+
+				err = atomically(ctx, store, func(tx persistence.Tx) error {
+					return repo.DeleteKeys(ctx, tx, "<pk>", id)
+				})
+				m.Expect(err).ShouldNot(m.HaveOccurred())
+
+				_, ok, err := find("<pk>", "<key-1>")
+				m.Expect(err).ShouldNot(m.HaveOccurred())
+				m.Expect(ok).To(m.BeFalse())
+
+				_, ok, err = find("<pk>", "<key-2>")
+				m.Expect(err).ShouldNot(m.HaveOccurred())
+				m.Expect(ok).To(m.BeFalse())
+			})
+		})
+	}
+}
@@ -0,0 +1,3 @@
+// Package sagatests contains functional test suites for implementations of
+// saga related features.
+package sagatests
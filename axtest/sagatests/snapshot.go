@@ -0,0 +1,141 @@
+package sagatests
+
+import (
+	"context"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/jmalloc/ax/axtest/testmessages"
+	"github.com/jmalloc/ax/persistence"
+	"github.com/jmalloc/ax/saga"
+	"github.com/jmalloc/ax/saga/persistence/eventsourcing"
+	g "github.com/onsi/ginkgo"
+	m "github.com/onsi/gomega"
+)
+
+// SnapshotRepositorySuite returns a test suite for implementations of
+// eventsourcing.SnapshotRepository.
+func SnapshotRepositorySuite(
+	getStore func() persistence.DataStore,
+	getRepo func() eventsourcing.SnapshotRepository,
+) func() {
+	return func() {
+		var (
+			store  persistence.DataStore
+			repo   eventsourcing.SnapshotRepository
+			ctx    context.Context
+			cancel func()
+			id     saga.InstanceID
+		)
+
+		g.BeforeEach(func() {
+			store = getStore()
+			repo = getRepo()
+
+			var fn func()
+			ctx, fn = context.WithTimeout(context.Background(), 15*time.Second)
+			cancel = fn // defeat go vet warning about unused cancel func
+
+			id = saga.GenerateInstanceID()
+		})
+
+		g.AfterEach(func() {
+			cancel()
+		})
+
+		save := func(pk string, rev saga.Revision, v string) error {
+			return atomically(ctx, store, func(tx persistence.Tx) error {
+				return repo.SaveSagaSnapshot(
+					ctx,
+					tx,
+					pk,
+					saga.Instance{
+						InstanceID: id,
+						Revision:   rev,
+						Data:       &testmessages.Message{Value: v},
+					},
+				)
+			})
+		}
+
+		load := func(pk string) (i saga.Instance, ok bool, err error) {
+			err = atomically(ctx, store, func(tx persistence.Tx) error {
+				var err error
+				i, ok, err = repo.LoadSagaSnapshot(ctx, tx, pk, id)
+				return err
+			})
+			return
+		}
+
+		g.Describe("LoadSagaSnapshot", func() {
+			g.It("returns false if there are no snapshots of the instance", func() {
+				_, ok, err := load("<pk>")
+				m.Expect(err).ShouldNot(m.HaveOccurred())
+				m.Expect(ok).To(m.BeFalse())
+			})
+
+			g.It("returns the snapshot with the latest revision", func() {
+				err := save("<pk>", 3, "<rev-3>")
+				m.Expect(err).ShouldNot(m.HaveOccurred())
+
+				err = save("<pk>", 5, "<rev-5>")
+				m.Expect(err).ShouldNot(m.HaveOccurred())
+
+				err = save("<pk>", 4, "<rev-4>")
+				m.Expect(err).ShouldNot(m.HaveOccurred())
+
+				i, ok, err := load("<pk>")
+				m.Expect(err).ShouldNot(m.HaveOccurred())
+				m.Expect(ok).To(m.BeTrue())
+				m.Expect(i.InstanceID).To(m.Equal(id))
+				m.Expect(i.Revision).To(m.BeEquivalentTo(5))
+				m.Expect(
+					proto.Equal(i.Data, &testmessages.Message{Value: "<rev-5>"}),
+				).To(m.BeTrue())
+			})
+
+			g.It("returns an error if the snapshot belongs to a different saga", func() {
+				err := save("<pk>", 1, "<value>")
+				m.Expect(err).ShouldNot(m.HaveOccurred())
+
+				_, _, err = load("<other>")
+				m.Expect(err).Should(m.HaveOccurred())
+			})
+		})
+
+		g.Describe("SaveSagaSnapshot", func() {
+			g.It("returns an error if a snapshot already exists at the same revision", func() {
+				err := save("<pk>", 1, "<first>")
+				m.Expect(err).ShouldNot(m.HaveOccurred())
+
+				err = save("<pk>", 1, "<second>")
+				m.Expect(err).Should(m.HaveOccurred())
+
+				i, _, err := load("<pk>")
+				m.Expect(err).ShouldNot(m.HaveOccurred())
+				m.Expect(
+					proto.Equal(i.Data, &testmessages.Message{Value: "<first>"}),
+				).To(m.BeTrue())
+			})
+		})
+
+		g.Describe("DeleteSagaSnapshots", func() {
+			g.It("deletes all snapshots of the instance", func() {
+				err := save("<pk>", 1, "<rev-1>")
+				m.Expect(err).ShouldNot(m.HaveOccurred())
+
+				err = save("<pk>", 2, "<rev-2>")
+				m.Expect(err).ShouldNot(m.HaveOccurred())
+
+				err = atomically(ctx, store, func(tx persistence.Tx) error {
+					return repo.DeleteSagaSnapshots(ctx, tx, "<pk>", id)
+				})
+				m.Expect(err).ShouldNot(m.HaveOccurred())
+
+				_, ok, err := load("<pk>")
+				m.Expect(err).ShouldNot(m.HaveOccurred())
+				m.Expect(ok).To(m.BeFalse())
+			})
+		})
+	}
+}
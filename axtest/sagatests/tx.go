@@ -0,0 +1,27 @@
+package sagatests
+
+import (
+	"context"
+
+	"github.com/jmalloc/ax/persistence"
+)
+
+// atomically calls fn within a transaction on ds. The transaction is committed
+// if fn returns nil, otherwise it is rolled back.
+func atomically(
+	ctx context.Context,
+	ds persistence.DataStore,
+	fn func(tx persistence.Tx) error,
+) error {
+	tx, com, err := ds.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer com.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	return com.Commit()
+}
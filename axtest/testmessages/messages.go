@@ -80,3 +80,9 @@ func (*FailedSelfValidatingEvent) Validate() error {
 func (*FailedSelfValidatingEvent) MessageDescription() string {
 	return "test self-validating failing event"
 }
+
+// InstanceDescription returns a human-readable description of the saga
+// instance, allowing the message to be used as saga data in tests.
+func (*Message) InstanceDescription() string {
+	return "test saga instance"
+}
@@ -0,0 +1,15 @@
+package testmessages
+
+// IsEvent marks the message as an event.
+func (*PersonalDataEvent) IsEvent() {}
+
+// MessageDescription returns a human-readable description of the message.
+func (*PersonalDataEvent) MessageDescription() string { return "test personal data event" }
+
+// DataSubjectID returns the ID of the person that the message's personal data
+// is about.
+func (m *PersonalDataEvent) DataSubjectID() string { return m.SubjectId }
+
+// PersonalDataFields returns the names of the fields that contain personal
+// data.
+func (*PersonalDataEvent) PersonalDataFields() []string { return []string{"name", "data"} }
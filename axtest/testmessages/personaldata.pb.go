@@ -0,0 +1,178 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.33.0
+// 	protoc        v3.14.0
+// source: github.com/jmalloc/ax/axtest/testmessages/personaldata.proto
+
+package testmessages
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// PersonalDataEvent is a protocol buffers message that implements both
+// ax.Event and shredding.PersonalData.
+type PersonalDataEvent struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	SubjectId string `protobuf:"bytes,1,opt,name=subject_id,json=subjectId,proto3" json:"subject_id,omitempty"`
+	Name      string `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Data      []byte `protobuf:"bytes,3,opt,name=data,proto3" json:"data,omitempty"`
+	Value     string `protobuf:"bytes,4,opt,name=value,proto3" json:"value,omitempty"`
+}
+
+func (x *PersonalDataEvent) Reset() {
+	*x = PersonalDataEvent{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_github_com_jmalloc_ax_axtest_testmessages_personaldata_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *PersonalDataEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PersonalDataEvent) ProtoMessage() {}
+
+func (x *PersonalDataEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_jmalloc_ax_axtest_testmessages_personaldata_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PersonalDataEvent.ProtoReflect.Descriptor instead.
+func (*PersonalDataEvent) Descriptor() ([]byte, []int) {
+	return file_github_com_jmalloc_ax_axtest_testmessages_personaldata_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *PersonalDataEvent) GetSubjectId() string {
+	if x != nil {
+		return x.SubjectId
+	}
+	return ""
+}
+
+func (x *PersonalDataEvent) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *PersonalDataEvent) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *PersonalDataEvent) GetValue() string {
+	if x != nil {
+		return x.Value
+	}
+	return ""
+}
+
+var File_github_com_jmalloc_ax_axtest_testmessages_personaldata_proto protoreflect.FileDescriptor
+
+var file_github_com_jmalloc_ax_axtest_testmessages_personaldata_proto_rawDesc = []byte{
+	0x0a, 0x3c, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x6a, 0x6d, 0x61,
+	0x6c, 0x6c, 0x6f, 0x63, 0x2f, 0x61, 0x78, 0x2f, 0x61, 0x78, 0x74, 0x65, 0x73, 0x74, 0x2f, 0x74,
+	0x65, 0x73, 0x74, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x73, 0x2f, 0x70, 0x65, 0x72, 0x73,
+	0x6f, 0x6e, 0x61, 0x6c, 0x64, 0x61, 0x74, 0x61, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x13,
+	0x61, 0x78, 0x74, 0x65, 0x73, 0x74, 0x2e, 0x74, 0x65, 0x73, 0x74, 0x6d, 0x65, 0x73, 0x73, 0x61,
+	0x67, 0x65, 0x73, 0x22, 0x70, 0x0a, 0x11, 0x50, 0x65, 0x72, 0x73, 0x6f, 0x6e, 0x61, 0x6c, 0x44,
+	0x61, 0x74, 0x61, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x12, 0x1d, 0x0a, 0x0a, 0x73, 0x75, 0x62, 0x6a,
+	0x65, 0x63, 0x74, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x73, 0x75,
+	0x62, 0x6a, 0x65, 0x63, 0x74, 0x49, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x64,
+	0x61, 0x74, 0x61, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x64, 0x61, 0x74, 0x61, 0x12,
+	0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05,
+	0x76, 0x61, 0x6c, 0x75, 0x65, 0x42, 0x2b, 0x5a, 0x29, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e,
+	0x63, 0x6f, 0x6d, 0x2f, 0x6a, 0x6d, 0x61, 0x6c, 0x6c, 0x6f, 0x63, 0x2f, 0x61, 0x78, 0x2f, 0x61,
+	0x78, 0x74, 0x65, 0x73, 0x74, 0x2f, 0x74, 0x65, 0x73, 0x74, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67,
+	0x65, 0x73, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_github_com_jmalloc_ax_axtest_testmessages_personaldata_proto_rawDescOnce sync.Once
+	file_github_com_jmalloc_ax_axtest_testmessages_personaldata_proto_rawDescData = file_github_com_jmalloc_ax_axtest_testmessages_personaldata_proto_rawDesc
+)
+
+func file_github_com_jmalloc_ax_axtest_testmessages_personaldata_proto_rawDescGZIP() []byte {
+	file_github_com_jmalloc_ax_axtest_testmessages_personaldata_proto_rawDescOnce.Do(func() {
+		file_github_com_jmalloc_ax_axtest_testmessages_personaldata_proto_rawDescData = protoimpl.X.CompressGZIP(file_github_com_jmalloc_ax_axtest_testmessages_personaldata_proto_rawDescData)
+	})
+	return file_github_com_jmalloc_ax_axtest_testmessages_personaldata_proto_rawDescData
+}
+
+var file_github_com_jmalloc_ax_axtest_testmessages_personaldata_proto_msgTypes = make([]protoimpl.MessageInfo, 1)
+var file_github_com_jmalloc_ax_axtest_testmessages_personaldata_proto_goTypes = []interface{}{
+	(*PersonalDataEvent)(nil), // 0: axtest.testmessages.PersonalDataEvent
+}
+var file_github_com_jmalloc_ax_axtest_testmessages_personaldata_proto_depIdxs = []int32{
+	0, // [0:0] is the sub-list for method output_type
+	0, // [0:0] is the sub-list for method input_type
+	0, // [0:0] is the sub-list for extension type_name
+	0, // [0:0] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_github_com_jmalloc_ax_axtest_testmessages_personaldata_proto_init() }
+func file_github_com_jmalloc_ax_axtest_testmessages_personaldata_proto_init() {
+	if File_github_com_jmalloc_ax_axtest_testmessages_personaldata_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_github_com_jmalloc_ax_axtest_testmessages_personaldata_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*PersonalDataEvent); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_github_com_jmalloc_ax_axtest_testmessages_personaldata_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   1,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_github_com_jmalloc_ax_axtest_testmessages_personaldata_proto_goTypes,
+		DependencyIndexes: file_github_com_jmalloc_ax_axtest_testmessages_personaldata_proto_depIdxs,
+		MessageInfos:      file_github_com_jmalloc_ax_axtest_testmessages_personaldata_proto_msgTypes,
+	}.Build()
+	File_github_com_jmalloc_ax_axtest_testmessages_personaldata_proto = out.File
+	file_github_com_jmalloc_ax_axtest_testmessages_personaldata_proto_rawDesc = nil
+	file_github_com_jmalloc_ax_axtest_testmessages_personaldata_proto_goTypes = nil
+	file_github_com_jmalloc_ax_axtest_testmessages_personaldata_proto_depIdxs = nil
+}
@@ -31,24 +31,75 @@ func (i *Interceptor) Accept(ctx context.Context, env endpoint.OutboundEnvelope)
 		return i.Next.Accept(ctx, env)
 	}
 
-	tracing.LogEvent(
-		ctx,
-		"delay",
-		"intercepting the message to be sent after a delay",
-		tracing.Duration("delay_for", env.Delay()),
-		tracing.Time("delay_until", env.SendAt),
-		tracing.TypeName("pipeline_stage", i),
-	)
+	return i.save(ctx, []endpoint.OutboundEnvelope{env})
+}
+
+// AcceptBatch passes the messages in envs that are ready to send now to the
+// next pipeline stage as a single batch, and stores the remaining messages to
+// be sent in the future.
+func (i *Interceptor) AcceptBatch(ctx context.Context, envs []endpoint.OutboundEnvelope) error {
+	var ready, delayed []endpoint.OutboundEnvelope
+	now := time.Now()
+
+	for _, env := range envs {
+		if env.SendAt.After(now) {
+			delayed = append(delayed, env)
+		} else {
+			ready = append(ready, env)
+		}
+	}
+
+	if len(delayed) != 0 {
+		if err := i.save(ctx, delayed); err != nil {
+			return err
+		}
+	}
+
+	if len(ready) != 0 {
+		return endpoint.AcceptBatch(ctx, i.Next, ready)
+	}
+
+	return nil
+}
+
+// save stores envs to be sent in the future.
+func (i *Interceptor) save(ctx context.Context, envs []endpoint.OutboundEnvelope) error {
+	for _, env := range envs {
+		tracing.LogEvent(
+			ctx,
+			"delay",
+			"intercepting the message to be sent after a delay",
+			tracing.Duration("delay_for", env.Delay()),
+			tracing.Time("delay_until", env.SendAt),
+			tracing.TypeName("pipeline_stage", i),
+		)
+	}
 
-	tx, com, err := persistence.GetOrBeginTx(ctx)
-	if err != nil {
-		return err
+	// participate in the existing transaction, if any, so that the messages are
+	// saved atomically with the caller's changes.
+	if tx, ok := persistence.GetTx(ctx); ok {
+		return i.saveMessages(ctx, tx, envs)
 	}
-	defer com.Rollback()
 
-	if err := i.Repository.SaveMessage(ctx, tx, env); err != nil {
-		return err
+	return persistence.Atomically(
+		ctx,
+		func(ctx context.Context, tx persistence.Tx) error {
+			return i.saveMessages(ctx, tx, envs)
+		},
+	)
+}
+
+// saveMessages saves envs to the repository within tx.
+func (i *Interceptor) saveMessages(
+	ctx context.Context,
+	tx persistence.Tx,
+	envs []endpoint.OutboundEnvelope,
+) error {
+	for _, env := range envs {
+		if err := i.Repository.SaveMessage(ctx, tx, env); err != nil {
+			return err
+		}
 	}
 
-	return com.Commit()
+	return nil
 }
@@ -67,17 +67,12 @@ func (s *Sender) send(ctx context.Context, env endpoint.OutboundEnvelope) error
 		return err
 	}
 
-	tx, com, err := s.DataStore.BeginTx(ctx)
-	if err != nil {
-		return err
-	}
-	defer com.Rollback()
-
-	if err := s.Repository.MarkAsSent(ctx, tx, env); err != nil {
-		return err
-	}
-
-	return com.Commit()
+	return persistence.Atomically(
+		persistence.WithDataStore(ctx, s.DataStore),
+		func(ctx context.Context, tx persistence.Tx) error {
+			return s.Repository.MarkAsSent(ctx, tx, env)
+		},
+	)
 }
 
 // sleep blocks until ctx is canceled or the given duration elapses.
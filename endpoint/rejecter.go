@@ -68,6 +68,35 @@ func (o *OutboundRejecter) Initialize(
 func (o *OutboundRejecter) Accept(
 	ctx context.Context,
 	env OutboundEnvelope,
+) error {
+	if err := o.validate(ctx, env); err != nil {
+		return err
+	}
+
+	return o.Next.Accept(ctx, env)
+}
+
+// AcceptBatch forwards outbound messages to the next pipeline stage as a
+// single batch only if all of them are successfully validated.
+//
+// If any message fails validation, none of the messages are forwarded.
+func (o *OutboundRejecter) AcceptBatch(
+	ctx context.Context,
+	envs []OutboundEnvelope,
+) error {
+	for _, env := range envs {
+		if err := o.validate(ctx, env); err != nil {
+			return err
+		}
+	}
+
+	return AcceptBatch(ctx, o.Next, envs)
+}
+
+// validate validates an outbound message using each of the validators.
+func (o *OutboundRejecter) validate(
+	ctx context.Context,
+	env OutboundEnvelope,
 ) error {
 	for _, v := range o.Validators {
 		traceValidate(ctx, v)
@@ -79,7 +108,7 @@ func (o *OutboundRejecter) Accept(
 
 	traceValidated(ctx, o.Validators)
 
-	return o.Next.Accept(ctx, env)
+	return nil
 }
 
 func traceValidate(ctx context.Context, v Validator) {
@@ -242,4 +242,57 @@ var _ = Describe("OutboundRejecter", func() {
 			Expect(len(next.AcceptCalls())).Should(BeNumerically("==", 0))
 		})
 	})
+
+	Describe("AcceptBatch", func() {
+		envs := []OutboundEnvelope{
+			{
+				Envelope: ax.NewEnvelope(
+					&testmessages.MessageA{},
+				),
+			},
+			{
+				Envelope: ax.NewEnvelope(
+					&testmessages.MessageB{},
+				),
+			},
+		}
+
+		It("validates each message before forwarding them to the next stage", func() {
+			os := &OutboundRejecter{
+				Next: next,
+				Validators: []Validator{
+					validator1, validator2, validator3,
+				},
+			}
+
+			err := os.AcceptBatch(context.Background(), envs)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(validator1.ValidateCalls()).To(HaveLen(2))
+			Expect(validator2.ValidateCalls()).To(HaveLen(2))
+			Expect(validator3.ValidateCalls()).To(HaveLen(2))
+			Expect(next.AcceptCalls()).To(HaveLen(2))
+		})
+
+		It("does not forward any messages if one of them fails validation", func() {
+			expected := errors.New("<error>")
+
+			os := &OutboundRejecter{
+				Next: next,
+				Validators: []Validator{
+					validator1, validator2, validator3,
+				},
+			}
+
+			validator2.ValidateFunc = func(ctx context.Context, m ax.Message) error {
+				if _, ok := m.(*testmessages.MessageB); ok {
+					return expected
+				}
+				return nil
+			}
+
+			err := os.AcceptBatch(context.Background(), envs)
+			Expect(err).To(Equal(expected))
+			Expect(next.AcceptCalls()).To(BeEmpty())
+		})
+	})
 })
@@ -11,6 +11,37 @@ type MessageSink interface {
 	Accept(ctx context.Context, env OutboundEnvelope) error
 }
 
+// BatchMessageSink is a MessageSink that can accept several outbound message
+// envelopes in a single operation.
+type BatchMessageSink interface {
+	MessageSink
+
+	// AcceptBatch processes the messages encapsulated in envs.
+	//
+	// If an error is returned, some, all or none of the messages may have been
+	// processed.
+	AcceptBatch(ctx context.Context, envs []OutboundEnvelope) error
+}
+
+// AcceptBatch passes envs to s.
+//
+// If s implements BatchMessageSink the messages are passed in a single
+// operation, otherwise they are passed to s.Accept() one at a time. It is used
+// by outbound pipeline stages to forward batches to the next stage.
+func AcceptBatch(ctx context.Context, s MessageSink, envs []OutboundEnvelope) error {
+	if bs, ok := s.(BatchMessageSink); ok {
+		return bs.AcceptBatch(ctx, envs)
+	}
+
+	for _, env := range envs {
+		if err := s.Accept(ctx, env); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // BufferedSink is a MessageSink that buffers message envelopes in memory.
 type BufferedSink struct {
 	m   sync.RWMutex
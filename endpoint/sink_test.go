@@ -2,9 +2,11 @@ package endpoint_test
 
 import (
 	"context"
+	"errors"
 
 	"github.com/jmalloc/ax"
 
+	"github.com/jmalloc/ax/axtest/mocks"
 	. "github.com/jmalloc/ax/endpoint"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -50,3 +52,58 @@ var _ = Describe("BufferedSink", func() {
 		})
 	})
 })
+
+var _ = Describe("AcceptBatch", func() {
+	var env1, env2 OutboundEnvelope
+
+	BeforeEach(func() {
+		env1 = OutboundEnvelope{
+			Envelope: ax.Envelope{
+				MessageID: ax.GenerateMessageID(),
+			},
+		}
+
+		env2 = OutboundEnvelope{
+			Envelope: ax.Envelope{
+				MessageID: ax.GenerateMessageID(),
+			},
+		}
+	})
+
+	It("passes the messages to AcceptBatch() if the sink accepts batches", func() {
+		stage := &TransportStage{}
+		tr := &batchTransport{}
+		stage.Initialize(context.Background(), &Endpoint{OutboundTransport: tr})
+
+		err := AcceptBatch(context.Background(), stage, []OutboundEnvelope{env1, env2})
+		Expect(err).ShouldNot(HaveOccurred())
+
+		Expect(tr.batches).To(Equal(
+			[][]OutboundEnvelope{
+				{env1, env2},
+			},
+		))
+	})
+
+	It("passes each message to Accept() if the sink does not accept batches", func() {
+		sink := &BufferedSink{}
+
+		err := AcceptBatch(context.Background(), sink, []OutboundEnvelope{env1, env2})
+		Expect(err).ShouldNot(HaveOccurred())
+
+		Expect(sink.Envelopes()).To(Equal([]OutboundEnvelope{env1, env2}))
+	})
+
+	It("stops at the first error", func() {
+		sink := &mocks.MessageSinkMock{
+			AcceptFunc: func(context.Context, OutboundEnvelope) error {
+				return errors.New("<error>")
+			},
+		}
+
+		err := AcceptBatch(context.Background(), sink, []OutboundEnvelope{env1, env2})
+		Expect(err).To(MatchError("<error>"))
+
+		Expect(sink.AcceptCalls()).To(HaveLen(1))
+	})
+})
@@ -51,6 +51,31 @@ func (s OutboundTracer) Accept(ctx context.Context, env OutboundEnvelope) error
 	return nil
 }
 
+// AcceptBatch processes the messages encapsulated in envs.
+//
+// A single span is started for the entire batch.
+func (s OutboundTracer) AcceptBatch(ctx context.Context, envs []OutboundEnvelope) error {
+	span := tracing.StartChildOf(
+		ctx,
+		s.Tracer,
+		"outbound-batch",
+		ext.SpanKindProducer,
+		opentracing.Tags{
+			"batch_size": len(envs),
+		},
+	)
+	defer span.Finish()
+
+	ctx = opentracing.ContextWithSpan(ctx, span)
+
+	if err := AcceptBatch(ctx, s.Next, envs); err != nil {
+		tracing.LogErrorS(span, err)
+		return err
+	}
+
+	return nil
+}
+
 // spanTagsForEnvelope returns the OpenTracing span tags describing a message
 // envelope. It is used for both inbound and outbound traces.
 func spanTagsForEnvelope(env ax.Envelope) opentracing.Tags {
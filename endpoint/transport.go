@@ -7,6 +7,7 @@ import (
 	"github.com/jmalloc/ax"
 	"github.com/jmalloc/ax/internal/tracing"
 	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/log"
 )
 
 // InboundTransport is an interface for receiving messages from endpoints.
@@ -32,6 +33,18 @@ type OutboundTransport interface {
 	Send(ctx context.Context, env OutboundEnvelope) error
 }
 
+// BatchOutboundTransport is an OutboundTransport that can send several
+// messages in a single operation.
+type BatchOutboundTransport interface {
+	OutboundTransport
+
+	// SendBatch sends envs via the transport.
+	//
+	// If an error is returned, some, all or none of the messages may have been
+	// sent.
+	SendBatch(ctx context.Context, envs []OutboundEnvelope) error
+}
+
 // TransportStage is an outbound pipeline stage that forwards messages to a
 // transport. It is typically used as the last stage in an outbound pipeline.
 type TransportStage struct {
@@ -63,6 +76,41 @@ func (s *TransportStage) Accept(ctx context.Context, env OutboundEnvelope) error
 	return s.transport.Send(ctx, env)
 }
 
+// AcceptBatch sends envs via the transport.
+//
+// If the transport implements BatchOutboundTransport the messages are sent in
+// a single operation, otherwise they are sent one at a time.
+func (s *TransportStage) AcceptBatch(ctx context.Context, envs []OutboundEnvelope) error {
+	if span := opentracing.SpanFromContext(ctx); span != nil {
+		tracing.LogEventS(
+			span,
+			"send",
+			"sending a batch of messages via the transport",
+			log.Int("batch_size", len(envs)),
+			tracing.TypeName("pipeline_stage", s),
+		)
+
+		// if there is a span in the context, propagate it via the transport,
+		// without modifying the caller's envelopes
+		envs = append([]OutboundEnvelope(nil), envs...)
+		for i := range envs {
+			envs[i].SpanContext = span.Context()
+		}
+	}
+
+	if t, ok := s.transport.(BatchOutboundTransport); ok {
+		return t.SendBatch(ctx, envs)
+	}
+
+	for _, env := range envs {
+		if err := s.transport.Send(ctx, env); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // Acknowledger is an interface for acknowledging a specific inbound message.
 type Acknowledger interface {
 	// Ack acknowledges the message, indicating that is was handled successfully
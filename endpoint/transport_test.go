@@ -36,4 +36,76 @@ var _ = Describe("TransportStage", func() {
 			Expect(tr.SendCalls()[0].Env).To(Equal(env))
 		})
 	})
+
+	Describe("AcceptBatch", func() {
+		var (
+			ctx        context.Context
+			env1, env2 OutboundEnvelope
+		)
+
+		BeforeEach(func() {
+			ctx = context.Background()
+
+			env1 = OutboundEnvelope{
+				Envelope: ax.Envelope{
+					MessageID: ax.GenerateMessageID(),
+				},
+			}
+
+			env2 = OutboundEnvelope{
+				Envelope: ax.Envelope{
+					MessageID: ax.GenerateMessageID(),
+				},
+			}
+		})
+
+		It("sends each message via the transport if it does not support batches", func() {
+			stage := &TransportStage{}
+
+			tr := &mocks.OutboundTransportMock{
+				SendFunc: func(context.Context, OutboundEnvelope) error {
+					return nil
+				},
+			}
+
+			stage.Initialize(ctx, &Endpoint{OutboundTransport: tr})
+
+			err := stage.AcceptBatch(ctx, []OutboundEnvelope{env1, env2})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(tr.SendCalls()).To(HaveLen(2))
+			Expect(tr.SendCalls()[0].Env).To(Equal(env1))
+			Expect(tr.SendCalls()[1].Env).To(Equal(env2))
+		})
+
+		It("sends the messages in a single batch if the transport supports batches", func() {
+			stage := &TransportStage{}
+
+			tr := &batchTransport{}
+
+			stage.Initialize(ctx, &Endpoint{OutboundTransport: tr})
+
+			err := stage.AcceptBatch(ctx, []OutboundEnvelope{env1, env2})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(tr.batches).To(Equal(
+				[][]OutboundEnvelope{
+					{env1, env2},
+				},
+			))
+		})
+	})
 })
+
+// batchTransport is a BatchOutboundTransport that records the batches passed to
+// SendBatch().
+type batchTransport struct {
+	mocks.OutboundTransportMock
+
+	batches [][]OutboundEnvelope
+}
+
+func (t *batchTransport) SendBatch(_ context.Context, envs []OutboundEnvelope) error {
+	t.batches = append(t.batches, envs)
+	return nil
+}
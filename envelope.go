@@ -184,7 +184,21 @@ func (e Envelope) Equal(env Envelope) bool {
 }
 
 // AsProto returns a Protocol Buffers representation of the envelope.
+//
+// It returns an error if any of the envelope's message IDs are invalid.
 func (e Envelope) AsProto() (*EnvelopeProto, error) {
+	if err := e.MessageID.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid message ID: %w", err)
+	}
+
+	if err := e.CausationID.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid causation ID: %w", err)
+	}
+
+	if err := e.CorrelationID.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid correlation ID: %w", err)
+	}
+
 	createdAt, err := ptypes.TimestampProto(e.CreatedAt)
 	if err != nil {
 		return nil, err
@@ -201,9 +215,9 @@ func (e Envelope) AsProto() (*EnvelopeProto, error) {
 	}
 
 	return &EnvelopeProto{
-		MessageId:     e.MessageID.String(),
-		CausationId:   e.CausationID.String(),
-		CorrelationId: e.CorrelationID.String(),
+		MessageId:     e.MessageID.Get(),
+		CausationId:   e.CausationID.Get(),
+		CorrelationId: e.CorrelationID.Get(),
 		CreatedAt:     createdAt,
 		SendAt:        sendAt,
 		Message:       message,
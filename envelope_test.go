@@ -7,6 +7,7 @@ import (
 	"github.com/golang/protobuf/ptypes"
 	. "github.com/jmalloc/ax"
 	"github.com/jmalloc/ax/axtest/testmessages"
+	"github.com/jmalloc/ax/ident"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	uuid "github.com/satori/go.uuid"
@@ -225,6 +226,41 @@ var _ = Describe("Envelope", func() {
 			Expect(err).ShouldNot(HaveOccurred())
 			Expect(sendAt).To(BeTemporally("==", env.SendAt))
 		})
+
+		It("uses the full representation of UUID message IDs", func() {
+			env := NewEnvelope(&testmessages.Message{})
+
+			pb, err := env.AsProto()
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(pb.MessageId).To(Equal(env.MessageID.Get()))
+			Expect(pb.CorrelationId).To(Equal(env.CorrelationID.Get()))
+			Expect(pb.CausationId).To(Equal(env.CausationID.Get()))
+		})
+
+		It("returns an error if the message ID is empty", func() {
+			env := NewEnvelope(&testmessages.Message{})
+			env.MessageID = MessageID{}
+
+			_, err := env.AsProto()
+			Expect(err).To(MatchError("invalid message ID: " + ident.ErrEmptyID.Error()))
+		})
+
+		It("returns an error if the causation ID is empty", func() {
+			env := NewEnvelope(&testmessages.Message{})
+			env.CausationID = MessageID{}
+
+			_, err := env.AsProto()
+			Expect(err).To(MatchError("invalid causation ID: " + ident.ErrEmptyID.Error()))
+		})
+
+		It("returns an error if the correlation ID is empty", func() {
+			env := NewEnvelope(&testmessages.Message{})
+			env.CorrelationID = MessageID{}
+
+			_, err := env.AsProto()
+			Expect(err).To(MatchError("invalid correlation ID: " + ident.ErrEmptyID.Error()))
+		})
 	})
 })
 
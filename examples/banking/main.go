@@ -19,6 +19,7 @@ import (
 	"github.com/jmalloc/ax/examples/banking/messages"
 	"github.com/jmalloc/ax/examples/banking/projections"
 	"github.com/jmalloc/ax/examples/banking/workflows"
+	"github.com/jmalloc/ax/messagestore/shredding"
 	"github.com/jmalloc/ax/observability"
 	"github.com/jmalloc/ax/outbox"
 	"github.com/jmalloc/ax/persistence"
@@ -67,8 +68,15 @@ func run() int {
 		Repository: axmysql.SagaCRUDRepository,
 	}
 
+	// personal data in events is encrypted with a key for each account holder,
+	// so that it can be forgotten by deleting the key.
+	messageStore := shredding.Store{
+		MessageStore: axmysql.MessageStore,
+		KeyStore:     axmysql.ShreddingKeyStore,
+	}
+
 	esPersister := &eventsourcing.Persister{
-		MessageStore:      axmysql.MessageStore,
+		MessageStore:      messageStore,
 		Snapshots:         axmysql.SagaSnapshotRepository,
 		SnapshotFrequency: 3,
 	}
@@ -164,11 +172,26 @@ func run() int {
 		Tracer: tracer,
 	}
 
+	relay := &outbox.Relay{
+		DataStore:        ds,
+		Repository:       axmysql.OutboxRepository,
+		OutboundPipeline: ep.OutboundPipeline,
+	}
+
+	purger := &outbox.Purger{
+		DataStore:  ds,
+		Repository: axmysql.OutboxRepository,
+	}
+
+	// messages that can not be applied to the projection are retried a few
+	// times, then parked so that the consumer does not stop the endpoint.
 	con := &projection.GlobalStoreConsumer{
-		Projector:    projections.AccountProjector,
-		DataStore:    ds,
-		MessageStore: axmysql.MessageStore,
-		Offsets:      axmysql.ProjectionOffsetStore,
+		Projector:      projections.AccountProjector,
+		DataStore:      ds,
+		MessageStore:   messageStore,
+		Offsets:        axmysql.ProjectionOffsetStore,
+		ErrorPolicy:    projection.NewRetryPolicy(5, 1*time.Second, 1*time.Minute, projection.Park),
+		ParkedMessages: axmysql.ProjectionParkedMessageStore,
 	}
 
 	// -------------------------------------------------------
@@ -200,10 +223,19 @@ func run() int {
 	}
 
 	cli.AddCommand(commands...)
+	cli.AddCommand(axmysql.NewMigrateCommand(db, ""))
+	cli.AddCommand(axcli.NewExportCommand(ds, axmysql.MessageStore))
+	cli.AddCommand(axcli.NewImportCommand(ds, axmysql.MessageStore))
+	cli.AddCommand(axcli.NewRebuildCommand(con))
+	cli.AddCommand(axcli.NewParkedCommand(con))
 	cli.AddCommand(&cobra.Command{
 		Use:   "serve",
 		Short: fmt.Sprintf("Run the '%s' endpoint", ep.Name),
 		RunE: func(*cobra.Command, []string) error {
+			if _, err := axmysql.Migrate(ctx, db, ""); err != nil {
+				return err
+			}
+
 			g, ctx := errgroup.WithContext(ctx)
 
 			g.Go(func() error {
@@ -214,6 +246,14 @@ func run() int {
 				return dms.Run(ctx)
 			})
 
+			g.Go(func() error {
+				return relay.Run(ctx)
+			})
+
+			g.Go(func() error {
+				return purger.Run(ctx)
+			})
+
 			g.Go(func() error {
 				return con.Consume(ctx)
 			})
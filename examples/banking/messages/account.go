@@ -31,6 +31,18 @@ func (m *AccountOpened) MessageDescription() string {
 	)
 }
 
+// DataSubjectID returns the ID of the account holder that the message's
+// personal data is about.
+func (m *AccountOpened) DataSubjectID() string {
+	return m.AccountId
+}
+
+// PersonalDataFields returns the names of the fields that contain personal
+// data.
+func (*AccountOpened) PersonalDataFields() []string {
+	return []string{"name"}
+}
+
 // IsCommand marks the message as a command.
 func (*CreditAccount) IsCommand() {}
 
@@ -56,6 +56,11 @@ func (account) WhenAccountCredited(ctx context.Context, tx *sql.Tx, ev *messages
 	return nil
 }
 
+func (account) ResetReadModel(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `DELETE FROM account`)
+	return err
+}
+
 func insertAccount(
 	ctx context.Context,
 	tx *sql.Tx,
@@ -0,0 +1,37 @@
+package messagestore
+
+import (
+	"context"
+	"strings"
+)
+
+// CategorySeparator is the character that separates a stream's category from
+// the remainder of its name.
+const CategorySeparator = ":"
+
+// CategoryOf returns the category of the stream with the given name.
+//
+// The category is the portion of the name before the last CategorySeparator,
+// for example, the category of "account:123" is "account", and the category of
+// "saga:banking.transfer:456" is "saga:banking.transfer". A stream whose name
+// does not contain the separator is in the empty category.
+func CategoryOf(stream string) string {
+	if i := strings.LastIndex(stream, CategorySeparator); i != -1 {
+		return stream[:i]
+	}
+
+	return ""
+}
+
+// StreamCategory returns the category of the messages that are appended to the
+// stream with the given name within ctx.
+//
+// It returns the category in ctx, as per WithCategory(), if present. Otherwise,
+// it returns CategoryOf(stream).
+func StreamCategory(ctx context.Context, stream string) string {
+	if c, ok := GetCategory(ctx); ok {
+		return c
+	}
+
+	return CategoryOf(stream)
+}
@@ -0,0 +1,34 @@
+package messagestore_test
+
+import (
+	"context"
+
+	. "github.com/jmalloc/ax/messagestore"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CategoryOf", func() {
+	DescribeTable(
+		"returns the portion of the stream name before the last separator",
+		func(stream, expected string) {
+			Expect(CategoryOf(stream)).To(Equal(expected))
+		},
+		Entry("single separator", "account:123", "account"),
+		Entry("multiple separators", "saga:banking.transfer:456", "saga:banking.transfer"),
+		Entry("no separator", "account", ""),
+		Entry("leading separator", ":123", ""),
+	)
+})
+
+var _ = Describe("StreamCategory", func() {
+	It("returns the category in the context", func() {
+		ctx := WithCategory(context.Background(), "saga:banking.transfer")
+		Expect(StreamCategory(ctx, "saga:456")).To(Equal("saga:banking.transfer"))
+	})
+
+	It("returns the category of the stream name if the context does not contain a category", func() {
+		Expect(StreamCategory(context.Background(), "account:123")).To(Equal("account"))
+	})
+})
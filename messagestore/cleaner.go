@@ -0,0 +1,79 @@
+package messagestore
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmalloc/ax/persistence"
+)
+
+// DefaultCleanBatchSize is the default maximum number of messages that the
+// cleaner removes within a single transaction.
+const DefaultCleanBatchSize = 1000
+
+// DefaultCleanPollInterval is the duration to wait before checking for messages
+// to remove when the previous check found fewer than a full batch.
+var DefaultCleanPollInterval = 1 * time.Minute
+
+// Cleaner is a service that removes messages that are no longer retained
+// according to the metadata of their streams, such as the messages in deleted
+// or truncated streams.
+//
+// It is safe to run multiple cleaners against the same store concurrently.
+type Cleaner struct {
+	DataStore    persistence.DataStore
+	MessageStore RetentionStore
+	BatchSize    int
+	PollInterval time.Duration
+}
+
+// Run removes expired messages until ctx is canceled or an error occurs.
+func (c *Cleaner) Run(ctx context.Context) error {
+	for {
+		if err := c.tick(ctx); err != nil {
+			return err
+		}
+	}
+}
+
+// tick removes a single batch of expired messages. If the batch is not full,
+// it waits for the poll interval before returning.
+func (c *Cleaner) tick(ctx context.Context) error {
+	n := c.BatchSize
+	if n == 0 {
+		n = DefaultCleanBatchSize
+	}
+
+	count, err := c.clean(ctx, n)
+	if err != nil {
+		return err
+	}
+
+	if count == n {
+		return nil
+	}
+
+	d := c.PollInterval
+	if d == 0 {
+		d = DefaultCleanPollInterval
+	}
+
+	return sleep(ctx, d)
+}
+
+// clean removes up to n expired messages. It returns the number of messages
+// removed.
+func (c *Cleaner) clean(ctx context.Context, n int) (int, error) {
+	var count int
+
+	err := persistence.Atomically(
+		persistence.WithDataStore(ctx, c.DataStore),
+		func(ctx context.Context, tx persistence.Tx) error {
+			var err error
+			count, err = c.MessageStore.RemoveExpiredMessages(ctx, tx, n)
+			return err
+		},
+	)
+
+	return count, err
+}
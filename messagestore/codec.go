@@ -0,0 +1,171 @@
+package messagestore
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/jmalloc/ax"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// The export format is a sequence of records, each of which is a
+// varint-encoded length followed by a Protocol Buffers message of that length.
+// The message is equivalent to:
+//
+//	message ExportRecord {
+//		string stream = 1;
+//		uint64 stream_offset = 2;
+//		ax.EnvelopeProto envelope = 3;
+//		string category = 4;
+//	}
+//
+// Unknown fields are ignored by the decoder, so that fields may be added to the
+// record in the future.
+const (
+	recordStreamField       protowire.Number = 1
+	recordStreamOffsetField protowire.Number = 2
+	recordEnvelopeField     protowire.Number = 3
+	recordCategoryField     protowire.Number = 4
+)
+
+// MaxRecordSize is the maximum size of a single record that is accepted by a
+// Decoder, in bytes.
+const MaxRecordSize = 64 * 1024 * 1024
+
+// Encoder writes stored messages to an output stream in the export format.
+type Encoder struct {
+	w   io.Writer
+	buf []byte
+}
+
+// NewEncoder returns a new encoder that writes to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// Encode writes the stream name, stream offset, category and envelope of m to
+// the output stream. The remaining fields of m are not exported.
+func (e *Encoder) Encode(m StoredMessage) error {
+	env, err := ax.MarshalEnvelope(m.Envelope)
+	if err != nil {
+		return err
+	}
+
+	var rec []byte
+	rec = protowire.AppendTag(rec, recordStreamField, protowire.BytesType)
+	rec = protowire.AppendString(rec, m.Stream)
+	rec = protowire.AppendTag(rec, recordStreamOffsetField, protowire.VarintType)
+	rec = protowire.AppendVarint(rec, m.StreamOffset)
+	rec = protowire.AppendTag(rec, recordEnvelopeField, protowire.BytesType)
+	rec = protowire.AppendBytes(rec, env)
+	rec = protowire.AppendTag(rec, recordCategoryField, protowire.BytesType)
+	rec = protowire.AppendString(rec, m.Category)
+
+	e.buf = protowire.AppendBytes(e.buf[:0], rec)
+	_, err = e.w.Write(e.buf)
+
+	return err
+}
+
+// Decoder reads stored messages in the export format from an input stream.
+type Decoder struct {
+	r *bufio.Reader
+}
+
+// NewDecoder returns a new decoder that reads from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{bufio.NewReader(r)}
+}
+
+// Decode reads the next message from the input stream and stores it in m.
+//
+// Only the stream name, stream offset, category and envelope of m are
+// populated. Records written before the category was exported have the
+// category of their stream's name, as per CategoryOf(). It returns io.EOF if
+// there are no more messages.
+func (d *Decoder) Decode(m *StoredMessage) error {
+	n, err := binary.ReadUvarint(d.r)
+	if err != nil {
+		return err
+	}
+
+	if n > MaxRecordSize {
+		return fmt.Errorf("export record size (%d bytes) exceeds the maximum of %d bytes", n, MaxRecordSize)
+	}
+
+	rec := make([]byte, n)
+	if _, err := io.ReadFull(d.r, rec); err != nil {
+		if err == io.EOF {
+			return io.ErrUnexpectedEOF
+		}
+		return err
+	}
+
+	return decodeRecord(rec, m)
+}
+
+// decodeRecord unmarshals the export record in rec into m.
+func decodeRecord(rec []byte, m *StoredMessage) error {
+	*m = StoredMessage{}
+	hasEnvelope := false
+	hasCategory := false
+
+	for len(rec) > 0 {
+		num, typ, n := protowire.ConsumeTag(rec)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		rec = rec[n:]
+
+		switch {
+		case num == recordStreamField && typ == protowire.BytesType:
+			var v string
+			v, n = protowire.ConsumeString(rec)
+			m.Stream = v
+
+		case num == recordStreamOffsetField && typ == protowire.VarintType:
+			var v uint64
+			v, n = protowire.ConsumeVarint(rec)
+			m.StreamOffset = v
+
+		case num == recordEnvelopeField && typ == protowire.BytesType:
+			var v []byte
+			v, n = protowire.ConsumeBytes(rec)
+			if n >= 0 {
+				env, err := ax.UnmarshalEnvelope(v)
+				if err != nil {
+					return err
+				}
+				m.Envelope = env
+				hasEnvelope = true
+			}
+
+		case num == recordCategoryField && typ == protowire.BytesType:
+			var v string
+			v, n = protowire.ConsumeString(rec)
+			m.Category = v
+			hasCategory = true
+
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, rec)
+		}
+
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		rec = rec[n:]
+	}
+
+	if m.Stream == "" || !hasEnvelope {
+		return errors.New("export record does not contain a stream name and envelope")
+	}
+
+	if !hasCategory {
+		m.Category = CategoryOf(m.Stream)
+	}
+
+	return nil
+}
@@ -0,0 +1,130 @@
+package messagestore_test
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/jmalloc/ax"
+	"github.com/jmalloc/ax/axtest"
+	"github.com/jmalloc/ax/axtest/testmessages"
+	. "github.com/jmalloc/ax/messagestore"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+var _ = Describe("Encoder and Decoder", func() {
+	var (
+		buf    *bytes.Buffer
+		m1, m2 StoredMessage
+		enc    *Encoder
+	)
+
+	BeforeEach(func() {
+		buf = &bytes.Buffer{}
+		enc = NewEncoder(buf)
+
+		m1 = StoredMessage{
+			Envelope:     ax.NewEnvelope(&testmessages.MessageA{Value: "<foo>"}),
+			Stream:       "<stream-1>",
+			StreamOffset: 0,
+			Category:     "<category>",
+		}
+
+		m2 = StoredMessage{
+			Envelope:        ax.NewEnvelope(&testmessages.MessageB{Value: "<bar>"}),
+			Stream:          "<stream-2>",
+			StreamOffset:    3,
+			GlobalOffset:    7,
+			HasGlobalOffset: true,
+		}
+	})
+
+	It("round-trips the stream name, stream offset, category and envelope of each message", func() {
+		Expect(enc.Encode(m1)).To(Succeed())
+		Expect(enc.Encode(m2)).To(Succeed())
+
+		dec := NewDecoder(buf)
+
+		var m StoredMessage
+		Expect(dec.Decode(&m)).To(Succeed())
+		Expect(m.Stream).To(Equal("<stream-1>"))
+		Expect(m.StreamOffset).To(BeNumerically("==", 0))
+		Expect(m.Category).To(Equal("<category>"))
+		Expect(axtest.EnvelopesEqual(m.Envelope, m1.Envelope)).To(BeTrue())
+
+		Expect(dec.Decode(&m)).To(Succeed())
+		Expect(m.Stream).To(Equal("<stream-2>"))
+		Expect(m.StreamOffset).To(BeNumerically("==", 3))
+		Expect(m.Category).To(Equal(""))
+		Expect(m.HasGlobalOffset).To(BeFalse())
+		Expect(axtest.EnvelopesEqual(m.Envelope, m2.Envelope)).To(BeTrue())
+
+		Expect(dec.Decode(&m)).To(Equal(io.EOF))
+	})
+
+	It("ignores unknown fields", func() {
+		env, err := ax.MarshalEnvelope(m1.Envelope)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		var rec []byte
+		rec = protowire.AppendTag(rec, 100, protowire.BytesType)
+		rec = protowire.AppendString(rec, "<unknown>")
+		rec = protowire.AppendTag(rec, 1, protowire.BytesType)
+		rec = protowire.AppendString(rec, "<stream-1>")
+		rec = protowire.AppendTag(rec, 3, protowire.BytesType)
+		rec = protowire.AppendBytes(rec, env)
+		buf.Write(protowire.AppendBytes(nil, rec))
+
+		var m StoredMessage
+		err = NewDecoder(buf).Decode(&m)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(m.Stream).To(Equal("<stream-1>"))
+		Expect(axtest.EnvelopesEqual(m.Envelope, m1.Envelope)).To(BeTrue())
+	})
+
+	It("uses the category of the stream name if the record does not contain a category", func() {
+		env, err := ax.MarshalEnvelope(m1.Envelope)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		var rec []byte
+		rec = protowire.AppendTag(rec, 1, protowire.BytesType)
+		rec = protowire.AppendString(rec, "<category>:<id>")
+		rec = protowire.AppendTag(rec, 3, protowire.BytesType)
+		rec = protowire.AppendBytes(rec, env)
+		buf.Write(protowire.AppendBytes(nil, rec))
+
+		var m StoredMessage
+		err = NewDecoder(buf).Decode(&m)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(m.Category).To(Equal("<category>"))
+	})
+
+	It("returns an error if the input ends part way through a record", func() {
+		Expect(enc.Encode(m1)).To(Succeed())
+		buf.Truncate(buf.Len() - 1)
+
+		var m StoredMessage
+		err := NewDecoder(buf).Decode(&m)
+		Expect(err).To(Equal(io.ErrUnexpectedEOF))
+	})
+
+	It("returns an error if a record exceeds the maximum size", func() {
+		buf.Write(protowire.AppendVarint(nil, MaxRecordSize+1))
+
+		var m StoredMessage
+		err := NewDecoder(buf).Decode(&m)
+		Expect(err).Should(HaveOccurred())
+	})
+
+	It("returns an error if a record does not contain an envelope", func() {
+		var rec []byte
+		rec = protowire.AppendTag(rec, 1, protowire.BytesType)
+		rec = protowire.AppendString(rec, "<stream-1>")
+		buf.Write(protowire.AppendBytes(nil, rec))
+
+		var m StoredMessage
+		err := NewDecoder(buf).Decode(&m)
+		Expect(err).Should(HaveOccurred())
+	})
+})
@@ -0,0 +1,64 @@
+package messagestore
+
+import (
+	"context"
+)
+
+// WithStoredMessage returns a new context derived from p that contains m.
+// The message can be retrieved from the context with GetStoredMessage().
+func WithStoredMessage(p context.Context, m StoredMessage) context.Context {
+	return context.WithValue(
+		p,
+		storedMessageKey,
+		m,
+	)
+}
+
+// GetStoredMessage returns the stored message contained in ctx.
+// If ctx does not contain a stored message then ok is false.
+//
+// Projection consumers make the stored message available to projectors via
+// the context, so that read models can record the position of the last
+// message applied, or the time at which it was persisted.
+func GetStoredMessage(ctx context.Context) (m StoredMessage, ok bool) {
+	v := ctx.Value(storedMessageKey)
+
+	if v != nil {
+		m, ok = v.(StoredMessage)
+	}
+
+	return
+}
+
+// WithCategory returns a new context derived from p that contains the category
+// c. Messages appended within the context are placed in category c, instead of
+// the category of their stream's name. The category can be retrieved from the
+// context with GetCategory().
+func WithCategory(p context.Context, c string) context.Context {
+	return context.WithValue(
+		p,
+		categoryKey,
+		c,
+	)
+}
+
+// GetCategory returns the category contained in ctx.
+// If ctx does not contain a category then ok is false.
+func GetCategory(ctx context.Context) (c string, ok bool) {
+	v := ctx.Value(categoryKey)
+
+	if v != nil {
+		c, ok = v.(string)
+	}
+
+	return
+}
+
+// contextKey is a type used for the keys of context values. A specific type is
+// used to prevent collisions with context keys from other packages.
+type contextKey string
+
+const (
+	storedMessageKey contextKey = "stored-message"
+	categoryKey      contextKey = "category"
+)
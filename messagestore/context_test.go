@@ -0,0 +1,50 @@
+package messagestore_test
+
+import (
+	"context"
+
+	"github.com/jmalloc/ax"
+	"github.com/jmalloc/ax/axtest/testmessages"
+	. "github.com/jmalloc/ax/messagestore"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("WithStoredMessage / GetStoredMessage", func() {
+	It("transports a stored message via the context", func() {
+		expected := StoredMessage{
+			Envelope:     ax.NewEnvelope(&testmessages.Message{}),
+			Stream:       "<stream>",
+			StreamOffset: 1,
+		}
+		ctx := WithStoredMessage(context.Background(), expected)
+
+		m, ok := GetStoredMessage(ctx)
+
+		Expect(ok).To(BeTrue())
+		Expect(m).To(Equal(expected))
+	})
+
+	It("returns false if the context does not contain a stored message", func() {
+		_, ok := GetStoredMessage(context.Background())
+
+		Expect(ok).To(BeFalse())
+	})
+})
+
+var _ = Describe("WithCategory / GetCategory", func() {
+	It("transports a category via the context", func() {
+		ctx := WithCategory(context.Background(), "<category>")
+
+		c, ok := GetCategory(ctx)
+
+		Expect(ok).To(BeTrue())
+		Expect(c).To(Equal("<category>"))
+	})
+
+	It("returns false if the context does not contain a category", func() {
+		_, ok := GetCategory(context.Background())
+
+		Expect(ok).To(BeFalse())
+	})
+})
@@ -0,0 +1,83 @@
+package messagestore
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jmalloc/ax"
+)
+
+// ConflictError is returned by Store.AppendMessages() when messages can not be
+// appended because the offset is not the next unused offset in the stream.
+//
+// This typically indicates that another process has appended to the stream
+// since it was read. It is not transient in itself, as retrying the append
+// fails again unless the caller re-reads the stream first. Callers that do so
+// within persistence.Atomically() may classify the conflict as transient.
+type ConflictError struct {
+	// Stream is the name of the stream that could not be appended to.
+	Stream string
+
+	// Offset is the offset at which the append was attempted.
+	Offset uint64
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf(
+		"can not append to stream %s, %d is not the next free offset",
+		e.Stream,
+		e.Offset,
+	)
+}
+
+// IsConflict returns true if err is, or wraps, a *ConflictError.
+func IsConflict(err error) bool {
+	var e *ConflictError
+	return errors.As(err, &e)
+}
+
+// DuplicateMessageError is returned by Store.AppendMessages() when a message
+// can not be appended because a message with the same ID is already stored at
+// some other position.
+type DuplicateMessageError struct {
+	// MessageID is the ID of the message that could not be appended.
+	MessageID ax.MessageID
+
+	// Stream and StreamOffset identify the position of the message that is
+	// already stored.
+	Stream       string
+	StreamOffset uint64
+}
+
+func (e *DuplicateMessageError) Error() string {
+	return fmt.Sprintf(
+		"can not append message %s, it is already stored at offset %d of stream %s",
+		e.MessageID.Get(),
+		e.StreamOffset,
+		e.Stream,
+	)
+}
+
+// IsDuplicateMessage returns true if err is, or wraps, a
+// *DuplicateMessageError.
+func IsDuplicateMessage(err error) bool {
+	var e *DuplicateMessageError
+	return errors.As(err, &e)
+}
+
+// DeletedStreamError is returned by Store.AppendMessages() when messages can
+// not be appended because the stream has been deleted.
+type DeletedStreamError struct {
+	// Stream is the name of the deleted stream.
+	Stream string
+}
+
+func (e *DeletedStreamError) Error() string {
+	return fmt.Sprintf("can not append to stream %s, it has been deleted", e.Stream)
+}
+
+// IsDeletedStream returns true if err is, or wraps, a *DeletedStreamError.
+func IsDeletedStream(err error) bool {
+	var e *DeletedStreamError
+	return errors.As(err, &e)
+}
@@ -0,0 +1,190 @@
+package messagestore
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/jmalloc/ax"
+	"github.com/jmalloc/ax/persistence"
+)
+
+// ImportBatchSize is the maximum number of messages that Import() appends to
+// the store within a single transaction.
+const ImportBatchSize = 100
+
+// Export writes the messages in ms to w in the format produced by Encoder.
+//
+// If streams is empty, every message in the store is exported in global order.
+// Otherwise, only the messages in the given streams are exported, one stream
+// after the other. It returns an error if any of the streams do not exist.
+//
+// The export is not a consistent snapshot of the store. Messages are read in
+// batches until the end of each stream is reached, so messages that are
+// committed while the export is in progress may or may not be exported. It
+// returns the number of messages exported.
+func Export(
+	ctx context.Context,
+	w io.Writer,
+	ds persistence.DataStore,
+	ms GloballyOrderedStore,
+	streams []string,
+) (uint64, error) {
+	enc := NewEncoder(w)
+
+	if len(streams) == 0 {
+		s, err := ms.OpenGlobal(ctx, ds, 0, ax.MessageTypeSet{})
+		if err != nil {
+			return 0, err
+		}
+		defer s.Close()
+
+		return exportStream(ctx, enc, s)
+	}
+
+	var count uint64
+
+	for _, stream := range streams {
+		s, ok, err := ms.OpenStream(ctx, ds, stream, 0)
+		if err != nil {
+			return count, err
+		}
+
+		if !ok {
+			return count, fmt.Errorf("can not export stream '%s', it does not exist", stream)
+		}
+
+		n, err := exportStream(ctx, enc, s)
+		count += n
+		s.Close()
+
+		if err != nil {
+			return count, err
+		}
+	}
+
+	return count, nil
+}
+
+// exportStream encodes each of the remaining messages in s.
+func exportStream(ctx context.Context, enc *Encoder, s Stream) (uint64, error) {
+	var count uint64
+
+	for {
+		ok, err := s.TryNext(ctx)
+		if !ok || err != nil {
+			return count, err
+		}
+
+		m, err := s.GetStored(ctx)
+		if err != nil {
+			return count, err
+		}
+
+		if err := enc.Encode(m); err != nil {
+			return count, err
+		}
+
+		count++
+	}
+}
+
+// Import appends the messages read from r to ms. r must contain messages in
+// the format produced by Encoder, such as the output of Export().
+//
+// Each message is appended to the same stream, at the same stream offset and in
+// the same category that it was exported from, and retains its message ID. It returns an error
+// if any of the offsets are already in use by other messages. Messages that
+// have already been imported are skipped, so an import that fails part way
+// through may be repeated.
+//
+// Streams that were truncated before they were exported can only be imported
+// into a store that already contains the messages before the first exported
+// offset, as the truncated messages are not present in the export. Otherwise,
+// it returns an error that wraps the *ConflictError returned by the store.
+//
+// Consecutive messages in the same stream and category are appended in batches of up to
+// ImportBatchSize messages, each within its own transaction. If an error
+// occurs, the batches appended before the error remain in the store. It
+// returns the number of messages imported.
+func Import(
+	ctx context.Context,
+	r io.Reader,
+	ds persistence.DataStore,
+	ms Store,
+) (uint64, error) {
+	dec := NewDecoder(r)
+	ctx = persistence.WithDataStore(ctx, ds)
+
+	var (
+		count    uint64
+		stream   string
+		category string
+		offset   uint64
+		envs     []ax.Envelope
+
+		// first is the first exported offset of each stream that has been
+		// read from r.
+		first = map[string]uint64{}
+	)
+
+	flush := func() error {
+		if len(envs) == 0 {
+			return nil
+		}
+
+		err := persistence.Atomically(
+			WithCategory(ctx, category),
+			func(ctx context.Context, tx persistence.Tx) error {
+				return ms.AppendMessages(ctx, tx, stream, offset, envs)
+			},
+		)
+
+		if IsConflict(err) && offset != 0 && first[stream] == offset {
+			return fmt.Errorf(
+				"can not import stream '%s', the messages before offset %d were not exported, for example because the stream was truncated: %w",
+				stream,
+				offset,
+				err,
+			)
+		} else if err != nil {
+			return err
+		}
+
+		count += uint64(len(envs))
+		envs = envs[:0]
+
+		return nil
+	}
+
+	for {
+		var m StoredMessage
+		err := dec.Decode(&m)
+
+		if err == io.EOF {
+			return count, flush()
+		} else if err != nil {
+			return count, err
+		}
+
+		contiguous := m.Stream == stream &&
+			m.Category == category &&
+			m.StreamOffset == offset+uint64(len(envs))
+
+		if !contiguous || len(envs) == ImportBatchSize {
+			if err := flush(); err != nil {
+				return count, err
+			}
+
+			stream = m.Stream
+			category = m.Category
+			offset = m.StreamOffset
+
+			if _, ok := first[stream]; !ok {
+				first[stream] = offset
+			}
+		}
+
+		envs = append(envs, m.Envelope)
+	}
+}
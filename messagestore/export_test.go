@@ -0,0 +1,238 @@
+package messagestore_test
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"github.com/jmalloc/ax"
+	"github.com/jmalloc/ax/axmemory"
+	"github.com/jmalloc/ax/axtest"
+	"github.com/jmalloc/ax/axtest/testmessages"
+	. "github.com/jmalloc/ax/messagestore"
+	"github.com/jmalloc/ax/persistence"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Export and Import", func() {
+	var (
+		ctx              context.Context
+		cancel           func()
+		src, dst         *axmemory.DataStore
+		buf              *bytes.Buffer
+		env1, env2, env3 ax.Envelope
+	)
+
+	appendMessages := func(
+		ds persistence.DataStore,
+		stream string,
+		offset uint64,
+		envs ...ax.Envelope,
+	) {
+		tx, com, err := ds.BeginTx(ctx)
+		Expect(err).ShouldNot(HaveOccurred())
+		defer com.Rollback()
+
+		err = axmemory.MessageStore.AppendMessages(ctx, tx, stream, offset, envs)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		err = com.Commit()
+		Expect(err).ShouldNot(HaveOccurred())
+	}
+
+	readStream := func(ds persistence.DataStore, stream string) []StoredMessage {
+		s, ok, err := axmemory.MessageStore.OpenStream(ctx, ds, stream, 0)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(ok).To(BeTrue())
+		defer s.Close()
+
+		var messages []StoredMessage
+
+		for {
+			ok, err := s.TryNext(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			if !ok {
+				return messages
+			}
+
+			m, err := s.GetStored(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			messages = append(messages, m)
+		}
+	}
+
+	BeforeEach(func() {
+		var fn func()
+		ctx, fn = context.WithTimeout(context.Background(), 5*time.Second)
+		cancel = fn // defeat go vet warning about unused cancel func
+
+		src = axmemory.NewDataStore()
+		dst = axmemory.NewDataStore()
+		buf = &bytes.Buffer{}
+
+		env1 = ax.NewEnvelope(&testmessages.MessageA{Value: "<foo>"})
+		env2 = ax.NewEnvelope(&testmessages.MessageB{Value: "<bar>"})
+		env3 = ax.NewEnvelope(&testmessages.MessageC{Value: "<baz>"})
+
+		appendMessages(src, "<stream-1>", 0, env1)
+		appendMessages(src, "<stream-2>", 0, env2)
+		appendMessages(src, "<stream-1>", 1, env3)
+	})
+
+	AfterEach(func() {
+		cancel()
+	})
+
+	Describe("Export", func() {
+		It("exports every message in the store when no streams are given", func() {
+			n, err := Export(ctx, buf, src, axmemory.MessageStore, nil)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(n).To(BeNumerically("==", 3))
+		})
+
+		It("exports only the messages in the given streams", func() {
+			n, err := Export(ctx, buf, src, axmemory.MessageStore, []string{"<stream-1>"})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(n).To(BeNumerically("==", 2))
+
+			dec := NewDecoder(buf)
+
+			var m StoredMessage
+			Expect(dec.Decode(&m)).To(Succeed())
+			Expect(axtest.EnvelopesEqual(m.Envelope, env1)).To(BeTrue())
+
+			Expect(dec.Decode(&m)).To(Succeed())
+			Expect(axtest.EnvelopesEqual(m.Envelope, env3)).To(BeTrue())
+		})
+
+		It("returns an error if one of the streams does not exist", func() {
+			_, err := Export(ctx, buf, src, axmemory.MessageStore, []string{"<unknown>"})
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+
+	Describe("Import", func() {
+		It("appends the exported messages, preserving stream offsets and message IDs", func() {
+			_, err := Export(ctx, buf, src, axmemory.MessageStore, nil)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			n, err := Import(ctx, buf, dst, axmemory.MessageStore)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(n).To(BeNumerically("==", 3))
+
+			messages := readStream(dst, "<stream-1>")
+			Expect(messages).To(HaveLen(2))
+			Expect(axtest.EnvelopesEqual(messages[0].Envelope, env1)).To(BeTrue())
+			Expect(messages[0].StreamOffset).To(BeNumerically("==", 0))
+			Expect(axtest.EnvelopesEqual(messages[1].Envelope, env3)).To(BeTrue())
+			Expect(messages[1].StreamOffset).To(BeNumerically("==", 1))
+
+			messages = readStream(dst, "<stream-2>")
+			Expect(messages).To(HaveLen(1))
+			Expect(messages[0].Envelope.MessageID).To(Equal(env2.MessageID))
+		})
+
+		It("appends the exported messages to their original category", func() {
+			env := ax.NewEnvelope(&testmessages.MessageA{Value: "<categorized>"})
+
+			err := persistence.Atomically(
+				persistence.WithDataStore(WithCategory(ctx, "<category>"), src),
+				func(ctx context.Context, tx persistence.Tx) error {
+					return axmemory.MessageStore.AppendMessages(ctx, tx, "<stream-3>", 0, []ax.Envelope{env})
+				},
+			)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			_, err = Export(ctx, buf, src, axmemory.MessageStore, nil)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			_, err = Import(ctx, buf, dst, axmemory.MessageStore)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			messages := readStream(dst, "<stream-3>")
+			Expect(messages).To(HaveLen(1))
+			Expect(messages[0].Category).To(Equal("<category>"))
+		})
+
+		It("appends messages in batches", func() {
+			var envs []ax.Envelope
+			for i := 0; i < ImportBatchSize+1; i++ {
+				envs = append(envs, ax.NewEnvelope(&testmessages.MessageA{}))
+			}
+			appendMessages(src, "<stream-3>", 0, envs...)
+
+			_, err := Export(ctx, buf, src, axmemory.MessageStore, []string{"<stream-3>"})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			n, err := Import(ctx, buf, dst, axmemory.MessageStore)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(n).To(BeNumerically("==", ImportBatchSize+1))
+
+			messages := readStream(dst, "<stream-3>")
+			Expect(messages).To(HaveLen(ImportBatchSize + 1))
+		})
+
+		It("does not append messages that have already been imported", func() {
+			_, err := Export(ctx, buf, src, axmemory.MessageStore, nil)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			data := buf.Bytes()
+
+			_, err = Import(ctx, bytes.NewReader(data), dst, axmemory.MessageStore)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			_, err = Import(ctx, bytes.NewReader(data), dst, axmemory.MessageStore)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			messages := readStream(dst, "<stream-1>")
+			Expect(messages).To(HaveLen(2))
+		})
+
+		It("returns an error if the stream offsets are already in use by other messages", func() {
+			_, err := Export(ctx, buf, src, axmemory.MessageStore, nil)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			appendMessages(dst, "<stream-1>", 0, ax.NewEnvelope(&testmessages.MessageA{}))
+
+			_, err = Import(ctx, buf, dst, axmemory.MessageStore)
+			Expect(IsConflict(err)).To(BeTrue())
+			Expect(persistence.IsTransientError(dst, err)).To(BeFalse())
+		})
+
+		Context("when a stream was truncated before it was exported", func() {
+			BeforeEach(func() {
+				// the message at offset 0 is not present in the export
+				err := NewEncoder(buf).Encode(StoredMessage{
+					Stream:       "<stream-1>",
+					StreamOffset: 1,
+					Envelope:     env3,
+				})
+				Expect(err).ShouldNot(HaveOccurred())
+			})
+
+			It("returns an error if the messages before the first exported offset are not in the store", func() {
+				_, err := Import(ctx, buf, dst, axmemory.MessageStore)
+				Expect(err).To(MatchError(
+					"can not import stream '<stream-1>', the messages before offset 1 were not exported, for example because the stream was truncated: " +
+						"can not append to stream <stream-1>, 1 is not the next free offset",
+				))
+				Expect(IsConflict(err)).To(BeTrue())
+			})
+
+			It("appends the exported messages if the messages before the first exported offset are in the store", func() {
+				appendMessages(dst, "<stream-1>", 0, env1)
+
+				n, err := Import(ctx, buf, dst, axmemory.MessageStore)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(n).To(BeNumerically("==", 1))
+
+				messages := readStream(dst, "<stream-1>")
+				Expect(messages).To(HaveLen(2))
+				Expect(axtest.EnvelopesEqual(messages[1].Envelope, env3)).To(BeTrue())
+			})
+		})
+	})
+})
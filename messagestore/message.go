@@ -0,0 +1,36 @@
+package messagestore
+
+import (
+	"time"
+
+	"github.com/jmalloc/ax"
+)
+
+// StoredMessage is a message that has been persisted in a message store,
+// along with its position within the store.
+type StoredMessage struct {
+	// Envelope is the envelope containing the message.
+	Envelope ax.Envelope
+
+	// Stream is the name of the stream that the message was appended to.
+	Stream string
+
+	// StreamOffset is the zero-based offset of the message within its stream.
+	StreamOffset uint64
+
+	// Category is the category that the message was placed in when it was
+	// appended, as per StreamCategory().
+	Category string
+
+	// GlobalOffset is the zero-based offset of the message within the entire
+	// store. It is only meaningful if HasGlobalOffset is true.
+	GlobalOffset uint64
+
+	// HasGlobalOffset is true if the message has been assigned a global offset.
+	// Stores that assign global offsets after the append is committed may not
+	// have done so when the message is read from its own stream.
+	HasGlobalOffset bool
+
+	// InsertTime is the time at which the message was persisted.
+	InsertTime time.Time
+}
@@ -0,0 +1,53 @@
+package messagestore
+
+import "sync"
+
+// Notifier is an interface for notifying readers of a message store that new
+// messages have been appended.
+//
+// Notifications carry no information about which messages were appended. A
+// reader that is woken by a notification must query the store to determine
+// whether there are new messages on its stream.
+type Notifier interface {
+	// Notify wakes all readers that are waiting for new messages.
+	Notify()
+
+	// Ready returns a channel that is closed the next time Notify() is called.
+	//
+	// Readers must obtain the channel before checking for new messages, so
+	// that messages appended between the check and the wait are not missed.
+	Ready() <-chan struct{}
+}
+
+// LocalNotifier is an in-process implementation of Notifier.
+//
+// It only notifies readers within the same process as the writer. Readers must
+// continue to poll the store in order to observe messages appended by other
+// processes. The zero-value is ready to use.
+type LocalNotifier struct {
+	m     sync.Mutex
+	ready chan struct{}
+}
+
+// Notify wakes all readers that are waiting for new messages.
+func (n *LocalNotifier) Notify() {
+	n.m.Lock()
+	defer n.m.Unlock()
+
+	if n.ready != nil {
+		close(n.ready)
+		n.ready = nil
+	}
+}
+
+// Ready returns a channel that is closed the next time Notify() is called.
+func (n *LocalNotifier) Ready() <-chan struct{} {
+	n.m.Lock()
+	defer n.m.Unlock()
+
+	if n.ready == nil {
+		n.ready = make(chan struct{})
+	}
+
+	return n.ready
+}
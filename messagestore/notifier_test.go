@@ -0,0 +1,44 @@
+package messagestore_test
+
+import (
+	. "github.com/jmalloc/ax/messagestore"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("LocalNotifier", func() {
+	var notifier *LocalNotifier
+
+	BeforeEach(func() {
+		notifier = &LocalNotifier{}
+	})
+
+	Describe("Ready", func() {
+		It("returns a channel that is not closed until Notify() is called", func() {
+			ch := notifier.Ready()
+			Consistently(ch).ShouldNot(BeClosed())
+
+			notifier.Notify()
+			Eventually(ch).Should(BeClosed())
+		})
+
+		It("returns the same channel to all readers", func() {
+			Expect(notifier.Ready()).To(Equal(notifier.Ready()))
+		})
+
+		It("returns a new channel after Notify() is called", func() {
+			ch := notifier.Ready()
+			notifier.Notify()
+
+			Expect(notifier.Ready()).NotTo(BeClosed())
+			Expect(ch).To(BeClosed())
+		})
+	})
+
+	Describe("Notify", func() {
+		It("does not block if there are no readers", func() {
+			notifier.Notify()
+			notifier.Notify()
+		})
+	})
+})
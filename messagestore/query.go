@@ -0,0 +1,60 @@
+package messagestore
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmalloc/ax"
+	"github.com/jmalloc/ax/persistence"
+)
+
+// QueryableStore is an interface for message stores that can locate messages
+// by their identifiers and insert time, without reading an entire stream.
+//
+// It is intended for diagnostic tooling, such as answering the question "what
+// happened as a result of this message?". It is not intended to be used by
+// message handlers or projections.
+type QueryableStore interface {
+	// LoadMessage loads the message with the given message ID.
+	//
+	// It returns false if the message does not exist.
+	LoadMessage(
+		ctx context.Context,
+		ds persistence.DataStore,
+		id ax.MessageID,
+	) (StoredMessage, bool, error)
+
+	// LoadMessagesByCorrelationID loads all messages with the given
+	// correlation ID, in the order they were inserted.
+	//
+	// This includes the message that began the correlation chain, if it was
+	// persisted in the store.
+	LoadMessagesByCorrelationID(
+		ctx context.Context,
+		ds persistence.DataStore,
+		id ax.MessageID,
+	) ([]StoredMessage, error)
+
+	// LoadMessagesByCausationID loads all messages that were caused by the
+	// message with the given ID, in the order they were inserted.
+	//
+	// A message at the root of a correlation chain is its own cause, and is
+	// therefore included in the result.
+	LoadMessagesByCausationID(
+		ctx context.Context,
+		ds persistence.DataStore,
+		id ax.MessageID,
+	) ([]StoredMessage, error)
+
+	// LoadMessagesByInsertTime loads the messages that were inserted at or
+	// after begin, and before end, in the order they were inserted.
+	//
+	// At most limit messages are returned. If limit is zero, all messages
+	// within the time range are returned.
+	LoadMessagesByInsertTime(
+		ctx context.Context,
+		ds persistence.DataStore,
+		begin, end time.Time,
+		limit uint64,
+	) ([]StoredMessage, error)
+}
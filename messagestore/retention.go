@@ -0,0 +1,126 @@
+package messagestore
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmalloc/ax/persistence"
+)
+
+// StreamMetadata contains information about a stream that determines which of
+// its messages are retained by the store.
+type StreamMetadata struct {
+	// Deleted is true if the stream has been deleted.
+	//
+	// Messages can not be appended to a deleted stream, and it can not be
+	// re-created. Its messages are removed from the store by Cleaner. Once a
+	// stream is deleted it can not be restored.
+	Deleted bool
+
+	// TruncateBefore is the offset of the earliest message in the stream that is
+	// retained.
+	//
+	// Messages at earlier offsets are not returned when the stream is opened
+	// with OpenStream(), and are removed from the store by Cleaner. It can not
+	// be decreased, nor advanced beyond the end of the stream.
+	TruncateBefore uint64
+
+	// MaxAge is the maximum age of the messages in the stream. If it is
+	// non-zero, Cleaner truncates the stream before the earliest message that
+	// was appended less than MaxAge ago.
+	MaxAge time.Duration
+
+	// MaxCount is the maximum number of messages in the stream. If it is
+	// non-zero, Cleaner truncates the stream such that only the most recent
+	// MaxCount messages are retained.
+	MaxCount uint64
+}
+
+// RetentionStore is a store that supports per-stream metadata, allowing
+// streams to be deleted and truncated.
+//
+// Retention policies are not intended for streams that are used to rebuild
+// state, such as the event streams of event-sourced sagas, unless the state is
+// also recoverable from some other source, such as a snapshot.
+type RetentionStore interface {
+	Store
+
+	// LoadStreamMetadata loads the metadata for a stream.
+	//
+	// It returns the zero-value if the stream has no metadata, including when
+	// the stream does not exist.
+	LoadStreamMetadata(
+		ctx context.Context,
+		tx persistence.Tx,
+		stream string,
+	) (StreamMetadata, error)
+
+	// SaveStreamMetadata saves the metadata for a stream.
+	//
+	// The stream need not exist. Changes that would restore a deleted stream,
+	// decrease TruncateBefore, or advance it beyond the end of the stream are
+	// ignored.
+	SaveStreamMetadata(
+		ctx context.Context,
+		tx persistence.Tx,
+		stream string,
+		md StreamMetadata,
+	) error
+
+	// RemoveExpiredMessages removes up to n messages that are no longer
+	// retained according to the metadata of their streams. It returns the
+	// number of messages removed.
+	//
+	// Messages that are removed are no longer returned when reading the global
+	// stream, or any category stream.
+	RemoveExpiredMessages(
+		ctx context.Context,
+		tx persistence.Tx,
+		n int,
+	) (int, error)
+}
+
+// DeleteStream marks a stream as deleted.
+//
+// Messages can no longer be appended to the stream, and it can not be
+// re-created. Its messages are removed from the store by Cleaner.
+func DeleteStream(
+	ctx context.Context,
+	tx persistence.Tx,
+	ms RetentionStore,
+	stream string,
+) error {
+	md, err := ms.LoadStreamMetadata(ctx, tx, stream)
+	if err != nil {
+		return err
+	}
+
+	md.Deleted = true
+
+	return ms.SaveStreamMetadata(ctx, tx, stream, md)
+}
+
+// TruncateStream truncates a stream such that the messages before the given
+// offset are no longer retained.
+//
+// It has no effect if the stream is already truncated at or beyond offset.
+func TruncateStream(
+	ctx context.Context,
+	tx persistence.Tx,
+	ms RetentionStore,
+	stream string,
+	offset uint64,
+) error {
+	md, err := ms.LoadStreamMetadata(ctx, tx, stream)
+	if err != nil {
+		return err
+	}
+
+	if offset <= md.TruncateBefore {
+		return nil
+	}
+
+	md.TruncateBefore = offset
+
+	return ms.SaveStreamMetadata(ctx, tx, stream, md)
+}
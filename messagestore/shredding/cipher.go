@@ -0,0 +1,217 @@
+package shredding
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// encryptedPrefix is the prefix of each encrypted field value. It
+// distinguishes encrypted values from those that were stored before the
+// message store was wrapped by Store.
+const encryptedPrefix = "ax-shredding:v1:"
+
+// encoding is the encoding used for encrypted field values. A text encoding is
+// used so that encrypted values are valid in string fields.
+var encoding = base64.RawStdEncoding
+
+// transform is a function that returns the new value of a personal data field,
+// given its current value.
+type transform func(v []byte) ([]byte, error)
+
+// transformMessage returns a copy of m in which each non-empty personal data
+// field is replaced with the result of fn.
+func transformMessage(m PersonalData, fn transform) (PersonalData, error) {
+	m = proto.Clone(m).(PersonalData)
+	r := proto.MessageReflect(m)
+	desc := r.Descriptor()
+
+	for _, n := range m.PersonalDataFields() {
+		fd := desc.Fields().ByName(protoreflect.Name(n))
+		if fd == nil {
+			return nil, fmt.Errorf(
+				"%s does not have a field named '%s'",
+				desc.FullName(),
+				n,
+			)
+		}
+
+		if fd.IsList() || fd.IsMap() {
+			return nil, unsupportedField(desc, fd)
+		}
+
+		switch fd.Kind() {
+		case protoreflect.StringKind:
+			v := r.Get(fd).String()
+			if v == "" {
+				continue
+			}
+
+			x, err := fn([]byte(v))
+			if err != nil {
+				return nil, err
+			}
+
+			r.Set(fd, protoreflect.ValueOfString(string(x)))
+
+		case protoreflect.BytesKind:
+			v := r.Get(fd).Bytes()
+			if len(v) == 0 {
+				continue
+			}
+
+			x, err := fn(v)
+			if err != nil {
+				return nil, err
+			}
+
+			r.Set(fd, protoreflect.ValueOfBytes(x))
+
+		default:
+			return nil, unsupportedField(desc, fd)
+		}
+	}
+
+	return m, nil
+}
+
+// unsupportedField returns an error indicating that fd can not contain
+// personal data.
+func unsupportedField(desc protoreflect.MessageDescriptor, fd protoreflect.FieldDescriptor) error {
+	return fmt.Errorf(
+		"the '%s' field of %s can not contain personal data, only singular string and bytes fields are supported",
+		fd.Name(),
+		desc.FullName(),
+	)
+}
+
+// newAEAD returns the cipher used to encrypt and decrypt values with the given
+// key.
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	b, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(b)
+}
+
+// encrypter returns a transform that encrypts values with the given key.
+//
+// The subject is authenticated along with each value, so that a value can not
+// be decrypted as if it were personal data about some other subject.
+//
+// Values are always encrypted, even if they already appear to be encrypted.
+// Otherwise, a data subject could prevent their personal data from being
+// encrypted, and hence forgotten, by giving it the prefix of an encrypted
+// value.
+func encrypter(key []byte, subject string) (transform, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(v []byte) ([]byte, error) {
+		ns := aead.NonceSize()
+		nonce := make([]byte, ns, ns+len(v)+aead.Overhead())
+
+		if _, err := rand.Read(nonce); err != nil {
+			return nil, err
+		}
+
+		ct := aead.Seal(nonce, nonce, v, []byte(subject))
+
+		buf := make([]byte, len(encryptedPrefix)+encoding.EncodedLen(len(ct)))
+		copy(buf, encryptedPrefix)
+		encoding.Encode(buf[len(encryptedPrefix):], ct)
+
+		return buf, nil
+	}, nil
+}
+
+// decrypter returns a transform that decrypts values that were encrypted by
+// a transform returned by encrypter() with the same key and subject.
+//
+// Values that are not encrypted are returned unchanged.
+//
+// A message that is appended again after it has been encrypted, such as when
+// it is imported from an export of the underlying store, is encrypted twice.
+// The decrypted value is therefore decrypted again if it is itself a value
+// that was encrypted with the same key and subject. A value that merely has
+// the prefix of an encrypted value can not be authenticated, and so is
+// returned as it was appended.
+func decrypter(key []byte, subject string) (transform, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(v []byte) ([]byte, error) {
+		if !isEncrypted(v) {
+			return v, nil
+		}
+
+		v, err := open(aead, v, subject)
+		if err != nil {
+			return nil, err
+		}
+
+		for isEncrypted(v) {
+			x, err := open(aead, v, subject)
+			if err != nil {
+				break
+			}
+
+			v = x
+		}
+
+		return v, nil
+	}, nil
+}
+
+// open decrypts the encrypted value v.
+func open(aead cipher.AEAD, v []byte, subject string) ([]byte, error) {
+	v = v[len(encryptedPrefix):]
+	ct := make([]byte, encoding.DecodedLen(len(v)))
+
+	n, err := encoding.Decode(ct, v)
+	if err != nil {
+		return nil, err
+	}
+	ct = ct[:n]
+
+	ns := aead.NonceSize()
+	if len(ct) < ns {
+		return nil, errors.New("encrypted value is too short")
+	}
+
+	return aead.Open(nil, ct[:ns], ct[ns:], []byte(subject))
+}
+
+// forget is a transform that replaces every value with the Forgotten
+// placeholder.
+func forget([]byte) ([]byte, error) {
+	return []byte(Forgotten), nil
+}
+
+// forgetEncrypted is a transform that replaces encrypted values with the
+// Forgotten placeholder. Values that are not encrypted are returned unchanged.
+func forgetEncrypted(v []byte) ([]byte, error) {
+	if isEncrypted(v) {
+		return forget(v)
+	}
+
+	return v, nil
+}
+
+// isEncrypted returns true if v is an encrypted value.
+func isEncrypted(v []byte) bool {
+	return bytes.HasPrefix(v, []byte(encryptedPrefix))
+}
@@ -0,0 +1,58 @@
+package shredding
+
+import (
+	"context"
+	"crypto/rand"
+
+	"github.com/jmalloc/ax/persistence"
+)
+
+// KeySize is the size of the encryption keys used by Store, in bytes.
+const KeySize = 32
+
+// KeyStore is an interface for persisting the encryption key of each data
+// subject.
+type KeyStore interface {
+	// LoadOrCreateKey loads the encryption key for a data subject, creating a
+	// new key using GenerateKey() if the subject does not have one.
+	//
+	// It returns false if the subject's key has been deleted, in which case a
+	// new key is not created.
+	LoadOrCreateKey(
+		ctx context.Context,
+		tx persistence.Tx,
+		subject string,
+	) ([]byte, bool, error)
+
+	// LoadKey loads the encryption key for a data subject.
+	//
+	// It returns false if the subject does not have a key, or if the key has
+	// been deleted.
+	LoadKey(
+		ctx context.Context,
+		ds persistence.DataStore,
+		subject string,
+	) ([]byte, bool, error)
+
+	// DeleteKey permanently deletes the encryption key for a data subject,
+	// such that any personal data encrypted with that key can no longer be
+	// recovered.
+	//
+	// Once a subject's key has been deleted, it is never replaced.
+	DeleteKey(
+		ctx context.Context,
+		tx persistence.Tx,
+		subject string,
+	) error
+}
+
+// GenerateKey returns a new random encryption key.
+func GenerateKey() ([]byte, error) {
+	k := make([]byte, KeySize)
+
+	if _, err := rand.Read(k); err != nil {
+		return nil, err
+	}
+
+	return k, nil
+}
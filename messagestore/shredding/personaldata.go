@@ -0,0 +1,30 @@
+package shredding
+
+import "github.com/jmalloc/ax"
+
+// Forgotten is the value that is placed in each personal data field of a
+// message when the subject of that data has been forgotten.
+const Forgotten = "[forgotten]"
+
+// PersonalData is an interface for messages that contain personal data.
+//
+// The personal data fields of such messages are encrypted by Store before
+// they are persisted.
+type PersonalData interface {
+	ax.Message
+
+	// DataSubjectID returns the ID of the person that the message's personal
+	// data is about.
+	//
+	// The ID must be obtained from a field that does not itself contain
+	// personal data, so that it is available when the message is read from the
+	// store.
+	DataSubjectID() string
+
+	// PersonalDataFields returns the names of the message's fields that
+	// contain personal data.
+	//
+	// The names are the field names used in the message's .proto file, such
+	// as "customer_name". Each field must be a singular string or bytes field.
+	PersonalDataFields() []string
+}
@@ -0,0 +1,11 @@
+// Package shredding provides a message store that encrypts personal data
+// before it is persisted, such that it can be made unrecoverable on request.
+//
+// This technique is known as "crypto-shredding". Each data subject, that is,
+// each person that personal data is about, has their own encryption key.
+// Deleting a subject's key "forgets" that subject, without modifying the
+// immutable messages in the store.
+//
+// Only the message store is covered. Personal data that is copied elsewhere,
+// such as into saga snapshots or read-models, must be removed separately.
+package shredding
@@ -0,0 +1,272 @@
+package shredding
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jmalloc/ax"
+	"github.com/jmalloc/ax/messagestore"
+	"github.com/jmalloc/ax/persistence"
+)
+
+// Store is a message store that encrypts the personal data fields of messages
+// that implement PersonalData before they are appended to an underlying
+// message store, and decrypts them when they are read.
+//
+// Each data subject's personal data is encrypted with their own key, obtained
+// from a KeyStore. Once a subject's key has been deleted, the personal data
+// fields of messages about that subject are read as the Forgotten placeholder,
+// and any further personal data about that subject is replaced with the
+// placeholder before it is stored.
+//
+// Messages that were appended before the underlying store was wrapped are not
+// encrypted, and so can not be forgotten. They are read unchanged.
+type Store struct {
+	// MessageStore is the underlying store in which messages are persisted.
+	MessageStore messagestore.GloballyOrderedStore
+
+	// KeyStore is the store that contains the encryption key for each data
+	// subject.
+	KeyStore KeyStore
+}
+
+// AppendMessages appends one or more messages to a named stream.
+//
+// The personal data fields of each message that implements PersonalData are
+// encrypted before the messages are appended to the underlying store. The
+// messages in envs are not modified.
+func (s Store) AppendMessages(
+	ctx context.Context,
+	tx persistence.Tx,
+	stream string,
+	offset uint64,
+	envs []ax.Envelope,
+) error {
+	transforms := map[string]transform{}
+	encrypted := make([]ax.Envelope, len(envs))
+
+	for i, env := range envs {
+		if m, ok := env.Message.(PersonalData); ok {
+			subject := m.DataSubjectID()
+
+			fn, ok := transforms[subject]
+			if !ok {
+				var err error
+				fn, err = s.encrypter(ctx, tx, m)
+				if err != nil {
+					return err
+				}
+
+				transforms[subject] = fn
+			}
+
+			x, err := transformMessage(m, fn)
+			if err != nil {
+				return fmt.Errorf(
+					"can not encrypt personal data in message %s: %w",
+					env.MessageID.Get(),
+					err,
+				)
+			}
+
+			env.Message = x
+		}
+
+		encrypted[i] = env
+	}
+
+	return s.MessageStore.AppendMessages(ctx, tx, stream, offset, encrypted)
+}
+
+// OpenStream opens a stream of messages for reading from a specific offset.
+//
+// The offset may be beyond the end of the stream. It returns false if the
+// stream does not exist.
+func (s Store) OpenStream(
+	ctx context.Context,
+	ds persistence.DataStore,
+	stream string,
+	offset uint64,
+) (messagestore.Stream, bool, error) {
+	str, ok, err := s.MessageStore.OpenStream(ctx, ds, stream, offset)
+	if !ok || err != nil {
+		return nil, ok, err
+	}
+
+	return s.wrap(ds, str), true, nil
+}
+
+// OpenGlobal opens the entire store for reading as a single stream.
+//
+// The offset may be beyond the end of the stream. If types is non-empty, only
+// messages of those types are returned.
+func (s Store) OpenGlobal(
+	ctx context.Context,
+	ds persistence.DataStore,
+	offset uint64,
+	types ax.MessageTypeSet,
+) (messagestore.Stream, error) {
+	str, err := s.MessageStore.OpenGlobal(ctx, ds, offset, types)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.wrap(ds, str), nil
+}
+
+// OpenCategory opens all messages in a category for reading as a single stream.
+// The category of each message is determined when it is appended, as per
+// messagestore.StreamCategory().
+//
+// The offset may be beyond the end of the stream. If types is non-empty, only
+// messages of those types are returned.
+func (s Store) OpenCategory(
+	ctx context.Context,
+	ds persistence.DataStore,
+	category string,
+	offset uint64,
+	types ax.MessageTypeSet,
+) (messagestore.Stream, error) {
+	str, err := s.MessageStore.OpenCategory(ctx, ds, category, offset, types)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.wrap(ds, str), nil
+}
+
+// Decrypt returns a copy of env with the personal data fields of its message
+// decrypted.
+//
+// It can be used to decrypt messages that are obtained from the underlying
+// store by some other means, such as via messagestore.QueryableStore.
+func (s Store) Decrypt(
+	ctx context.Context,
+	ds persistence.DataStore,
+	env ax.Envelope,
+) (ax.Envelope, error) {
+	m, ok := env.Message.(PersonalData)
+	if !ok {
+		return env, nil
+	}
+
+	fn, err := s.decrypter(ctx, ds, m)
+	if err != nil {
+		return ax.Envelope{}, err
+	}
+
+	x, err := transformMessage(m, fn)
+	if err != nil {
+		return ax.Envelope{}, fmt.Errorf(
+			"can not decrypt personal data in message %s: %w",
+			env.MessageID.Get(),
+			err,
+		)
+	}
+
+	env.Message = x
+
+	return env, nil
+}
+
+// encrypter returns the transform used to encrypt the personal data in m.
+func (s Store) encrypter(
+	ctx context.Context,
+	tx persistence.Tx,
+	m PersonalData,
+) (transform, error) {
+	subject, err := subjectOf(m)
+	if err != nil {
+		return nil, err
+	}
+
+	key, ok, err := s.KeyStore.LoadOrCreateKey(ctx, tx, subject)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		return forget, nil
+	}
+
+	return encrypter(key, subject)
+}
+
+// decrypter returns the transform used to decrypt the personal data in m.
+func (s Store) decrypter(
+	ctx context.Context,
+	ds persistence.DataStore,
+	m PersonalData,
+) (transform, error) {
+	subject, err := subjectOf(m)
+	if err != nil {
+		return nil, err
+	}
+
+	key, ok, err := s.KeyStore.LoadKey(ctx, ds, subject)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		return forgetEncrypted, nil
+	}
+
+	return decrypter(key, subject)
+}
+
+// wrap returns a stream that decrypts the messages read from str.
+func (s Store) wrap(ds persistence.DataStore, str messagestore.Stream) messagestore.Stream {
+	return &stream{
+		Stream:    str,
+		store:     s,
+		dataStore: ds,
+	}
+}
+
+// subjectOf returns the ID of the data subject of m.
+func subjectOf(m PersonalData) (string, error) {
+	if subject := m.DataSubjectID(); subject != "" {
+		return subject, nil
+	}
+
+	return "", fmt.Errorf(
+		"can not determine the data subject of %s, the ID is empty",
+		ax.TypeOf(m).Name,
+	)
+}
+
+// stream is a messagestore.Stream that decrypts the personal data in each
+// message that is read.
+type stream struct {
+	messagestore.Stream
+
+	store     Store
+	dataStore persistence.DataStore
+}
+
+// Get returns the message at the current offset in the stream.
+func (s *stream) Get(ctx context.Context) (ax.Envelope, error) {
+	env, err := s.Stream.Get(ctx)
+	if err != nil {
+		return ax.Envelope{}, err
+	}
+
+	return s.store.Decrypt(ctx, s.dataStore, env)
+}
+
+// GetStored returns the message at the current offset in the stream, along
+// with its position within the store.
+func (s *stream) GetStored(ctx context.Context) (messagestore.StoredMessage, error) {
+	m, err := s.Stream.GetStored(ctx)
+	if err != nil {
+		return messagestore.StoredMessage{}, err
+	}
+
+	m.Envelope, err = s.store.Decrypt(ctx, s.dataStore, m.Envelope)
+	if err != nil {
+		return messagestore.StoredMessage{}, err
+	}
+
+	return m, nil
+}
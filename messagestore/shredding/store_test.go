@@ -0,0 +1,342 @@
+package shredding_test
+
+import (
+	"context"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/jmalloc/ax"
+	"github.com/jmalloc/ax/axmemory"
+	"github.com/jmalloc/ax/axtest"
+	"github.com/jmalloc/ax/axtest/testmessages"
+	"github.com/jmalloc/ax/messagestore"
+	. "github.com/jmalloc/ax/messagestore/shredding"
+	"github.com/jmalloc/ax/persistence"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Store", func() {
+	var (
+		ctx              context.Context
+		cancel           func()
+		ds               *axmemory.DataStore
+		store            Store
+		env1, env2, env3 ax.Envelope
+	)
+
+	atomically := func(fn func(tx persistence.Tx) error) error {
+		tx, com, err := ds.BeginTx(ctx)
+		if err != nil {
+			return err
+		}
+		defer com.Rollback()
+
+		if err := fn(tx); err != nil {
+			return err
+		}
+
+		return com.Commit()
+	}
+
+	appendMessages := func(ms messagestore.Store, stream string, offset uint64, envs ...ax.Envelope) {
+		err := atomically(func(tx persistence.Tx) error {
+			return ms.AppendMessages(ctx, tx, stream, offset, envs)
+		})
+		Expect(err).ShouldNot(HaveOccurred())
+	}
+
+	deleteKey := func(subject string) {
+		err := atomically(func(tx persistence.Tx) error {
+			return store.KeyStore.DeleteKey(ctx, tx, subject)
+		})
+		Expect(err).ShouldNot(HaveOccurred())
+	}
+
+	readAll := func(s messagestore.Stream) []ax.Envelope {
+		defer s.Close()
+
+		var envs []ax.Envelope
+
+		for {
+			ok, err := s.TryNext(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			if !ok {
+				return envs
+			}
+
+			env, err := s.Get(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			envs = append(envs, env)
+		}
+	}
+
+	readStream := func(ms messagestore.Store, stream string) []ax.Envelope {
+		s, ok, err := ms.OpenStream(ctx, ds, stream, 0)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(ok).To(BeTrue())
+
+		return readAll(s)
+	}
+
+	BeforeEach(func() {
+		var fn func()
+		ctx, fn = context.WithTimeout(context.Background(), 5*time.Second)
+		cancel = fn // defeat go vet warning about unused cancel func
+
+		ds = axmemory.NewDataStore()
+		store = Store{
+			MessageStore: axmemory.MessageStore,
+			KeyStore:     axmemory.ShreddingKeyStore,
+		}
+
+		env1 = ax.NewEnvelope(&testmessages.PersonalDataEvent{
+			SubjectId: "<subject-1>",
+			Name:      "<name-1>",
+			Data:      []byte("<data-1>"),
+			Value:     "<value-1>",
+		})
+		env2 = ax.NewEnvelope(&testmessages.PersonalDataEvent{
+			SubjectId: "<subject-2>",
+			Name:      "<name-2>",
+			Value:     "<value-2>",
+		})
+		env3 = ax.NewEnvelope(&testmessages.MessageA{Value: "<foo>"})
+	})
+
+	AfterEach(func() {
+		cancel()
+	})
+
+	Describe("AppendMessages", func() {
+		It("encrypts the personal data fields in the underlying store", func() {
+			appendMessages(store, "person:1", 0, env1)
+
+			envs := readStream(axmemory.MessageStore, "person:1")
+			Expect(envs).To(HaveLen(1))
+
+			m := envs[0].Message.(*testmessages.PersonalDataEvent)
+			Expect(m.SubjectId).To(Equal("<subject-1>"))
+			Expect(m.Value).To(Equal("<value-1>"))
+			Expect(m.Name).NotTo(ContainSubstring("<name-1>"))
+			Expect(string(m.Data)).NotTo(ContainSubstring("<data-1>"))
+		})
+
+		It("does not encrypt empty fields", func() {
+			appendMessages(store, "person:2", 0, env2)
+
+			envs := readStream(axmemory.MessageStore, "person:2")
+			m := envs[0].Message.(*testmessages.PersonalDataEvent)
+			Expect(m.Data).To(BeEmpty())
+		})
+
+		It("does not modify the given envelopes", func() {
+			m := proto.Clone(env1.Message)
+
+			appendMessages(store, "person:1", 0, env1)
+
+			Expect(proto.Equal(env1.Message, m)).To(BeTrue())
+		})
+
+		It("does not modify messages that do not contain personal data", func() {
+			appendMessages(store, "person:1", 0, env3)
+
+			envs := readStream(axmemory.MessageStore, "person:1")
+			Expect(axtest.ConsistsOfEnvelopes(envs, env3)).To(BeTrue())
+		})
+
+		It("stores the placeholder value if the subject has been forgotten", func() {
+			deleteKey("<subject-1>")
+			appendMessages(store, "person:1", 0, env1)
+
+			envs := readStream(axmemory.MessageStore, "person:1")
+			m := envs[0].Message.(*testmessages.PersonalDataEvent)
+			Expect(m.Name).To(Equal(Forgotten))
+			Expect(m.Data).To(Equal([]byte(Forgotten)))
+			Expect(m.Value).To(Equal("<value-1>"))
+		})
+
+		It("can re-append messages that are already encrypted", func() {
+			appendMessages(store, "person:1", 0, env1)
+			envs := readStream(axmemory.MessageStore, "person:1")
+
+			appendMessages(store, "person:2", 0, ax.Envelope{
+				MessageID: ax.GenerateMessageID(),
+				Message:   envs[0].Message,
+			})
+
+			envs = readStream(store, "person:2")
+			Expect(proto.Equal(envs[0].Message, env1.Message)).To(BeTrue())
+		})
+
+		It("encrypts values that have the prefix of an encrypted value", func() {
+			env := ax.NewEnvelope(&testmessages.PersonalDataEvent{
+				SubjectId: "<subject-1>",
+				Name:      "ax-shredding:v1:<name-1>",
+			})
+			appendMessages(store, "person:1", 0, env)
+
+			envs := readStream(axmemory.MessageStore, "person:1")
+			m := envs[0].Message.(*testmessages.PersonalDataEvent)
+			Expect(m.Name).NotTo(ContainSubstring("<name-1>"))
+
+			envs = readStream(store, "person:1")
+			Expect(axtest.EnvelopesEqual(envs[0], env)).To(BeTrue())
+
+			deleteKey("<subject-1>")
+
+			envs = readStream(store, "person:1")
+			m = envs[0].Message.(*testmessages.PersonalDataEvent)
+			Expect(m.Name).To(Equal(Forgotten))
+		})
+
+		It("returns an error if the data subject ID is empty", func() {
+			env := ax.NewEnvelope(&testmessages.PersonalDataEvent{
+				Name: "<name>",
+			})
+
+			err := atomically(func(tx persistence.Tx) error {
+				return store.AppendMessages(ctx, tx, "person:1", 0, []ax.Envelope{env})
+			})
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+
+	Describe("OpenStream", func() {
+		BeforeEach(func() {
+			appendMessages(store, "person:1", 0, env1, env2, env3)
+		})
+
+		It("decrypts the personal data fields", func() {
+			envs := readStream(store, "person:1")
+			Expect(axtest.ConsistsOfEnvelopes(envs, env1, env2, env3)).To(BeTrue())
+		})
+
+		It("decrypts the personal data fields of stored messages", func() {
+			s, ok, err := store.OpenStream(ctx, ds, "person:1", 0)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(ok).To(BeTrue())
+			defer s.Close()
+
+			ok, err = s.TryNext(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(ok).To(BeTrue())
+
+			m, err := s.GetStored(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(axtest.EnvelopesEqual(m.Envelope, env1)).To(BeTrue())
+			Expect(m.Stream).To(Equal("person:1"))
+		})
+
+		It("returns the placeholder value for subjects that have been forgotten", func() {
+			deleteKey("<subject-1>")
+
+			envs := readStream(store, "person:1")
+			Expect(envs).To(HaveLen(3))
+
+			m := envs[0].Message.(*testmessages.PersonalDataEvent)
+			Expect(m.SubjectId).To(Equal("<subject-1>"))
+			Expect(m.Name).To(Equal(Forgotten))
+			Expect(m.Data).To(Equal([]byte(Forgotten)))
+			Expect(m.Value).To(Equal("<value-1>"))
+
+			Expect(axtest.EnvelopesEqual(envs[1], env2)).To(BeTrue())
+			Expect(axtest.EnvelopesEqual(envs[2], env3)).To(BeTrue())
+		})
+
+		It("returns false if the stream does not exist", func() {
+			_, ok, err := store.OpenStream(ctx, ds, "person:2", 0)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(ok).To(BeFalse())
+		})
+
+		It("returns messages appended before the store was in use unchanged", func() {
+			env := ax.NewEnvelope(&testmessages.PersonalDataEvent{
+				SubjectId: "<subject-3>",
+				Name:      "<name-3>",
+			})
+			appendMessages(axmemory.MessageStore, "person:1", 3, env)
+
+			envs := readStream(store, "person:1")
+			Expect(axtest.EnvelopesEqual(envs[3], env)).To(BeTrue())
+		})
+
+		It("returns messages appended before the store was in use unchanged when the subject has been forgotten", func() {
+			env := ax.NewEnvelope(&testmessages.PersonalDataEvent{
+				SubjectId: "<subject-3>",
+				Name:      "<name-3>",
+			})
+			appendMessages(axmemory.MessageStore, "person:1", 3, env)
+			deleteKey("<subject-3>")
+
+			envs := readStream(store, "person:1")
+			Expect(axtest.EnvelopesEqual(envs[3], env)).To(BeTrue())
+		})
+	})
+
+	Describe("OpenGlobal", func() {
+		It("decrypts the personal data fields", func() {
+			appendMessages(store, "person:1", 0, env1)
+			appendMessages(store, "person:2", 0, env2)
+
+			s, err := store.OpenGlobal(ctx, ds, 0, ax.MessageTypeSet{})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			envs := readAll(s)
+			Expect(axtest.ConsistsOfEnvelopes(envs, env1, env2)).To(BeTrue())
+		})
+	})
+
+	Describe("OpenCategory", func() {
+		It("decrypts the personal data fields", func() {
+			appendMessages(store, "person:1", 0, env1)
+			appendMessages(store, "other:1", 0, env3)
+			appendMessages(store, "person:2", 0, env2)
+
+			s, err := store.OpenCategory(ctx, ds, "person", 0, ax.MessageTypeSet{})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			envs := readAll(s)
+			Expect(axtest.ConsistsOfEnvelopes(envs, env1, env2)).To(BeTrue())
+		})
+	})
+
+	Describe("Decrypt", func() {
+		It("decrypts a message read from the underlying store", func() {
+			appendMessages(store, "person:1", 0, env1)
+
+			m, ok, err := axmemory.MessageStore.(messagestore.QueryableStore).LoadMessage(ctx, ds, env1.MessageID)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(ok).To(BeTrue())
+
+			env, err := store.Decrypt(ctx, ds, m.Envelope)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(axtest.EnvelopesEqual(env, env1)).To(BeTrue())
+		})
+
+		It("returns an error if the encrypted value has been tampered with", func() {
+			appendMessages(store, "person:1", 0, env1)
+
+			envs := readStream(axmemory.MessageStore, "person:1")
+			m := envs[0].Message.(*testmessages.PersonalDataEvent)
+			m.Name = m.Name[:len(m.Name)-2]
+
+			_, err := store.Decrypt(ctx, ds, envs[0])
+			Expect(err).Should(HaveOccurred())
+		})
+
+		It("returns an error if the encrypted value belongs to a different subject", func() {
+			appendMessages(store, "person:1", 0, env1, env2)
+
+			envs := readStream(axmemory.MessageStore, "person:1")
+			m1 := envs[0].Message.(*testmessages.PersonalDataEvent)
+			m2 := envs[1].Message.(*testmessages.PersonalDataEvent)
+			m2.Name = m1.Name
+
+			_, err := store.Decrypt(ctx, ds, envs[1])
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,19 @@
+package messagestore
+
+import (
+	"context"
+	"time"
+)
+
+// sleep blocks until ctx is canceled or the given duration elapses.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
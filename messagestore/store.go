@@ -11,8 +11,17 @@ import (
 type Store interface {
 	// AppendMessages appends one or more messages to a named stream.
 	//
-	// offset is a zero-based index into the stream. An error is returned if
-	// offset is not the next unused offset in the stream.
+	// offset is a zero-based index into the stream. If offset is not the next
+	// unused offset in the stream, it returns a *ConflictError, unless the
+	// messages already stored at offset have the same message IDs as envs. Such
+	// messages are assumed to have been appended by a prior attempt, and are
+	// not appended again. This allows an append to be retried safely when the
+	// outcome of a commit is unknown.
+	//
+	// It returns a *DuplicateMessageError if a message with the same ID as one
+	// of envs is already stored at some other position.
+	//
+	// The messages are placed in the category returned by StreamCategory().
 	AppendMessages(
 		ctx context.Context,
 		tx persistence.Tx,
@@ -41,10 +50,32 @@ type GloballyOrderedStore interface {
 
 	// OpenGlobal opens the entire store for reading as a single stream.
 	//
-	// The offset may be beyond the end of the stream.
+	// The offset may be beyond the end of the stream. If types is non-empty,
+	// only messages of those types are returned.
+	//
+	// The offsets reported by the stream are global offsets, so when types is
+	// non-empty there may be gaps between the offsets of consecutive messages.
 	OpenGlobal(
 		ctx context.Context,
 		ds persistence.DataStore,
 		offset uint64,
+		types ax.MessageTypeSet,
+	) (Stream, error)
+
+	// OpenCategory opens all messages in a category for reading as a single
+	// stream. The category of each message is determined when it is appended,
+	// as per StreamCategory().
+	//
+	// The offset may be beyond the end of the stream. If types is non-empty,
+	// only messages of those types are returned.
+	//
+	// The offsets reported by the stream are global offsets, so there may be
+	// gaps between the offsets of consecutive messages.
+	OpenCategory(
+		ctx context.Context,
+		ds persistence.DataStore,
+		category string,
+		offset uint64,
+		types ax.MessageTypeSet,
 	) (Stream, error)
 }
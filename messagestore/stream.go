@@ -21,6 +21,10 @@ type Stream interface {
 	// Get returns the message at the current offset in the stream.
 	Get(ctx context.Context) (ax.Envelope, error)
 
+	// GetStored returns the message at the current offset in the stream,
+	// along with its position within the store.
+	GetStored(ctx context.Context) (StoredMessage, error)
+
 	// Offset returns the offset of the message returned by Get().
 	Offset() (uint64, error)
 
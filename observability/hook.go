@@ -64,3 +64,22 @@ func (h *OutboundHook) Accept(ctx context.Context, env endpoint.OutboundEnvelope
 
 	return err
 }
+
+// AcceptBatch processes the messages encapsulated in envs.
+func (h *OutboundHook) AcceptBatch(ctx context.Context, envs []endpoint.OutboundEnvelope) error {
+	for _, env := range envs {
+		for _, o := range h.Observers {
+			o.BeforeOutbound(ctx, env)
+		}
+	}
+
+	err := endpoint.AcceptBatch(ctx, h.Next, envs)
+
+	for _, env := range envs {
+		for _, o := range h.Observers {
+			o.AfterOutbound(ctx, env, err)
+		}
+	}
+
+	return err
+}
@@ -183,4 +183,64 @@ var _ = Describe("OutboundHook", func() {
 			Expect(observer.AfterOutboundCalls()).To(HaveLen(1)) // ensure observer is actually called
 		})
 	})
+
+	Describe("AcceptBatch", func() {
+		var other endpoint.OutboundEnvelope
+
+		BeforeEach(func() {
+			if err := hook.Initialize(context.Background(), ep); err != nil {
+				panic(err)
+			}
+
+			other = endpoint.OutboundEnvelope{
+				Envelope: ax.NewEnvelope(
+					&testmessages.Message{},
+				),
+			}
+		})
+
+		It("invokes the before-observer for each message before processing the messages", func() {
+			observer.BeforeOutboundFunc = func(context.Context, endpoint.OutboundEnvelope) {
+				Expect(next.AcceptCalls()).To(BeEmpty()) // ensure messages have not been processed yet
+			}
+
+			err := hook.AcceptBatch(context.Background(), []endpoint.OutboundEnvelope{env, other})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			calls := observer.BeforeOutboundCalls()
+			Expect(calls).To(HaveLen(2))
+			Expect(calls[0].Env).To(Equal(env))
+			Expect(calls[1].Env).To(Equal(other))
+		})
+
+		It("invokes the after-observer for each message after processing the messages", func() {
+			observer.AfterOutboundFunc = func(_ context.Context, _ endpoint.OutboundEnvelope, err error) {
+				Expect(err).To(BeNil())
+				Expect(next.AcceptCalls()).To(HaveLen(2)) // ensure messages have already been processed
+			}
+
+			err := hook.AcceptBatch(context.Background(), []endpoint.OutboundEnvelope{env, other})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			calls := observer.AfterOutboundCalls()
+			Expect(calls).To(HaveLen(2))
+			Expect(calls[0].Env).To(Equal(env))
+			Expect(calls[1].Env).To(Equal(other))
+		})
+
+		It("provides the after-observer with the message processing error", func() {
+			expected := errors.New("<error>")
+			next.AcceptFunc = func(context.Context, endpoint.OutboundEnvelope) error {
+				return expected
+			}
+
+			err := hook.AcceptBatch(context.Background(), []endpoint.OutboundEnvelope{env, other})
+			Expect(err).To(Equal(expected))
+
+			for _, c := range observer.AfterOutboundCalls() {
+				Expect(c.Err).To(Equal(expected))
+			}
+			Expect(observer.AfterOutboundCalls()).To(HaveLen(2))
+		})
+	})
 })
@@ -14,9 +14,14 @@ import (
 // using the "outbox" pattern.
 //
 // See http://gistlabs.com/2014/05/the-outbox/
+//
+// The messages produced by the next stage are sent concurrently, up to a
+// maximum of SendConcurrency messages at a time. If SendConcurrency is zero,
+// DefaultSendConcurrency is used.
 type Deduplicator struct {
-	Repository Repository
-	Next       endpoint.InboundPipeline
+	Repository      Repository
+	Next            endpoint.InboundPipeline
+	SendConcurrency int
 }
 
 // Initialize is called during initialization of the endpoint, after the
@@ -76,68 +81,67 @@ func (d *Deduplicator) Accept(ctx context.Context, s endpoint.MessageSink, env e
 		)
 	}
 
-	for _, o := range envs {
-		if err := d.send(ctx, s, o); err != nil {
-			return err
-		}
-	}
-
-	return nil
+	return d.send(ctx, s, envs)
 }
 
 // forward passes env to the next pipeline stage and persists the messages it produces to the outbox.
 // The messages are also returned to be sent via the transport immediately.
 func (d *Deduplicator) forward(ctx context.Context, env endpoint.InboundEnvelope) ([]endpoint.OutboundEnvelope, error) {
-	tx, com, err := persistence.BeginTx(ctx)
-	if err != nil {
-		return nil, err
-	}
-	defer com.Rollback()
+	var envs []endpoint.OutboundEnvelope
 
-	var s endpoint.BufferedSink
-
-	if err := d.Next.Accept(
-		persistence.WithTx(ctx, tx),
-		&s,
-		env,
-	); err != nil {
-		return nil, err
-	}
-
-	envs := s.TakeEnvelopes()
-
-	if err := d.Repository.SaveOutbox(
+	err := persistence.Atomically(
 		ctx,
-		tx,
-		env.MessageID,
-		envs,
-	); err != nil {
-		return nil, err
-	}
+		func(ctx context.Context, tx persistence.Tx) error {
+			var s endpoint.BufferedSink
+
+			if err := d.Next.Accept(ctx, &s, env); err != nil {
+				return err
+			}
+
+			envs = s.TakeEnvelopes()
+
+			return d.Repository.SaveOutbox(
+				ctx,
+				tx,
+				env.MessageID,
+				envs,
+			)
+		},
+	)
 
-	return envs, com.Commit()
+	return envs, err
 }
 
-// send uses s to send a message that was previously persisted before marking it
-// as sent.
+// send uses s to send messages that were previously persisted before marking
+// them as sent.
+//
+// Messages that are sent successfully are marked as sent even if some other
+// message can not be sent.
 func (d *Deduplicator) send(
 	ctx context.Context,
 	s endpoint.MessageSink,
-	env endpoint.OutboundEnvelope,
+	envs []endpoint.OutboundEnvelope,
 ) error {
-	if err := s.Accept(ctx, env); err != nil {
-		return err
-	}
+	sent, sendErr := sendMessages(ctx, s, envs, d.SendConcurrency)
 
-	tx, com, err := persistence.BeginTx(ctx)
-	if err != nil {
-		return err
+	if len(sent) > 0 {
+		if err := d.markAsSent(ctx, sent); err != nil {
+			return err
+		}
 	}
-	defer com.Rollback()
 
-	if err := d.Repository.MarkAsSent(ctx, tx, env); err != nil {
-		return err
-	}
+	return sendErr
+}
 
-	return com.Commit()
+// markAsSent marks envs as sent within a single transaction.
+func (d *Deduplicator) markAsSent(
+	ctx context.Context,
+	envs []endpoint.OutboundEnvelope,
+) error {
+	return persistence.Atomically(
+		ctx,
+		func(ctx context.Context, tx persistence.Tx) error {
+			return d.Repository.MarkAsSent(ctx, tx, envs...)
+		},
+	)
 }
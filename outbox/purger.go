@@ -0,0 +1,94 @@
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmalloc/ax/persistence"
+)
+
+const (
+	// DefaultRetention is the default minimum age of an empty outbox before it
+	// is deleted by the purger.
+	DefaultRetention = 7 * 24 * time.Hour
+
+	// DefaultPurgeBatchSize is the default maximum number of outboxes that the
+	// purger deletes within a single transaction.
+	DefaultPurgeBatchSize = 1000
+)
+
+// DefaultPurgePollInterval is the duration to wait before checking for
+// outboxes to delete when the previous check found fewer than a full batch.
+var DefaultPurgePollInterval = 1 * time.Minute
+
+// Purger is a service that deletes empty outboxes once they are older than a
+// retention period.
+//
+// The presence of an outbox is what prevents a redelivered message from being
+// processed twice. The retention period should therefore be longer than the
+// longest period of time over which the transport may redeliver a message.
+//
+// It is safe to run multiple purgers against the same repository concurrently.
+type Purger struct {
+	DataStore    persistence.DataStore
+	Repository   Repository
+	Retention    time.Duration
+	BatchSize    int
+	PollInterval time.Duration
+}
+
+// Run deletes expired outboxes until ctx is canceled or an error occurs.
+func (p *Purger) Run(ctx context.Context) error {
+	for {
+		if err := p.tick(ctx); err != nil {
+			return err
+		}
+	}
+}
+
+// tick deletes a single batch of expired outboxes. If the batch is not full, it
+// waits for the poll interval before returning.
+func (p *Purger) tick(ctx context.Context) error {
+	n := p.BatchSize
+	if n == 0 {
+		n = DefaultPurgeBatchSize
+	}
+
+	count, err := p.purge(ctx, n)
+	if err != nil {
+		return err
+	}
+
+	if count == n {
+		return nil
+	}
+
+	d := p.PollInterval
+	if d == 0 {
+		d = DefaultPurgePollInterval
+	}
+
+	return sleep(ctx, d)
+}
+
+// purge deletes up to n expired outboxes. It returns the number of outboxes
+// deleted.
+func (p *Purger) purge(ctx context.Context, n int) (int, error) {
+	r := p.Retention
+	if r == 0 {
+		r = DefaultRetention
+	}
+
+	var count int
+
+	err := persistence.Atomically(
+		persistence.WithDataStore(ctx, p.DataStore),
+		func(ctx context.Context, tx persistence.Tx) error {
+			var err error
+			count, err = p.Repository.PurgeOutboxes(ctx, tx, r, n)
+			return err
+		},
+	)
+
+	return count, err
+}
@@ -0,0 +1,128 @@
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmalloc/ax/endpoint"
+	"github.com/jmalloc/ax/persistence"
+)
+
+const (
+	// DefaultRelayThreshold is the default minimum age of an outbox before its
+	// unsent messages are sent by the relay.
+	DefaultRelayThreshold = 5 * time.Minute
+
+	// DefaultRelayBatchSize is the default maximum number of messages that the
+	// relay sends within a single transaction.
+	DefaultRelayBatchSize = 100
+)
+
+// DefaultRelayPollInterval is the duration to wait before checking for unsent
+// messages when the previous check found fewer than a full batch.
+//
+// It is kept short because it dictates the latency of messages sent via a
+// TransactionalSender.
+var DefaultRelayPollInterval = 1 * time.Second
+
+// Relay is a service that sends messages that remain in an outbox long after
+// the outbox was saved.
+//
+// Ordinarily, the messages in an outbox are sent by the Deduplicator
+// immediately after they are saved, or when the inbound message that produced
+// them is redelivered. If neither of these occur, for example because the
+// endpoint crashed and the transport has since discarded the inbound message,
+// the outbound messages are "orphaned" and must be sent by the relay.
+//
+// The relay also sends the messages saved by a TransactionalSender, regardless
+// of their age.
+//
+// It is safe to run multiple relays against the same repository concurrently.
+type Relay struct {
+	DataStore        persistence.DataStore
+	Repository       Repository
+	OutboundPipeline endpoint.OutboundPipeline
+	Threshold        time.Duration
+	BatchSize        int
+	PollInterval     time.Duration
+}
+
+// Run sends orphaned messages until ctx is canceled or an error occurs.
+func (r *Relay) Run(ctx context.Context) error {
+	for {
+		if err := r.tick(ctx); err != nil {
+			return err
+		}
+	}
+}
+
+// tick sends a single batch of orphaned messages. If the batch is not full, it
+// waits for the poll interval before returning.
+func (r *Relay) tick(ctx context.Context) error {
+	n := r.BatchSize
+	if n == 0 {
+		n = DefaultRelayBatchSize
+	}
+
+	count, err := r.send(ctx, n)
+	if err != nil {
+		return err
+	}
+
+	if count == n {
+		return nil
+	}
+
+	d := r.PollInterval
+	if d == 0 {
+		d = DefaultRelayPollInterval
+	}
+
+	return sleep(ctx, d)
+}
+
+// send claims up to n orphaned messages, sends them and marks them as sent
+// within the same transaction. It returns the number of messages sent.
+//
+// Messages that are sent successfully are marked as sent even if some other
+// message can not be sent.
+func (r *Relay) send(ctx context.Context, n int) (int, error) {
+	t := r.Threshold
+	if t == 0 {
+		t = DefaultRelayThreshold
+	}
+
+	var (
+		count   int
+		sendErr error
+	)
+
+	err := persistence.Atomically(
+		persistence.WithDataStore(ctx, r.DataStore),
+		func(ctx context.Context, tx persistence.Tx) error {
+			envs, err := r.Repository.ClaimUnsentMessages(ctx, tx, t, n)
+			if err != nil {
+				return err
+			}
+
+			// the outbound pipeline is given access to the transaction (via ctx) so
+			// that any messages it persists, such as delayed messages, are saved
+			// atomically with the removal of the messages from the outbox. as such,
+			// the messages are never sent concurrently.
+			var sent []endpoint.OutboundEnvelope
+			sent, sendErr = sendMessages(ctx, r.OutboundPipeline, envs, 0)
+			count = len(sent)
+
+			if count == 0 {
+				return nil
+			}
+
+			return r.Repository.MarkAsSent(ctx, tx, sent...)
+		},
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, sendErr
+}
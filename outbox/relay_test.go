@@ -0,0 +1,156 @@
+package outbox_test
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jmalloc/ax/axmemory"
+	"github.com/jmalloc/ax/axtest/mocks"
+	"github.com/jmalloc/ax/axtest/testmessages"
+	"github.com/jmalloc/ax/endpoint"
+	. "github.com/jmalloc/ax/outbox"
+	"github.com/jmalloc/ax/persistence"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Relay", func() {
+	var (
+		ctx    context.Context
+		cancel func()
+		ds     *axmemory.DataStore
+		relay  *Relay
+		done   chan error
+	)
+
+	BeforeEach(func() {
+		ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+
+		ds = axmemory.NewDataStore()
+		relay = &Relay{
+			DataStore:    ds,
+			Repository:   axmemory.OutboxRepository,
+			PollInterval: 1 * time.Millisecond,
+		}
+		done = make(chan error, 1)
+
+		err := persistence.Atomically(
+			persistence.WithDataStore(ctx, ds),
+			func(ctx context.Context, tx persistence.Tx) error {
+				s := &TransactionalSender{
+					Tx:         tx,
+					Repository: axmemory.OutboxRepository,
+				}
+
+				for i := 0; i < 5; i++ {
+					if _, err := s.ExecuteCommand(ctx, &testmessages.Command{}); err != nil {
+						return err
+					}
+				}
+
+				return nil
+			},
+		)
+		Expect(err).ShouldNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		cancel()
+	})
+
+	run := func() {
+		go func() {
+			done <- relay.Run(ctx)
+		}()
+	}
+
+	stop := func() {
+		cancel()
+		Expect(<-done).To(Equal(context.Canceled))
+	}
+
+	Describe("Run", func() {
+		It("sends messages one at a time within the transaction if the pipeline does not accept batches", func() {
+			var (
+				m            sync.Mutex
+				active, peak int
+				sent         int
+				withoutTx    bool
+			)
+
+			relay.OutboundPipeline = &mocks.OutboundPipelineMock{
+				AcceptFunc: func(ctx context.Context, _ endpoint.OutboundEnvelope) error {
+					m.Lock()
+					active++
+					if active > peak {
+						peak = active
+					}
+					if _, ok := persistence.GetTx(ctx); !ok {
+						withoutTx = true
+					}
+					m.Unlock()
+
+					time.Sleep(1 * time.Millisecond)
+
+					m.Lock()
+					active--
+					sent++
+					m.Unlock()
+
+					return nil
+				},
+			}
+
+			run()
+
+			Eventually(func() int {
+				m.Lock()
+				defer m.Unlock()
+				return sent
+			}).Should(Equal(5))
+
+			stop()
+
+			Expect(peak).To(Equal(1))
+			Expect(withoutTx).To(BeFalse())
+		})
+
+		It("sends the messages as a single batch if the pipeline accepts batches", func() {
+			p := &batchPipeline{}
+			relay.OutboundPipeline = p
+
+			run()
+
+			Eventually(p.Batches).Should(HaveLen(1))
+
+			stop()
+
+			Expect(p.Batches()[0]).To(HaveLen(5))
+		})
+	})
+})
+
+// batchPipeline is an OutboundPipeline that records the batches passed to
+// AcceptBatch(). Calls to Accept() panic.
+type batchPipeline struct {
+	mocks.OutboundPipelineMock
+
+	m       sync.Mutex
+	batches [][]endpoint.OutboundEnvelope
+}
+
+func (p *batchPipeline) AcceptBatch(_ context.Context, envs []endpoint.OutboundEnvelope) error {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	p.batches = append(p.batches, envs)
+	return nil
+}
+
+func (p *batchPipeline) Batches() [][]endpoint.OutboundEnvelope {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	return append([][]endpoint.OutboundEnvelope(nil), p.batches...)
+}
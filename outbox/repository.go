@@ -2,6 +2,7 @@ package outbox
 
 import (
 	"context"
+	"time"
 
 	"github.com/jmalloc/ax"
 	"github.com/jmalloc/ax/endpoint"
@@ -30,10 +31,48 @@ type Repository interface {
 		envs []endpoint.OutboundEnvelope,
 	) error
 
-	// MarkAsSent marks a message as sent, removing it from the outbox.
+	// SaveMessages saves a set of unsent outbound messages that were not
+	// produced by processing an inbound message, such as those sent via a
+	// TransactionalSender.
+	//
+	// These messages are returned by ClaimUnsentMessages() regardless of their
+	// age.
+	SaveMessages(
+		ctx context.Context,
+		tx persistence.Tx,
+		envs []endpoint.OutboundEnvelope,
+	) error
+
+	// MarkAsSent marks one or more messages as sent, removing them from the
+	// outbox.
 	MarkAsSent(
 		ctx context.Context,
 		tx persistence.Tx,
-		env endpoint.OutboundEnvelope,
+		envs ...endpoint.OutboundEnvelope,
 	) error
+
+	// ClaimUnsentMessages loads up to n unsent outbound messages from outboxes
+	// that were saved at least d ago, and messages saved by SaveMessages().
+	//
+	// The returned messages are locked within tx. They are not returned by
+	// calls to ClaimUnsentMessages() within other transactions until tx is
+	// committed or rolled back.
+	ClaimUnsentMessages(
+		ctx context.Context,
+		tx persistence.Tx,
+		d time.Duration,
+		n int,
+	) ([]endpoint.OutboundEnvelope, error)
+
+	// PurgeOutboxes deletes up to n outboxes that contain no unsent messages
+	// and were saved at least d ago. It returns the number of outboxes deleted.
+	//
+	// Once an outbox is deleted, the message that produced it is no longer
+	// deduplicated, and is processed again if it is redelivered.
+	PurgeOutboxes(
+		ctx context.Context,
+		tx persistence.Tx,
+		d time.Duration,
+		n int,
+	) (int, error)
 }
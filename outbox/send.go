@@ -0,0 +1,79 @@
+package outbox
+
+import (
+	"context"
+	"sync"
+
+	"github.com/jmalloc/ax/endpoint"
+	"github.com/jmalloc/ax/persistence"
+)
+
+// DefaultSendConcurrency is the default maximum number of outbox messages that
+// are sent concurrently.
+var DefaultSendConcurrency = 10
+
+// sendMessages sends envs via s, sending up to n messages concurrently.
+//
+// If s is an endpoint.BatchMessageSink the messages are sent as a single batch.
+// Otherwise, if ctx contains a transaction the messages are sent one at a time,
+// as the transaction can not be used by several goroutines at once.
+//
+// It returns the messages that were sent successfully, even if an error occurs
+// while sending some other message.
+func sendMessages(
+	ctx context.Context,
+	s endpoint.MessageSink,
+	envs []endpoint.OutboundEnvelope,
+	n int,
+) ([]endpoint.OutboundEnvelope, error) {
+	if len(envs) == 0 {
+		return nil, nil
+	}
+
+	if bs, ok := s.(endpoint.BatchMessageSink); ok {
+		if err := bs.AcceptBatch(ctx, envs); err != nil {
+			return nil, err
+		}
+
+		return envs, nil
+	}
+
+	if _, ok := persistence.GetTx(ctx); ok {
+		n = 1
+	} else if n <= 0 {
+		n = DefaultSendConcurrency
+	}
+
+	var (
+		wg        sync.WaitGroup
+		m         sync.Mutex
+		sent      []endpoint.OutboundEnvelope
+		firstErr  error
+		semaphore = make(chan struct{}, n)
+	)
+
+	for _, env := range envs {
+		semaphore <- struct{}{}
+		wg.Add(1)
+
+		go func(env endpoint.OutboundEnvelope) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			err := s.Accept(ctx, env)
+
+			m.Lock()
+			defer m.Unlock()
+
+			if err == nil {
+				sent = append(sent, env)
+			} else if firstErr == nil {
+				firstErr = err
+			}
+		}(env)
+	}
+
+	wg.Wait()
+
+	return sent, firstErr
+}
@@ -0,0 +1,68 @@
+package outbox
+
+import (
+	"context"
+
+	"github.com/jmalloc/ax"
+	"github.com/jmalloc/ax/endpoint"
+	"github.com/jmalloc/ax/persistence"
+)
+
+// TransactionalSender is an implementation of ax.Sender that saves messages to
+// the outbox within a transaction, instead of sending them immediately.
+//
+// It allows code that runs outside of a message handler, such as an HTTP
+// handler, to send messages atomically with its own changes to the data
+// store. The messages are sent by a Relay once the transaction is committed.
+// If the transaction is rolled back, the messages are never sent.
+type TransactionalSender struct {
+	Tx         persistence.Tx
+	Repository Repository
+	Validators []endpoint.Validator
+}
+
+// ExecuteCommand saves a command message to the outbox.
+//
+// If ctx contains a message envelope, m is sent as a child of the message in
+// that envelope.
+func (s *TransactionalSender) ExecuteCommand(
+	ctx context.Context,
+	m ax.Command,
+	opts ...ax.ExecuteOption,
+) (ax.Envelope, error) {
+	return s.sender().ExecuteCommand(ctx, m, opts...)
+}
+
+// PublishEvent saves an event message to the outbox.
+//
+// If ctx contains a message envelope, m is sent as a child of the message in
+// that envelope.
+func (s *TransactionalSender) PublishEvent(
+	ctx context.Context,
+	m ax.Event,
+	opts ...ax.PublishOption,
+) (ax.Envelope, error) {
+	return s.sender().PublishEvent(ctx, m, opts...)
+}
+
+// sender returns a sender that saves messages to the outbox within s.Tx.
+func (s *TransactionalSender) sender() endpoint.SinkSender {
+	return endpoint.SinkSender{
+		Sink:       transactionalSink{s},
+		Validators: s.Validators,
+	}
+}
+
+// transactionalSink is a message sink that saves messages to the outbox.
+type transactionalSink struct {
+	sender *TransactionalSender
+}
+
+// Accept saves env to the outbox.
+func (s transactionalSink) Accept(ctx context.Context, env endpoint.OutboundEnvelope) error {
+	return s.sender.Repository.SaveMessages(
+		ctx,
+		s.sender.Tx,
+		[]endpoint.OutboundEnvelope{env},
+	)
+}
@@ -0,0 +1,82 @@
+package outbox_test
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jmalloc/ax/axtest/mocks"
+	"github.com/jmalloc/ax/axtest/testmessages"
+	"github.com/jmalloc/ax/endpoint"
+	. "github.com/jmalloc/ax/outbox"
+	"github.com/jmalloc/ax/persistence"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("TransactionalSender", func() {
+	var (
+		tx     *mocks.TxMock
+		repo   *repository
+		sender *TransactionalSender
+	)
+
+	BeforeEach(func() {
+		tx = &mocks.TxMock{}
+		repo = &repository{}
+		sender = &TransactionalSender{
+			Tx:         tx,
+			Repository: repo,
+		}
+	})
+
+	Describe("ExecuteCommand", func() {
+		It("saves a unicast message to the outbox within the transaction", func() {
+			env, err := sender.ExecuteCommand(context.Background(), &testmessages.Command{})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(repo.tx).To(BeIdenticalTo(tx))
+			Expect(repo.envs).To(HaveLen(1))
+			Expect(repo.envs[0].Envelope).To(Equal(env))
+			Expect(repo.envs[0].Operation).To(Equal(endpoint.OpSendUnicast))
+		})
+
+		It("returns an error if the message can not be saved", func() {
+			repo.err = errors.New("<error>")
+
+			_, err := sender.ExecuteCommand(context.Background(), &testmessages.Command{})
+			Expect(err).To(MatchError("<error>"))
+		})
+	})
+
+	Describe("PublishEvent", func() {
+		It("saves a multicast message to the outbox within the transaction", func() {
+			env, err := sender.PublishEvent(context.Background(), &testmessages.Event{})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(repo.tx).To(BeIdenticalTo(tx))
+			Expect(repo.envs).To(HaveLen(1))
+			Expect(repo.envs[0].Envelope).To(Equal(env))
+			Expect(repo.envs[0].Operation).To(Equal(endpoint.OpSendMulticast))
+		})
+	})
+})
+
+// repository is a Repository that records the messages passed to
+// SaveMessages(). Calls to other methods panic.
+type repository struct {
+	Repository
+
+	tx   persistence.Tx
+	envs []endpoint.OutboundEnvelope
+	err  error
+}
+
+func (r *repository) SaveMessages(
+	_ context.Context,
+	tx persistence.Tx,
+	envs []endpoint.OutboundEnvelope,
+) error {
+	r.tx = tx
+	r.envs = append(r.envs, envs...)
+	return r.err
+}
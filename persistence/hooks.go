@@ -0,0 +1,80 @@
+package persistence
+
+import "sync"
+
+// Hooks is a set of functions that are called when a transaction ends.
+//
+// It is intended to be embedded within implementations of Tx to provide the
+// AfterCommit() and AfterRollback() methods. The zero-value is ready to use.
+type Hooks struct {
+	m        sync.Mutex
+	done     bool
+	commit   []func()
+	rollback []func()
+}
+
+// AfterCommit registers fn to be called after the transaction is committed.
+//
+// fn is not called if the transaction is rolled back.
+func (h *Hooks) AfterCommit(fn func()) {
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	h.commit = append(h.commit, fn)
+}
+
+// AfterRollback registers fn to be called after the transaction is rolled
+// back, including when the transaction is rolled back because the commit
+// failed.
+//
+// fn is not called if the transaction is committed.
+func (h *Hooks) AfterRollback(fn func()) {
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	h.rollback = append(h.rollback, fn)
+}
+
+// Committed calls the functions registered with AfterCommit(), in the order
+// that they were registered.
+//
+// It must be called by the Tx implementation after the transaction has been
+// committed. It has no effect if the hooks have already been run.
+func (h *Hooks) Committed() {
+	for _, fn := range h.take(true) {
+		fn()
+	}
+}
+
+// RolledBack calls the functions registered with AfterRollback(), in the
+// order that they were registered.
+//
+// It must be called by the Tx implementation after the transaction has been
+// rolled back. It has no effect if the hooks have already been run.
+func (h *Hooks) RolledBack() {
+	for _, fn := range h.take(false) {
+		fn()
+	}
+}
+
+// take returns the functions that should be called when the transaction is
+// committed or rolled back, and marks the hooks as done.
+func (h *Hooks) take(commit bool) []func() {
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	if h.done {
+		return nil
+	}
+
+	fns := h.rollback
+	if commit {
+		fns = h.commit
+	}
+
+	h.done = true
+	h.commit = nil
+	h.rollback = nil
+
+	return fns
+}
@@ -0,0 +1,56 @@
+package persistence_test
+
+import (
+	. "github.com/jmalloc/ax/persistence"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Hooks", func() {
+	var (
+		hooks *Hooks
+		calls []string
+	)
+
+	BeforeEach(func() {
+		hooks = &Hooks{}
+		calls = nil
+
+		hooks.AfterCommit(func() { calls = append(calls, "commit-1") })
+		hooks.AfterRollback(func() { calls = append(calls, "rollback-1") })
+		hooks.AfterCommit(func() { calls = append(calls, "commit-2") })
+		hooks.AfterRollback(func() { calls = append(calls, "rollback-2") })
+	})
+
+	Describe("Committed", func() {
+		It("calls the after-commit hooks in the order they were registered", func() {
+			hooks.Committed()
+
+			Expect(calls).To(Equal([]string{"commit-1", "commit-2"}))
+		})
+
+		It("does not call the hooks more than once", func() {
+			hooks.Committed()
+			hooks.Committed()
+			hooks.RolledBack()
+
+			Expect(calls).To(Equal([]string{"commit-1", "commit-2"}))
+		})
+	})
+
+	Describe("RolledBack", func() {
+		It("calls the after-rollback hooks in the order they were registered", func() {
+			hooks.RolledBack()
+
+			Expect(calls).To(Equal([]string{"rollback-1", "rollback-2"}))
+		})
+
+		It("does not call the hooks more than once", func() {
+			hooks.RolledBack()
+			hooks.RolledBack()
+			hooks.Committed()
+
+			Expect(calls).To(Equal([]string{"rollback-1", "rollback-2"}))
+		})
+	})
+})
@@ -64,6 +64,17 @@ func (i *OutboundInjector) Accept(
 	return i.Next.Accept(ctx, env)
 }
 
+// AcceptBatch calls i.Next.AcceptBatch() with a context derived from ctx and
+// containing i.DataStore. If i.Next does not accept batches, i.Next.Accept()
+// is called for each message instead.
+func (i *OutboundInjector) AcceptBatch(
+	ctx context.Context,
+	envs []endpoint.OutboundEnvelope,
+) error {
+	ctx = WithDataStore(ctx, i.DataStore)
+	return endpoint.AcceptBatch(ctx, i.Next, envs)
+}
+
 func traceInject(ctx context.Context, ps interface{}, ds DataStore) {
 	tracing.LogEvent(
 		ctx,
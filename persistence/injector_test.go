@@ -98,4 +98,22 @@ var _ = Describe("OutboundInjector", func() {
 			Expect(next.AcceptCalls()).To(HaveLen(1))
 		})
 	})
+
+	Describe("AcceptBatch", func() {
+		It("calls the next pipeline with the data store in the context", func() {
+			next.AcceptFunc = func(ctx context.Context, _ endpoint.OutboundEnvelope) error {
+				ds, ok := GetDataStore(ctx)
+				Expect(ok).To(BeTrue())
+				Expect(ds).To(Equal(inj.DataStore))
+				return nil
+			}
+
+			inj.AcceptBatch(
+				context.Background(),
+				[]endpoint.OutboundEnvelope{{}, {}},
+			)
+
+			Expect(next.AcceptCalls()).To(HaveLen(2))
+		})
+	})
 })
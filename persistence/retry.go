@@ -0,0 +1,123 @@
+package persistence
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// TransientErrorClassifier is an interface for data stores that can identify
+// errors caused by transient conditions, such as deadlocks, that are likely to
+// be resolved by retrying the transaction.
+type TransientErrorClassifier interface {
+	// IsTransientError returns true if err is caused by a transient condition.
+	IsTransientError(err error) bool
+}
+
+// TransientError is an interface for errors that identify themselves as being
+// caused by transient conditions, independently of the data store in use.
+//
+// For example, an optimistic concurrency conflict is likely to be resolved by
+// retrying the transaction, as the retry observes the conflicting change.
+type TransientError interface {
+	error
+
+	// IsTransient returns true if the error is caused by a transient condition.
+	IsTransient() bool
+}
+
+var (
+	// DefaultMaxAttempts is the maximum number of times that Atomically()
+	// attempts a unit-of-work that fails because of a transient error.
+	DefaultMaxAttempts = 5
+
+	// DefaultRetryDelay is the duration to wait before the first retry of a
+	// unit-of-work that fails because of a transient error. The delay is
+	// doubled for each subsequent retry.
+	DefaultRetryDelay = 10 * time.Millisecond
+)
+
+// Atomically executes fn within a new transaction, committing the transaction
+// if fn returns nil.
+//
+// The transaction is started using the data store in ctx. If fn or the commit
+// fails with an error that the data store classifies as transient, the
+// transaction is rolled back and fn is called again within a new transaction,
+// up to DefaultMaxAttempts times.
+//
+// The context passed to fn contains the transaction. fn must not have side
+// effects outside of the transaction that are unsafe to repeat.
+func Atomically(
+	ctx context.Context,
+	fn func(ctx context.Context, tx Tx) error,
+) error {
+	ds, ok := GetDataStore(ctx)
+	if !ok {
+		return errNoDataStore
+	}
+
+	delay := DefaultRetryDelay
+
+	for n := 1; ; n++ {
+		err := attempt(ctx, ds, fn)
+		if err == nil || n >= DefaultMaxAttempts || !IsTransientError(ds, err) {
+			return err
+		}
+
+		if err := sleep(ctx, delay); err != nil {
+			return err
+		}
+
+		delay *= 2
+	}
+}
+
+// IsTransientError returns true if err is caused by a transient condition.
+//
+// err is transient if it is, or wraps, a TransientError that reports itself as
+// transient, or if ds implements TransientErrorClassifier and classifies err as
+// transient.
+func IsTransientError(ds DataStore, err error) bool {
+	var e TransientError
+	if errors.As(err, &e) && e.IsTransient() {
+		return true
+	}
+
+	if c, ok := ds.(TransientErrorClassifier); ok {
+		return c.IsTransientError(err)
+	}
+
+	return false
+}
+
+// attempt executes fn within a new transaction on ds.
+func attempt(
+	ctx context.Context,
+	ds DataStore,
+	fn func(ctx context.Context, tx Tx) error,
+) error {
+	tx, com, err := ds.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer com.Rollback()
+
+	if err := fn(WithTx(ctx, tx), tx); err != nil {
+		return err
+	}
+
+	return com.Commit()
+}
+
+// sleep blocks until ctx is canceled or the given duration elapses.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,191 @@
+package persistence_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmalloc/ax/axtest/mocks"
+	. "github.com/jmalloc/ax/persistence"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Atomically", func() {
+	var (
+		tx    *mocks.TxMock
+		com   *mocks.CommitterMock
+		ds    *transientDataStore
+		ctx   context.Context
+		delay time.Duration
+	)
+
+	BeforeEach(func() {
+		tx = &mocks.TxMock{}
+		com = &mocks.CommitterMock{
+			CommitFunc:   func() error { return nil },
+			RollbackFunc: func() error { return nil },
+		}
+		ds = &transientDataStore{
+			DataStoreMock: mocks.DataStoreMock{
+				BeginTxFunc: func(context.Context) (Tx, Committer, error) {
+					return tx, com, nil
+				},
+			},
+		}
+		ctx = WithDataStore(context.Background(), ds)
+
+		delay = DefaultRetryDelay
+		DefaultRetryDelay = time.Microsecond
+	})
+
+	AfterEach(func() {
+		DefaultRetryDelay = delay
+	})
+
+	It("calls fn with the transaction in the context", func() {
+		err := Atomically(ctx, func(ctx context.Context, t Tx) error {
+			Expect(t).To(BeIdenticalTo(tx))
+
+			x, ok := GetTx(ctx)
+			Expect(ok).To(BeTrue())
+			Expect(x).To(BeIdenticalTo(tx))
+
+			return nil
+		})
+
+		Expect(err).ShouldNot(HaveOccurred())
+	})
+
+	It("commits the transaction if fn succeeds", func() {
+		err := Atomically(ctx, func(context.Context, Tx) error {
+			return nil
+		})
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(com.CommitCalls()).To(HaveLen(1))
+	})
+
+	It("rolls back the transaction and returns the error if fn fails", func() {
+		expected := errors.New("<error>")
+
+		err := Atomically(ctx, func(context.Context, Tx) error {
+			return expected
+		})
+
+		Expect(err).To(Equal(expected))
+		Expect(com.CommitCalls()).To(BeEmpty())
+		Expect(com.RollbackCalls()).To(HaveLen(1))
+		Expect(ds.BeginTxCalls()).To(HaveLen(1))
+	})
+
+	It("retries fn if it fails with a transient error", func() {
+		n := 0
+
+		err := Atomically(ctx, func(context.Context, Tx) error {
+			n++
+			if n < 3 {
+				return errTransient
+			}
+			return nil
+		})
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(n).To(Equal(3))
+		Expect(ds.BeginTxCalls()).To(HaveLen(3))
+		Expect(com.CommitCalls()).To(HaveLen(1))
+	})
+
+	It("retries fn if the commit fails with a transient error", func() {
+		com.CommitFunc = func() error {
+			com.CommitFunc = func() error { return nil }
+			return errTransient
+		}
+
+		err := Atomically(ctx, func(context.Context, Tx) error {
+			return nil
+		})
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(ds.BeginTxCalls()).To(HaveLen(2))
+	})
+
+	It("returns the transient error after the maximum number of attempts", func() {
+		err := Atomically(ctx, func(context.Context, Tx) error {
+			return errTransient
+		})
+
+		Expect(err).To(Equal(errTransient))
+		Expect(ds.BeginTxCalls()).To(HaveLen(DefaultMaxAttempts))
+	})
+
+	It("does not retry transient errors if the data store does not classify errors", func() {
+		ctx = WithDataStore(ctx, &ds.DataStoreMock)
+
+		err := Atomically(ctx, func(context.Context, Tx) error {
+			return errTransient
+		})
+
+		Expect(err).To(Equal(errTransient))
+		Expect(ds.BeginTxCalls()).To(HaveLen(1))
+	})
+
+	It("retries fn if it fails with an error that reports itself as transient", func() {
+		ctx = WithDataStore(ctx, &ds.DataStoreMock)
+		n := 0
+
+		err := Atomically(ctx, func(context.Context, Tx) error {
+			n++
+			if n < 3 {
+				return fmt.Errorf("<wrapped>: %w", selfTransientError{})
+			}
+			return nil
+		})
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(n).To(Equal(3))
+	})
+
+	It("returns an error if the context does not contain a data store", func() {
+		err := Atomically(context.Background(), func(context.Context, Tx) error {
+			Fail("unexpected call")
+			return nil
+		})
+
+		Expect(err).Should(HaveOccurred())
+	})
+
+	It("returns an error if the context is canceled while waiting to retry", func() {
+		DefaultRetryDelay = time.Hour
+
+		ctx, cancel := context.WithCancel(ctx)
+
+		err := Atomically(ctx, func(context.Context, Tx) error {
+			cancel()
+			return errTransient
+		})
+
+		Expect(err).To(Equal(context.Canceled))
+	})
+})
+
+// errTransient is an error that is classified as transient by
+// transientDataStore.
+var errTransient = errors.New("<transient>")
+
+// transientDataStore is a data store that classifies errTransient as a
+// transient error.
+type transientDataStore struct {
+	mocks.DataStoreMock
+}
+
+func (ds *transientDataStore) IsTransientError(err error) bool {
+	return err == errTransient
+}
+
+// selfTransientError is an error that reports itself as transient.
+type selfTransientError struct{}
+
+func (selfTransientError) Error() string     { return "<self-transient>" }
+func (selfTransientError) IsTransient() bool { return true }
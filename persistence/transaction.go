@@ -9,6 +9,19 @@ import (
 type Tx interface {
 	// DataStore returns the DataStore that the transaction operates on.
 	DataStore() DataStore
+
+	// AfterCommit registers fn to be called after the transaction is
+	// committed.
+	//
+	// fn is not called if the transaction is rolled back.
+	AfterCommit(fn func())
+
+	// AfterRollback registers fn to be called after the transaction is rolled
+	// back, including when the transaction is rolled back because the commit
+	// failed.
+	//
+	// fn is not called if the transaction is committed.
+	AfterRollback(fn func())
 }
 
 // Committer is an interface used to commit and rollback persistence
@@ -57,6 +70,11 @@ func GetTx(ctx context.Context) (tx Tx, ok bool) {
 // the transaction, but is not responsible for committing. In this case, the
 // returned Committer is configured such that Commit() and Rollback() are
 // no-ops that always return nil.
+//
+// Functions registered with the transaction's AfterCommit() and
+// AfterRollback() methods are called when the owner commits or rolls back the
+// transaction, regardless of whether they were registered by the owner or by a
+// participant.
 func GetOrBeginTx(ctx context.Context) (Tx, Committer, error) {
 	if tx, ok := GetTx(ctx); ok {
 		return tx, noOpCommitter{}, nil
@@ -71,9 +89,13 @@ func BeginTx(ctx context.Context) (Tx, Committer, error) {
 		return ds.BeginTx(ctx)
 	}
 
-	return nil, nil, errors.New("can not begin transaction, no data store is available in ctx")
+	return nil, nil, errNoDataStore
 }
 
+// errNoDataStore is returned when a transaction is required but ctx does not
+// contain a data store.
+var errNoDataStore = errors.New("can not begin transaction, no data store is available in ctx")
+
 // noOpCommitter is an implementation of Committer has no-op commit and rollback
 // operations.
 type noOpCommitter struct{}
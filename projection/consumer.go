@@ -2,6 +2,8 @@ package projection
 
 import (
 	"context"
+	"fmt"
+	"time"
 
 	"github.com/jmalloc/ax"
 	"github.com/jmalloc/ax/messagestore"
@@ -11,97 +13,605 @@ import (
 	opentracing "github.com/opentracing/opentracing-go"
 )
 
+const (
+	// DefaultConsumerBatchSize is the default maximum number of messages that a
+	// GlobalStoreConsumer applies to the projector within a single transaction.
+	DefaultConsumerBatchSize = 100
+
+	// DefaultConsumerBatchDuration is the default maximum duration of each
+	// transaction in which a GlobalStoreConsumer applies messages to the
+	// projector.
+	DefaultConsumerBatchDuration = 1 * time.Second
+)
+
 // GlobalStoreConsumer reads messages from all streams in a message store and
 // forwards them to an application-defined projector to produce a projection.
+//
+// If Category is non-empty, only messages in that category are read, as per
+// messagestore.StreamCategory(). The stored offset is a global offset in either
+// case.
+//
+// Multiple consumers may run for the same projector, such as one in each
+// replica of a service. If the stored offset is changed by another consumer,
+// or the projection is reset, the consumer resumes reading from the stored
+// offset.
+//
+// If the projector implements VersionedProjector, each version of the
+// projector is consumed independently, so a new version is built from the
+// beginning of the store while consumers for the previous version continue to
+// run.
+//
+// Messages are applied to the projector in batches. Each batch is applied
+// within a single transaction, which also advances the stored offset past the
+// last message in the batch. A batch contains at most BatchSize messages, and
+// is committed once BatchDuration has elapsed, even if it is not full. Batches
+// are also committed whenever the consumer reaches the end of the store, so
+// the consumer does not wait for more messages while a transaction is open.
+//
+// If the projector fails to apply a message and ErrorPolicy is nil, the
+// consumer halts without applying any of the messages in the batch. Otherwise,
+// the messages before the failed message are applied in a batch of their own,
+// then the action returned by ErrorPolicy is taken. Messages can only be
+// parked if ParkedMessages is non-nil, otherwise the consumer halts instead.
 type GlobalStoreConsumer struct {
-	Projector    Projector
-	DataStore    persistence.DataStore
-	MessageStore messagestore.GloballyOrderedStore
-	Offsets      OffsetStore
-	Logger       twelf.Logger
+	Projector      Projector
+	DataStore      persistence.DataStore
+	MessageStore   messagestore.GloballyOrderedStore
+	Offsets        OffsetStore
+	Logger         twelf.Logger
+	Category       string
+	BatchSize      int
+	BatchDuration  time.Duration
+	ErrorPolicy    ErrorPolicy
+	ParkedMessages ParkedMessageStore
 
-	key    string
-	types  ax.MessageTypeSet
-	stream messagestore.Stream
+	key       string
+	version   uint64
+	types     ax.MessageTypeSet
+	stream    messagestore.Stream
+	offset    uint64
+	activated bool
+	limit     int
+	attempts  uint
 }
 
 // Consume reads messages from the store and forwards them to the projector until
 // an error occurs or ctx is canceled.
+//
+// The projector's version is activated once the consumer reaches the end of
+// the store, unless a later version is already active.
 func (c *GlobalStoreConsumer) Consume(ctx context.Context) error {
-	c.key = c.Projector.PersistenceKey()
-	c.types = c.Projector.MessageTypes()
+	c.init()
+	defer c.close()
+
+	ctx = persistence.WithDataStore(ctx, c.DataStore)
+
+	for {
+		if err := c.open(ctx); err != nil {
+			return err
+		}
+
+		ok, err := c.stream.TryNext(ctx)
+		if err == nil && !ok {
+			// the consumer has caught up, activate its version before
+			// waiting for more messages.
+			if err = c.activate(ctx); err == nil {
+				err = c.stream.Next(ctx)
+			}
+		}
+
+		if err == nil {
+			err = c.processBatch(ctx)
+		}
+
+		if err != nil && !c.handleConflict(err) {
+			return err
+		}
+	}
+}
+
+// Reset discards the state produced by the projector and rewinds its offset to
+// the beginning of the store, within a single transaction.
+//
+// If the projector implements Resetter, its ResetProjection() method is called
+// to discard its state. Otherwise, only the offset is reset. Any parked
+// messages are discarded, as they are applied again when the projection is
+// rebuilt.
+//
+// Only the projector's current version is reset. Reset may be called while
+// consumers for the same version of the projector are running in other
+// processes. Those consumers resume reading from the beginning of the store
+// the next time they attempt to save their offset.
+func (c *GlobalStoreConsumer) Reset(ctx context.Context) error {
+	pk := c.Projector.PersistenceKey()
+	v := VersionOf(c.Projector)
+
+	return persistence.Atomically(
+		persistence.WithDataStore(ctx, c.DataStore),
+		func(ctx context.Context, tx persistence.Tx) error {
+			// the offset is reset first, so that any consumer that is part way
+			// through a batch either commits before the projection is discarded,
+			// or fails to save its offset once the reset is committed.
+			if err := c.Offsets.ResetOffset(ctx, tx, pk, v); err != nil {
+				return err
+			}
+
+			if r, ok := c.Projector.(Resetter); ok {
+				if err := r.ResetProjection(ctx); err != nil {
+					return err
+				}
+			}
+
+			if c.ParkedMessages != nil {
+				return c.ParkedMessages.ResetParkedMessages(ctx, tx, pk, v)
+			}
+
+			return nil
+		},
+	)
+}
+
+// LoadParkedMessages returns the messages that have been parked for the
+// projector's current version, in order of their offset.
+func (c *GlobalStoreConsumer) LoadParkedMessages(ctx context.Context) ([]ParkedMessage, error) {
+	if c.ParkedMessages == nil {
+		return nil, nil
+	}
+
+	return c.ParkedMessages.LoadParkedMessages(
+		ctx,
+		c.DataStore,
+		c.Projector.PersistenceKey(),
+		VersionOf(c.Projector),
+	)
+}
+
+// RetryParkedMessage forwards the parked message at offset o to the projector
+// and removes it from the parked messages, within a single transaction.
+//
+// The message is applied after any messages that were applied since it was
+// parked, so the projector must tolerate it being applied out of order. If the
+// projector fails to apply the message, the error is returned and the message
+// remains parked.
+func (c *GlobalStoreConsumer) RetryParkedMessage(ctx context.Context, o uint64) error {
+	if c.ParkedMessages == nil {
+		return fmt.Errorf("there is no parked message at offset %d", o)
+	}
+
+	pk := c.Projector.PersistenceKey()
+	v := VersionOf(c.Projector)
+
+	ctx = persistence.WithDataStore(ctx, c.DataStore)
+
+	s, err := c.MessageStore.OpenGlobal(ctx, c.DataStore, o, c.Projector.MessageTypes())
+	if err != nil {
+		return err
+	}
+	defer s.Close()
 
-	o, err := c.Offsets.LoadOffset(ctx, c.DataStore, c.key)
+	ok, err := s.TryNext(ctx)
 	if err != nil {
 		return err
 	}
 
-	c.stream, err = c.MessageStore.OpenGlobal(ctx, c.DataStore, o)
+	if ok {
+		var x uint64
+		x, err = s.Offset()
+		if err != nil {
+			return err
+		}
+
+		ok = x == o
+	}
+
+	if !ok {
+		return fmt.Errorf("the message at offset %d is no longer in the message store", o)
+	}
+
+	m, err := s.GetStored(ctx)
 	if err != nil {
 		return err
 	}
-	defer c.stream.Close()
+
+	return persistence.Atomically(
+		ctx,
+		func(ctx context.Context, tx persistence.Tx) error {
+			ok, err := c.ParkedMessages.UnparkMessage(ctx, tx, pk, v, o)
+			if err != nil {
+				return err
+			}
+
+			if !ok {
+				return fmt.Errorf("there is no parked message at offset %d", o)
+			}
+
+			return c.apply(ctx, m)
+		},
+	)
+}
+
+// Rebuild resets the projection, as per Reset(), then forwards the messages in
+// the store to the projector until it reaches the end of the store, or an
+// error occurs. The projector's version is then activated, as per Consume().
+//
+// Messages that are appended while the projection is being rebuilt may not be
+// applied before Rebuild() returns. They are applied by Consume().
+func (c *GlobalStoreConsumer) Rebuild(ctx context.Context) error {
+	c.init()
+	defer c.close()
+
+	if err := c.Reset(ctx); err != nil {
+		return err
+	}
 
 	ctx = persistence.WithDataStore(ctx, c.DataStore)
 
 	for {
-		err = c.processNextMessage(ctx)
-		if err != nil {
+		if err := c.open(ctx); err != nil {
+			return err
+		}
+
+		ok, err := c.stream.TryNext(ctx)
+		if err == nil {
+			if !ok {
+				return c.activate(ctx)
+			}
+
+			err = c.processBatch(ctx)
+		}
+
+		if err != nil && !c.handleConflict(err) {
 			return err
 		}
 	}
 }
 
-func (c *GlobalStoreConsumer) processNextMessage(ctx context.Context) error {
-	err := c.stream.Next(ctx)
+// init prepares the consumer to read messages.
+func (c *GlobalStoreConsumer) init() {
+	c.key = c.Projector.PersistenceKey()
+	c.version = VersionOf(c.Projector)
+	c.types = c.Projector.MessageTypes()
+	c.activated = false
+	c.limit = 0
+	c.attempts = 0
+}
+
+// activate activates the projector's version, if it has not already been
+// activated by this consumer.
+func (c *GlobalStoreConsumer) activate(ctx context.Context) error {
+	if c.activated {
+		return nil
+	}
+
+	err := persistence.Atomically(
+		ctx,
+		func(ctx context.Context, tx persistence.Tx) error {
+			_, err := c.Offsets.ActivateVersion(ctx, tx, c.key, c.version)
+			return err
+		},
+	)
 	if err != nil {
 		return err
 	}
 
-	env, err := c.stream.Get(ctx)
+	// the version is not activated again even if a later version is active,
+	// so that consumers for different versions do not repeatedly activate
+	// their own version.
+	c.activated = true
+
+	return nil
+}
+
+// open opens the stream from the stored offset, if it is not already open.
+func (c *GlobalStoreConsumer) open(ctx context.Context) error {
+	if c.stream != nil {
+		return nil
+	}
+
+	var err error
+	c.offset, err = c.Offsets.LoadOffset(ctx, c.DataStore, c.key, c.version)
 	if err != nil {
 		return err
 	}
 
-	tx, com, err := persistence.GetOrBeginTx(ctx)
-	if err != nil {
+	// only messages that the projector handles are read from the store, the
+	// stored offset is advanced past any messages that are skipped.
+	if c.Category == "" {
+		c.stream, err = c.MessageStore.OpenGlobal(ctx, c.DataStore, c.offset, c.types)
+	} else {
+		c.stream, err = c.MessageStore.OpenCategory(ctx, c.DataStore, c.Category, c.offset, c.types)
+	}
+
+	return err
+}
+
+// close closes the stream, if it is open.
+func (c *GlobalStoreConsumer) close() {
+	if c.stream != nil {
+		c.stream.Close()
+		c.stream = nil
+	}
+}
+
+// handleConflict closes the stream if err indicates that the stored offset was
+// changed by some other consumer, or by a reset, so that it is re-opened from
+// the stored offset. It returns false if err is any other error.
+func (c *GlobalStoreConsumer) handleConflict(err error) bool {
+	if !IsConflict(err) {
+		return false
+	}
+
+	c.close()
+
+	return true
+}
+
+// processBatch forwards the message at the current offset of the stream, and
+// any subsequent messages that are immediately available, to the projector
+// within a single transaction. The stored offset is advanced past the last
+// message in the batch.
+//
+// The batch ends when it contains c.BatchSize messages, when c.BatchDuration
+// has elapsed since the batch began, or when no more messages are available.
+// If an error occurs, none of the messages in the batch are applied.
+func (c *GlobalStoreConsumer) processBatch(ctx context.Context) error {
+	size := c.BatchSize
+	if size == 0 {
+		size = DefaultConsumerBatchSize
+	}
+
+	if c.limit != 0 && c.limit < size {
+		size = c.limit
+	}
+
+	d := c.BatchDuration
+	if d == 0 {
+		d = DefaultConsumerBatchDuration
+	}
+
+	var (
+		next     uint64
+		attempts int
+		failure  *applyError
+	)
+
+	err := persistence.Atomically(
+		ctx,
+		func(ctx context.Context, tx persistence.Tx) error {
+			// if the transaction is retried the stream is re-opened, so that the
+			// batch begins again with its first message.
+			attempts++
+			failure = nil
+
+			if attempts > 1 {
+				c.close()
+
+				if err := c.open(ctx); err != nil {
+					return err
+				}
+
+				ok, err := c.stream.TryNext(ctx)
+				if err != nil || !ok {
+					next = c.offset
+					return err
+				}
+			}
+
+			deadline := time.Now().Add(d)
+
+			for n := 1; ; n++ {
+				o, err := c.applyCurrentMessage(ctx, n)
+				if e, ok := err.(*applyError); ok {
+					// the projector's error is returned unwrapped so that it
+					// can be classified as transient by the data store.
+					failure = e
+					return e.Err
+				} else if err != nil {
+					return err
+				}
+
+				next = o + 1
+
+				if n >= size || !time.Now().Before(deadline) {
+					break
+				}
+
+				ok, err := c.stream.TryNext(ctx)
+				if err != nil {
+					return err
+				}
+
+				if !ok {
+					break
+				}
+			}
+
+			return c.Offsets.SaveOffset(
+				ctx,
+				tx,
+				c.key,
+				c.version,
+				c.offset,
+				next,
+			)
+		},
+	)
+	if failure != nil && err != nil {
+		return c.handleApplyError(ctx, failure)
+	} else if err != nil {
 		return err
 	}
-	defer com.Rollback()
 
-	if c.types.Has(env.Type()) {
-		mctx := ax.NewMessageContext(
-			env,
-			opentracing.SpanFromContext(ctx),
-			observability.NewProjectionLogger(
-				c.Logger,
-				env,
-			),
-		)
+	c.offset = next
+	c.limit = 0
+	c.attempts = 0
 
-		err = c.Projector.ApplyMessage(
-			persistence.WithTx(ctx, tx),
-			mctx,
-		)
-		if err != nil {
-			return err
-		}
+	return nil
+}
+
+// applyCurrentMessage forwards the message at the current offset of the stream
+// to the projector. It returns the offset of the message.
+//
+// n is the position of the message within the current batch. If the projector
+// fails to apply the message, the error is an *applyError.
+func (c *GlobalStoreConsumer) applyCurrentMessage(ctx context.Context, n int) (uint64, error) {
+	m, err := c.stream.GetStored(ctx)
+	if err != nil {
+		return 0, err
 	}
 
 	o, err := c.stream.Offset()
 	if err != nil {
-		return err
+		return 0, err
+	}
+
+	if c.types.Has(m.Envelope.Type()) {
+		if err := c.apply(ctx, m); err != nil {
+			return 0, &applyError{err, m, o, n}
+		}
 	}
 
-	err = c.Offsets.IncrementOffset(
+	return o, nil
+}
+
+// apply forwards m to the projector.
+func (c *GlobalStoreConsumer) apply(ctx context.Context, m messagestore.StoredMessage) error {
+	env := m.Envelope
+
+	mctx := ax.NewMessageContext(
+		env,
+		opentracing.SpanFromContext(ctx),
+		observability.NewProjectionLogger(
+			c.Logger,
+			env,
+		),
+	)
+
+	return c.Projector.ApplyMessage(
+		messagestore.WithStoredMessage(ctx, m),
+		mctx,
+	)
+}
+
+// handleApplyError takes the action dictated by the consumer's error policy
+// when the projector fails to apply a message.
+func (c *GlobalStoreConsumer) handleApplyError(ctx context.Context, e *applyError) error {
+	if c.ErrorPolicy == nil {
+		return e.Err
+	}
+
+	// the messages that precede the failed message in the batch are applied
+	// again in a batch of their own, so that the error policy only applies to
+	// the failed message.
+	if e.Position > 1 {
+		c.limit = e.Position - 1
+		c.close()
+		return nil
+	}
+
+	c.attempts++
+	a, d := c.ErrorPolicy(e.Message.Envelope, e.Err, c.attempts)
+
+	switch a {
+	case Retry:
+		observability.NewProjectionLogger(c.Logger, e.Message.Envelope).Log(
+			"retrying message at offset %d in %s after %d attempt(s): %s",
+			e.Offset,
+			d,
+			c.attempts,
+			e.Err,
+		)
+
+		c.close()
+		return sleep(ctx, d)
+	case Park:
+		if c.ParkedMessages != nil {
+			return c.park(ctx, e)
+		}
+	}
+
+	return e.Err
+}
+
+// park skips the message that caused e, and records it in the parked message
+// store, within a single transaction.
+func (c *GlobalStoreConsumer) park(ctx context.Context, e *applyError) error {
+	env := e.Message.Envelope
+
+	err := persistence.Atomically(
 		ctx,
-		tx,
-		c.key,
-		o,
+		func(ctx context.Context, tx persistence.Tx) error {
+			if err := c.ParkedMessages.ParkMessage(
+				ctx,
+				tx,
+				c.key,
+				c.version,
+				ParkedMessage{
+					Offset:      e.Offset,
+					MessageID:   env.MessageID,
+					MessageType: env.Type().Name,
+					Error:       e.Err.Error(),
+					ParkedAt:    time.Now(),
+				},
+			); err != nil {
+				return err
+			}
+
+			return c.Offsets.SaveOffset(
+				ctx,
+				tx,
+				c.key,
+				c.version,
+				c.offset,
+				e.Offset+1,
+			)
+		},
 	)
 	if err != nil {
 		return err
 	}
 
-	return com.Commit()
+	observability.NewProjectionLogger(c.Logger, env).Log(
+		"parked message at offset %d after %d attempt(s): %s",
+		e.Offset,
+		c.attempts,
+		e.Err,
+	)
+
+	c.offset = e.Offset + 1
+	c.attempts = 0
+
+	return nil
+}
+
+// applyError is an error returned by the projector when applying a message.
+type applyError struct {
+	// Err is the error returned by the projector.
+	Err error
+
+	// Message is the message that could not be applied.
+	Message messagestore.StoredMessage
+
+	// Offset is the offset of the message.
+	Offset uint64
+
+	// Position is the 1-based position of the message within its batch.
+	Position int
+}
+
+func (e *applyError) Error() string {
+	return e.Err.Error()
+}
+
+// sleep blocks until ctx is canceled or the given duration elapses.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
@@ -0,0 +1,780 @@
+package projection_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/jmalloc/ax"
+	"github.com/jmalloc/ax/axmemory"
+	"github.com/jmalloc/ax/axtest/testmessages"
+	"github.com/jmalloc/ax/persistence"
+	. "github.com/jmalloc/ax/projection"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// testProjector is a projector that records the messages applied to it when
+// the transaction that applied them is committed.
+type testProjector struct {
+	m        sync.Mutex
+	messages []ax.MessageID
+	resets   int
+}
+
+func (p *testProjector) PersistenceKey() string {
+	return "<projector>"
+}
+
+func (p *testProjector) MessageTypes() ax.MessageTypeSet {
+	return ax.TypesOf(&testmessages.MessageA{})
+}
+
+func (p *testProjector) ApplyMessage(ctx context.Context, mctx ax.MessageContext) error {
+	tx, _ := persistence.GetTx(ctx)
+	tx.AfterCommit(func() {
+		p.m.Lock()
+		defer p.m.Unlock()
+
+		p.messages = append(p.messages, mctx.Envelope.MessageID)
+	})
+
+	return nil
+}
+
+func (p *testProjector) ResetProjection(ctx context.Context) error {
+	tx, _ := persistence.GetTx(ctx)
+	tx.AfterCommit(func() {
+		p.m.Lock()
+		defer p.m.Unlock()
+
+		p.messages = nil
+		p.resets++
+	})
+
+	return nil
+}
+
+func (p *testProjector) applied() []ax.MessageID {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	return append([]ax.MessageID(nil), p.messages...)
+}
+
+// nonResettingProjector is a projector that does not implement Resetter.
+type nonResettingProjector struct {
+	p *testProjector
+}
+
+func (p nonResettingProjector) PersistenceKey() string {
+	return p.p.PersistenceKey()
+}
+
+func (p nonResettingProjector) MessageTypes() ax.MessageTypeSet {
+	return p.p.MessageTypes()
+}
+
+func (p nonResettingProjector) ApplyMessage(ctx context.Context, mctx ax.MessageContext) error {
+	return p.p.ApplyMessage(ctx, mctx)
+}
+
+// versionedProjector is a projector that implements VersionedProjector.
+type versionedProjector struct {
+	*testProjector
+	version uint64
+}
+
+func (p versionedProjector) ProjectionVersion() uint64 {
+	return p.version
+}
+
+// failingProjector is a projector that fails to apply a specific message.
+type failingProjector struct {
+	*testProjector
+	id ax.MessageID
+}
+
+func (p failingProjector) ApplyMessage(ctx context.Context, mctx ax.MessageContext) error {
+	if mctx.Envelope.MessageID == p.id {
+		return errors.New("<error>")
+	}
+
+	return p.testProjector.ApplyMessage(ctx, mctx)
+}
+
+// blockingProjector is a projector that blocks the first time it applies a
+// specific message, until release is closed.
+type blockingProjector struct {
+	*testProjector
+	id      ax.MessageID
+	once    *sync.Once
+	reached chan struct{}
+	release chan struct{}
+}
+
+func (p blockingProjector) ApplyMessage(ctx context.Context, mctx ax.MessageContext) error {
+	if mctx.Envelope.MessageID == p.id {
+		p.once.Do(func() {
+			close(p.reached)
+			<-p.release
+		})
+	}
+
+	return p.testProjector.ApplyMessage(ctx, mctx)
+}
+
+// orderedResetProjector is a projector that records when its projection is
+// reset, relative to the offset.
+type orderedResetProjector struct {
+	*testProjector
+	calls *[]string
+}
+
+func (p orderedResetProjector) ResetProjection(ctx context.Context) error {
+	*p.calls = append(*p.calls, "ResetProjection")
+	return p.testProjector.ResetProjection(ctx)
+}
+
+// orderedResetOffsetStore is an offset store that records when offsets are
+// reset, relative to the projection.
+type orderedResetOffsetStore struct {
+	OffsetStore
+	calls *[]string
+}
+
+func (s orderedResetOffsetStore) ResetOffset(
+	ctx context.Context,
+	tx persistence.Tx,
+	pk string,
+	v uint64,
+) error {
+	*s.calls = append(*s.calls, "ResetOffset")
+	return s.OffsetStore.ResetOffset(ctx, tx, pk, v)
+}
+
+// transientError is an error caused by a transient condition.
+type transientError struct{}
+
+func (transientError) Error() string     { return "<transient>" }
+func (transientError) IsTransient() bool { return true }
+
+// flakyProjector is a projector that fails to apply a specific message the
+// first time it is applied.
+type flakyProjector struct {
+	*testProjector
+	id     ax.MessageID
+	err    error
+	failed *bool
+}
+
+func (p flakyProjector) ApplyMessage(ctx context.Context, mctx ax.MessageContext) error {
+	if mctx.Envelope.MessageID == p.id && !*p.failed {
+		*p.failed = true
+		return p.err
+	}
+
+	return p.testProjector.ApplyMessage(ctx, mctx)
+}
+
+var _ = Describe("GlobalStoreConsumer", func() {
+	var (
+		ctx              context.Context
+		cancel           func()
+		ds               *axmemory.DataStore
+		projector        *testProjector
+		consumer         *GlobalStoreConsumer
+		env1, env2, env3 ax.Envelope
+	)
+
+	appendMessages := func(stream string, offset uint64, envs ...ax.Envelope) {
+		tx, com, err := ds.BeginTx(ctx)
+		Expect(err).ShouldNot(HaveOccurred())
+		defer com.Rollback()
+
+		err = axmemory.MessageStore.AppendMessages(ctx, tx, stream, offset, envs)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		err = com.Commit()
+		Expect(err).ShouldNot(HaveOccurred())
+	}
+
+	loadVersionOffset := func(v uint64) uint64 {
+		o, err := axmemory.ProjectionOffsetStore.LoadOffset(ctx, ds, "<projector>", v)
+		Expect(err).ShouldNot(HaveOccurred())
+		return o
+	}
+
+	loadOffset := func() uint64 {
+		return loadVersionOffset(0)
+	}
+
+	loadActiveVersion := func() uint64 {
+		v, ok, err := axmemory.ProjectionOffsetStore.LoadActiveVersion(ctx, ds, "<projector>")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(ok).To(BeTrue())
+		return v
+	}
+
+	BeforeEach(func() {
+		var fn func()
+		ctx, fn = context.WithTimeout(context.Background(), 5*time.Second)
+		cancel = fn // defeat go vet warning about unused cancel func
+
+		ds = axmemory.NewDataStore()
+		projector = &testProjector{}
+		consumer = &GlobalStoreConsumer{
+			Projector:    projector,
+			DataStore:    ds,
+			MessageStore: axmemory.MessageStore,
+			Offsets:      axmemory.ProjectionOffsetStore,
+		}
+
+		env1 = ax.NewEnvelope(&testmessages.MessageA{Value: "<foo>"})
+		env2 = ax.NewEnvelope(&testmessages.MessageB{Value: "<bar>"})
+		env3 = ax.NewEnvelope(&testmessages.MessageA{Value: "<baz>"})
+
+		appendMessages("<stream>", 0, env1, env2, env3)
+	})
+
+	AfterEach(func() {
+		cancel()
+	})
+
+	Describe("Consume", func() {
+		It("applies messages to the projector and saves the offset", func() {
+			ctx, cancel := context.WithCancel(ctx)
+			defer cancel()
+
+			done := make(chan error, 1)
+			go func() { done <- consumer.Consume(ctx) }()
+
+			Eventually(projector.applied).Should(Equal(
+				[]ax.MessageID{env1.MessageID, env3.MessageID},
+			))
+			Eventually(loadOffset).Should(BeNumerically("==", 3))
+
+			cancel()
+			Eventually(done).Should(Receive(Equal(context.Canceled)))
+		})
+
+		It("resumes from the beginning of the store when the projection is reset", func() {
+			ctx, cancel := context.WithCancel(ctx)
+			defer cancel()
+
+			done := make(chan error, 1)
+			go func() { done <- consumer.Consume(ctx) }()
+
+			Eventually(loadOffset).Should(BeNumerically("==", 3))
+
+			// reset the projection without rewinding the running consumer's
+			// stream, as would happen if it were reset by another process.
+			other := &GlobalStoreConsumer{
+				Projector:    projector,
+				DataStore:    ds,
+				MessageStore: axmemory.MessageStore,
+				Offsets:      axmemory.ProjectionOffsetStore,
+			}
+			err := other.Reset(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			env4 := ax.NewEnvelope(&testmessages.MessageA{Value: "<qux>"})
+			appendMessages("<stream>", 3, env4)
+
+			Eventually(projector.applied).Should(Equal(
+				[]ax.MessageID{env1.MessageID, env3.MessageID, env4.MessageID},
+			))
+			Eventually(loadOffset).Should(BeNumerically("==", 4))
+			Consistently(done).ShouldNot(Receive())
+		})
+
+		It("activates the projector's version once it reaches the end of the store", func() {
+			consumer.Projector = versionedProjector{projector, 2}
+
+			ctx, cancel := context.WithCancel(ctx)
+			defer cancel()
+
+			done := make(chan error, 1)
+			go func() { done <- consumer.Consume(ctx) }()
+
+			Eventually(func() (uint64, error) {
+				v, _, err := axmemory.ProjectionOffsetStore.LoadActiveVersion(ctx, ds, "<projector>")
+				return v, err
+			}).Should(BeNumerically("==", 2))
+			Consistently(done).ShouldNot(Receive())
+
+			Expect(loadVersionOffset(2)).To(BeNumerically("==", 3))
+			Expect(projector.applied()).To(HaveLen(2))
+		})
+
+		It("builds a new version of the projection from the beginning of the store", func() {
+			err := consumer.Rebuild(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			next := &testProjector{}
+			consumer.Projector = versionedProjector{next, 1}
+
+			ctx, cancel := context.WithCancel(ctx)
+			defer cancel()
+
+			done := make(chan error, 1)
+			go func() { done <- consumer.Consume(ctx) }()
+
+			Eventually(next.applied).Should(Equal(
+				[]ax.MessageID{env1.MessageID, env3.MessageID},
+			))
+			Eventually(func() uint64 {
+				return loadVersionOffset(1)
+			}).Should(BeNumerically("==", 3))
+			Expect(loadOffset()).To(BeNumerically("==", 3))
+		})
+	})
+
+	Describe("Reset", func() {
+		It("resets the projector and its offset", func() {
+			err := consumer.Rebuild(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = consumer.Reset(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(projector.applied()).To(BeEmpty())
+			Expect(projector.resets).To(Equal(2))
+			Expect(loadOffset()).To(BeNumerically("==", 0))
+		})
+
+		It("resets the offset of projectors that do not implement Resetter", func() {
+			consumer.Projector = nonResettingProjector{projector}
+
+			err := consumer.Rebuild(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = consumer.Reset(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(projector.applied()).To(HaveLen(2))
+			Expect(projector.resets).To(Equal(0))
+			Expect(loadOffset()).To(BeNumerically("==", 0))
+		})
+
+		It("resets the offset before discarding the projection", func() {
+			var calls []string
+			consumer.Projector = orderedResetProjector{projector, &calls}
+			consumer.Offsets = orderedResetOffsetStore{axmemory.ProjectionOffsetStore, &calls}
+
+			err := consumer.Reset(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(calls).To(Equal([]string{"ResetOffset", "ResetProjection"}))
+		})
+
+		It("does not retain messages applied by a batch that is in progress when the projection is reset", func() {
+			ctx, cancel := context.WithCancel(ctx)
+			defer cancel()
+
+			reached := make(chan struct{})
+			release := make(chan struct{})
+
+			consumer.BatchSize = 1
+			consumer.Projector = blockingProjector{
+				projector,
+				env3.MessageID,
+				&sync.Once{},
+				reached,
+				release,
+			}
+
+			done := make(chan error, 1)
+			go func() { done <- consumer.Consume(ctx) }()
+
+			// wait until the consumer has committed env1 and is part way
+			// through the batch containing env3.
+			Eventually(reached).Should(BeClosed())
+			Expect(loadOffset()).To(BeNumerically("==", 1))
+
+			other := &GlobalStoreConsumer{
+				Projector:    projector,
+				DataStore:    ds,
+				MessageStore: axmemory.MessageStore,
+				Offsets:      axmemory.ProjectionOffsetStore,
+			}
+			err := other.Reset(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			close(release)
+
+			// the batch fails to save its offset, so the consumer applies every
+			// message again, exactly once, from the beginning of the store.
+			Eventually(loadOffset).Should(BeNumerically("==", 3))
+			Eventually(projector.applied).Should(Equal(
+				[]ax.MessageID{env1.MessageID, env3.MessageID},
+			))
+			Consistently(done).ShouldNot(Receive())
+		})
+	})
+
+	Describe("Rebuild", func() {
+		It("replays all messages to the projector and returns once it reaches the end of the store", func() {
+			err := consumer.Rebuild(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = consumer.Rebuild(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(projector.applied()).To(Equal(
+				[]ax.MessageID{env1.MessageID, env3.MessageID},
+			))
+			Expect(projector.resets).To(Equal(2))
+			Expect(loadOffset()).To(BeNumerically("==", 3))
+		})
+
+		It("replays from the beginning of the store regardless of the stored offset", func() {
+			tx, com, err := ds.BeginTx(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+			defer com.Rollback()
+
+			err = axmemory.ProjectionOffsetStore.SaveOffset(ctx, tx, "<projector>", 0, 0, 2)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = com.Commit()
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = consumer.Rebuild(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(projector.applied()).To(Equal(
+				[]ax.MessageID{env1.MessageID, env3.MessageID},
+			))
+		})
+
+		It("activates the projector's version", func() {
+			consumer.Projector = versionedProjector{projector, 1}
+
+			err := consumer.Rebuild(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(loadActiveVersion()).To(BeNumerically("==", 1))
+		})
+
+		It("does not replace a later active version", func() {
+			consumer.Projector = versionedProjector{projector, 2}
+
+			err := consumer.Rebuild(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			consumer.Projector = versionedProjector{projector, 1}
+
+			err = consumer.Rebuild(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(loadActiveVersion()).To(BeNumerically("==", 2))
+			Expect(loadVersionOffset(1)).To(BeNumerically("==", 3))
+		})
+	})
+})
+
+var _ = Describe("GlobalStoreConsumer (batching)", func() {
+	var (
+		ctx                          context.Context
+		cancel                       func()
+		ds                           *axmemory.DataStore
+		projector                    *testProjector
+		consumer                     *GlobalStoreConsumer
+		env1, env2, env3, env4, env5 ax.Envelope
+	)
+
+	loadOffset := func() uint64 {
+		o, err := axmemory.ProjectionOffsetStore.LoadOffset(ctx, ds, "<projector>", 0)
+		Expect(err).ShouldNot(HaveOccurred())
+		return o
+	}
+
+	BeforeEach(func() {
+		var fn func()
+		ctx, fn = context.WithTimeout(context.Background(), 5*time.Second)
+		cancel = fn // defeat go vet warning about unused cancel func
+
+		ds = axmemory.NewDataStore()
+		projector = &testProjector{}
+		consumer = &GlobalStoreConsumer{
+			Projector:    projector,
+			DataStore:    ds,
+			MessageStore: axmemory.MessageStore,
+			Offsets:      axmemory.ProjectionOffsetStore,
+		}
+
+		env1 = ax.NewEnvelope(&testmessages.MessageA{Value: "<foo>"})
+		env2 = ax.NewEnvelope(&testmessages.MessageB{Value: "<bar>"})
+		env3 = ax.NewEnvelope(&testmessages.MessageA{Value: "<baz>"})
+		env4 = ax.NewEnvelope(&testmessages.MessageA{Value: "<qux>"})
+		env5 = ax.NewEnvelope(&testmessages.MessageA{Value: "<quux>"})
+
+		tx, com, err := ds.BeginTx(ctx)
+		Expect(err).ShouldNot(HaveOccurred())
+		defer com.Rollback()
+
+		err = axmemory.MessageStore.AppendMessages(
+			ctx,
+			tx,
+			"<stream>",
+			0,
+			[]ax.Envelope{env1, env2, env3, env4, env5},
+		)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		err = com.Commit()
+		Expect(err).ShouldNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		cancel()
+	})
+
+	It("applies all available messages in a single transaction by default", func() {
+		consumer.Projector = failingProjector{projector, env5.MessageID}
+
+		err := consumer.Rebuild(ctx)
+		Expect(err).To(MatchError("<error>"))
+
+		Expect(projector.applied()).To(BeEmpty())
+		Expect(loadOffset()).To(BeNumerically("==", 0))
+	})
+
+	It("commits a transaction once it contains BatchSize messages", func() {
+		consumer.Projector = failingProjector{projector, env5.MessageID}
+		consumer.BatchSize = 2
+
+		err := consumer.Rebuild(ctx)
+		Expect(err).To(MatchError("<error>"))
+
+		Expect(projector.applied()).To(Equal(
+			[]ax.MessageID{env1.MessageID, env3.MessageID},
+		))
+		Expect(loadOffset()).To(BeNumerically("==", 3))
+	})
+
+	It("commits a transaction once BatchDuration has elapsed", func() {
+		consumer.Projector = failingProjector{projector, env3.MessageID}
+		consumer.BatchDuration = time.Nanosecond
+
+		err := consumer.Rebuild(ctx)
+		Expect(err).To(MatchError("<error>"))
+
+		Expect(projector.applied()).To(Equal(
+			[]ax.MessageID{env1.MessageID},
+		))
+		Expect(loadOffset()).To(BeNumerically("==", 1))
+	})
+
+	It("applies the entire batch again if its transaction is retried", func() {
+		consumer.Projector = flakyProjector{projector, env4.MessageID, transientError{}, new(bool)}
+
+		err := consumer.Rebuild(ctx)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		Expect(projector.applied()).To(Equal(
+			[]ax.MessageID{env1.MessageID, env3.MessageID, env4.MessageID, env5.MessageID},
+		))
+		Expect(loadOffset()).To(BeNumerically("==", 5))
+	})
+
+	It("advances the offset past the last message in each batch", func() {
+		consumer.BatchSize = 3
+
+		err := consumer.Rebuild(ctx)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		Expect(projector.applied()).To(Equal(
+			[]ax.MessageID{env1.MessageID, env3.MessageID, env4.MessageID, env5.MessageID},
+		))
+		Expect(loadOffset()).To(BeNumerically("==", 5))
+	})
+})
+
+var _ = Describe("GlobalStoreConsumer (error handling)", func() {
+	var (
+		ctx                          context.Context
+		cancel                       func()
+		ds                           *axmemory.DataStore
+		projector                    *testProjector
+		consumer                     *GlobalStoreConsumer
+		env1, env2, env3, env4, env5 ax.Envelope
+	)
+
+	loadOffset := func() uint64 {
+		o, err := axmemory.ProjectionOffsetStore.LoadOffset(ctx, ds, "<projector>", 0)
+		Expect(err).ShouldNot(HaveOccurred())
+		return o
+	}
+
+	loadParked := func() []ParkedMessage {
+		m, err := consumer.LoadParkedMessages(ctx)
+		Expect(err).ShouldNot(HaveOccurred())
+		return m
+	}
+
+	BeforeEach(func() {
+		var fn func()
+		ctx, fn = context.WithTimeout(context.Background(), 5*time.Second)
+		cancel = fn // defeat go vet warning about unused cancel func
+
+		ds = axmemory.NewDataStore()
+		projector = &testProjector{}
+
+		env1 = ax.NewEnvelope(&testmessages.MessageA{Value: "<foo>"})
+		env2 = ax.NewEnvelope(&testmessages.MessageB{Value: "<bar>"})
+		env3 = ax.NewEnvelope(&testmessages.MessageA{Value: "<baz>"})
+		env4 = ax.NewEnvelope(&testmessages.MessageA{Value: "<qux>"})
+		env5 = ax.NewEnvelope(&testmessages.MessageA{Value: "<quux>"})
+
+		consumer = &GlobalStoreConsumer{
+			Projector:      failingProjector{projector, env3.MessageID},
+			DataStore:      ds,
+			MessageStore:   axmemory.MessageStore,
+			Offsets:        axmemory.ProjectionOffsetStore,
+			ErrorPolicy:    ParkPolicy,
+			ParkedMessages: axmemory.ProjectionParkedMessageStore,
+		}
+
+		tx, com, err := ds.BeginTx(ctx)
+		Expect(err).ShouldNot(HaveOccurred())
+		defer com.Rollback()
+
+		err = axmemory.MessageStore.AppendMessages(
+			ctx,
+			tx,
+			"<stream>",
+			0,
+			[]ax.Envelope{env1, env2, env3, env4, env5},
+		)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		err = com.Commit()
+		Expect(err).ShouldNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		cancel()
+	})
+
+	Context("when the error policy parks the message", func() {
+		It("skips the message and records it as parked", func() {
+			err := consumer.Rebuild(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(projector.applied()).To(Equal(
+				[]ax.MessageID{env1.MessageID, env4.MessageID, env5.MessageID},
+			))
+			Expect(loadOffset()).To(BeNumerically("==", 5))
+
+			parked := loadParked()
+			Expect(parked).To(HaveLen(1))
+			Expect(parked[0].Offset).To(BeNumerically("==", 2))
+			Expect(parked[0].MessageID).To(Equal(env3.MessageID))
+			Expect(parked[0].MessageType).To(Equal(env3.Type().Name))
+			Expect(parked[0].Error).To(Equal("<error>"))
+		})
+
+		It("halts if there is no parked message store", func() {
+			consumer.ParkedMessages = nil
+
+			err := consumer.Rebuild(ctx)
+			Expect(err).To(MatchError("<error>"))
+
+			Expect(projector.applied()).To(Equal(
+				[]ax.MessageID{env1.MessageID},
+			))
+			Expect(loadOffset()).To(BeNumerically("==", 1))
+		})
+	})
+
+	Context("when the error policy retries the message", func() {
+		It("applies the message once it succeeds", func() {
+			consumer.Projector = flakyProjector{projector, env3.MessageID, errors.New("<error>"), new(bool)}
+			consumer.ErrorPolicy = NewRetryPolicy(0, time.Millisecond, time.Millisecond, Halt)
+
+			err := consumer.Rebuild(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(projector.applied()).To(Equal(
+				[]ax.MessageID{env1.MessageID, env3.MessageID, env4.MessageID, env5.MessageID},
+			))
+			Expect(loadOffset()).To(BeNumerically("==", 5))
+		})
+
+		It("takes the policy's action once the maximum attempts is reached", func() {
+			var attempts []uint
+			consumer.ErrorPolicy = func(env ax.Envelope, err error, n uint) (ErrorAction, time.Duration) {
+				attempts = append(attempts, n)
+				return NewRetryPolicy(3, time.Millisecond, time.Millisecond, Halt)(env, err, n)
+			}
+
+			err := consumer.Rebuild(ctx)
+			Expect(err).To(MatchError("<error>"))
+
+			Expect(attempts).To(Equal([]uint{1, 2, 3}))
+			Expect(projector.applied()).To(Equal(
+				[]ax.MessageID{env1.MessageID},
+			))
+			Expect(loadOffset()).To(BeNumerically("==", 1))
+		})
+	})
+
+	Describe("RetryParkedMessage", func() {
+		BeforeEach(func() {
+			err := consumer.Rebuild(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		It("applies the message and removes it from the parked messages", func() {
+			consumer.Projector = projector
+
+			err := consumer.RetryParkedMessage(ctx, 2)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(projector.applied()).To(Equal(
+				[]ax.MessageID{env1.MessageID, env4.MessageID, env5.MessageID, env3.MessageID},
+			))
+			Expect(loadParked()).To(BeEmpty())
+		})
+
+		It("leaves the message parked if it can not be applied", func() {
+			err := consumer.RetryParkedMessage(ctx, 2)
+			Expect(err).To(MatchError("<error>"))
+
+			Expect(loadParked()).To(HaveLen(1))
+		})
+
+		It("returns an error if the message is not parked", func() {
+			err := consumer.RetryParkedMessage(ctx, 3)
+			Expect(err).To(MatchError("there is no parked message at offset 3"))
+
+			Expect(projector.applied()).To(HaveLen(3))
+		})
+	})
+
+	Describe("Reset", func() {
+		It("discards the parked messages", func() {
+			err := consumer.Rebuild(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = consumer.Reset(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(loadParked()).To(BeEmpty())
+		})
+	})
+})
+
+var _ = Describe("ConflictError", func() {
+	Describe("IsConflict", func() {
+		It("returns true for a conflict error", func() {
+			Expect(IsConflict(&ConflictError{})).To(BeTrue())
+		})
+
+		It("returns false for other errors", func() {
+			Expect(IsConflict(context.Canceled)).To(BeFalse())
+		})
+	})
+})
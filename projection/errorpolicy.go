@@ -0,0 +1,85 @@
+package projection
+
+import (
+	"math"
+	"time"
+
+	"github.com/jmalloc/ax"
+)
+
+// ErrorAction is an action taken by a GlobalStoreConsumer when a projector
+// fails to apply a message.
+type ErrorAction int
+
+const (
+	// Halt causes the consumer to stop and return the projector's error.
+	Halt ErrorAction = iota
+
+	// Retry causes the consumer to apply the message again after a delay.
+	Retry
+
+	// Park causes the consumer to skip the message and record it in the
+	// consumer's ParkedMessageStore, so that it can be retried later.
+	Park
+)
+
+// ErrorPolicy is a function responsible for determining the action that a
+// GlobalStoreConsumer takes when a projector fails to apply a message.
+//
+// env is the message that could not be applied and err is the error returned
+// by the projector. n is the number of attempts that have been made to apply
+// the message, including the attempt that failed.
+//
+// It returns the action to take, and the delay that should occur before
+// retrying if the action is Retry.
+type ErrorPolicy func(env ax.Envelope, err error, n uint) (ErrorAction, time.Duration)
+
+// HaltPolicy is an ErrorPolicy that stops the consumer as soon as a message
+// can not be applied.
+//
+// Unlike a consumer with a nil ErrorPolicy, the messages that precede the
+// failed message in its batch are applied before the consumer stops.
+func HaltPolicy(ax.Envelope, error, uint) (ErrorAction, time.Duration) {
+	return Halt, 0
+}
+
+// ParkPolicy is an ErrorPolicy that parks a message as soon as it can not be
+// applied.
+func ParkPolicy(ax.Envelope, error, uint) (ErrorAction, time.Duration) {
+	return Park, 0
+}
+
+// NewRetryPolicy returns an error policy that retries a message with an
+// exponentially increasing delay, until some maximum number of attempts is
+// reached, at which point it takes the action a.
+//
+// mr is the maximum total attempts before taking action a. If mr is zero, the
+// message is retried indefinitely.
+//
+// bt is a "base" delay between retries. It is used as a multiplier for the
+// backoff duration. mt is the maximum delay between retries.
+func NewRetryPolicy(
+	mr uint,
+	bt, mt time.Duration,
+	a ErrorAction,
+) ErrorPolicy {
+	return func(_ ax.Envelope, _ error, n uint) (ErrorAction, time.Duration) {
+		// Stop retrying if we've reached the maximum number of attempts.
+		if mr != 0 && n >= mr {
+			return a, 0
+		}
+
+		p := math.Pow(
+			2,
+			float64(n-1), // number of previous retries
+		)
+
+		// Cap the delay at the maximum. The comparison is performed using
+		// floating-point values so that the delay itself can not overflow.
+		if p*float64(bt) > float64(mt) {
+			return Retry, mt
+		}
+
+		return Retry, time.Duration(p) * bt
+	}
+}
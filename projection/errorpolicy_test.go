@@ -0,0 +1,77 @@
+package projection_test
+
+import (
+	"errors"
+	"time"
+
+	"github.com/jmalloc/ax"
+	"github.com/jmalloc/ax/axtest/testmessages"
+	. "github.com/jmalloc/ax/projection"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = DescribeTable(
+	"NewRetryPolicy",
+	func(
+		attempts int,
+		mr int,
+		action ErrorAction,
+		delay time.Duration,
+	) {
+		policy := NewRetryPolicy(
+			uint(mr),
+			3*time.Second,
+			10*time.Minute,
+			Park,
+		)
+
+		env := ax.NewEnvelope(&testmessages.MessageA{})
+
+		a, d := policy(env, errors.New("<error>"), uint(attempts))
+		Expect(a).To(Equal(action))
+		Expect(d).To(Equal(delay))
+	},
+	Entry(
+		"retries after the base delay",
+		1,             // attempt count
+		0,             // max attempts
+		Retry,         // expected action
+		3*time.Second, // expected delay
+	),
+	Entry(
+		"backs off exponentially",
+		3,              // attempt count
+		0,              // max attempts
+		Retry,          // expected action
+		12*time.Second, // expected delay
+	),
+	Entry(
+		"caps the delay at the maximum",
+		20,             // attempt count
+		0,              // max attempts
+		Retry,          // expected action
+		10*time.Minute, // expected delay
+	),
+	Entry(
+		"caps the delay at the maximum when the delay would overflow",
+		40,             // attempt count
+		0,              // max attempts
+		Retry,          // expected action
+		10*time.Minute, // expected delay
+	),
+	Entry(
+		"caps the delay at the maximum when the multiplier overflows",
+		100,            // attempt count
+		0,              // max attempts
+		Retry,          // expected action
+		10*time.Minute, // expected delay
+	),
+	Entry(
+		"takes the given action once the attempt count reaches mr",
+		5,             // attempt count
+		5,             // max attempts
+		Park,          // expected action
+		0*time.Second, // expected delay
+	),
+)
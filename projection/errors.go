@@ -0,0 +1,38 @@
+package projection
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ConflictError is returned by OffsetStore.SaveOffset() when an offset can not
+// be saved because the offset that is currently stored is not the offset that
+// was expected.
+//
+// This typically indicates that another consumer has applied messages to the
+// same projector, or that the projection has been reset.
+type ConflictError struct {
+	// PersistenceKey is the persistence key of the projector.
+	PersistenceKey string
+
+	// Version is the version of the projector.
+	Version uint64
+
+	// Offset is the offset that was expected to be stored.
+	Offset uint64
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf(
+		"can not save projection offset for persistence key %s (version %d), offset %d is not the current offset",
+		e.PersistenceKey,
+		e.Version,
+		e.Offset,
+	)
+}
+
+// IsConflict returns true if err is, or wraps, a *ConflictError.
+func IsConflict(err error) bool {
+	var e *ConflictError
+	return errors.As(err, &e)
+}
@@ -8,27 +8,72 @@ import (
 
 // OffsetStore is an interface for persisting a consumer's current position in a
 // message stream.
+//
+// A separate offset is stored for each version of a projector, as per
+// VersionedProjector. Projectors that are not versioned use version zero.
 type OffsetStore interface {
 	// LoadOffset returns the offset at which a consumer should resume
 	// reading from the stream.
 	//
-	// pk is the projector's persistence key.
+	// pk is the projector's persistence key, and v is its version.
 	LoadOffset(
 		ctx context.Context,
 		ds persistence.DataStore,
 		pk string,
+		v uint64,
 	) (uint64, error)
 
-	// IncrementOffset increments the offset at which a consumer should resume
-	// reading from the stream by one.
+	// SaveOffset sets the offset at which a consumer should resume reading
+	// from the stream.
 	//
-	// pk is the projector's persitence key. c is the offset that is currently
-	// stored, as returned by LoadOffset(). If c is not the offset that is
-	// currently stored, the increment fails and a non-nil error is returned.
-	IncrementOffset(
+	// pk is the projector's persistence key, and v is its version. c is the
+	// offset that is currently stored, as returned by LoadOffset(), and n is
+	// the new offset. If c is not the offset that is currently stored, the save
+	// fails and a *ConflictError is returned.
+	SaveOffset(
 		ctx context.Context,
 		tx persistence.Tx,
 		pk string,
-		c uint64,
+		v uint64,
+		c, n uint64,
 	) error
+
+	// ResetOffset sets the offset at which a consumer should resume reading
+	// from the stream back to zero, so that the stream is read from the
+	// beginning.
+	//
+	// pk is the projector's persistence key, and v is its version. A consumer
+	// that subsequently attempts to save an offset, passing the offset that was
+	// stored before the reset as c, fails with a *ConflictError.
+	//
+	// The offset must be locked until tx ends, as per SaveOffset(), so that
+	// changes made within tx after the reset are not interleaved with those of
+	// a consumer that saves its offset concurrently.
+	ResetOffset(
+		ctx context.Context,
+		tx persistence.Tx,
+		pk string,
+		v uint64,
+	) error
+
+	// ActivateVersion makes v the active version of the projector with the
+	// persistence key pk, unless a later version is already active.
+	//
+	// It returns true if v is the active version.
+	ActivateVersion(
+		ctx context.Context,
+		tx persistence.Tx,
+		pk string,
+		v uint64,
+	) (bool, error)
+
+	// LoadActiveVersion returns the active version of the projector with the
+	// persistence key pk.
+	//
+	// It returns false if no version of the projector has been activated.
+	LoadActiveVersion(
+		ctx context.Context,
+		ds persistence.DataStore,
+		pk string,
+	) (uint64, bool, error)
 }
@@ -0,0 +1,76 @@
+package projection
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmalloc/ax"
+	"github.com/jmalloc/ax/persistence"
+)
+
+// ParkedMessage is a message that a projector failed to apply, and that was
+// skipped by a GlobalStoreConsumer as per its ErrorPolicy.
+type ParkedMessage struct {
+	// Offset is the global offset of the message in the message store.
+	Offset uint64
+
+	// MessageID is the ID of the message.
+	MessageID ax.MessageID
+
+	// MessageType is the fully-qualified Protocol Buffers name of the
+	// message type.
+	MessageType string
+
+	// Error is the error message returned by the projector when the message
+	// was last applied.
+	Error string
+
+	// ParkedAt is the time at which the message was parked.
+	ParkedAt time.Time
+}
+
+// ParkedMessageStore is an interface for persisting the messages that have been
+// parked by a GlobalStoreConsumer.
+//
+// Parked messages are stored separately for each version of each projector. pk
+// is the projector's persistence key, and v is its version.
+type ParkedMessageStore interface {
+	// ParkMessage records that the message at offset m.Offset has been parked.
+	//
+	// If the message is already parked, its details are replaced with m.
+	ParkMessage(
+		ctx context.Context,
+		tx persistence.Tx,
+		pk string,
+		v uint64,
+		m ParkedMessage,
+	) error
+
+	// LoadParkedMessages returns the parked messages, in order of their
+	// offset.
+	LoadParkedMessages(
+		ctx context.Context,
+		ds persistence.DataStore,
+		pk string,
+		v uint64,
+	) ([]ParkedMessage, error)
+
+	// UnparkMessage removes the message at offset o from the parked messages.
+	//
+	// It returns false if the message is not parked.
+	UnparkMessage(
+		ctx context.Context,
+		tx persistence.Tx,
+		pk string,
+		v uint64,
+		o uint64,
+	) (bool, error)
+
+	// ResetParkedMessages removes all of the parked messages.
+	ResetParkedMessages(
+		ctx context.Context,
+		tx persistence.Tx,
+		pk string,
+		v uint64,
+	) error
+}
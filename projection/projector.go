@@ -28,5 +28,53 @@ type Projector interface {
 	//
 	// It may panic if env.Message is not one of the types described by
 	// MessageTypes().
+	//
+	// When messages are read from a message store by a GlobalStoreConsumer,
+	// ctx contains the stored message, including its position within the
+	// store. It can be obtained with messagestore.GetStoredMessage().
 	ApplyMessage(ctx context.Context, mctx ax.MessageContext) error
 }
+
+// Resetter is an interface for projectors that can discard the state that they
+// have produced, so that the projection can be rebuilt from the beginning of
+// the stream.
+type Resetter interface {
+	// ResetProjection discards all of the state produced by the projector.
+	//
+	// It is called within the same transaction that resets the projector's
+	// offset, which can be obtained with persistence.GetTx().
+	ResetProjection(ctx context.Context) error
+}
+
+// VersionedProjector is an interface for projectors that declare the version
+// of the logic that they use to produce their projection.
+//
+// Each version of a projector has its own stored offset. When a projector's
+// version is changed, the GlobalStoreConsumer for the new version builds the
+// projection from the beginning of the message store, while consumers for the
+// previous version continue to run. Once the new version has caught up it
+// becomes the active version, as reported by OffsetStore.LoadActiveVersion().
+//
+// Each version must therefore produce its state separately from the others,
+// for example by using different tables for each version, and readers of the
+// projection should read the state produced by the active version.
+type VersionedProjector interface {
+	Projector
+
+	// ProjectionVersion returns the version of the projector's logic.
+	//
+	// The version must be increased whenever the projector is changed in such a
+	// way that its existing state must be rebuilt. A projector that does not
+	// implement this interface has a version of zero.
+	ProjectionVersion() uint64
+}
+
+// VersionOf returns the version of p, or zero if p does not implement
+// VersionedProjector.
+func VersionOf(p Projector) uint64 {
+	if v, ok := p.(VersionedProjector); ok {
+		return v.ProjectionVersion()
+	}
+
+	return 0
+}
@@ -36,9 +36,37 @@ func (r *Router) Initialize(ctx context.Context, ep *endpoint.Endpoint) error {
 // Accept populates the evn.DestinationEndpoint field of unicast messages that
 // do not already have a DestinationEndpoint specified.
 func (r *Router) Accept(ctx context.Context, env endpoint.OutboundEnvelope) error {
+	if err := r.route(ctx, &env); err != nil {
+		return err
+	}
+
+	return r.Next.Accept(ctx, env)
+}
+
+// AcceptBatch populates the DestinationEndpoint field of each unicast message
+// in envs that does not already have a DestinationEndpoint specified, then
+// forwards the messages to the next stage as a single batch.
+//
+// If any message can not be routed, none of the messages are forwarded.
+func (r *Router) AcceptBatch(ctx context.Context, envs []endpoint.OutboundEnvelope) error {
+	// route a copy of the envelopes, so that the caller's slice is not modified
+	envs = append([]endpoint.OutboundEnvelope(nil), envs...)
+
+	for i := range envs {
+		if err := r.route(ctx, &envs[i]); err != nil {
+			return err
+		}
+	}
+
+	return endpoint.AcceptBatch(ctx, r.Next, envs)
+}
+
+// route populates the env.DestinationEndpoint field if env is a unicast
+// message that does not already have a DestinationEndpoint specified.
+func (r *Router) route(ctx context.Context, env *endpoint.OutboundEnvelope) error {
 	if env.Operation == endpoint.OpSendUnicast {
 		if env.DestinationEndpoint == "" {
-			if err := r.ensureDestination(&env); err != nil {
+			if err := r.ensureDestination(env); err != nil {
 				return err
 			}
 
@@ -71,7 +99,7 @@ func (r *Router) Accept(ctx context.Context, env endpoint.OutboundEnvelope) erro
 		)
 	}
 
-	return r.Next.Accept(ctx, env)
+	return nil
 }
 
 // ensureDestintion ensures that env.DestinationEndpoint is set if required.
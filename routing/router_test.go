@@ -13,6 +13,7 @@ import (
 )
 
 var _ endpoint.OutboundPipeline = (*Router)(nil) // ensure Router implements OutboundPipeline
+var _ endpoint.BatchMessageSink = (*Router)(nil) // ensure Router implements BatchMessageSink
 
 var _ = Describe("Router", func() {
 	var (
@@ -139,4 +140,67 @@ var _ = Describe("Router", func() {
 			Expect(next.AcceptCalls()[0].Env).To(Equal(env))
 		})
 	})
+
+	Describe("AcceptBatch", func() {
+		It("routes each message before forwarding them to the next stage", func() {
+			envs := []endpoint.OutboundEnvelope{
+				{
+					Operation: endpoint.OpSendUnicast,
+					Envelope: ax.Envelope{
+						Message: &testmessages.Message{},
+					},
+				},
+				{
+					Operation:           endpoint.OpSendUnicast,
+					DestinationEndpoint: "<endpoint>",
+				},
+				{
+					Operation: endpoint.OpSendMulticast,
+				},
+			}
+
+			err := router.AcceptBatch(context.Background(), envs)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(next.AcceptCalls()).To(HaveLen(3))
+			Expect(next.AcceptCalls()[0].Env.DestinationEndpoint).To(Equal("axtest.testmessages"))
+			Expect(next.AcceptCalls()[1].Env.DestinationEndpoint).To(Equal("<endpoint>"))
+			Expect(next.AcceptCalls()[2].Env.DestinationEndpoint).To(Equal(""))
+		})
+
+		It("does not modify the caller's envelopes", func() {
+			envs := []endpoint.OutboundEnvelope{
+				{
+					Operation: endpoint.OpSendUnicast,
+					Envelope: ax.Envelope{
+						Message: &testmessages.Message{},
+					},
+				},
+			}
+
+			err := router.AcceptBatch(context.Background(), envs)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(envs[0].DestinationEndpoint).To(Equal(""))
+		})
+
+		It("does not forward any messages if one of them can not be routed", func() {
+			envs := []endpoint.OutboundEnvelope{
+				{
+					Operation: endpoint.OpSendUnicast,
+					Envelope: ax.Envelope{
+						Message: &testmessages.Message{},
+					},
+				},
+				{
+					Operation: endpoint.OpSendUnicast,
+					Envelope: ax.Envelope{
+						Message: &testmessages.NoPackage{},
+					},
+				},
+			}
+
+			err := router.AcceptBatch(context.Background(), envs)
+			Expect(err).Should(HaveOccurred())
+			Expect(next.AcceptCalls()).To(BeEmpty())
+		})
+	})
 })
@@ -16,6 +16,16 @@ func streamName(id saga.InstanceID) string {
 	return "saga:" + id.Get()
 }
 
+// streamCategory returns the category of the events appended to the streams of
+// the saga with the given persistence key.
+//
+// The category is "saga:<pk>", which allows the events of all instances of a
+// saga to be read as a single stream. It can not be derived from the stream
+// name, which does not include the persistence key.
+func streamCategory(pk string) string {
+	return "saga:" + pk
+}
+
 // applyEvents calls Data.ApplyEvent for each event in a saga's message stream.
 func applyEvents(
 	ctx context.Context,
@@ -33,6 +43,7 @@ func applyEvents(
 	if !ok || err != nil {
 		return err
 	}
+	defer s.Close()
 
 	for {
 		ok, err := s.TryNext(ctx)
@@ -40,6 +51,21 @@ func applyEvents(
 			return err
 		}
 
+		o, err := s.Offset()
+		if err != nil {
+			return err
+		}
+
+		// the events before o have been truncated, and the snapshot that
+		// covered them is not available.
+		if o != uint64(i.Revision) {
+			return fmt.Errorf(
+				"event stream for saga instance %s is truncated at revision %d, but there is no snapshot at or after this revision",
+				i.InstanceID.Get(),
+				o,
+			)
+		}
+
 		env, err := s.Get(ctx)
 		if err != nil {
 			return err
@@ -2,6 +2,7 @@ package eventsourcing
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/jmalloc/ax"
 	"github.com/jmalloc/ax/messagestore"
@@ -17,10 +18,17 @@ const DefaultSnapshotFrequency saga.Revision = 1000
 // using event-sourcing semantics.
 //
 // The saga data MUST implement saga.EventedData.
+//
+// If TruncateOnSnapshot is true, each instance's event stream is truncated
+// whenever a snapshot is saved, such that only the events after the snapshot
+// are retained. This requires that MessageStore implements
+// messagestore.RetentionStore. Snapshots of completed instances are retained
+// when truncation is enabled, as the instance could not otherwise be rebuilt.
 type Persister struct {
-	MessageStore      messagestore.Store
-	Snapshots         SnapshotRepository
-	SnapshotFrequency saga.Revision
+	MessageStore       messagestore.Store
+	Snapshots          SnapshotRepository
+	SnapshotFrequency  saga.Revision
+	TruncateOnSnapshot bool
 }
 
 // BeginUnitOfWork starts a new unit-of-work that modifies a saga instance.
@@ -44,12 +52,13 @@ func (p *Persister) BeginUnitOfWork(
 		messageStore: p.MessageStore,
 		snapshots:    p.Snapshots,
 		frequency:    p.SnapshotFrequency,
+		truncate:     p.TruncateOnSnapshot,
 		tx:           tx,
+		key:          sg.PersistenceKey(),
 		recorder:     &Recorder{Next: s},
 	}
 
 	if p.Snapshots != nil {
-		uow.key = sg.PersistenceKey()
 		uow.instance, ok, err = p.Snapshots.LoadSagaSnapshot(ctx, uow.tx, uow.key, id)
 		if err != nil {
 			return nil, err
@@ -84,6 +93,7 @@ type unitOfWork struct {
 	messageStore messagestore.Store
 	snapshots    SnapshotRepository
 	frequency    saga.Revision
+	truncate     bool
 
 	lastKnownSnapshot saga.Revision
 	tx                persistence.Tx
@@ -114,6 +124,12 @@ func (w *unitOfWork) Save(ctx context.Context) (bool, error) {
 		if err := w.snapshots.SaveSagaSnapshot(ctx, w.tx, w.key, w.instance); err != nil {
 			return false, err
 		}
+
+		if w.truncate {
+			if err := w.truncateEvents(ctx); err != nil {
+				return false, err
+			}
+		}
 	}
 
 	return true, nil
@@ -126,7 +142,9 @@ func (w *unitOfWork) SaveAndComplete(ctx context.Context) error {
 		return err
 	}
 
-	if w.snapshots != nil {
+	// the snapshots are retained if the events have been truncated, as they can
+	// not be used to rebuild the instance.
+	if w.snapshots != nil && !w.truncate {
 		return w.snapshots.DeleteSagaSnapshots(ctx, w.tx, w.key, w.instance.InstanceID)
 	}
 
@@ -146,12 +164,16 @@ func (w *unitOfWork) appendEvents(ctx context.Context) (bool, error) {
 	}
 
 	if err := w.messageStore.AppendMessages(
-		ctx,
+		messagestore.WithCategory(ctx, streamCategory(w.key)),
 		w.tx,
 		streamName(w.instance.InstanceID),
 		uint64(w.instance.Revision),
 		w.recorder.Events,
 	); err != nil {
+		if messagestore.IsConflict(err) {
+			return false, conflictError{err}
+		}
+
 		return false, err
 	}
 
@@ -160,6 +182,26 @@ func (w *unitOfWork) appendEvents(ctx context.Context) (bool, error) {
 	return true, nil
 }
 
+// truncateEvents truncates the instance's message stream such that only the
+// events after the current revision are retained.
+func (w *unitOfWork) truncateEvents(ctx context.Context) error {
+	rs, ok := w.messageStore.(messagestore.RetentionStore)
+	if !ok {
+		return fmt.Errorf(
+			"can not truncate the event stream for saga instance %s, the message store does not support truncation",
+			w.instance.InstanceID.Get(),
+		)
+	}
+
+	return messagestore.TruncateStream(
+		ctx,
+		w.tx,
+		rs,
+		streamName(w.instance.InstanceID),
+		uint64(w.instance.Revision),
+	)
+}
+
 // shouldSnapshot returns true if a new snapshot should be stored.
 func (w *unitOfWork) shouldSnapshot() bool {
 	if w.snapshots == nil {
@@ -173,3 +215,23 @@ func (w *unitOfWork) shouldSnapshot() bool {
 
 	return (w.instance.Revision - w.lastKnownSnapshot) >= freq
 }
+
+// conflictError wraps a *messagestore.ConflictError that occurs when appending
+// the events of a saga instance.
+//
+// It reports itself as transient, as the instance is loaded from the message
+// store again when the message is retried, and so the retry observes the
+// conflicting events.
+type conflictError struct {
+	error
+}
+
+// IsTransient returns true.
+func (e conflictError) IsTransient() bool {
+	return true
+}
+
+// Unwrap returns the *messagestore.ConflictError.
+func (e conflictError) Unwrap() error {
+	return e.error
+}